@@ -0,0 +1,139 @@
+// Command fakeprinter runs a fake Zebra printer for local development and CI.
+//
+// It listens for raw ZPL on -addr, answers ~HS status queries and stores each
+// ^XA...^XZ label in memory and, when -dir is set, on disk. Faults can be set
+// at startup with flags or changed on demand through the -control HTTP API:
+//
+//	GET  /labels  captured labels
+//	GET  /faults  current faults
+//	PUT  /faults  {"refuse":false,"delay":"2s","drop_after":0,"paper_out":true}
+//	POST /reset   clear labels and faults
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"labelops-backend/internal/printer/fake"
+)
+
+// faultsRequest is the JSON body of PUT /faults; delay is a Go duration string
+type faultsRequest struct {
+	Refuse    bool   `json:"refuse"`
+	Delay     string `json:"delay"`
+	DropAfter int    `json:"drop_after"`
+	PaperOut  bool   `json:"paper_out"`
+}
+
+func main() {
+	addr := flag.String("addr", ":9100", "address to accept ZPL on")
+	control := flag.String("control", ":9101", "address of the fault control HTTP API (empty disables)")
+	dir := flag.String("dir", "", "directory to save captured labels to")
+	refuse := flag.Bool("refuse", false, "refuse connections")
+	delay := flag.Duration("delay", 0, "delay before reading each connection")
+	dropAfter := flag.Int("drop-after", 0, "drop each connection after this many bytes")
+	paperOut := flag.Bool("paper-out", false, "report paper out and discard labels")
+	flag.Parse()
+
+	if *dir != "" {
+		if err := os.MkdirAll(*dir, 0755); err != nil {
+			log.Fatalf("Failed to create label directory: %v", err)
+		}
+	}
+
+	srv, err := fake.Listen(*addr)
+	if err != nil {
+		log.Fatalf("Failed to start fake printer: %v", err)
+	}
+	srv.Dir = *dir
+	if err := srv.SetFaults(fake.Faults{
+		Refuse:    *refuse,
+		Delay:     *delay,
+		DropAfter: *dropAfter,
+		PaperOut:  *paperOut,
+	}); err != nil {
+		log.Fatalf("Failed to set faults: %v", err)
+	}
+	log.Printf("Fake printer listening on %s", srv.Addr())
+
+	if *control != "" {
+		go func() {
+			log.Printf("Fault control API listening on %s", *control)
+			if err := http.ListenAndServe(*control, controlHandler(srv)); err != nil {
+				log.Fatalf("Control API failed: %v", err)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Printf("Shutting down fake printer after %d labels", len(srv.Labels()))
+	srv.Close()
+}
+
+// controlHandler exposes captured labels and fault injection over HTTP
+func controlHandler(srv *fake.Server) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/labels", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"labels": srv.Labels(), "count": len(srv.Labels())})
+	})
+
+	mux.HandleFunc("/faults", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, srv.Faults())
+		case http.MethodPut, http.MethodPost:
+			var req faultsRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			var d time.Duration
+			if req.Delay != "" {
+				var err error
+				if d, err = time.ParseDuration(req.Delay); err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+					return
+				}
+			}
+			f := fake.Faults{Refuse: req.Refuse, Delay: d, DropAfter: req.DropAfter, PaperOut: req.PaperOut}
+			if err := srv.SetFaults(f); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Printf("Faults updated: %+v", f)
+			writeJSON(w, http.StatusOK, f)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := srv.Reset(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "reset"})
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
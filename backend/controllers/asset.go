@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"labelops-backend/internal/assets"
+	"labelops-backend/models"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAssets lists the latest version of every logo asset (admin only)
+func GetAssets(c *gin.Context) {
+	list, err := assets.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"assets": list, "count": len(list)})
+}
+
+// GetAssetVersions lists every version of an asset, newest first (admin only)
+func GetAssetVersions(c *gin.Context) {
+	list, err := assets.Versions(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset", "details": err.Error()})
+		return
+	}
+	if len(list) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": c.Param("name"), "versions": list})
+}
+
+// CreateAsset uploads a PNG or SVG logo as a multipart form with fields file,
+// name and optionally width (dots), dither and threshold. It is converted to
+// 1 bit per pixel and stored as the next version of the named asset; template
+// versions keep the asset versions they were saved with (admin only).
+func CreateAsset(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, assets.MaxUpload+1<<16)
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if !assets.ValidName(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1-64 lower case letters, digits, '-' or '_'"})
+		return
+	}
+	opts := assets.Options{Dither: c.PostForm("dither")}
+	switch opts.Dither {
+	case "", models.AssetDitherFloydSteinberg, models.AssetDitherOrdered, models.AssetDitherNone:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dither must be floyd-steinberg, ordered or none"})
+		return
+	}
+	for field, dst := range map[string]*int{"width": &opts.Width, "threshold": &opts.Threshold} {
+		if v := c.PostForm(field); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": field + " must be a positive number"})
+				return
+			}
+			*dst = n
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A PNG or SVG file is required", "details": err.Error()})
+		return
+	}
+	if header.Size > assets.MaxUpload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Asset files must be at most 2 MB"})
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload", "details": err.Error()})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, assets.MaxUpload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload", "details": err.Error()})
+		return
+	}
+
+	bitmap, format, err := assets.Convert(data, opts)
+	if errors.Is(err, assets.ErrUnsupportedFormat) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to convert asset", "details": err.Error()})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	a, err := assets.Create(name, format, data, bitmap, opts, &userModel.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store asset", "details": err.Error()})
+		return
+	}
+
+	idStr := a.ID.String()
+	utils.LogAudit(c, userModel.ID, "create_asset", "label_assets", &idStr, "Logo asset uploaded by admin",
+		map[string]interface{}{"name": a.Name, "version": a.Version, "format": a.Format, "width": a.Width, "height": a.Height})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Asset uploaded successfully",
+		"asset":   a,
+		"usage": gin.H{
+			"inline": `{{asset "` + a.Name + `"}}`,
+			"stored": `{{stored "` + a.Name + `"}}`,
+		},
+	})
+}
+
+// assetVersion fetches the asset version named in the URL, writing an error response when it cannot
+func assetVersion(c *gin.Context) (models.LabelAsset, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asset version"})
+		return models.LabelAsset{}, false
+	}
+	a, err := assets.Get(c.Param("name"), version)
+	if err == assets.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset version not found"})
+		return a, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset", "details": err.Error()})
+		return a, false
+	}
+	return a, true
+}
+
+// GetAssetPreview returns an asset version as converted, as a black and white PNG (admin only)
+func GetAssetPreview(c *gin.Context) {
+	a, ok := assetVersion(c)
+	if !ok {
+		return
+	}
+	data, err := assets.PNG(a)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render asset", "details": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// GetAssetZPL returns the ZPL for an asset version: the ^GFA field that
+// prints it inline, and the ~DG download and ^XG recall that store it on the
+// printer (admin only)
+func GetAssetZPL(c *gin.Context) {
+	a, ok := assetVersion(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"name":          a.Name,
+		"version":       a.Version,
+		"graphic_field": assets.GraphicField(a),
+		"download":      assets.Download(a),
+		"recall":        assets.Recall(a),
+		"object":        assets.ObjectName(a),
+	})
+}
@@ -3,15 +3,25 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"labelops-backend/db"
+	"labelops-backend/internal/dedupe"
+	"labelops-backend/internal/dispatcher"
+	"labelops-backend/internal/ingest"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/lifecycle"
 	"labelops-backend/internal/printer"
+	"labelops-backend/internal/templates"
 	"labelops-backend/models"
 	"labelops-backend/utils"
 
@@ -35,83 +45,179 @@ func getUserFromContext(c *gin.Context) (models.User, bool) {
 	return userModel, true
 }
 
-// ensurePrinterDirectories creates required folders used by the printer package
-// printers/bat -> where print batch scripts live
-func ensurePrinterDirectories() error {
-    if err := os.MkdirAll("printers/bat", 0755); err != nil {
-        return err
-    }
-    return nil
+// resolvePrinter picks the registered printer serving the label's MILL/LOCATION and
+// returns its ID, language and media. It returns a nil ID and ZPL when no printer is registered,
+// in which case the dispatcher falls back to the PRINTER_* environment printer. The media is
+// nil when the printer has no media profile, and labels print at their design size.
+func resolvePrinter(label models.Label) (*uuid.UUID, string, *layout.Media, error) {
+	m, err := printer.Resolve(label.Mill, label.Location)
+	if err == printer.ErrPrinterNotFound {
+		return nil, models.PrinterLanguageZPL, nil, nil
+	}
+	if err != nil {
+		return nil, models.PrinterLanguageZPL, nil, err
+	}
+	profile, err := printer.MediaFor(m)
+	if err != nil || profile == nil {
+		return &m.ID, m.Language, nil, err
+	}
+	media := layout.MediaFromProfile(*profile)
+	return &m.ID, m.Language, &media, nil
 }
 
-// convertLabelDataToLabelWithID maps incoming LabelData to models.Label using an existing DB UUID
-// This is used for ZPL generation where a full models.Label is expected
-func convertLabelDataToLabelWithID(data models.LabelData, userID uuid.UUID, id uuid.UUID) models.Label {
-    return models.Label{
-        ID:             id,
-        LabelID:        data.ID,
-        Location:       data.LOCATION,
-        BundleNo:       data.BUNDLE_NO,
-        BundleType:     data.BUNDLE_TYPE,
-        PQD:            data.PQD,
-        Unit:           data.UNIT,
-        Time:           data.TIME,
-        Length:         data.LENGTH,
-        HeatNo:         data.HEAT_NO,
-        ProductHeading: data.PRODUCT_HEADING,
-        IsiBottom:      data.ISI_BOTTOM,
-        IsiTop:         data.ISI_TOP,
-        ChargeDtm:      "",
-        Mill:           data.MILL,
-        Grade:          data.GRADE,
-        UrlApikey:      data.URL_APIKEY,
-        Weight:         data.WEIGHT,
-        Section:        data.SECTION,
-        Date:           data.DATE,
-        UserID:         userID,
-        Status:         "success",
-        IsDuplicate:    false,
-        CreatedAt:      time.Now(),
-        UpdatedAt:      time.Now(),
-    }
+// renderForPrinter renders a label for a print job in the language of the printer it is routed to
+func renderForPrinter(label models.Label, language string, job layout.Job) (templates.Rendered, error) {
+	renderer, err := labelrender.ForLanguage(language)
+	if err != nil {
+		return templates.Rendered{}, err
+	}
+	return renderer.Render(label, job)
 }
 
-// createPrintJob inserts a new print job using the actual DB label UUID, user ID, heat number,
-// and ZPL content read from the provided file path. Returns the new job ID as string.
-func createPrintJob(labelID uuid.UUID, userID uuid.UUID, heatNo string, zplFilePath string, actualLabelID string) (string, error) {
-    // Read ZPL content from file path generated earlier
-    content, err := os.ReadFile(zplFilePath)
-    if err != nil {
-        return "", fmt.Errorf("failed to read ZPL file: %w", err)
-    }
+// bundleCopies returns the labels printed for a bundle type, read from
+// BUNDLE_TYPE_COPIES as TYPE=copies pairs, e.g. "DOUBLE=2,LONG=2". Other types get one.
+func bundleCopies(bundleType string) int {
+	for _, pair := range strings.Split(os.Getenv("BUNDLE_TYPE_COPIES"), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(bundleType)) {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n >= 1 && n <= layout.MaxCopies {
+			return n
+		}
+	}
+	return 1
+}
 
-    jobID := uuid.New()
-    _, err = db.DB.Exec(`
-        INSERT INTO print_jobs (id, label_id, heat_no, actual_label_id, user_id, status, zpl_content, max_retries)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-    `, jobID, labelID, heatNo, actualLabelID, userID, "pending", string(content), 3)
-    if err != nil {
-        return "", fmt.Errorf("failed to insert print job: %w", err)
-    }
-    return jobID.String(), nil
+// jobMedia encodes a job's media for the print_jobs.media column, NULL when it has none
+func jobMedia(job layout.Job) interface{} {
+	if job.Media == nil {
+		return nil
+	}
+	data, _ := json.Marshal(job.Media)
+	return string(data)
 }
 
-// updatePrintJobStatus updates the status and optional error message for a job
-func updatePrintJobStatus(jobID string, status string, errorMessage string) {
-    // Best-effort update; log on failure but do not interrupt caller flow
-    id, err := uuid.Parse(jobID)
-    if err != nil {
-        log.Printf("updatePrintJobStatus: invalid job id %s: %v", jobID, err)
-        return
-    }
-    _, err = db.DB.Exec(`
-        UPDATE print_jobs
-        SET status = $1, error_message = NULLIF($2, ''), updated_at = NOW()
-        WHERE id = $3
-    `, status, errorMessage, id)
-    if err != nil {
-        log.Printf("updatePrintJobStatus: failed updating job %s: %v", jobID, err)
-    }
+// insertPrintJob queues a rendered label in tx: it inserts the print job with the
+// template version, QR settings, printer, copies and reprint it was rendered for, and moves the
+// label to queued. reprintOf is the job that first printed the label, if any.
+func insertPrintJob(tx *sql.Tx, label models.Label, userID uuid.UUID, rendered templates.Rendered, printerID *uuid.UUID, job layout.Job, reprintOf *uuid.UUID) (uuid.UUID, error) {
+	jobID := uuid.New()
+	templateID, templateVersion := rendered.TemplateRef()
+	_, err := tx.Exec(`
+		INSERT INTO print_jobs (id, label_id, heat_no, actual_label_id, label_version, user_id, status, zpl_content,
+		                        max_retries, printer_id, template_id, template_version, zpl_hash, language, qr_hash,
+		                        copies, serialized, reprint_of, reprint_no, media, qr_url_format, qr_data_format, qr_sign_alg)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+	`, jobID, label.ID, label.HeatNo, label.LabelID, label.Version, userID, dispatcher.StatusPending, rendered.ZPL,
+		3, printerID, templateID, templateVersion, rendered.Hash, rendered.Language, rendered.QRHash,
+		job.Copies, job.Serial, reprintOf, job.Reprint, jobMedia(job),
+		rendered.QR.Format.URL, rendered.QR.Format.Data, rendered.QR.SignAlg)
+	if err != nil {
+		return jobID, fmt.Errorf("failed to insert print job: %w", err)
+	}
+	if _, err := lifecycle.Advance(tx, label.ID, lifecycle.AfterQueue, lifecycle.Event{ActorID: &userID, PrintJobID: &jobID}); err != nil {
+		return jobID, fmt.Errorf("failed to queue label: %w", err)
+	}
+	return jobID, nil
+}
+
+// insertBatchPrintJob queues a batch label in tx under a savepoint, so a job
+// that fails to insert leaves the rest of the batch's transaction usable
+func insertBatchPrintJob(tx *sql.Tx, label models.Label, userID uuid.UUID, rendered templates.Rendered, printerID *uuid.UUID, job layout.Job) (uuid.UUID, error) {
+	if _, err := tx.Exec(`SAVEPOINT print_job`); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert print job: %w", err)
+	}
+	jobID, err := insertPrintJob(tx, label, userID, rendered, printerID, job, nil)
+	if err != nil {
+		if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT print_job`); rbErr != nil {
+			return jobID, fmt.Errorf("failed to insert print job: %w", rbErr)
+		}
+		return jobID, err
+	}
+	if _, err := tx.Exec(`RELEASE SAVEPOINT print_job`); err != nil {
+		return jobID, fmt.Errorf("failed to insert print job: %w", err)
+	}
+	return jobID, nil
+}
+
+// lockLabel reads a label by its UUID and locks its row until tx ends
+func lockLabel(tx *sql.Tx, labelUUID uuid.UUID) (models.Label, error) {
+	var label models.Label
+	err := tx.QueryRow(`
+		SELECT id, label_id, location, bundle_no, bundle_type, pqd, unit, time, length,
+		       heat_no, product_heading, isi_bottom, isi_top, charge_dtm, mill, grade,
+		       url_apikey, weight, section, date, user_id, status, is_duplicate,
+		       version, created_at, updated_at
+		FROM labels
+		WHERE id = $1
+		FOR UPDATE
+	`, labelUUID).Scan(
+		&label.ID, &label.LabelID, &label.Location, &label.BundleNo, &label.BundleType, &label.PQD,
+		&label.Unit, &label.Time, &label.Length, &label.HeatNo, &label.ProductHeading,
+		&label.IsiBottom, &label.IsiTop, &label.ChargeDtm, &label.Mill, &label.Grade,
+		&label.UrlApikey, &label.Weight, &label.Section, &label.Date,
+		&label.UserID, &label.Status, &label.IsDuplicate,
+		&label.Version, &label.CreatedAt, &label.UpdatedAt,
+	)
+	return label, err
+}
+
+// renderError is a label that could not be rendered for its printer
+type renderError struct{ err error }
+
+func (e renderError) Error() string { return e.err.Error() }
+func (e renderError) Unwrap() error { return e.err }
+
+// queuedPrint is a print job queued for a stored label
+type queuedPrint struct {
+	ID        uuid.UUID
+	Rendered  templates.Rendered
+	ReprintOf *uuid.UUID
+	Reprint   int
+}
+
+// queueLabelPrint renders a stored label for its printer and queues the job in tx.
+// A label that has already printed is reprinted: the job is linked to the first job
+// that printed it and carries a REPRINT n mark. The label row should be locked in tx
+// so concurrent reprints are numbered in turn. Rendering failures are a renderError.
+func queueLabelPrint(tx *sql.Tx, label models.Label, userID uuid.UUID, job layout.Job) (queuedPrint, error) {
+	var q queuedPrint
+	var original uuid.UUID
+	err := tx.QueryRow(`
+		SELECT o.id, (SELECT COUNT(*) FROM print_jobs r WHERE r.reprint_of = o.id)
+		FROM print_jobs o
+		WHERE o.label_id = $1 AND o.status = $2 AND o.reprint_of IS NULL
+		ORDER BY o.created_at
+		LIMIT 1
+	`, label.ID, dispatcher.StatusCompleted).Scan(&original, &job.Reprint)
+	if err != nil && err != sql.ErrNoRows {
+		return q, fmt.Errorf("failed to fetch print history: %w", err)
+	}
+	if err == nil {
+		q.ReprintOf = &original
+		job.Reprint++
+	}
+	q.Reprint = job.Reprint
+
+	// Route the label to the printer serving its mill/location
+	printerID, language, media, err := resolvePrinter(label)
+	if err != nil {
+		log.Printf("Failed to resolve printer for label %s: %v", label.LabelID, err)
+	}
+	job.Media = media
+
+	// Render the label in the printer's language and for its media; ZPL comes from the template selected for it
+	q.Rendered, err = renderForPrinter(label, language, job)
+	if err != nil {
+		return q, renderError{err}
+	}
+
+	q.ID, err = insertPrintJob(tx, label, userID, q.Rendered, printerID, job, q.ReprintOf)
+	if err != nil {
+		return q, err
+	}
+	return q, nil
 }
 
 // nilIfInvalidString converts sql.NullString to either its string or nil for JSON
@@ -130,7 +236,62 @@ func nilIfInvalidTime(nt sql.NullTime) interface{} {
     return nil
 }
 
-// BatchLabelProcess processes a batch of labels and sends new labels to printer
+// batchModeFromRequest reads the validation mode of a batch from ?mode=, falling
+// back to BATCH_MODE and then partial; it writes a 400 response for unknown modes
+func batchModeFromRequest(c *gin.Context) (string, bool) {
+	mode := c.Query("mode")
+	if mode == "" {
+		mode = os.Getenv("BATCH_MODE")
+	}
+	if mode == "" {
+		return ingest.ModePartial, true
+	}
+	if !ingest.ValidMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be partial or strict"})
+		return "", false
+	}
+	return mode, true
+}
+
+// duplicatePolicyFromRequest reads the duplicate policy of a batch from
+// ?duplicate_policy= and ?duplicate_window=, falling back to DUPLICATE_POLICY
+// and DUPLICATE_WINDOW; it writes a 400 response for invalid values, including
+// a window with the label_id policy
+func duplicatePolicyFromRequest(c *gin.Context) (dedupe.Policy, bool) {
+	policy, err := dedupe.PolicyFromEnv()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid duplicate policy configuration", "details": err.Error()})
+		return policy, false
+	}
+	if match := c.Query("duplicate_policy"); match != "" {
+		if !dedupe.ValidMatch(match) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duplicate_policy must be label_id, composite or content_hash"})
+			return policy, false
+		}
+		policy.Match = match
+	}
+	window, ok := c.GetQuery("duplicate_window")
+	if ok {
+		if policy.Window, err = dedupe.ParseWindow(window); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duplicate_window", "details": err.Error()})
+			return policy, false
+		}
+	} else if policy.Match == dedupe.MatchLabelID {
+		// DUPLICATE_WINDOW is meant for the configured policy, not one chosen per batch
+		policy.Window = 0
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duplicate_window", "details": err.Error()})
+		return policy, false
+	}
+	return policy, true
+}
+
+// BatchLabelProcess processes a batch of labels and sends new labels to printer.
+// The response lists every print job created in print_jobs with its current
+// status, split into succeeded_job_ids, failed_jobs and pending_job_ids. The
+// batch returns once its jobs are queued, so they are usually pending; with
+// ?wait= it first waits up to that long (at most a minute) for them to print.
 func BatchLabelProcess(c *gin.Context) {
 	var req models.LabelBatchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -143,29 +304,61 @@ func BatchLabelProcess(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No labels provided"})
 		return
 	}
+	mode, ok := batchModeFromRequest(c)
+	if !ok {
+		return
+	}
+	policy, ok := duplicatePolicyFromRequest(c)
+	if !ok {
+		return
+	}
 
 	userModel, ok := getUserFromContext(c)
 	if !ok {
 		return
 	}
 
-	// Ensure printer directories exist
-	if err := ensurePrinterDirectories(); err != nil {
-		log.Printf("Failed to create printer directories: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize printer system", "details": err.Error()})
+	// Validate every row; invalid rows are reported, and in strict mode reject the whole batch
+	validated := ingest.Batch(req.Labels)
+	if len(validated.Errors) > 0 && (mode == ingest.ModeStrict || len(validated.Labels) == 0) {
+		utils.LogAudit(c, userModel.ID, "reject_batch", "labels", nil,
+			"Rejected batch of labels that failed validation", map[string]interface{}{
+				"mode":           mode,
+				"total_received": len(req.Labels),
+				"rejected_count": len(validated.Errors),
+			})
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":          "Batch failed validation",
+			"mode":           mode,
+			"total_received": len(req.Labels),
+			"accepted_count": 0,
+			"rejected_count": len(validated.Errors),
+			"errors":         validated.Errors,
+			"warnings":       validated.Warnings,
+		})
 		return
 	}
 
 	// Convert labels to JSON for DB stored procedure
-	labelsJSON, err := json.Marshal(req.Labels)
+	labelsJSON, err := json.Marshal(validated.Labels)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal labels", "details": err.Error()})
 		return
 	}
 
+	// The labels and their print jobs are stored in one transaction, so a strict
+	// batch with a label that cannot be printed stores nothing
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process batch", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
 	// Process batch in database
 	var resultStr string
-	err = db.DB.QueryRow("SELECT batch_label_process($1, $2)", labelsJSON, userModel.ID).Scan(&resultStr)
+	err = tx.QueryRow("SELECT batch_label_process($1, $2, $3, NULLIF($4::BIGINT, 0) * INTERVAL '1 second')",
+		labelsJSON, userModel.ID, policy.Match, int64(policy.Window/time.Second)).Scan(&resultStr)
 	if err != nil {
 		log.Printf("Database batch processing failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process batch", "details": err.Error()})
@@ -178,6 +371,38 @@ func BatchLabelProcess(c *gin.Context) {
 		return
 	}
 
+	// Split duplicates into identical resends and conflicts, which differ from the stored label
+	duplicatesJSON, err := json.Marshal(result["duplicate_labels"])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process duplicate labels", "details": err.Error()})
+		return
+	}
+	var duplicateRows []struct {
+		Label     models.LabelData `json:"label"`
+		MatchedBy string           `json:"matched_by"`
+		Existing  models.Label     `json:"existing"`
+	}
+	if err := json.Unmarshal(duplicatesJSON, &duplicateRows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse duplicate labels", "details": err.Error()})
+		return
+	}
+	identical := []dedupe.Duplicate{}
+	conflicts := []dedupe.Duplicate{}
+	for _, d := range duplicateRows {
+		dup := dedupe.Duplicate{
+			ID:              d.Label.ID,
+			MatchedBy:       d.MatchedBy,
+			ExistingID:      d.Existing.ID,
+			ExistingLabelID: d.Existing.LabelID,
+			Diff:            dedupe.Compare(d.Label, d.Existing),
+		}
+		if len(dup.Diff) == 0 {
+			identical = append(identical, dup)
+		} else {
+			conflicts = append(conflicts, dup)
+		}
+	}
+
 	// Extract new labels for printing - these should contain the DB-generated IDs (business IDs provided)
 	newLabelsInterface, exists := result["new_labels"]
 	if !exists {
@@ -198,8 +423,20 @@ func BatchLabelProcess(c *gin.Context) {
 		return
 	}
 
-	// Generate ZPL files and create print jobs only for NEW labels
-	var zplPaths []string
+	// Generate ZPL and queue print jobs only for NEW labels. A label that cannot be
+	// rendered or queued is reported as an error of its row.
+	batchRows := map[string]int{}
+	for i, data := range validated.Labels {
+		if _, seen := batchRows[data.ID]; !seen {
+			batchRows[data.ID] = validated.Rows[i]
+		}
+	}
+	printErrors := []ingest.RowError{}
+	rowFailed := func(businessID, message string, err error) {
+		log.Printf("%s for label %s: %v", message, businessID, err)
+		printErrors = append(printErrors, ingest.RowError{Row: batchRows[businessID], ID: businessID,
+			Errors: []ingest.FieldError{{Message: fmt.Sprintf("%s: %v", message, err)}}})
+	}
 	var printJobIDs []string
 
 	for _, labelMap := range newLabelsWithIDs {
@@ -212,99 +449,176 @@ func BatchLabelProcess(c *gin.Context) {
 
 		// Query the database to get the actual UUID for this label
 		var labelUUID uuid.UUID
-		err := db.DB.QueryRow(`
+		err := tx.QueryRow(`
 			SELECT id FROM labels 
 			WHERE label_id = $1 AND user_id = $2 
 			ORDER BY created_at DESC 
 			LIMIT 1
 		`, businessID, userModel.ID).Scan(&labelUUID)
 		if err != nil {
-			log.Printf("Failed to find database UUID for label %s: %v", businessID, err)
+			rowFailed(businessID, "Failed to find stored label", err)
 			continue
 		}
 
 		// Convert the label map back to LabelData for ZPL generation
 		labelDataJSON, err := json.Marshal(labelMap)
 		if err != nil {
-			log.Printf("Failed to marshal label data: %v", err)
+			rowFailed(businessID, "Failed to read stored label", err)
 			continue
 		}
 
 		var labelData models.LabelData
 		if err := json.Unmarshal(labelDataJSON, &labelData); err != nil {
-			log.Printf("Failed to unmarshal label data: %v", err)
+			rowFailed(businessID, "Failed to read stored label", err)
 			continue
 		}
 
 		// Convert LabelData to Label for ZPL generation, using the DB ID
-		label := convertLabelDataToLabelWithID(labelData, userModel.ID, labelUUID)
+		label := labelrender.FromData(labelData, userModel.ID, labelUUID)
 
-		// Generate ZPL file
-		zplPath, err := printer.GenerateAndSaveZPL(label)
+		// Route the label to the printer serving its mill/location
+		printerID, language, media, err := resolvePrinter(label)
 		if err != nil {
-			log.Printf("Failed to generate ZPL for label %s: %v", labelData.PQD, err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to generate ZPL file",
-				"label":   labelData.PQD,
-				"details": err.Error(),
-			})
-			return
+			log.Printf("Failed to resolve printer for label %s: %v", businessID, err)
+		}
+
+		// Render the label in the printer's language and for its media; ZPL comes from the template selected for it
+		job := layout.Job{Copies: bundleCopies(label.BundleType), Media: media}
+		rendered, err := renderForPrinter(label, language, job)
+		if err != nil {
+			rowFailed(businessID, "Failed to render label", err)
+			continue
 		}
-		zplPaths = append(zplPaths, zplPath)
 
 		// Create print job record in database using the actual DB label ID and store business ID as actual_label_id
-		// Pass heat number for the NOT NULL heat_no column
-		printJobID, err := createPrintJob(labelUUID, userModel.ID, label.HeatNo, zplPath, businessID)
+		// Pass heat number for the NOT NULL heat_no column. The dispatcher picks it up from here.
+		printJobID, err := insertBatchPrintJob(tx, label, userModel.ID, rendered, printerID, job)
 		if err != nil {
-			log.Printf("Failed to create print job for label %s: %v", businessID, err)
-			// Continue processing other labels, but log the error
-		} else {
-			printJobIDs = append(printJobIDs, printJobID)
+			rowFailed(businessID, "Failed to create print job", err)
+			continue
 		}
+		printJobIDs = append(printJobIDs, printJobID.String())
 	}
 
-	// Print all ZPL files in batch if any new labels exist
-	var printError error
-	if len(zplPaths) > 0 {
-		if err := printer.PrintZPLBatch(zplPaths); err != nil {
-			printError = err
-			log.Printf("Batch printing failed: %v", err)
-
-			// Update print job statuses to failed
-			for _, jobID := range printJobIDs {
-				updatePrintJobStatus(jobID, "failed", err.Error())
-			}
-		} else {
-			// Update print job statuses to completed
-			for _, jobID := range printJobIDs {
-				updatePrintJobStatus(jobID, "completed", "")
-			}
-		}
+	if len(printErrors) > 0 && mode == ingest.ModeStrict {
+		utils.LogAudit(c, userModel.ID, "reject_batch", "labels", nil,
+			"Rejected batch of labels that could not be queued for printing", map[string]interface{}{
+				"mode":           mode,
+				"total_received": len(req.Labels),
+				"rejected_count": len(printErrors),
+			})
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":          "Batch could not be queued for printing",
+			"mode":           mode,
+			"total_received": len(req.Labels),
+			"accepted_count": 0,
+			"rejected_count": len(printErrors),
+			"errors":         printErrors,
+			"warnings":       validated.Warnings,
+		})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process batch", "details": err.Error()})
+		return
 	}
 
+	// Rows that were stored but not queued are reported with the invalid rows
+	rowErrors := append(validated.Errors, printErrors...)
+	sort.Slice(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
 	// Audit logging
 	utils.LogAudit(c, userModel.ID, "process_batch", "labels", nil,
 		"Processed batch of labels", map[string]interface{}{
+			"mode":               mode,
+			"total_received":     len(req.Labels),
+			"rejected_count":     len(validated.Errors),
 			"total_processed":    result["total_processed"],
 			"new_count":          result["new_count"],
 			"duplicate_count":    result["duplicate_count"],
+			"identical_count":    len(identical),
+			"conflict_count":     len(conflicts),
+			"duplicate_policy":   policy.Match,
 			"print_jobs_created": len(printJobIDs),
+			"print_error_count":  len(printErrors),
 		})
 
 	// Prepare response
 	response := gin.H{
-		"message":            "Batch processed successfully",
-		"total_processed":    result["total_processed"],
-		"new_count":          result["new_count"],
-		"duplicate_count":    result["duplicate_count"],
-		"print_jobs_created": len(printJobIDs),
+		"message":              "Batch processed successfully",
+		"mode":                 mode,
+		"total_received":       len(req.Labels),
+		"accepted_count":       len(validated.Labels),
+		"rejected_count":       len(validated.Errors),
+		"errors":               rowErrors,
+		"warnings":             validated.Warnings,
+		"total_processed":      result["total_processed"],
+		"new_count":            result["new_count"],
+		"duplicate_count":      result["duplicate_count"],
+		"duplicate_policy":     policy.Match,
+		"duplicate_window":     policy.WindowString(),
+		"identical_count":      len(identical),
+		"conflict_count":       len(conflicts),
+		"identical_duplicates": identical,
+		"conflicts":            conflicts,
+		"print_jobs_created":   len(printJobIDs),
 	}
 
-	if printError != nil {
-		response["print_warning"] = "Labels processed but printing failed: " + printError.Error()
-		response["message"] = "Batch processed with print errors"
-	} else if len(zplPaths) > 0 {
-		response["message"] = "Batch processed and sent to printer"
+	if len(printJobIDs) > 0 {
+		response["message"] = "Batch processed and queued for printing"
+		response["print_job_ids"] = printJobIDs
+
+		// Every job is reported with its current status. When asked to with ?wait=,
+		// the dispatcher is first given a bounded amount of time to print them.
+		var outcomes []printJobOutcome
+		wait := printWaitFromRequest(c)
+		if wait > 0 {
+			outcomes, err = waitForPrintJobs(c.Request.Context(), printJobIDs, wait)
+		} else {
+			outcomes, err = fetchPrintJobOutcomes(printJobIDs)
+		}
+		if err != nil {
+			log.Printf("Failed to fetch print job results: %v", err)
+		}
+		succeeded := []string{}
+		failedIDs := []string{}
+		failed := []printJobOutcome{}
+		pending := []string{}
+		for _, o := range outcomes {
+			switch {
+			case o.Status == dispatcher.StatusCompleted:
+				succeeded = append(succeeded, o.ID)
+			case o.settled():
+				failedIDs = append(failedIDs, o.ID)
+				failed = append(failed, o)
+			default:
+				pending = append(pending, o.ID)
+			}
+		}
+		if outcomes == nil {
+			outcomes = []printJobOutcome{}
+		}
+		response["print_jobs"] = outcomes
+		response["succeeded_job_ids"] = succeeded
+		response["failed_job_ids"] = failedIDs
+		response["failed_jobs"] = failed
+		response["pending_job_ids"] = pending
+
+		switch {
+		case len(failed) > 0:
+			response["message"] = "Batch processed with print errors"
+		case len(pending) == 0 && err == nil:
+			response["message"] = "Batch processed and sent to printer"
+		}
+	}
+	if len(validated.Errors) > 0 {
+		response["message"] = fmt.Sprintf("%s; %d invalid rows rejected", response["message"], len(validated.Errors))
+	}
+	if len(printErrors) > 0 {
+		response["message"] = fmt.Sprintf("%s; %d rows could not be queued for printing", response["message"], len(printErrors))
+	}
+	if len(conflicts) > 0 {
+		response["message"] = fmt.Sprintf("%s; %d rows conflict with stored labels", response["message"], len(conflicts))
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -318,7 +632,7 @@ func GetPrintJobs(c *gin.Context) {
 	}
 
 	query := `SELECT id, label_id, actual_label_id, user_id, status, heat_no, error_message,
-       zpl_content, max_retries, retry_count, created_at, updated_at
+       zpl_content, max_retries, retry_count, printer_id, copies, reprint_of, reprint_no, created_at, updated_at
 FROM print_jobs WHERE 1=1
 `
 	args := []interface{}{}
@@ -343,11 +657,14 @@ FROM print_jobs WHERE 1=1
 			zplContent                                         string
 			maxRetries                                         int
 			retryCount                                         int
+			printerID                                          sql.NullString
+			copies, reprintNo                                  int
+			reprintOf                                          sql.NullString
 			createdAt, updatedAt                               sql.NullTime
 		)
 		err := rows.Scan(
 			&id, &labelID, &actualLabelID, &userID, &status, &heatNo, &errorMessage, &zplContent, &maxRetries,
-			&retryCount, &createdAt, &updatedAt,
+			&retryCount, &printerID, &copies, &reprintOf, &reprintNo, &createdAt, &updatedAt,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan print job", "details": err.Error()})
@@ -365,6 +682,10 @@ FROM print_jobs WHERE 1=1
 			"zpl_content":     zplContent,
 			"max_retries":     maxRetries,
 			"retry_count":     retryCount,
+			"printer_id":      nilIfInvalidString(printerID),
+			"copies":          copies,
+			"reprint_of":      nilIfInvalidString(reprintOf),
+			"reprint_no":      reprintNo,
 			"created_at":      nilIfInvalidTime(createdAt),
 			"updated_at":      nilIfInvalidTime(updatedAt),
 		}
@@ -402,7 +723,7 @@ func GetLabels(c *gin.Context) {
 	section := c.Query("section")
 
 	// Build base query
-	query := `SELECT id, label_id, location, bundle_no, pqd, unit, time, length, 
+	query := `SELECT id, label_id, location, bundle_no, bundle_type, pqd, unit, time, length, 
 			  heat_no, product_heading, isi_bottom, isi_top, charge_dtm, mill, grade, 
 			  url_apikey, weight, section, date, user_id, status, 
 			  is_duplicate, created_at, updated_at 
@@ -535,7 +856,9 @@ func GetPrintJobByID(c *gin.Context) {
 
 	query := `
         SELECT id, label_id, user_id, status, zpl_content, max_retries, 
-           retry_count, error_message, actual_label_id, heat_no, created_at, updated_at 
+           retry_count, error_message, actual_label_id, heat_no, printer_id,
+           template_id, template_version, zpl_hash, language, copies, serialized,
+           reprint_of, reprint_no, media, created_at, updated_at 
     FROM print_jobs WHERE id = $1
     `
 	log.Printf("Executing SQL Query: %s", query)
@@ -547,12 +870,22 @@ func GetPrintJobByID(c *gin.Context) {
 		errorMessage                            sql.NullString
 		actualLabelID                           sql.NullString
 		heatNoCol                               string
+		printerID                               sql.NullString
+		templateID, zplHash                     sql.NullString
+		templateVersion                         sql.NullInt64
+		language                                string
+		copies, reprintNo                       int
+		serialized                              bool
+		reprintOf                               sql.NullString
+		media                                   []byte
 		createdAt, updatedAt                    sql.NullTime
 	)
 
 	err := db.DB.QueryRow(query, jobID).Scan(
 		&id, &labelID, &userID, &status, &zplContent, &maxRetries, &retryCount,
-		&errorMessage, &actualLabelID, &heatNoCol, &createdAt, &updatedAt,
+		&errorMessage, &actualLabelID, &heatNoCol, &printerID,
+		&templateID, &templateVersion, &zplHash, &language, &copies, &serialized,
+		&reprintOf, &reprintNo, &media, &createdAt, &updatedAt,
 	)
 
 	if err != nil {
@@ -576,9 +909,27 @@ func GetPrintJobByID(c *gin.Context) {
 		"error_message":   nilIfInvalidString(errorMessage),
 		"heat_no":         heatNoCol,
 		"actual_label_id": nilIfInvalidString(actualLabelID),
+		"printer_id":      nilIfInvalidString(printerID),
+		"template_id":     nilIfInvalidString(templateID),
+		"zpl_hash":        nilIfInvalidString(zplHash),
+		"language":        language,
+		"copies":          copies,
+		"serialized":      serialized,
+		"reprint_of":      nilIfInvalidString(reprintOf),
+		"reprint_no":      reprintNo,
 		"created_at":      nilIfInvalidTime(createdAt),
 		"updated_at":      nilIfInvalidTime(updatedAt),
 	}
+	if len(media) > 0 {
+		job["media"] = json.RawMessage(media)
+	} else {
+		job["media"] = nil
+	}
+	if templateVersion.Valid {
+		job["template_version"] = templateVersion.Int64
+	} else {
+		job["template_version"] = nil
+	}
 
 	c.JSON(http.StatusOK, job)
 }
@@ -660,7 +1011,9 @@ func GetPrintJobsByHeatNo(c *gin.Context) {
 // PrintLabel prints a specific label
 func PrintLabel(c *gin.Context) {
 	var request struct {
-		ID string `json:"id" binding:"required"`
+		ID     string `json:"id" binding:"required"`
+		Copies int    `json:"copies"` // defaults to the bundle type's
+		Serial bool   `json:"serial"` // number the copies
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -668,6 +1021,16 @@ func PrintLabel(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
+	if request.Copies < 0 || request.Copies > layout.MaxCopies {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("copies must be between 1 and %d", layout.MaxCopies)})
+		return
+	}
+
+	labelUUID, err := uuid.Parse(request.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
 
 	log.Printf("PrintLabel: Received request with label_id: %s", request.ID)
 
@@ -676,24 +1039,16 @@ func PrintLabel(c *gin.Context) {
 		return
 	}
 
-	// Fetch label using the label_id (string), but retrieve its UUID `id`
-	var label models.Label
-	err := db.DB.QueryRow(`
-		SELECT id, label_id, location, bundle_no, pqd, unit, time, length,
-		       heat_no, product_heading, isi_bottom, isi_top, charge_dtm, mill, grade,
-		       url_apikey, weight, section, date, user_id, status, is_duplicate,
-		       created_at, updated_at
-		FROM labels
-		WHERE id = $1
-	`, request.ID).Scan(
-		&label.ID, &label.LabelID, &label.Location, &label.BundleNo, &label.PQD,
-		&label.Unit, &label.Time, &label.Length, &label.HeatNo, &label.ProductHeading,
-		&label.IsiBottom, &label.IsiTop, &label.ChargeDtm, &label.Mill, &label.Grade,
-		&label.UrlApikey, &label.Weight, &label.Section, &label.Date,
-		&label.UserID, &label.Status, &label.IsDuplicate,
-		&label.CreatedAt, &label.UpdatedAt,
-	)
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create print job", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
 
+	// Fetch the label by its UUID `id`. The row stays locked until the job is queued,
+	// so concurrent reprints are numbered in turn.
+	label, err := lockLabel(tx, labelUUID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Printf("PrintLabel: No label found for label_id: %s", request.ID)
@@ -712,19 +1067,34 @@ func PrintLabel(c *gin.Context) {
 	}
 
 	log.Printf("PrintLabel: Found label UUID: %s", label.ID.String())
+	if label.Status == models.LabelStatusVoided {
+		c.JSON(http.StatusConflict, gin.H{"error": "Voided labels cannot be printed"})
+		return
+	}
 
-	// Generate ZPL content
-	zplContent := utils.GenerateLabelZPL(label)
-	log.Printf("PrintLabel: ZPL content generated (length: %d)", len(zplContent))
-	log.Printf("PrintLabel: ZPL content generated: %s", zplContent)
-
-	// Create print job
-	printJobID := uuid.New()
-	_, err = db.DB.Exec(`
-		INSERT INTO print_jobs (id, label_id, heat_no, user_id, status, zpl_content, max_retries)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, printJobID, label.ID, label.HeatNo, userModel.ID, "pending", zplContent, 3)
+	job := layout.Job{Copies: request.Copies, Serial: request.Serial}
+	if job.Copies == 0 {
+		job.Copies = bundleCopies(label.BundleType)
+	}
 
+	queued, err := queueLabelPrint(tx, label, userModel.ID, job)
+	if err == nil {
+		err = tx.Commit()
+	}
+	var renderErr renderError
+	if errors.As(err, &renderErr) {
+		log.Printf("PrintLabel: Failed to render template: %v", err)
+		status := http.StatusInternalServerError
+		var fieldErr *templates.FieldError
+		if errors.As(err, &fieldErr) {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to render label template",
+			"details": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		log.Printf("PrintLabel: Failed to insert print job: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -733,24 +1103,32 @@ func PrintLabel(c *gin.Context) {
 		})
 		return
 	}
-
-	log.Printf("PrintLabel: Print job created with ID: %s", printJobID.String())
+	log.Printf("PrintLabel: Print job %s created with %s content from %s v%d (length: %d)", queued.ID,
+		queued.Rendered.Language, queued.Rendered.TemplateName, queued.Rendered.Version, len(queued.Rendered.ZPL))
 
 	// Audit log
 	utils.LogAudit(c, userModel.ID, "print_label", "labels", &label.LabelID,
 		"Label print job created", map[string]interface{}{
-			"print_job_id": printJobID.String(),
+			"print_job_id": queued.ID.String(),
 			"label_id":     label.LabelID,
+			"copies":       job.Copies,
+			"serialized":   job.Serial,
+			"reprint_of":   queued.ReprintOf,
+			"reprint_no":   queued.Reprint,
 		})
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":      "Print job created successfully",
-		"print_job_id": printJobID.String(),
-		"zpl_content":  zplContent,
+		"message":      "Print job queued successfully",
+		"print_job_id": queued.ID.String(),
+		"zpl_content":  queued.Rendered.ZPL,
+		"copies":       job.Copies,
+		"reprint_of":   queued.ReprintOf,
+		"reprint_no":   queued.Reprint,
 	})
 }
 
-// ExportLabelsCSV exports labels as CSV
+// ExportLabelsCSV exports labels as CSV. Voided labels are left out unless
+// ?include_voided=true or ?status=voided.
 func ExportLabelsCSV(c *gin.Context) {
 	userModel, ok := getUserFromContext(c)
 	if !ok {
@@ -763,9 +1141,16 @@ func ExportLabelsCSV(c *gin.Context) {
 			  FROM labels WHERE 1=1`
 	args := []interface{}{}
 
+	if status := c.Query("status"); status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	} else if !includeVoided(c) {
+		query += " AND status <> '" + models.LabelStatusVoided + "'"
+	}
+
 	if userModel.Role != "admin" {
-		query += " AND user_id = $1"
 		args = append(args, userModel.ID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
 	}
 
 	query += " ORDER BY created_at DESC"
@@ -810,23 +1195,7 @@ func GetLabelByID(c *gin.Context) {
 	}
 
 	// Get label
-	var label models.Label
-	err = db.DB.QueryRow(
-		`SELECT id, label_id, location, bundle_no, pqd, unit, time, length, 
-		 heat_no, product_heading, isi_bottom, isi_top, charge_dtm, mill, grade, 
-		 url_apikey, weight, section, date, user_id, status, 
-		 is_duplicate, created_at, updated_at 
-		 FROM labels WHERE id = $1`,
-		labelUUID,
-	).Scan(
-		&label.ID, &label.LabelID, &label.Location, &label.BundleNo, &label.PQD,
-		&label.Unit, &label.Time, &label.Length, &label.HeatNo, &label.ProductHeading,
-		&label.IsiBottom, &label.IsiTop, &label.ChargeDtm, &label.Mill, &label.Grade,
-		&label.UrlApikey, &label.Weight, &label.Section, &label.Date,
-		&label.UserID, &label.Status, &label.IsDuplicate,
-		&label.CreatedAt, &label.UpdatedAt,
-	)
-
+	label, err := labelrender.Load(labelUUID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
@@ -862,13 +1231,34 @@ func RetryPrintJob(c *gin.Context) {
 		return
 	}
 
-	// Update print job status and increment retry count
-	_, err = db.DB.Exec(
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry print job"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Hand the job back to the dispatcher for an immediate attempt. Automatic retries
+	// are scheduled by the dispatcher itself; this lets a user skip the backoff.
+	var labelUUID uuid.UUID
+	err = tx.QueryRow(
 		`UPDATE print_jobs
-         SET retry_count = retry_count + 1, status = 'retrying', updated_at = NOW()
-         WHERE id = $1 AND user_id = $2`,
-		jobUUID, userModel.ID,
-	)
+         SET status = $1, next_attempt_at = NULL, updated_at = NOW()
+         WHERE id = $2 AND user_id = $3 AND status IN ($4, $5, $6)
+         RETURNING label_id`,
+		dispatcher.StatusPending, jobUUID, userModel.ID,
+		dispatcher.StatusFailed, dispatcher.StatusRetrying, dispatcher.StatusDead,
+	).Scan(&labelUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed print job found with this ID"})
+		return
+	}
+	if err == nil {
+		_, err = lifecycle.Advance(tx, labelUUID, lifecycle.AfterQueue, lifecycle.Event{ActorID: &userModel.ID, PrintJobID: &jobUUID})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry print job"})
 		return
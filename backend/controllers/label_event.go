@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/lifecycle"
+	"labelops-backend/models"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DispatchLabel records that a printed label has left the plant with its
+// bundle. It takes an optional note, e.g. the vehicle or invoice number.
+func DispatchLabel(c *gin.Context) {
+	labelUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+	note, ok := decisionNote(c)
+	if !ok {
+		return
+	}
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dispatch label", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	e := lifecycle.Event{ActorID: &userModel.ID}
+	if note != nil {
+		e.Note = *note
+	}
+	event, err := lifecycle.Advance(tx, labelUUID, lifecycle.To(models.LabelStatusDispatched), e)
+	if err == nil {
+		err = tx.Commit()
+	}
+	var transitionErr *lifecycle.TransitionError
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": "Label cannot be dispatched", "details": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dispatch label", "details": err.Error()})
+		return
+	}
+
+	idStr := labelUUID.String()
+	utils.LogAudit(c, userModel.ID, "dispatch_label", "labels", &idStr, "Label dispatched",
+		map[string]interface{}{"from_status": event.FromStatus, "note": note})
+	c.JSON(http.StatusOK, gin.H{"message": "Label dispatched", "event": event})
+}
+
+// GetLabelEvents lists the status changes of a label, oldest first
+func GetLabelEvents(c *gin.Context) {
+	labelUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+	var status string
+	err = db.DB.QueryRow(`SELECT status FROM labels WHERE id = $1`, labelUUID).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label", "details": err.Error()})
+		return
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT id, label_id, from_status, to_status, actor_id, print_job_id, note, created_at
+		FROM label_events
+		WHERE label_id = $1
+		ORDER BY id
+	`, labelUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label events", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	events := []models.LabelEvent{}
+	for rows.Next() {
+		var e models.LabelEvent
+		if err := rows.Scan(&e.ID, &e.LabelID, &e.FromStatus, &e.ToStatus, &e.ActorID, &e.PrintJobID, &e.Note, &e.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read label event", "details": err.Error()})
+			return
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label events", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "events": events})
+}
+
+// labelStats derives label statistics from label_events: each label counts
+// under the status of its latest event. Voided labels are left out of every
+// count but VoidedLabels unless includeVoided.
+func labelStats(includeVoided bool) (models.LabelStats, error) {
+	stats := models.LabelStats{ByStatus: map[string]int{}, ByGrade: map[string]int{}, BySection: map[string]int{}}
+	counted := "s.status <> 'voided'"
+	if includeVoided {
+		counted = "TRUE"
+	}
+
+	rows, err := db.DB.Query(`SELECT status, COUNT(*) FROM label_states GROUP BY status`)
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var (
+			status string
+			count  int
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.ByStatus[status] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+	for status, count := range stats.ByStatus {
+		if status != models.LabelStatusVoided || includeVoided {
+			stats.TotalLabels += count
+		}
+	}
+	stats.PendingLabels = stats.ByStatus[models.LabelStatusReceived] + stats.ByStatus[models.LabelStatusQueued]
+	stats.FailedLabels = stats.ByStatus[models.LabelStatusFailed]
+	stats.ReprintedLabels = stats.ByStatus[models.LabelStatusReprinted]
+	stats.DispatchedLabels = stats.ByStatus[models.LabelStatusDispatched]
+	stats.VoidedLabels = stats.ByStatus[models.LabelStatusVoided]
+
+	err = db.DB.QueryRow(`
+		SELECT COUNT(DISTINCT e.label_id)
+		FROM label_events e
+		JOIN label_states s ON s.label_id = e.label_id
+		WHERE e.to_status = 'printed' AND ` + counted).Scan(&stats.PrintedLabels)
+	if err != nil {
+		return stats, err
+	}
+	err = db.DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM label_states s
+		JOIN labels l ON l.id = s.label_id
+		WHERE l.is_duplicate AND ` + counted).Scan(&stats.DuplicateLabels)
+	if err != nil {
+		return stats, err
+	}
+
+	for column, counts := range map[string]map[string]int{"grade": stats.ByGrade, "section": stats.BySection} {
+		rows, err := db.DB.Query(`
+			SELECT l.` + column + `, COUNT(*)
+			FROM label_states s
+			JOIN labels l ON l.id = s.label_id
+			WHERE ` + counted + `
+			GROUP BY l.` + column + `
+			ORDER BY COUNT(*) DESC
+			LIMIT 10`)
+		if err != nil {
+			return stats, err
+		}
+		for rows.Next() {
+			var (
+				value string
+				count int
+			)
+			if err := rows.Scan(&value, &count); err != nil {
+				rows.Close()
+				return stats, err
+			}
+			counts[value] = count
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/models"
+)
+
+// rowDriver is a database/sql driver answering every SELECT with one row,
+// holding the values of the selected columns
+type rowDriver map[string]driver.Value
+
+func (d rowDriver) Open(string) (driver.Conn, error) { return rowConn{d}, nil }
+
+type rowConn struct{ row rowDriver }
+
+func (c rowConn) Prepare(query string) (driver.Stmt, error) { return rowStmt{c.row, query}, nil }
+func (rowConn) Close() error                                { return nil }
+func (rowConn) Begin() (driver.Tx, error)                   { return rowTx{}, nil }
+
+type rowTx struct{}
+
+func (rowTx) Commit() error   { return nil }
+func (rowTx) Rollback() error { return nil }
+
+type rowStmt struct {
+	row   rowDriver
+	query string
+}
+
+func (rowStmt) Close() error  { return nil }
+func (rowStmt) NumInput() int { return -1 }
+func (rowStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("rowDriver does not execute statements")
+}
+
+func (s rowStmt) Query([]driver.Value) (driver.Rows, error) {
+	_, list, ok := strings.Cut(s.query, "SELECT")
+	if ok {
+		list, _, ok = strings.Cut(list, "FROM")
+	}
+	if !ok {
+		return nil, fmt.Errorf("rowDriver cannot answer %q", s.query)
+	}
+	rows := &rowRows{}
+	for _, column := range strings.Split(list, ",") {
+		column = strings.TrimSpace(column)
+		value, ok := s.row[column]
+		if !ok {
+			return nil, fmt.Errorf("rowDriver has no column %s", column)
+		}
+		rows.columns = append(rows.columns, column)
+		rows.values = append(rows.values, value)
+	}
+	return rows, nil
+}
+
+type rowRows struct {
+	columns []string
+	values  []driver.Value
+	done    bool
+}
+
+func (r *rowRows) Columns() []string { return r.columns }
+func (r *rowRows) Close() error      { return nil }
+func (r *rowRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+// A stored DOUBLE bundle is reprinted with the copies its bundle type gets,
+// whichever path loads it
+func TestReprintStoredBundleCopies(t *testing.T) {
+	t.Setenv("BUNDLE_TYPE_COPIES", "DOUBLE=2,LONG=2")
+	now := time.Now()
+	sql.Register("rowdriver", rowDriver{
+		"id": "00000000-0000-0000-0000-000000000001", "label_id": "2025015212", "location": nil,
+		"bundle_no": "2025015212", "bundle_type": "DOUBLE", "pqd": "100080004004005372", "unit": "SAIL-BSP",
+		"time": "13:55", "length": int64(12000), "heat_no": "C103247", "product_heading": "ANGLE",
+		"isi_bottom": "CML 57534", "isi_top": "IS 2062:2011", "charge_dtm": "", "mill": "MM",
+		"grade": "IS 2062 E250BR", "url_apikey": "c1a05e9bcdae44b590944f014dc00320", "weight": "2.15",
+		"section": "ANGLE 65*65*6", "date": "01-JUL-25", "user_id": "00000000-0000-0000-0000-000000000002",
+		"status": models.LabelStatusPrinted, "is_duplicate": false, "version": int64(1),
+		"created_at": now, "updated_at": now,
+	})
+	conn, err := sql.Open("rowdriver", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = saved; conn.Close() })
+
+	loaders := map[string]func() (models.Label, error){
+		"lockLabel": func() (models.Label, error) {
+			tx, err := db.DB.Begin()
+			if err != nil {
+				return models.Label{}, err
+			}
+			defer tx.Rollback()
+			return lockLabel(tx, [16]byte{15: 1})
+		},
+		"labelrender.Load": func() (models.Label, error) { return labelrender.Load([16]byte{15: 1}) },
+	}
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			label, err := load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got := bundleCopies(label.BundleType); got != 2 {
+				t.Errorf("bundle type %q reprints %d copies, want 2", label.BundleType, got)
+			}
+		})
+	}
+}
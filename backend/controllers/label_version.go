@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/dedupe"
+	"labelops-backend/internal/dispatcher"
+	"labelops-backend/internal/ingest"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/templates"
+	"labelops-backend/models"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AmendLabel corrects a stored label, e.g. a WEIGHT or LOCATION resent by the
+// weighbridge. The body holds a required reason and the fields to change, by
+// the names the batch endpoint takes; null clears an optional field. The
+// amended label is validated as a batch row would be, stored as the label's
+// next version and queued for a reprint; print jobs of earlier versions that
+// have not been sent are cancelled and listed as superseded_job_ids. Only the
+// label's owner, a supervisor or an admin may amend it.
+func AmendLabel(c *gin.Context) {
+	labelUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+	var request struct {
+		Reason  string                     `json:"reason"`
+		Changes map[string]json.RawMessage `json:"changes"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason for the amendment is required"})
+		return
+	}
+	if len(request.Changes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to amend label", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// The row stays locked until the amendment and its reprint are stored
+	label, err := lockLabel(tx, labelUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label", "details": err.Error()})
+		return
+	}
+	// Only the user who stored a label, or a supervisor or admin, may correct it
+	if label.UserID != userModel.ID && !userModel.IsSupervisor() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the label's owner or a supervisor may amend it"})
+		return
+	}
+	if label.Status == models.LabelStatusVoided {
+		c.JSON(http.StatusConflict, gin.H{"error": "Voided labels cannot be amended"})
+		return
+	}
+
+	// Apply the changes over the stored fields and validate the result as a whole
+	current := labelrender.ToData(label)
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read label", "details": err.Error()})
+		return
+	}
+	row := map[string]json.RawMessage{}
+	if err := json.Unmarshal(currentJSON, &row); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read label", "details": err.Error()})
+		return
+	}
+	for key, value := range request.Changes {
+		name, known := ingest.Canonical(key)
+		switch {
+		case !known:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown field " + key})
+			return
+		case name == "ID":
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " cannot be amended"})
+			return
+		}
+		row[name] = value
+	}
+	merged, err := json.Marshal(row)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid changes", "details": err.Error()})
+		return
+	}
+	amendedData, errs, _ := ingest.Row(merged)
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Amended label failed validation", "errors": errs})
+		return
+	}
+	// Bundle numbers are stored as the batch procedure stores them, without leading zeros
+	if n, err := strconv.Atoi(amendedData.BUNDLE_NO); err == nil {
+		amendedData.BUNDLE_NO = strconv.Itoa(n)
+	}
+
+	diff := dedupe.Compare(amendedData, label)
+	if len(diff) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amendment changes nothing"})
+		return
+	}
+	changes := models.LabelChanges{Before: map[string]string{}, After: map[string]string{}}
+	for _, d := range diff {
+		changes.Before[d.Field] = d.Stored
+		changes.After[d.Field] = d.Received
+	}
+	changesJSON, _ := json.Marshal(changes)
+	amendedJSON, _ := json.Marshal(amendedData)
+
+	// The label as first stored becomes version 1 of its history the first time it is amended
+	_, err = tx.Exec(`
+		INSERT INTO label_versions (label_id, version, data, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (label_id, version) DO NOTHING
+	`, label.ID, label.Version, currentJSON, label.UserID, label.CreatedAt)
+	if err == nil {
+		err = tx.QueryRow(`
+			UPDATE labels
+			SET location = $2, bundle_no = $3, pqd = $4, unit = $5, time = $6, length = $7,
+			    heat_no = $8, product_heading = $9, isi_bottom = $10, isi_top = $11, charge_dtm = $12,
+			    mill = $13, grade = $14, url_apikey = $15, weight = $16, section = $17, date = $18,
+			    bundle_type = $19, version = version + 1, updated_at = NOW()
+			WHERE id = $1
+			RETURNING version, updated_at
+		`, label.ID, amendedData.LOCATION, amendedData.BUNDLE_NO, amendedData.PQD, amendedData.UNIT,
+			amendedData.TIME, amendedData.LENGTH, amendedData.HEAT_NO, amendedData.PRODUCT_HEADING,
+			amendedData.ISI_BOTTOM, amendedData.ISI_TOP, amendedData.CHARGE_DTM, amendedData.MILL,
+			amendedData.GRADE, amendedData.URL_APIKEY, amendedData.WEIGHT, amendedData.SECTION,
+			amendedData.DATE, amendedData.BUNDLE_TYPE).Scan(&label.Version, &label.UpdatedAt)
+	}
+	if err == nil {
+		_, err = tx.Exec(`
+			INSERT INTO label_versions (label_id, version, data, reason, changes, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, label.ID, label.Version, amendedJSON, reason, changesJSON, userModel.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to amend label", "details": err.Error()})
+		return
+	}
+
+	amended := labelrender.FromData(amendedData, label.UserID, label.ID)
+	amended.Status, amended.IsDuplicate, amended.Version = label.Status, label.IsDuplicate, label.Version
+	amended.CreatedAt, amended.UpdatedAt = label.CreatedAt, label.UpdatedAt
+
+	// Jobs still waiting to print an earlier version would print stale data
+	supersededIDs, err := supersedePrintJobs(tx, label.ID, label.Version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel superseded print jobs", "details": err.Error()})
+		return
+	}
+
+	// Reprint the amended label; it is linked to the job that first printed the label
+	queued, err := queueLabelPrint(tx, amended, userModel.ID, layout.Job{Copies: bundleCopies(amended.BundleType)})
+	if err == nil {
+		err = tx.Commit()
+	}
+	var renderErr renderError
+	if errors.As(err, &renderErr) {
+		status := http.StatusInternalServerError
+		var fieldErr *templates.FieldError
+		if errors.As(err, &fieldErr) {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{"error": "Failed to render amended label", "details": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to amend label", "details": err.Error()})
+		return
+	}
+
+	utils.LogAudit(c, userModel.ID, "amend_label", "labels", &label.LabelID,
+		"Label amended and queued for reprint", map[string]interface{}{
+			"label_id":           label.LabelID,
+			"version":            label.Version,
+			"reason":             reason,
+			"before":             changes.Before,
+			"after":              changes.After,
+			"print_job_id":       queued.ID.String(),
+			"reprint_no":         queued.Reprint,
+			"superseded_job_ids": supersededIDs,
+		})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Label amended and queued for reprint",
+		"label":              amended,
+		"version":            label.Version,
+		"changes":            changes,
+		"print_job_id":       queued.ID.String(),
+		"reprint_of":         queued.ReprintOf,
+		"reprint_no":         queued.Reprint,
+		"superseded_job_ids": supersededIDs,
+	})
+}
+
+// supersedePrintJobs cancels in tx the label print jobs of a label that were
+// rendered from a version before version and have not been sent, as voidLabel
+// does. Jobs already printing cannot be recalled and are left to finish.
+func supersedePrintJobs(tx *sql.Tx, labelID uuid.UUID, version int) ([]uuid.UUID, error) {
+	rows, err := tx.Query(`
+		UPDATE print_jobs
+		SET status = $3, error_message = 'superseded by version ' || $2::text, next_attempt_at = NULL, updated_at = NOW()
+		WHERE label_id = $1 AND kind = 'label' AND label_version < $2 AND status IN ($4, $5, $6, $7, $8)
+		RETURNING id
+	`, labelID, version, dispatcher.StatusCancelled, dispatcher.StatusPending, dispatcher.StatusQueued,
+		dispatcher.StatusRetrying, dispatcher.StatusFailed, dispatcher.StatusDead)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+// GetLabelVersions lists the versions of a label, oldest first. A label that
+// was never amended has one version, itself as stored.
+func GetLabelVersions(c *gin.Context) {
+	labelUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+	label, err := labelrender.Load(labelUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label", "details": err.Error()})
+		return
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT id, label_id, version, data, reason, changes, created_by, created_at
+		FROM label_versions
+		WHERE label_id = $1
+		ORDER BY version
+	`, labelUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label versions", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	versions := []models.LabelVersion{}
+	for rows.Next() {
+		var (
+			v         models.LabelVersion
+			data      []byte
+			changes   []byte
+			createdBy uuid.NullUUID
+		)
+		if err := rows.Scan(&v.ID, &v.LabelID, &v.Version, &data, &v.Reason, &changes, &createdBy, &v.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read label version", "details": err.Error()})
+			return
+		}
+		if err := json.Unmarshal(data, &v.Data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode label version", "details": err.Error()})
+			return
+		}
+		if len(changes) > 0 {
+			v.Changes = &models.LabelChanges{}
+			if err := json.Unmarshal(changes, v.Changes); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode label version", "details": err.Error()})
+				return
+			}
+		}
+		if createdBy.Valid {
+			v.CreatedBy = &createdBy.UUID
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label versions", "details": err.Error()})
+		return
+	}
+	if len(versions) == 0 {
+		versions = append(versions, models.LabelVersion{
+			LabelID:   label.ID,
+			Version:   label.Version,
+			Data:      labelrender.ToData(label),
+			CreatedBy: &label.UserID,
+			CreatedAt: label.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"label_id":        label.LabelID,
+		"current_version": label.Version,
+		"versions":        versions,
+	})
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"net/http"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/printer"
+	"labelops-backend/models"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// validateMediaProfileRequest fills defaults
+func validateMediaProfileRequest(req *models.MediaProfileRequest) {
+	if req.DPI == 0 {
+		req.DPI = 203
+	}
+	if req.MediaType == "" {
+		req.MediaType = models.MediaTypeGap
+	}
+}
+
+// GetMediaProfiles lists all media profiles (admin only)
+func GetMediaProfiles(c *gin.Context) {
+	profiles, err := printer.ListMedia()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media profiles", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"media_profiles": profiles, "count": len(profiles)})
+}
+
+// GetMediaProfileByID retrieves a media profile (admin only)
+func GetMediaProfileByID(c *gin.Context) {
+	mediaUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media profile ID"})
+		return
+	}
+
+	m, err := printer.GetMedia(mediaUUID)
+	if err == printer.ErrMediaNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media profile not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media profile", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// CreateMediaProfile adds a media profile (admin only)
+func CreateMediaProfile(c *gin.Context) {
+	var req models.MediaProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	validateMediaProfileRequest(&req)
+
+	var id uuid.UUID
+	err := db.DB.QueryRow(
+		`INSERT INTO media_profiles (name, width_mm, length_mm, dpi, darkness, speed, tear_off, label_top, media_type)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id`,
+		req.Name, req.WidthMM, req.LengthMM, req.DPI, req.Darkness, req.Speed, req.TearOff, req.LabelTop, req.MediaType,
+	).Scan(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create media profile", "details": err.Error()})
+		return
+	}
+
+	m, err := printer.GetMedia(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media profile", "details": err.Error()})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	idStr := id.String()
+	utils.LogAudit(c, userModel.ID, "create_media_profile", "media_profiles", &idStr, "Media profile created by admin",
+		map[string]interface{}{"name": m.Name, "width_mm": m.WidthMM, "length_mm": m.LengthMM, "dpi": m.DPI})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "Media profile created successfully",
+		"media_profile": m,
+	})
+}
+
+// UpdateMediaProfile updates a media profile (admin only). Printers using it
+// print new jobs with the new settings; queued jobs keep the ones they were rendered with.
+func UpdateMediaProfile(c *gin.Context) {
+	mediaID := c.Param("id")
+	mediaUUID, err := uuid.Parse(mediaID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media profile ID"})
+		return
+	}
+
+	var req models.MediaProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	validateMediaProfileRequest(&req)
+
+	res, err := db.DB.Exec(
+		`UPDATE media_profiles SET name = $1, width_mm = $2, length_mm = $3, dpi = $4, darkness = $5, speed = $6,
+		 tear_off = $7, label_top = $8, media_type = $9, updated_at = NOW() WHERE id = $10`,
+		req.Name, req.WidthMM, req.LengthMM, req.DPI, req.Darkness, req.Speed, req.TearOff, req.LabelTop, req.MediaType,
+		mediaUUID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update media profile", "details": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media profile not found"})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	utils.LogAudit(c, userModel.ID, "update_media_profile", "media_profiles", &mediaID, "Media profile updated by admin")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Media profile updated successfully"})
+}
+
+// DeleteMediaProfile removes a media profile (admin only). Printers using it
+// go back to printing labels at their design size.
+func DeleteMediaProfile(c *gin.Context) {
+	mediaID := c.Param("id")
+	mediaUUID, err := uuid.Parse(mediaID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media profile ID"})
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM media_profiles WHERE id = $1", mediaUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media profile", "details": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media profile not found"})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	utils.LogAudit(c, userModel.ID, "delete_media_profile", "media_profiles", &mediaID, "Media profile deleted by admin")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Media profile deleted successfully"})
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/templates"
+	"labelops-backend/internal/zpl"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// previewDensities are the print densities Zebra printers ship with
+var previewDensities = map[int]bool{152: true, 203: true, 300: true, 600: true}
+
+// GetLabelPreview renders a label as PNG or PDF.
+// The ZPL of the label's latest print job is used when there is one, so the
+// preview matches what was sent to the printer; otherwise it is generated.
+func GetLabelPreview(c *gin.Context) {
+	labelUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "png")
+	if format != "png" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format", "details": "format must be png or pdf"})
+		return
+	}
+	dpi, err := strconv.Atoi(c.DefaultQuery("dpi", strconv.Itoa(zpl.DefaultDPI)))
+	if err != nil || !previewDensities[dpi] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dpi", "details": "dpi must be 152, 203, 300 or 600"})
+		return
+	}
+
+	zplContent, source, err := labelPreviewZPL(labelUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	}
+	var fieldErr *templates.FieldError
+	if errors.As(err, &fieldErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Label data cannot be printed", "details": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label", "details": err.Error()})
+		return
+	}
+
+	img, err := zpl.Render([]byte(zplContent), zpl.Options{DPI: dpi})
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to render label", "details": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = zpl.WritePDF(&buf, img, dpi)
+	} else {
+		err = zpl.WritePNG(&buf, img)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode preview", "details": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "inline; filename=label-"+labelUUID.String()+"."+format)
+	c.Header("X-ZPL-Source", source)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// labelPreviewZPL returns the ZPL to preview for a label and where it came from
+func labelPreviewZPL(labelUUID uuid.UUID) (string, string, error) {
+	var zplContent sql.NullString
+	err := db.DB.QueryRow(`
+		SELECT zpl_content FROM print_jobs
+		WHERE label_id = $1 AND zpl_content IS NOT NULL AND language = 'zpl'
+		ORDER BY created_at DESC LIMIT 1
+	`, labelUUID).Scan(&zplContent)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", err
+	}
+	if zplContent.Valid && zplContent.String != "" {
+		return zplContent.String, "print_job", nil
+	}
+
+	label, err := labelrender.Load(labelUUID)
+	if err != nil {
+		return "", "", err
+	}
+	rendered, err := labelrender.Render(label, layout.Job{})
+	return rendered.ZPL, "generated", err
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/dispatcher"
+	"labelops-backend/internal/lifecycle"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// GetDeadPrintJobs lists print jobs that exhausted their retries (admin only)
+func GetDeadPrintJobs(c *gin.Context) {
+	rows, err := db.DB.Query(`
+		SELECT id, label_id, actual_label_id, user_id, heat_no, printer_id,
+		       retry_count, max_retries, last_error, created_at, updated_at
+		FROM print_jobs
+		WHERE status = $1
+		ORDER BY updated_at DESC
+	`, dispatcher.StatusDead)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dead print jobs", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	jobs := []map[string]interface{}{}
+	for rows.Next() {
+		var (
+			id, labelID, userID, heatNo string
+			actualLabelID, printerID    sql.NullString
+			retryCount, maxRetries      int
+			lastError                   sql.NullString
+			createdAt, updatedAt        sql.NullTime
+		)
+		if err := rows.Scan(
+			&id, &labelID, &actualLabelID, &userID, &heatNo, &printerID,
+			&retryCount, &maxRetries, &lastError, &createdAt, &updatedAt,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan print job", "details": err.Error()})
+			return
+		}
+
+		jobs = append(jobs, map[string]interface{}{
+			"id":              id,
+			"label_id":        labelID,
+			"actual_label_id": nilIfInvalidString(actualLabelID),
+			"user_id":         userID,
+			"heat_no":         heatNo,
+			"printer_id":      nilIfInvalidString(printerID),
+			"status":          dispatcher.StatusDead,
+			"retry_count":     retryCount,
+			"max_retries":     maxRetries,
+			"last_error":      nilIfInvalidString(lastError),
+			"created_at":      nilIfInvalidTime(createdAt),
+			"updated_at":      nilIfInvalidTime(updatedAt),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"print_jobs": jobs, "count": len(jobs)})
+}
+
+// RequeueDeadPrintJobs moves dead print jobs back to pending with a fresh retry budget (admin only).
+// An empty job_ids list requeues every dead job.
+func RequeueDeadPrintJobs(c *gin.Context) {
+	var request struct {
+		JobIDs []string `json:"job_ids"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+
+	for _, id := range request.JobIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid print job ID", "job_id": id})
+			return
+		}
+	}
+
+	query := `UPDATE print_jobs
+		SET status = $1, retry_count = 0, next_attempt_at = NULL, updated_at = NOW()
+		WHERE status = $2`
+	args := []interface{}{dispatcher.StatusPending, dispatcher.StatusDead}
+	if len(request.JobIDs) > 0 {
+		query += " AND id = ANY($3::uuid[])"
+		args = append(args, pq.Array(request.JobIDs))
+	}
+	query += " RETURNING id, label_id"
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue print jobs", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue print jobs", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	requeued := []string{}
+	labels := map[uuid.UUID]uuid.UUID{} // label to a job requeued for it
+	for rows.Next() {
+		var id, labelID uuid.UUID
+		if err := rows.Scan(&id, &labelID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan print job", "details": err.Error()})
+			return
+		}
+		requeued = append(requeued, id.String())
+		labels[labelID] = id
+	}
+	rows.Close()
+
+	// Labels whose jobs had all died are queued again
+	for labelID, jobID := range labels {
+		jobID := jobID
+		if _, err := lifecycle.Advance(tx, labelID, lifecycle.AfterQueue, lifecycle.Event{ActorID: &userModel.ID, PrintJobID: &jobID}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue print jobs", "details": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue print jobs", "details": err.Error()})
+		return
+	}
+
+	utils.LogAudit(c, userModel.ID, "requeue_dead_print_jobs", "print_jobs", nil,
+		"Dead print jobs requeued by admin", map[string]interface{}{
+			"requested": len(request.JobIDs),
+			"requeued":  len(requeued),
+		})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Dead print jobs requeued",
+		"requeued_ids": requeued,
+		"count":        len(requeued),
+	})
+}
+
+// maxPrintWait caps how long a request may wait for its print jobs
+const maxPrintWait = 60 * time.Second
+
+// printJobOutcome is the state of a print job reported back to API clients
+type printJobOutcome struct {
+	ID         string  `json:"id"`
+	Status     string  `json:"status"`
+	Error      *string `json:"error,omitempty"`
+	BytesSent  *int64  `json:"bytes_sent,omitempty"`
+	DurationMs *int64  `json:"duration_ms,omitempty"`
+}
+
+// settled reports whether the dispatcher has made an attempt at the job
+func (o printJobOutcome) settled() bool {
+	switch o.Status {
+	case dispatcher.StatusCompleted, dispatcher.StatusFailed, dispatcher.StatusRetrying, dispatcher.StatusDead:
+		return true
+	}
+	return false
+}
+
+// printWaitFromRequest returns how long to wait for print results, taken from the
+// ?wait= query parameter. Waiting is opt-in: without it the batch returns as soon
+// as its jobs are queued.
+func printWaitFromRequest(c *gin.Context) time.Duration {
+	wait, err := time.ParseDuration(c.Query("wait"))
+	if err != nil || wait < 0 {
+		return 0
+	}
+	if wait > maxPrintWait {
+		wait = maxPrintWait
+	}
+	return wait
+}
+
+// waitForPrintJobs polls the given jobs until each has been attempted or the wait elapses,
+// then returns their current outcomes in the order requested
+func waitForPrintJobs(ctx context.Context, jobIDs []string, wait time.Duration) ([]printJobOutcome, error) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		outcomes, err := fetchPrintJobOutcomes(jobIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		allSettled := true
+		for _, o := range outcomes {
+			if !o.settled() {
+				allSettled = false
+				break
+			}
+		}
+		if allSettled {
+			return outcomes, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return outcomes, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchPrintJobOutcomes loads the current state of the given jobs
+func fetchPrintJobOutcomes(jobIDs []string) ([]printJobOutcome, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, status, error_message, bytes_sent, duration_ms
+		FROM print_jobs WHERE id = ANY($1::uuid[])
+	`, pq.Array(jobIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[string]printJobOutcome, len(jobIDs))
+	for rows.Next() {
+		var (
+			o                     printJobOutcome
+			errorMessage          sql.NullString
+			bytesSent, durationMs sql.NullInt64
+		)
+		if err := rows.Scan(&o.ID, &o.Status, &errorMessage, &bytesSent, &durationMs); err != nil {
+			return nil, err
+		}
+		if errorMessage.Valid {
+			o.Error = &errorMessage.String
+		}
+		if bytesSent.Valid {
+			o.BytesSent = &bytesSent.Int64
+		}
+		if durationMs.Valid {
+			o.DurationMs = &durationMs.Int64
+		}
+		byID[o.ID] = o
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	outcomes := make([]printJobOutcome, 0, len(jobIDs))
+	for _, id := range jobIDs {
+		if o, ok := byID[id]; ok {
+			outcomes = append(outcomes, o)
+		}
+	}
+	return outcomes, nil
+}
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/printer"
+	"labelops-backend/models"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// printerPoller caches printer health for the status endpoint
+var printerPoller *printer.Poller
+
+// SetPrinterPoller shares the background status poller with the printer handlers
+func SetPrinterPoller(p *printer.Poller) {
+	printerPoller = p
+}
+
+// validatePrinterRequest fills defaults and checks driver specific fields
+func validatePrinterRequest(req *models.PrinterRequest) error {
+	if req.Port == 0 {
+		req.Port = printer.DefaultPort
+	}
+	if req.DPI == 0 {
+		req.DPI = 203
+	}
+	if req.LabelWidth == 0 {
+		req.LabelWidth = 812
+	}
+	if req.LabelLength == 0 {
+		req.LabelLength = 609
+	}
+	if req.Language == "" {
+		req.Language = models.PrinterLanguageZPL
+	}
+
+	if req.MediaProfileID != nil {
+		if _, err := printer.GetMedia(*req.MediaProfileID); err == printer.ErrMediaNotFound {
+			return fmt.Errorf("media profile %s does not exist", req.MediaProfileID)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	switch req.Driver {
+	case models.PrinterDriverTCP:
+		if req.Host == nil || *req.Host == "" {
+			return fmt.Errorf("host is required for the tcp driver")
+		}
+	case models.PrinterDriverDevice:
+		if req.DevicePath == nil || *req.DevicePath == "" {
+			return fmt.Errorf("device_path is required for the device driver")
+		}
+	}
+	return nil
+}
+
+// GetPrinters lists all registered printers (admin only)
+func GetPrinters(c *gin.Context) {
+	printers, err := printer.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch printers", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"printers": printers, "count": len(printers)})
+}
+
+// GetPrinterByID retrieves a registered printer (admin only)
+func GetPrinterByID(c *gin.Context) {
+	printerUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid printer ID"})
+		return
+	}
+
+	p, err := printer.Get(printerUUID)
+	if err == printer.ErrPrinterNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch printer", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// CreatePrinter registers a new printer (admin only)
+func CreatePrinter(c *gin.Context) {
+	var req models.PrinterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePrinterRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	var id uuid.UUID
+	err := db.DB.QueryRow(
+		`INSERT INTO printers (name, driver, host, port, device_path, dpi, label_width, label_length,
+		 mill, location, is_default, is_active, language, media_profile_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		 RETURNING id`,
+		req.Name, req.Driver, req.Host, req.Port, req.DevicePath, req.DPI, req.LabelWidth, req.LabelLength,
+		req.Mill, req.Location, req.IsDefault, isActive, req.Language, req.MediaProfileID,
+	).Scan(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create printer", "details": err.Error()})
+		return
+	}
+
+	p, err := printer.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch printer", "details": err.Error()})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	idStr := id.String()
+	utils.LogAudit(c, userModel.ID, "create_printer", "printers", &idStr, "Printer registered by admin",
+		map[string]interface{}{"name": p.Name, "driver": p.Driver, "language": p.Language, "media_profile_id": p.MediaProfileID})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Printer created successfully",
+		"printer": p,
+	})
+}
+
+// UpdatePrinter updates a registered printer (admin only)
+func UpdatePrinter(c *gin.Context) {
+	printerID := c.Param("id")
+	printerUUID, err := uuid.Parse(printerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid printer ID"})
+		return
+	}
+
+	var req models.PrinterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePrinterRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	res, err := db.DB.Exec(
+		`UPDATE printers SET name = $1, driver = $2, host = $3, port = $4, device_path = $5, dpi = $6,
+		 label_width = $7, label_length = $8, mill = $9, location = $10, is_default = $11, is_active = $12,
+		 language = $13, media_profile_id = $14, updated_at = NOW() WHERE id = $15`,
+		req.Name, req.Driver, req.Host, req.Port, req.DevicePath, req.DPI, req.LabelWidth, req.LabelLength,
+		req.Mill, req.Location, req.IsDefault, isActive, req.Language, req.MediaProfileID, printerUUID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update printer", "details": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	utils.LogAudit(c, userModel.ID, "update_printer", "printers", &printerID, "Printer updated by admin")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Printer updated successfully"})
+}
+
+// DeletePrinter removes a registered printer (admin only)
+func DeletePrinter(c *gin.Context) {
+	printerID := c.Param("id")
+	printerUUID, err := uuid.Parse(printerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid printer ID"})
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM printers WHERE id = $1", printerUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete printer", "details": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	utils.LogAudit(c, userModel.ID, "delete_printer", "printers", &printerID, "Printer deleted by admin")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Printer deleted successfully"})
+}
+
+// GetPrinterStatus returns the last polled ~HS status of a printer.
+// Pass ?refresh=true to query the printer immediately.
+func GetPrinterStatus(c *gin.Context) {
+	printerUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid printer ID"})
+		return
+	}
+
+	p, err := printer.Get(printerUUID)
+	if err == printer.ErrPrinterNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch printer", "details": err.Error()})
+		return
+	}
+
+	poller := printerPoller
+	if poller == nil {
+		// Status polling is disabled; query on demand without caching in the background
+		poller = printer.NewPoller(0)
+	}
+
+	status, ok := poller.Status(p.ID)
+	if !ok || c.Query("refresh") == "true" {
+		status = poller.Check(c.Request.Context(), p)
+	}
+
+	c.JSON(http.StatusOK, status)
+}
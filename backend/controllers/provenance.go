@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/templates"
+	"labelops-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// VerifyPrintJob re-renders a print job with the label version, template version,
+// QR settings, copies, marks and media it was printed with and checks the output
+// against the stored hash. Jobs for EPL2 and TSPL printers are re-rendered from
+// the QCIN layout in their language. Jobs queued before their QR settings were
+// recorded are re-rendered with the template's and plant's current ones.
+func VerifyPrintJob(c *gin.Context) {
+	jobUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid print job ID"})
+		return
+	}
+
+	var (
+		labelUUID       uuid.UUID
+		labelVersion    int
+		zplContent      sql.NullString
+		zplHash         sql.NullString
+		templateID      uuid.NullUUID
+		templateVersion sql.NullInt64
+		language        string
+		media           []byte
+		qrURLFormat     sql.NullString
+		qrDataFormat    sql.NullString
+		qrSignAlg       sql.NullString
+		job             layout.Job
+	)
+	err = db.DB.QueryRow(`
+		SELECT label_id, label_version, zpl_content, zpl_hash, template_id, template_version, language,
+		       copies, serialized, reprint_no, media, qr_url_format, qr_data_format, qr_sign_alg
+		FROM print_jobs WHERE id = $1
+	`, jobUUID).Scan(&labelUUID, &labelVersion, &zplContent, &zplHash, &templateID, &templateVersion, &language,
+		&job.Copies, &job.Serial, &job.Reprint, &media, &qrURLFormat, &qrDataFormat, &qrSignAlg)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Print job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch print job", "details": err.Error()})
+		return
+	}
+	if len(media) > 0 {
+		job.Media = &layout.Media{}
+		if err := json.Unmarshal(media, job.Media); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode print job media", "details": err.Error()})
+			return
+		}
+	}
+	fromLayout := language != models.PrinterLanguageZPL
+	if !zplHash.Valid || (!fromLayout && (!templateID.Valid || !templateVersion.Valid)) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Print job has no template provenance to verify"})
+		return
+	}
+
+	// Amended labels are re-rendered as they were when the job was queued
+	label, err := labelrender.Load(labelUUID)
+	if err == nil {
+		label, err = labelrender.AtVersion(label, labelVersion)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label", "details": err.Error()})
+		return
+	}
+
+	var qr *templates.QRSettings
+	if qrDataFormat.Valid {
+		qr = &templates.QRSettings{
+			Format:  templates.QRFormat{URL: qrURLFormat.String, Data: qrDataFormat.String},
+			SignAlg: qrSignAlg.String,
+		}
+	}
+
+	var rendered templates.Rendered
+	if fromLayout {
+		// Jobs queued before layout jobs recorded their template version printed
+		// the QCIN layout with the plant's QR formats
+		var backend layout.Backend
+		if backend, err = layout.For(language); err == nil && templateID.Valid && templateVersion.Valid {
+			rendered, err = templates.RenderVersionLayout(backend, templateID.UUID, int(templateVersion.Int64), label, job, qr)
+		} else if err == nil {
+			rendered, err = templates.RenderLayout(backend, templates.BuiltinName, label, templates.Options{Job: job, QRRecorded: qr})
+		}
+	} else {
+		rendered, err = templates.RenderVersion(templateID.UUID, int(templateVersion.Int64), label, job, qr)
+	}
+	if err == templates.ErrVersionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version not found"})
+		return
+	}
+	if errors.Is(err, templates.ErrSignerChanged) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Print job was signed with a QR signing algorithm that is no longer configured",
+			"details": fmt.Sprintf("recorded algorithm %s", qrSignAlg.String),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to render template", "details": err.Error()})
+		return
+	}
+
+	// The stored commands must also still match their hash, or the job record was altered
+	storedMatches := templates.Hash(zplContent.String) == zplHash.String
+	renderMatches := rendered.Hash == zplHash.String
+
+	c.JSON(http.StatusOK, gin.H{
+		"print_job_id":     jobUUID,
+		"label_version":    labelVersion,
+		"language":         rendered.Language,
+		"template_id":      templateID,
+		"template_name":    rendered.TemplateName,
+		"template_version": rendered.Version,
+		"qr_sign_alg":      rendered.QR.SignAlg,
+		"zpl_hash":         zplHash.String,
+		"rendered_hash":    rendered.Hash,
+		"stored_matches":   storedMatches,
+		"render_matches":   renderMatches,
+		"verified":         storedMatches && renderMatches,
+	})
+}
@@ -0,0 +1,610 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/assets"
+	"labelops-backend/internal/templates"
+	"labelops-backend/internal/zpl/lint"
+	"labelops-backend/models"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// validateTemplateRequest checks the selection rules of a template request
+func validateTemplateRequest(req *models.LabelTemplateRequest) error {
+	for i, r := range req.Rules {
+		empty := func(s *string) bool { return s == nil || strings.TrimSpace(*s) == "" }
+		if empty(r.ProductHeading) && empty(r.Mill) && empty(r.Section) {
+			return fmt.Errorf("rule %d must set product_heading, mill or section", i+1)
+		}
+	}
+	return nil
+}
+
+// lintTemplateBody checks a GS1 AI mapping and QR formats, renders a template body
+// with sample data and the latest assets and lints the ZPL, writing a 400 response when any does not pass
+func lintTemplateBody(c *gin.Context, name, body string, opts templates.Options) bool {
+	if err := templates.ValidateGS1(opts.GS1); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := templates.ValidateQRFormat(opts.QR); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	opts.Assets = assets.LatestLookup
+	issues := templates.Lint(name, body, opts, lint.Options{})
+	if len(issues) == 0 {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Template failed validation", "issues": issues})
+	return false
+}
+
+// nilIfBlank trims s and maps an empty value to NULL
+func nilIfBlank(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	v := strings.TrimSpace(*s)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// saveTemplate writes the template fields, replaces its rules and keeps a single default
+func saveTemplate(tx *sql.Tx, id uuid.UUID, req models.LabelTemplateRequest) error {
+	if req.IsDefault {
+		if _, err := tx.Exec(`UPDATE label_templates SET is_default = false WHERE id <> $1 AND is_default`, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM label_template_rules WHERE template_id = $1`, id); err != nil {
+		return err
+	}
+	for _, r := range req.Rules {
+		_, err := tx.Exec(`
+			INSERT INTO label_template_rules (template_id, product_heading, mill, section, priority)
+			VALUES ($1, $2, $3, $4, $5)
+		`, id, nilIfBlank(r.ProductHeading), nilIfBlank(r.Mill), nilIfBlank(r.Section), r.Priority)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTemplates lists all label templates with their selection rules (admin only)
+func GetTemplates(c *gin.Context) {
+	list, err := templates.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": list, "count": len(list)})
+}
+
+// GetTemplateByID retrieves a label template (admin only)
+func GetTemplateByID(c *gin.Context) {
+	templateUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	t, err := templates.Get(templateUUID)
+	if err == templates.ErrTemplateNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// ValidateTemplate lints a template body, or raw ZPL, without saving anything (admin only).
+// Template bodies are rendered with sample label data first.
+func ValidateTemplate(c *gin.Context) {
+	var req models.TemplateValidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Body == "") == (req.ZPL == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either body or zpl"})
+		return
+	}
+
+	opts := lint.Options{DPI: req.DPI}
+	var issues []lint.Issue
+	if req.Body != "" {
+		name := req.Name
+		if name == "" {
+			name = "template"
+		}
+		tmplOpts := templates.Options{GS1: req.GS1AIs, Assets: assets.LatestLookup}
+		if req.QRURLFormat != nil {
+			tmplOpts.QR.URL = *req.QRURLFormat
+		}
+		if req.QRDataFormat != nil {
+			tmplOpts.QR.Data = *req.QRDataFormat
+		}
+		if err := templates.ValidateGS1(tmplOpts.GS1); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := templates.ValidateQRFormat(tmplOpts.QR); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		issues = templates.Lint(name, req.Body, tmplOpts, opts)
+	} else {
+		issues = lint.Lint([]byte(req.ZPL), opts)
+	}
+	if issues == nil {
+		issues = []lint.Issue{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":  len(issues) == 0,
+		"issues": issues,
+		"count":  len(issues),
+	})
+}
+
+// CreateTemplate stores a new label template and its selection rules (admin only)
+func CreateTemplate(c *gin.Context) {
+	var req models.LabelTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body is required"})
+		return
+	}
+	versionReq := models.LabelTemplateVersionRequest{
+		Body:         req.Body,
+		GS1AIs:       req.GS1AIs,
+		QRURLFormat:  req.QRURLFormat,
+		QRDataFormat: req.QRDataFormat,
+	}
+	if !lintTemplateBody(c, req.Name, req.Body, templates.VersionOptions(models.LabelTemplateVersion{}, versionReq)) {
+		return
+	}
+	if err := validateTemplateRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var id uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO label_templates (name, description, is_default, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, req.Name, req.Description, req.IsDefault, isActive, userModel.ID).Scan(&id)
+	if err == nil {
+		err = saveTemplate(tx, id, req)
+	}
+	if err == nil {
+		_, err = templates.CreateVersion(tx, id, versionReq, &userModel.ID, req.Publish)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template", "details": err.Error()})
+		return
+	}
+
+	t, err := templates.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template", "details": err.Error()})
+		return
+	}
+
+	idStr := id.String()
+	utils.LogAudit(c, userModel.ID, "create_template", "label_templates", &idStr, "Label template created by admin",
+		map[string]interface{}{"name": t.Name, "rules": len(t.Rules), "published": req.Publish})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Template created successfully",
+		"template": t,
+	})
+}
+
+// UpdateTemplate updates a label template's settings and replaces its rules (admin only).
+// The body is changed through template versions.
+func UpdateTemplate(c *gin.Context) {
+	templateID := c.Param("id")
+	templateUUID, err := uuid.Parse(templateID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	var req models.LabelTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateTemplateRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := templates.Get(templateUUID)
+	if err == templates.ErrTemplateNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template", "details": err.Error()})
+		return
+	}
+	if existing.Name == templates.BuiltinName && req.Name != templates.BuiltinName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The built-in template cannot be renamed"})
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE label_templates
+		SET name = $1, description = $2, is_default = $3, is_active = $4, updated_at = NOW()
+		WHERE id = $5
+	`, req.Name, req.Description, req.IsDefault, isActive, templateUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template", "details": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err := saveTemplate(tx, templateUUID, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template rules", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template", "details": err.Error()})
+		return
+	}
+
+	t, err := templates.Get(templateUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template", "details": err.Error()})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	utils.LogAudit(c, userModel.ID, "update_template", "label_templates", &templateID, "Label template updated by admin",
+		map[string]interface{}{"name": t.Name, "rules": len(t.Rules)})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Template updated successfully",
+		"template": t,
+	})
+}
+
+// DeleteTemplate removes a label template and its rules (admin only)
+func DeleteTemplate(c *gin.Context) {
+	templateID := c.Param("id")
+	templateUUID, err := uuid.Parse(templateID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM label_templates WHERE id = $1", templateUUID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Template has been used for printing and cannot be deleted; deactivate it or retire its versions instead",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template", "details": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	utils.LogAudit(c, userModel.ID, "delete_template", "label_templates", &templateID, "Label template deleted by admin")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// templateVersionParams parses the template ID and version path parameters,
+// writing a 400 response when either is invalid
+func templateVersionParams(c *gin.Context) (uuid.UUID, int, bool) {
+	templateUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return uuid.Nil, 0, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template version"})
+		return uuid.Nil, 0, false
+	}
+	return templateUUID, version, true
+}
+
+// respondTemplateVersionError maps registry errors onto HTTP responses
+func respondTemplateVersionError(c *gin.Context, action string, err error) {
+	switch err {
+	case templates.ErrTemplateNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+	case templates.ErrVersionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version not found"})
+	case templates.ErrVersionImmutable:
+		c.JSON(http.StatusConflict, gin.H{"error": "Only draft versions can be edited; create a new version instead"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action, "details": err.Error()})
+	}
+}
+
+// GetTemplateVersions lists every version of a label template (admin only)
+func GetTemplateVersions(c *gin.Context) {
+	templateUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+	if _, err := templates.Get(templateUUID); err != nil {
+		respondTemplateVersionError(c, "fetch template", err)
+		return
+	}
+
+	versions, err := templates.Versions(templateUUID)
+	if err != nil {
+		respondTemplateVersionError(c, "fetch template versions", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions, "count": len(versions)})
+}
+
+// GetTemplateVersion retrieves one version of a label template (admin only)
+func GetTemplateVersion(c *gin.Context) {
+	templateUUID, version, ok := templateVersionParams(c)
+	if !ok {
+		return
+	}
+
+	v, err := templates.GetVersion(templateUUID, version)
+	if err != nil {
+		respondTemplateVersionError(c, "fetch template version", err)
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// CreateTemplateVersion adds a new draft version to a label template,
+// publishing it immediately when ?publish=true (admin only)
+func CreateTemplateVersion(c *gin.Context) {
+	templateID := c.Param("id")
+	templateUUID, err := uuid.Parse(templateID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	var req models.LabelTemplateVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	t, err := templates.Get(templateUUID)
+	if err != nil {
+		respondTemplateVersionError(c, "fetch template", err)
+		return
+	}
+	if t.Name == templates.BuiltinName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The built-in template is managed by the server; create a new template instead"})
+		return
+	}
+
+	// Settings the request omits are kept from the latest version, so lint with those
+	var latest models.LabelTemplateVersion
+	if t.LatestVersion > 0 {
+		v, err := templates.GetVersion(templateUUID, t.LatestVersion)
+		if err != nil {
+			respondTemplateVersionError(c, "fetch template version", err)
+			return
+		}
+		latest = v
+	}
+	if !lintTemplateBody(c, t.Name, req.Body, templates.VersionOptions(latest, req)) {
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	publish := c.Query("publish") == "true"
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		respondTemplateVersionError(c, "create template version", err)
+		return
+	}
+	defer tx.Rollback()
+
+	version, err := templates.CreateVersion(tx, templateUUID, req, &userModel.ID, publish)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondTemplateVersionError(c, "create template version", err)
+		return
+	}
+
+	v, err := templates.GetVersion(templateUUID, version)
+	if err != nil {
+		respondTemplateVersionError(c, "fetch template version", err)
+		return
+	}
+
+	utils.LogAudit(c, userModel.ID, "create_template_version", "label_templates", &templateID, "Label template version created by admin",
+		map[string]interface{}{"name": t.Name, "version": version, "published": publish, "gs1_ais": v.GS1AIs,
+			"qr_url_format": v.QRURLFormat, "qr_data_format": v.QRDataFormat})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Template version created successfully",
+		"version": v,
+	})
+}
+
+// UpdateTemplateVersion replaces the body, and GS1 mapping and QR formats if given, of a draft version (admin only).
+// Published and retired versions are immutable.
+func UpdateTemplateVersion(c *gin.Context) {
+	templateUUID, version, ok := templateVersionParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.LabelTemplateVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	t, err := templates.Get(templateUUID)
+	if err != nil {
+		respondTemplateVersionError(c, "fetch template", err)
+		return
+	}
+
+	existing, err := templates.GetVersion(templateUUID, version)
+	if err != nil {
+		respondTemplateVersionError(c, "fetch template version", err)
+		return
+	}
+	if !lintTemplateBody(c, t.Name, req.Body, templates.VersionOptions(existing, req)) {
+		return
+	}
+
+	if err := templates.UpdateDraft(templateUUID, version, req); err != nil {
+		respondTemplateVersionError(c, "update template version", err)
+		return
+	}
+
+	v, err := templates.GetVersion(templateUUID, version)
+	if err != nil {
+		respondTemplateVersionError(c, "fetch template version", err)
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	templateID := templateUUID.String()
+	utils.LogAudit(c, userModel.ID, "update_template_version", "label_templates", &templateID, "Draft template version updated by admin",
+		map[string]interface{}{"name": t.Name, "version": version})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Template version updated successfully",
+		"version": v,
+	})
+}
+
+// PublishTemplateVersion makes a version the one used for printing and retires
+// the previously published version (admin only)
+func PublishTemplateVersion(c *gin.Context) {
+	templateUUID, version, ok := templateVersionParams(c)
+	if !ok {
+		return
+	}
+
+	if err := templates.Publish(templateUUID, version); err != nil {
+		respondTemplateVersionError(c, "publish template version", err)
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	templateID := templateUUID.String()
+	utils.LogAudit(c, userModel.ID, "publish_template_version", "label_templates", &templateID, "Template version published by admin",
+		map[string]interface{}{"version": version})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template version published successfully"})
+}
+
+// RetireTemplateVersion takes a version out of use (admin only). Retired
+// versions are kept so past print jobs can still be verified.
+func RetireTemplateVersion(c *gin.Context) {
+	templateUUID, version, ok := templateVersionParams(c)
+	if !ok {
+		return
+	}
+
+	if err := templates.Retire(templateUUID, version); err != nil {
+		respondTemplateVersionError(c, "retire template version", err)
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+	templateID := templateUUID.String()
+	utils.LogAudit(c, userModel.ID, "retire_template_version", "label_templates", &templateID, "Template version retired by admin",
+		map[string]interface{}{"version": version})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template version retired successfully"})
+}
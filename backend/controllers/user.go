@@ -146,92 +146,31 @@ func DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
-// GetDashboardStats retrieves comprehensive dashboard statistics
+// GetDashboardStats retrieves comprehensive dashboard statistics, derived from
+// label_events. Voided labels and cancelled print jobs are left out unless
+// ?include_voided=true.
 func GetDashboardStats(c *gin.Context) {
-	// Get basic label counts
-	var totalLabels, printedLabels, pendingLabels, failedLabels, duplicateLabels int
-
-	// Total labels count
-	err := db.DB.QueryRow("SELECT COUNT(*) FROM labels").Scan(&totalLabels)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get total labels count"})
-		return
-	}
-
-	// Printed labels count (status = 'printed')
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM labels WHERE status = 'success'").Scan(&printedLabels)
+	stats, err := labelStats(includeVoided(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get printed labels count"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get label statistics", "details": err.Error()})
 		return
 	}
 
-	// Pending labels count (status = 'pending')
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM labels WHERE status = 'pending'").Scan(&pendingLabels)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get pending labels count"})
-		return
-	}
-
-	// Failed labels count (status = 'failed')
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM labels WHERE status = 'failed'").Scan(&failedLabels)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get failed labels count"})
-		return
-	}
-
-	// Duplicate labels count
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM labels WHERE is_duplicate = true").Scan(&duplicateLabels)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get duplicate labels count"})
-		return
+	jobsCounted := "status <> 'cancelled'"
+	if includeVoided(c) {
+		jobsCounted = "TRUE"
 	}
 
-	// Get labels by grade
-	gradeRows, err := db.DB.Query("SELECT grade, COUNT(*) FROM labels GROUP BY grade ORDER BY COUNT(*) DESC LIMIT 10")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get labels by grade"})
-		return
-	}
-	defer gradeRows.Close()
-
-	byGrade := make(map[string]int)
-	for gradeRows.Next() {
-		var grade string
-		var count int
-		if err := gradeRows.Scan(&grade, &count); err != nil {
-			continue
-		}
-		byGrade[grade] = count
-	}
-
-	// Get labels by section
-	sectionRows, err := db.DB.Query("SELECT section, COUNT(*) FROM labels GROUP BY section ORDER BY COUNT(*) DESC LIMIT 10")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get labels by section"})
-		return
-	}
-	defer sectionRows.Close()
-
-	bySection := make(map[string]int)
-	for sectionRows.Next() {
-		var section string
-		var count int
-		if err := sectionRows.Scan(&section, &count); err != nil {
-			continue
-		}
-		bySection[section] = count
-	}
-
-	// Get recent activity (labels created in last 24 hours)
+	// Get recent activity (labels received in last 24 hours)
 	var recentLabels int
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM labels WHERE created_at >= NOW() - INTERVAL '24 hours'").Scan(&recentLabels)
+	err = db.DB.QueryRow("SELECT COUNT(*) FROM label_events WHERE to_status = 'received' AND created_at >= NOW() - INTERVAL '24 hours'").Scan(&recentLabels)
 	if err != nil {
 		recentLabels = 0 // Default to 0 if query fails
 	}
 
 	// Get print success rate
 	var totalPrintJobs, successfulPrintJobs int
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM print_jobs").Scan(&totalPrintJobs)
+	err = db.DB.QueryRow("SELECT COUNT(*) FROM print_jobs WHERE " + jobsCounted).Scan(&totalPrintJobs)
 	if err != nil {
 		totalPrintJobs = 0
 	}
@@ -248,7 +187,7 @@ func GetDashboardStats(c *gin.Context) {
 
 	// Get active users count
 	var activeUsers int
-	err = db.DB.QueryRow("SELECT COUNT(DISTINCT user_id) FROM labels WHERE created_at >= NOW() - INTERVAL '7 days'").Scan(&activeUsers)
+	err = db.DB.QueryRow("SELECT COUNT(DISTINCT actor_id) FROM label_events WHERE created_at >= NOW() - INTERVAL '7 days'").Scan(&activeUsers)
 	if err != nil {
 		activeUsers = 0
 	}
@@ -256,15 +195,19 @@ func GetDashboardStats(c *gin.Context) {
 	// Create comprehensive dashboard response
 	dashboardStats := gin.H{
 		"overview": gin.H{
-			"total_labels":     totalLabels,
-			"printed_labels":   printedLabels,
-			"pending_labels":   pendingLabels,
-			"failed_labels":    failedLabels,
-			"duplicate_labels": duplicateLabels,
+			"total_labels":      stats.TotalLabels,
+			"printed_labels":    stats.PrintedLabels,
+			"pending_labels":    stats.PendingLabels,
+			"failed_labels":     stats.FailedLabels,
+			"reprinted_labels":  stats.ReprintedLabels,
+			"dispatched_labels": stats.DispatchedLabels,
+			"duplicate_labels":  stats.DuplicateLabels,
+			"voided_labels":     stats.VoidedLabels,
 		},
 		"breakdown": gin.H{
-			"by_grade":   byGrade,
-			"by_section": bySection,
+			"by_status":  stats.ByStatus,
+			"by_grade":   stats.ByGrade,
+			"by_section": stats.BySection,
 		},
 		"activity": gin.H{
 			"recent_labels_24h": recentLabels,
@@ -290,9 +233,9 @@ func GetSystemStats(c *gin.Context) {
 		return
 	}
 
-	// Get total labels
+	// Get total labels, leaving out voided ones
 	var totalLabels int
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM labels").Scan(&totalLabels)
+	err = db.DB.QueryRow("SELECT COUNT(*) FROM labels WHERE status <> 'voided'").Scan(&totalLabels)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get label count"})
 		return
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/qrsign"
+	"labelops-backend/internal/templates"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// VerifyQRPayload authenticates a scanned lower QR payload and returns the label
+// it was printed for. The endpoint is public so customers can check bundles.
+func VerifyQRPayload(c *gin.Context) {
+	scanned := c.Query("payload")
+	if scanned == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload is required"})
+		return
+	}
+
+	// The QCIN layout prints the payload after a D (automatic data) mode character;
+	// scanners may or may not strip it
+	candidates := []string{scanned}
+	if strings.HasPrefix(scanned, "D") {
+		candidates = append(candidates, scanned[1:])
+	}
+
+	signer := templates.Signer()
+	var (
+		jobID        uuid.UUID
+		labelUUID    uuid.UUID
+		labelVersion int
+		signed       bool
+		found        bool
+	)
+	for _, candidate := range candidates {
+		payload, sig, err := qrsign.Split(candidate)
+		if err != nil {
+			continue
+		}
+		err = db.DB.QueryRow(`
+			SELECT id, label_id, label_version FROM print_jobs
+			WHERE qr_hash = $1
+			ORDER BY created_at DESC
+			LIMIT 1
+		`, templates.Hash(payload)).Scan(&jobID, &labelUUID, &labelVersion)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify payload", "details": err.Error()})
+			return
+		}
+		if signer != nil && (sig == nil || !signer.Verify([]byte(payload), sig)) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"verified": false, "error": "Payload signature is missing or invalid"})
+			return
+		}
+		signed, found = sig != nil, true
+		break
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"verified": false, "error": "No label was printed with this payload"})
+		return
+	}
+
+	current, err := labelrender.Load(labelUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"verified": false, "error": "Label not found"})
+		return
+	}
+	// Report the label as printed; a later amendment supersedes it
+	label := current
+	if err == nil {
+		label, err = labelrender.AtVersion(current, labelVersion)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch label", "details": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"verified":     true,
+		"signed":       signed,
+		"print_job_id": jobID,
+		"superseded":   labelVersion < current.Version,
+		"label": gin.H{
+			"id":              label.ID,
+			"label_id":        label.LabelID,
+			"bundle_no":       label.BundleNo,
+			"heat_no":         label.HeatNo,
+			"product_heading": label.ProductHeading,
+			"section":         label.Section,
+			"grade":           label.Grade,
+			"mill":            label.Mill,
+			"unit":            label.Unit,
+			"length":          label.Length,
+			"weight":          label.Weight,
+			"location":        label.Location,
+			"pqd":             label.PQD,
+			"date":            label.Date,
+			"time":            label.Time,
+			"status":          label.Status,
+			"version":         labelVersion,
+			"current_version": current.Version,
+		},
+	}
+	if signer != nil {
+		response["algorithm"] = signer.Algorithm()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetQRPublicKey returns the Ed25519 public key customers verify payloads with
+func GetQRPublicKey(c *gin.Context) {
+	signer := templates.Signer()
+	if signer == nil || signer.PublicKey() == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payloads are not signed with a public key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  signer.Algorithm(),
+		"public_key": base64.StdEncoding.EncodeToString(signer.PublicKey()),
+	})
+}
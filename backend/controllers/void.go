@@ -0,0 +1,439 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/dispatcher"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/lifecycle"
+	"labelops-backend/models"
+	"labelops-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// errLabelVoided is returned when voiding a label that is already voided
+var errLabelVoided = errors.New("label is already voided")
+
+const labelVoidColumns = `id, label_id, reason_code, note, print_void_label, status, requested_by,
+	decided_by, decision_note, void_print_job_id, created_at, decided_at`
+
+func scanLabelVoid(row rowScanner) (models.LabelVoid, error) {
+	var v models.LabelVoid
+	err := row.Scan(&v.ID, &v.LabelID, &v.ReasonCode, &v.Note, &v.PrintVoidLabel, &v.Status, &v.RequestedBy,
+		&v.DecidedBy, &v.DecisionNote, &v.VoidPrintJobID, &v.CreatedAt, &v.DecidedAt)
+	return v, err
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// voided is what voiding a label did to its print jobs
+type voided struct {
+	CancelledJobIDs []uuid.UUID `json:"cancelled_job_ids"`
+	InFlightJobIDs  []uuid.UUID `json:"in_flight_job_ids"` // already being sent to the printer
+	VoidPrintJobID  *uuid.UUID  `json:"void_print_job_id"`
+}
+
+// voidLabel marks a label voided in tx and cancels its print jobs that have not
+// been sent, including jobs the dispatcher has claimed but not started. Jobs
+// already printing cannot be recalled and are reported as in flight. When v
+// asks for it, a VOID label is queued on the label's printer.
+func voidLabel(tx *sql.Tx, v models.LabelVoid, userID uuid.UUID) (voided, error) {
+	res := voided{CancelledJobIDs: []uuid.UUID{}, InFlightJobIDs: []uuid.UUID{}}
+	label, err := lockLabel(tx, v.LabelID)
+	if err != nil {
+		return res, err
+	}
+	if label.Status == models.LabelStatusVoided {
+		return res, errLabelVoided
+	}
+	if _, err := lifecycle.Advance(tx, label.ID, lifecycle.To(models.LabelStatusVoided),
+		lifecycle.Event{ActorID: &userID, Note: v.ReasonCode}); err != nil {
+		return res, err
+	}
+
+	rows, err := tx.Query(`
+		UPDATE print_jobs SET status = $2, error_message = 'label voided', next_attempt_at = NULL, updated_at = NOW()
+		WHERE label_id = $1 AND status IN ($3, $4, $5, $6, $7)
+		RETURNING id
+	`, label.ID, dispatcher.StatusCancelled, dispatcher.StatusPending, dispatcher.StatusQueued,
+		dispatcher.StatusFailed, dispatcher.StatusRetrying, dispatcher.StatusDead)
+	if err != nil {
+		return res, err
+	}
+	res.CancelledJobIDs, err = scanIDs(rows)
+	if err != nil {
+		return res, err
+	}
+	rows, err = tx.Query(`SELECT id FROM print_jobs WHERE label_id = $1 AND status = $2`,
+		label.ID, dispatcher.StatusPrinting)
+	if err != nil {
+		return res, err
+	}
+	res.InFlightJobIDs, err = scanIDs(rows)
+	if err != nil {
+		return res, err
+	}
+
+	if !v.PrintVoidLabel {
+		return res, nil
+	}
+	printerID, language, media, err := resolvePrinter(label)
+	if err != nil {
+		return res, err
+	}
+	backend, err := layout.For(language)
+	if err != nil {
+		return res, err
+	}
+	job := layout.Job{Media: media}
+	out, err := backend.Encode(layout.QCINJob(layout.Void(layout.VoidValues{
+		LabelID: label.LabelID,
+		HeatNo:  label.HeatNo,
+		Reason:  strings.ToUpper(strings.ReplaceAll(v.ReasonCode, "_", " ")),
+		Voided:  strings.ToUpper(time.Now().Format("02-Jan-06 15:04")),
+	}), job))
+	if err != nil {
+		return res, renderError{err}
+	}
+	jobID := uuid.New()
+	_, err = tx.Exec(`
+		INSERT INTO print_jobs (id, label_id, heat_no, actual_label_id, label_version, user_id, status, zpl_content,
+		                        max_retries, printer_id, language, kind, media)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'void', $12)
+	`, jobID, label.ID, label.HeatNo, label.LabelID, label.Version, userID, dispatcher.StatusPending, out,
+		3, printerID, backend.Language(), jobMedia(job))
+	if err != nil {
+		return res, err
+	}
+	res.VoidPrintJobID = &jobID
+	return res, nil
+}
+
+func scanIDs(rows *sql.Rows) ([]uuid.UUID, error) {
+	defer rows.Close()
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// includeVoided reports whether ?include_voided=true asks for voided labels,
+// which stats and exports leave out by default
+func includeVoided(c *gin.Context) bool {
+	include, _ := strconv.ParseBool(c.Query("include_voided"))
+	return include
+}
+
+// voidFailed writes the response for a failure to void a label
+func voidFailed(c *gin.Context, err error) {
+	var (
+		renderErr     renderError
+		transitionErr *lifecycle.TransitionError
+	)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+	case err == errLabelVoided:
+		c.JSON(http.StatusConflict, gin.H{"error": "Label is already voided"})
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": "Label cannot be voided", "details": err.Error()})
+	case errors.As(err, &renderErr):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render VOID label", "details": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to void label", "details": err.Error()})
+	}
+}
+
+// RequestLabelVoid asks for a label to be voided, with a reason code, a note
+// (required for reason other) and whether to print a VOID label. A supervisor
+// or admin's request voids the label at once; anyone else's waits for one of
+// them to approve it.
+func RequestLabelVoid(c *gin.Context) {
+	labelUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+	var request struct {
+		ReasonCode     string `json:"reason_code" binding:"required"`
+		Note           string `json:"note"`
+		PrintVoidLabel bool   `json:"print_void_label"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if !models.ValidVoidReason(request.ReasonCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason_code must be mislabeled, damaged, data_error, duplicate, scrapped or other"})
+		return
+	}
+	v := models.LabelVoid{LabelID: labelUUID, ReasonCode: request.ReasonCode, PrintVoidLabel: request.PrintVoidLabel}
+	if note := strings.TrimSpace(request.Note); note != "" {
+		v.Note = &note
+	}
+	if v.ReasonCode == models.VoidReasonOther && v.Note == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A note is required for reason other"})
+		return
+	}
+
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		voidFailed(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var (
+		res     voided
+		message string
+	)
+	if userModel.IsSupervisor() {
+		if res, err = voidLabel(tx, v, userModel.ID); err != nil {
+			voidFailed(c, err)
+			return
+		}
+		v, err = scanLabelVoid(tx.QueryRow(`
+			INSERT INTO label_voids (label_id, reason_code, note, print_void_label, status, requested_by,
+			                         decided_by, void_print_job_id, decided_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $6, $7, NOW())
+			RETURNING `+labelVoidColumns,
+			v.LabelID, v.ReasonCode, v.Note, v.PrintVoidLabel, models.VoidStatusApproved, userModel.ID, res.VoidPrintJobID))
+		message = "Label voided"
+	} else {
+		var status string
+		err = tx.QueryRow(`SELECT status FROM labels WHERE id = $1`, labelUUID).Scan(&status)
+		if err == nil && status == models.LabelStatusVoided {
+			err = errLabelVoided
+		} else if err == nil && !lifecycle.Allowed(status, models.LabelStatusVoided) {
+			err = &lifecycle.TransitionError{From: status, To: models.LabelStatusVoided}
+		}
+		if err != nil {
+			voidFailed(c, err)
+			return
+		}
+		v, err = scanLabelVoid(tx.QueryRow(`
+			INSERT INTO label_voids (label_id, reason_code, note, print_void_label, requested_by)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+labelVoidColumns,
+			v.LabelID, v.ReasonCode, v.Note, v.PrintVoidLabel, userModel.ID))
+		message = "Void request awaiting supervisor approval"
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": "A void request for this label is already pending"})
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		voidFailed(c, err)
+		return
+	}
+
+	idStr := v.ID.String()
+	if v.Status == models.VoidStatusApproved {
+		utils.LogAudit(c, userModel.ID, "void_label", "label_voids", &idStr, "Label voided by supervisor",
+			map[string]interface{}{"label_id": v.LabelID, "reason_code": v.ReasonCode, "note": v.Note,
+				"cancelled_job_ids": res.CancelledJobIDs, "void_print_job_id": res.VoidPrintJobID})
+		c.JSON(http.StatusOK, gin.H{"message": message, "void": v, "jobs": res})
+		return
+	}
+	utils.LogAudit(c, userModel.ID, "request_void", "label_voids", &idStr, "Label void requested",
+		map[string]interface{}{"label_id": v.LabelID, "reason_code": v.ReasonCode, "note": v.Note})
+	c.JSON(http.StatusAccepted, gin.H{"message": message, "void": v})
+}
+
+// GetVoidRequests lists void requests, pending ones by default (?status=),
+// newest first (supervisor or admin)
+func GetVoidRequests(c *gin.Context) {
+	status := c.DefaultQuery("status", models.VoidStatusPending)
+	rows, err := db.DB.Query(`
+		SELECT v.id, v.label_id, v.reason_code, v.note, v.print_void_label, v.status, v.requested_by,
+		       v.decided_by, v.decision_note, v.void_print_job_id, v.created_at, v.decided_at,
+		       l.label_id, l.heat_no
+		FROM label_voids v
+		JOIN labels l ON l.id = v.label_id
+		WHERE v.status = $1
+		ORDER BY v.created_at DESC
+	`, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch void requests", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	type voidRequest struct {
+		models.LabelVoid
+		ActualLabelID string `json:"actual_label_id"`
+		HeatNo        string `json:"heat_no"`
+	}
+	list := []voidRequest{}
+	for rows.Next() {
+		var r voidRequest
+		v := &r.LabelVoid
+		if err := rows.Scan(&v.ID, &v.LabelID, &v.ReasonCode, &v.Note, &v.PrintVoidLabel, &v.Status, &v.RequestedBy,
+			&v.DecidedBy, &v.DecisionNote, &v.VoidPrintJobID, &v.CreatedAt, &v.DecidedAt,
+			&r.ActualLabelID, &r.HeatNo); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read void request", "details": err.Error()})
+			return
+		}
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch void requests", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"void_requests": list, "count": len(list)})
+}
+
+// decisionNote reads the optional note of an approval or rejection
+func decisionNote(c *gin.Context) (*string, bool) {
+	var request struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return nil, false
+		}
+	}
+	if note := strings.TrimSpace(request.Note); note != "" {
+		return &note, true
+	}
+	return nil, true
+}
+
+// pendingVoid locks a pending void request in tx, writing an error response when it cannot
+func pendingVoid(c *gin.Context, tx *sql.Tx) (models.LabelVoid, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid void request ID"})
+		return models.LabelVoid{}, false
+	}
+	v, err := scanLabelVoid(tx.QueryRow(`SELECT `+labelVoidColumns+` FROM label_voids WHERE id = $1 FOR UPDATE`, id))
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Void request not found"})
+		return v, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch void request", "details": err.Error()})
+		return v, false
+	case v.Status != models.VoidStatusPending:
+		c.JSON(http.StatusConflict, gin.H{"error": "Void request was already " + v.Status})
+		return v, false
+	}
+	return v, true
+}
+
+// ApproveVoidRequest approves a pending void request and voids its label
+// (supervisor or admin)
+func ApproveVoidRequest(c *gin.Context) {
+	note, ok := decisionNote(c)
+	if !ok {
+		return
+	}
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		voidFailed(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	v, ok := pendingVoid(c, tx)
+	if !ok {
+		return
+	}
+	res, err := voidLabel(tx, v, userModel.ID)
+	if err != nil {
+		voidFailed(c, err)
+		return
+	}
+	v, err = scanLabelVoid(tx.QueryRow(`
+		UPDATE label_voids
+		SET status = $2, decided_by = $3, decision_note = $4, void_print_job_id = $5, decided_at = NOW()
+		WHERE id = $1
+		RETURNING `+labelVoidColumns,
+		v.ID, models.VoidStatusApproved, userModel.ID, note, res.VoidPrintJobID))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		voidFailed(c, err)
+		return
+	}
+
+	idStr := v.ID.String()
+	utils.LogAudit(c, userModel.ID, "void_label", "label_voids", &idStr, "Label void request approved",
+		map[string]interface{}{"label_id": v.LabelID, "reason_code": v.ReasonCode, "requested_by": v.RequestedBy,
+			"note": note, "cancelled_job_ids": res.CancelledJobIDs, "void_print_job_id": res.VoidPrintJobID})
+	c.JSON(http.StatusOK, gin.H{"message": "Label voided", "void": v, "jobs": res})
+}
+
+// RejectVoidRequest rejects a pending void request; the label is left as it is
+// (supervisor or admin)
+func RejectVoidRequest(c *gin.Context) {
+	note, ok := decisionNote(c)
+	if !ok {
+		return
+	}
+	userModel, ok := getUserFromContext(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject void request", "details": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	v, ok := pendingVoid(c, tx)
+	if !ok {
+		return
+	}
+	v, err = scanLabelVoid(tx.QueryRow(`
+		UPDATE label_voids SET status = $2, decided_by = $3, decision_note = $4, decided_at = NOW()
+		WHERE id = $1
+		RETURNING `+labelVoidColumns,
+		v.ID, models.VoidStatusRejected, userModel.ID, note))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject void request", "details": err.Error()})
+		return
+	}
+
+	idStr := v.ID.String()
+	utils.LogAudit(c, userModel.ID, "reject_void", "label_voids", &idStr, "Label void request rejected",
+		map[string]interface{}{"label_id": v.LabelID, "reason_code": v.ReasonCode, "requested_by": v.RequestedBy, "note": note})
+	c.JSON(http.StatusOK, gin.H{"message": "Void request rejected", "void": v})
+}
@@ -1,2 +1,3 @@
--- Truncate tables with cascade for FK relations
-TRUNCATE audit_logs, print_jobs, labels, users RESTART IDENTITY CASCADE;
+-- Truncate tables with cascade for FK relations, children before the tables they reference
+TRUNCATE audit_logs, idempotency_keys, label_events, label_voids, label_versions, print_jobs, printers, media_profiles,
+	label_assets, label_template_rules, label_template_versions, label_templates, labels, users RESTART IDENTITY CASCADE;
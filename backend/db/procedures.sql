@@ -1,11 +1,24 @@
+-- Superseded by the version taking a duplicate policy below
+DROP FUNCTION IF EXISTS batch_label_process(JSONB, UUID);
+
+-- Stores the labels that are not duplicates. A label is a duplicate of the
+-- newest stored label matching it on match_policy (label_id, composite or
+-- content_hash) created within match_window, or at any time when the window
+-- is NULL. Label IDs are unique, so a reused one is a duplicate whatever the
+-- policy and however old; the window does not apply to label_id. Each duplicate is returned with the stored label it matched. New
+-- labels are received, with the event recording it.
 CREATE OR REPLACE FUNCTION batch_label_process(
 	labels_json JSONB,
-	user_uuid UUID
+	user_uuid UUID,
+	match_policy TEXT DEFAULT 'label_id',
+	match_window INTERVAL DEFAULT NULL
 ) RETURNS JSONB AS $$
 DECLARE
 	label_record JSONB;
 	label_id_val VARCHAR(255);
-	existing_label_id VARCHAR(255);
+	existing labels%ROWTYPE;
+	matched_by TEXT;
+	new_id UUID;
 	new_labels JSONB := '[]'::JSONB;
 	duplicate_labels JSONB := '[]'::JSONB;
 	new_count INTEGER := 0;
@@ -14,14 +27,53 @@ BEGIN
 	FOR label_record IN SELECT * FROM jsonb_array_elements(labels_json)
 	LOOP
 		label_id_val := label_record->>'ID';
+		matched_by := match_policy;
+
+		IF match_policy = 'composite' THEN
+			SELECT * INTO existing FROM labels
+			WHERE pqd = label_record->>'PQD'
+			  AND heat_no = label_record->>'HEAT_NO'
+			  AND bundle_no = ((label_record->>'BUNDLE_NO')::INTEGER)::TEXT
+			  AND (match_window IS NULL OR created_at >= NOW() - match_window)
+			ORDER BY created_at DESC
+			LIMIT 1;
+		ELSIF match_policy = 'content_hash' THEN
+			SELECT * INTO existing FROM labels
+			WHERE content_hash = label_content_hash(
+				label_record->>'LOCATION',
+				((label_record->>'BUNDLE_NO')::INTEGER)::TEXT,
+				label_record->>'PQD',
+				label_record->>'UNIT',
+				label_record->>'TIME',
+				(label_record->>'LENGTH')::INTEGER,
+				label_record->>'HEAT_NO',
+				label_record->>'PRODUCT_HEADING',
+				label_record->>'ISI_BOTTOM',
+				label_record->>'ISI_TOP',
+				label_record->>'CHARGE_DTM',
+				label_record->>'MILL',
+				label_record->>'GRADE',
+				label_record->>'URL_APIKEY',
+				label_record->>'WEIGHT',
+				label_record->>'SECTION',
+				label_record->>'DATE',
+				COALESCE(label_record->>'BUNDLE_TYPE', '')
+			  )
+			  AND (match_window IS NULL OR created_at >= NOW() - match_window)
+			ORDER BY created_at DESC
+			LIMIT 1;
+		ELSE
+			SELECT * INTO existing FROM labels WHERE label_id = label_id_val;
+		END IF;
 
-		SELECT label_id INTO existing_label_id 
-		FROM labels 
-		WHERE label_id = label_id_val;
+		IF NOT FOUND AND match_policy <> 'label_id' THEN
+			matched_by := 'label_id';
+			SELECT * INTO existing FROM labels WHERE label_id = label_id_val;
+		END IF;
 
-		IF existing_label_id IS NULL THEN
+		IF NOT FOUND THEN
 			INSERT INTO labels (
-				label_id, location, bundle_no, pqd, unit, time, length,
+				label_id, location, bundle_no, bundle_type, pqd, unit, time, length,
 				heat_no, product_heading, isi_bottom, isi_top, charge_dtm,
 				mill, grade, url_apikey, weight, section, date, user_id,
 				status, is_duplicate
@@ -29,6 +81,7 @@ BEGIN
 				label_id_val,
 				label_record->>'LOCATION',
 				(label_record->>'BUNDLE_NO')::INTEGER,
+				COALESCE(label_record->>'BUNDLE_TYPE', ''),
 				label_record->>'PQD',
 				label_record->>'UNIT',
 				label_record->>'TIME',
@@ -45,14 +98,20 @@ BEGIN
 				label_record->>'SECTION',
 				label_record->>'DATE',
 				user_uuid,
-				'success',
+				'received',
 				false
-			);
+			) RETURNING id INTO new_id;
+
+			INSERT INTO label_events (label_id, to_status, actor_id) VALUES (new_id, 'received', user_uuid);
 
 			new_labels := new_labels || label_record;
 			new_count := new_count + 1;
 		ELSE
-			duplicate_labels := duplicate_labels || label_record;
+			duplicate_labels := duplicate_labels || jsonb_build_object(
+				'label', label_record,
+				'matched_by', matched_by,
+				'existing', to_jsonb(existing) - 'created_at' - 'updated_at'
+			);
 			duplicate_count := duplicate_count + 1;
 		END IF;
 	END LOOP;
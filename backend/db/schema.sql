@@ -55,6 +55,346 @@ CREATE TABLE IF NOT EXISTS print_jobs (
 	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+CREATE TABLE IF NOT EXISTS printers (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR(100) UNIQUE NOT NULL,
+	driver VARCHAR(20) NOT NULL DEFAULT 'tcp',
+	host VARCHAR(255),
+	port INTEGER NOT NULL DEFAULT 9100,
+	device_path VARCHAR(255),
+	dpi INTEGER NOT NULL DEFAULT 203,
+	label_width INTEGER NOT NULL DEFAULT 812,
+	label_length INTEGER NOT NULL DEFAULT 609,
+	mill VARCHAR(50),
+	location VARCHAR(100),
+	is_default BOOLEAN NOT NULL DEFAULT false,
+	is_active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS printer_id UUID REFERENCES printers(id) ON DELETE SET NULL;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS bytes_sent INTEGER;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS duration_ms INTEGER;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS printed_at TIMESTAMP;
+
+-- Hash of everything stored about a label except its label_id, for the
+-- content_hash duplicate policy of batch_label_process
+CREATE OR REPLACE FUNCTION label_content_hash(
+	TEXT, TEXT, TEXT, TEXT, TEXT, INTEGER, TEXT, TEXT, TEXT,
+	TEXT, TEXT, TEXT, TEXT, TEXT, TEXT, TEXT, TEXT, TEXT
+) RETURNS TEXT AS $$
+	SELECT md5(
+		COALESCE($1, '') || E'\x1f' || COALESCE($2, '') || E'\x1f' || COALESCE($3, '') || E'\x1f' ||
+		COALESCE($4, '') || E'\x1f' || COALESCE($5, '') || E'\x1f' || COALESCE($6::TEXT, '') || E'\x1f' ||
+		COALESCE($7, '') || E'\x1f' || COALESCE($8, '') || E'\x1f' || COALESCE($9, '') || E'\x1f' ||
+		COALESCE($10, '') || E'\x1f' || COALESCE($11, '') || E'\x1f' || COALESCE($12, '') || E'\x1f' ||
+		COALESCE($13, '') || E'\x1f' || COALESCE($14, '') || E'\x1f' || COALESCE($15, '') || E'\x1f' ||
+		COALESCE($16, '') || E'\x1f' || COALESCE($17, '') || E'\x1f' || COALESCE($18, '')
+	)
+$$ LANGUAGE sql IMMUTABLE;
+
+-- The bundle type decides how many labels a bundle gets (BUNDLE_TYPE_COPIES),
+-- so reprints of a stored label need it too
+ALTER TABLE labels ADD COLUMN IF NOT EXISTS bundle_type VARCHAR(50) NOT NULL DEFAULT '';
+
+-- content_hash was first generated without bundle_type; regenerate it so labels
+-- differing only in their bundle type are not content duplicates
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_name = 'labels' AND column_name = 'content_hash'
+		  AND generation_expression NOT LIKE '%bundle_type%'
+	) THEN
+		ALTER TABLE labels DROP COLUMN content_hash;
+	END IF;
+END $$;
+
+ALTER TABLE labels ADD COLUMN IF NOT EXISTS content_hash TEXT GENERATED ALWAYS AS (label_content_hash(
+	location, bundle_no, pqd, unit, time, length, heat_no, product_heading, isi_bottom,
+	isi_top, charge_dtm, mill, grade, url_apikey, weight, section, date, bundle_type
+)) STORED;
+
+DROP FUNCTION IF EXISTS label_content_hash(
+	TEXT, TEXT, TEXT, TEXT, TEXT, INTEGER, TEXT, TEXT, TEXT,
+	TEXT, TEXT, TEXT, TEXT, TEXT, TEXT, TEXT, TEXT
+);
+
+-- Labels are amended in place. Each version is kept, from the first amendment
+-- on, and print jobs record the version they printed.
+ALTER TABLE labels ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS label_version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS label_versions (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	label_id UUID NOT NULL REFERENCES labels(id) ON DELETE CASCADE,
+	version INTEGER NOT NULL,
+	data JSONB NOT NULL,
+	reason TEXT,
+	changes JSONB,
+	created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	UNIQUE (label_id, version)
+);
+
+-- Voiding a label needs a supervisor or admin; other users' requests wait as
+-- pending. A VOID label may be printed for the voided one: print jobs are
+-- either a label or such a VOID label.
+CREATE TABLE IF NOT EXISTS label_voids (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	label_id UUID NOT NULL REFERENCES labels(id) ON DELETE CASCADE,
+	reason_code VARCHAR(30) NOT NULL CHECK (reason_code IN ('mislabeled', 'damaged', 'data_error', 'duplicate', 'scrapped', 'other')),
+	note TEXT,
+	print_void_label BOOLEAN NOT NULL DEFAULT false,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+	requested_by UUID NOT NULL REFERENCES users(id),
+	decided_by UUID REFERENCES users(id),
+	decision_note TEXT,
+	void_print_job_id UUID REFERENCES print_jobs(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	decided_at TIMESTAMP
+);
+
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'label' CHECK (kind IN ('label', 'void'));
+
+-- Labels move through received, queued, printed or failed, reprinted, and end
+-- voided or dispatched (see internal/lifecycle). Each move is an event; the
+-- CHECK lists the moves allowed, and labels.status is the latest event's
+-- (the labels_status_transition trigger allows the same moves).
+CREATE TABLE IF NOT EXISTS label_events (
+	id BIGSERIAL PRIMARY KEY,
+	label_id UUID NOT NULL REFERENCES labels(id) ON DELETE CASCADE,
+	from_status VARCHAR(20),
+	to_status VARCHAR(20) NOT NULL,
+	actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+	print_job_id UUID REFERENCES print_jobs(id) ON DELETE SET NULL,
+	note TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT label_events_transition_check CHECK (
+		(from_status IS NULL AND to_status = 'received') OR
+		(from_status, to_status) IN (
+			('received', 'queued'), ('received', 'voided'),
+			('queued', 'printed'), ('queued', 'failed'), ('queued', 'voided'),
+			('failed', 'queued'), ('failed', 'printed'), ('failed', 'voided'),
+			('printed', 'reprinted'), ('printed', 'dispatched'), ('printed', 'voided'),
+			('reprinted', 'reprinted'), ('reprinted', 'dispatched'), ('reprinted', 'voided')
+		)
+	)
+);
+
+-- Labels stored before the state machine were all 'success'; give them the
+-- status their print jobs show and the events that lead to it
+UPDATE labels l SET status = CASE
+	WHEN (SELECT COUNT(*) FROM print_jobs j WHERE j.label_id = l.id AND j.kind = 'label' AND j.status = 'completed') > 1 THEN 'reprinted'
+	WHEN EXISTS (SELECT 1 FROM print_jobs j WHERE j.label_id = l.id AND j.kind = 'label' AND j.status = 'completed') THEN 'printed'
+	WHEN EXISTS (SELECT 1 FROM print_jobs j WHERE j.label_id = l.id AND j.status IN ('pending', 'queued', 'printing', 'retrying')) THEN 'queued'
+	WHEN EXISTS (SELECT 1 FROM print_jobs j WHERE j.label_id = l.id AND j.status IN ('failed', 'dead')) THEN 'failed'
+	ELSE 'received'
+END
+WHERE status NOT IN ('received', 'queued', 'printed', 'failed', 'reprinted', 'voided', 'dispatched');
+
+INSERT INTO label_events (label_id, from_status, to_status, actor_id, note, created_at)
+SELECT l.id, p.from_status, p.to_status,
+       CASE WHEN p.step = 1 THEN l.user_id END,
+       CASE WHEN p.step > 1 THEN 'recorded from the status before label events' END,
+       CASE WHEN p.step = 1 THEN l.created_at ELSE GREATEST(l.created_at, l.updated_at) END
+FROM labels l
+JOIN (VALUES
+	('received', 1, NULL, 'received'),
+	('queued', 1, NULL, 'received'), ('queued', 2, 'received', 'queued'),
+	('printed', 1, NULL, 'received'), ('printed', 2, 'received', 'queued'), ('printed', 3, 'queued', 'printed'),
+	('reprinted', 1, NULL, 'received'), ('reprinted', 2, 'received', 'queued'), ('reprinted', 3, 'queued', 'printed'),
+	('reprinted', 4, 'printed', 'reprinted'),
+	('failed', 1, NULL, 'received'), ('failed', 2, 'received', 'queued'), ('failed', 3, 'queued', 'failed'),
+	('voided', 1, NULL, 'received'), ('voided', 2, 'received', 'voided')
+) AS p (status, step, from_status, to_status) ON p.status = l.status
+WHERE NOT EXISTS (SELECT 1 FROM label_events e WHERE e.label_id = l.id)
+ORDER BY l.created_at, l.id, p.step;
+
+ALTER TABLE labels ALTER COLUMN status SET DEFAULT 'received';
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'labels_status_check') THEN
+		ALTER TABLE labels ADD CONSTRAINT labels_status_check
+		CHECK (status IN ('received', 'queued', 'printed', 'failed', 'reprinted', 'voided', 'dispatched'));
+	END IF;
+END $$;
+
+-- labels.status only moves the way the state machine allows. The pairs must
+-- match label_events_transition_check.
+CREATE OR REPLACE FUNCTION label_status_transition() RETURNS TRIGGER AS $$
+BEGIN
+	IF (OLD.status, NEW.status) NOT IN (
+		('received', 'queued'), ('received', 'voided'),
+		('queued', 'printed'), ('queued', 'failed'), ('queued', 'voided'),
+		('failed', 'queued'), ('failed', 'printed'), ('failed', 'voided'),
+		('printed', 'reprinted'), ('printed', 'dispatched'), ('printed', 'voided'),
+		('reprinted', 'reprinted'), ('reprinted', 'dispatched'), ('reprinted', 'voided')
+	) THEN
+		RAISE EXCEPTION 'a % label cannot be %', OLD.status, NEW.status
+			USING ERRCODE = 'check_violation';
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS labels_status_transition ON labels;
+CREATE TRIGGER labels_status_transition
+	BEFORE UPDATE OF status ON labels
+	FOR EACH ROW EXECUTE FUNCTION label_status_transition();
+
+-- The status of each label as its events tell it
+CREATE OR REPLACE VIEW label_states AS
+SELECT DISTINCT ON (label_id) label_id, to_status AS status, created_at AS since
+FROM label_events
+ORDER BY label_id, id DESC;
+
+CREATE TABLE IF NOT EXISTS label_templates (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR(100) UNIQUE NOT NULL,
+	description TEXT,
+	is_default BOOLEAN NOT NULL DEFAULT false,
+	is_active BOOLEAN NOT NULL DEFAULT true,
+	created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Template bodies are immutable once published; only drafts may be edited
+CREATE TABLE IF NOT EXISTS label_template_versions (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	template_id UUID NOT NULL REFERENCES label_templates(id) ON DELETE CASCADE,
+	version INTEGER NOT NULL,
+	body TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'retired')),
+	created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	published_at TIMESTAMP,
+	retired_at TIMESTAMP,
+	UNIQUE (template_id, version)
+);
+
+-- Templates stored before versioning kept their body inline; move it to a published version
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns
+	           WHERE table_name = 'label_templates' AND column_name = 'body') THEN
+		INSERT INTO label_template_versions (template_id, version, body, status, created_by, published_at)
+		SELECT id, version, body, 'published', created_by, updated_at FROM label_templates
+		ON CONFLICT (template_id, version) DO NOTHING;
+		ALTER TABLE label_templates DROP COLUMN body;
+		ALTER TABLE label_templates DROP COLUMN version;
+	END IF;
+END $$;
+
+-- A rule matches labels on every non-null field; section matches by prefix
+CREATE TABLE IF NOT EXISTS label_template_rules (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	template_id UUID NOT NULL REFERENCES label_templates(id) ON DELETE CASCADE,
+	product_heading VARCHAR(255),
+	mill VARCHAR(50),
+	section VARCHAR(255),
+	priority INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Provenance: the template version a job was rendered from and the SHA-256 of its ZPL
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS template_id UUID REFERENCES label_templates(id) ON DELETE RESTRICT;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS template_version INTEGER;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS zpl_hash VARCHAR(64);
+
+-- Printer command language; jobs for EPL2 and TSPL printers are rendered from the QCIN layout
+ALTER TABLE printers ADD COLUMN IF NOT EXISTS language VARCHAR(10) NOT NULL DEFAULT 'zpl';
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS language VARCHAR(10) NOT NULL DEFAULT 'zpl';
+
+-- GS1 application identifiers each template version encodes, as [{"ai": "10", "field": "HeatNo"}, ...]
+ALTER TABLE label_template_versions ADD COLUMN IF NOT EXISTS gs1_ais JSONB NOT NULL DEFAULT '[]';
+
+-- QR payload formats of a template version; NULL uses the plant's (QR_URL_FORMAT, QR_DATA_FORMAT)
+ALTER TABLE label_template_versions ADD COLUMN IF NOT EXISTS qr_url_format TEXT;
+ALTER TABLE label_template_versions ADD COLUMN IF NOT EXISTS qr_data_format TEXT;
+
+-- SHA-256 of the unsigned lower QR payload, so scanned payloads can be traced back to their label
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS qr_hash VARCHAR(64);
+
+-- QR payload formats and signing algorithm ('' for unsigned) a job was rendered
+-- with, so provenance checks do not depend on the plant's current settings
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS qr_url_format TEXT;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS qr_data_format TEXT;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS qr_sign_alg VARCHAR(20);
+
+-- Labels a job prints (^PQ), whether they carry a serial number (^SN), and the
+-- job that first printed the label when this one is its n-th reprint
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS copies INTEGER NOT NULL DEFAULT 1 CHECK (copies BETWEEN 1 AND 99);
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS serialized BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS reprint_of UUID REFERENCES print_jobs(id) ON DELETE SET NULL;
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS reprint_no INTEGER NOT NULL DEFAULT 0;
+
+-- Label stock and print settings of a printer. Rendering scales labels designed
+-- at 203 dpi to the profile's density and sets the printer up for the stock.
+CREATE TABLE IF NOT EXISTS media_profiles (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR(100) UNIQUE NOT NULL,
+	width_mm NUMERIC(6,1) NOT NULL CHECK (width_mm > 0),
+	length_mm NUMERIC(6,1) NOT NULL CHECK (length_mm > 0),
+	dpi INTEGER NOT NULL DEFAULT 203 CHECK (dpi IN (152, 203, 300, 600)),
+	darkness INTEGER CHECK (darkness BETWEEN 0 AND 30),
+	speed INTEGER CHECK (speed BETWEEN 1 AND 14),
+	tear_off INTEGER NOT NULL DEFAULT 0 CHECK (tear_off BETWEEN -120 AND 120),
+	label_top INTEGER NOT NULL DEFAULT 0 CHECK (label_top BETWEEN -120 AND 120),
+	media_type VARCHAR(20) NOT NULL DEFAULT 'gap' CHECK (media_type IN ('gap', 'mark', 'continuous')),
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE printers ADD COLUMN IF NOT EXISTS media_profile_id UUID REFERENCES media_profiles(id) ON DELETE SET NULL;
+
+-- Media profile the job was rendered for, kept so provenance checks can render it again
+ALTER TABLE print_jobs ADD COLUMN IF NOT EXISTS media JSONB;
+
+-- Logos and marks templates place by name. Each upload adds a version; the
+-- source file is kept alongside the bitmap it was converted to.
+CREATE TABLE IF NOT EXISTS label_assets (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR(64) NOT NULL,
+	version INTEGER NOT NULL,
+	format VARCHAR(10) NOT NULL CHECK (format IN ('png', 'svg')),
+	source BYTEA,
+	width INTEGER NOT NULL CHECK (width > 0),
+	height INTEGER NOT NULL CHECK (height > 0),
+	bits BYTEA NOT NULL,
+	dither VARCHAR(20) NOT NULL DEFAULT 'floyd-steinberg' CHECK (dither IN ('floyd-steinberg', 'ordered', 'none')),
+	threshold INTEGER NOT NULL DEFAULT 128 CHECK (threshold BETWEEN 1 AND 255),
+	created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	UNIQUE (name, version)
+);
+
+-- Asset versions a template version places, by asset name, pinned when it is saved
+ALTER TABLE label_template_versions ADD COLUMN IF NOT EXISTS asset_versions JSONB NOT NULL DEFAULT '{}';
+
+-- Responses to requests sent with an Idempotency-Key, replayed when the client
+-- retries with the same key. status_code is NULL while the first request runs.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	key VARCHAR(255) NOT NULL,
+	request_hash VARCHAR(64) NOT NULL,
+	status_code INTEGER,
+	content_type VARCHAR(100),
+	response BYTEA,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, key)
+);
+
+-- When the request holding a key claimed it. A claim without a status_code
+-- older than IDEMPOTENCY_CLAIM_TIMEOUT was abandoned and is taken over.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMP NOT NULL DEFAULT NOW();
+
 CREATE TABLE IF NOT EXISTS audit_logs (
 	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 	user_id UUID NOT NULL REFERENCES users(id),
@@ -71,6 +411,8 @@ CREATE INDEX IF NOT EXISTS idx_labels_label_id ON labels(label_id);
 CREATE INDEX IF NOT EXISTS idx_labels_user_id ON labels(user_id);
 CREATE INDEX IF NOT EXISTS idx_labels_status ON labels(status);
 CREATE INDEX IF NOT EXISTS idx_labels_created_at ON labels(created_at);
+CREATE INDEX IF NOT EXISTS idx_labels_content_hash ON labels(content_hash);
+CREATE INDEX IF NOT EXISTS idx_labels_pqd_heat_no_bundle_no ON labels(pqd, heat_no, bundle_no);
 CREATE INDEX IF NOT EXISTS idx_print_jobs_status ON print_jobs(status);
 CREATE INDEX IF NOT EXISTS idx_print_jobs_user_id ON print_jobs(user_id);
 CREATE INDEX IF NOT EXISTS idx_print_jobs_heat_no ON print_jobs(heat_no);
@@ -79,5 +421,19 @@ CREATE INDEX IF NOT EXISTS idx_print_jobs_heat_no_created_at
 ON print_jobs (heat_no, created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_print_jobs_label_id ON print_jobs(label_id);
 CREATE INDEX IF NOT EXISTS idx_print_jobs_actual_label_id ON print_jobs(actual_label_id);
+CREATE INDEX IF NOT EXISTS idx_print_jobs_printer_id ON print_jobs(printer_id);
+CREATE INDEX IF NOT EXISTS idx_print_jobs_status_next_attempt_at ON print_jobs(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_printers_mill_location ON printers(mill, location);
+CREATE INDEX IF NOT EXISTS idx_print_jobs_template_id ON print_jobs(template_id, template_version);
+CREATE INDEX IF NOT EXISTS idx_print_jobs_qr_hash ON print_jobs(qr_hash);
+CREATE INDEX IF NOT EXISTS idx_print_jobs_reprint_of ON print_jobs(reprint_of);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_label_template_versions_published
+ON label_template_versions (template_id) WHERE status = 'published';
+CREATE INDEX IF NOT EXISTS idx_label_template_rules_template_id ON label_template_rules(template_id);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+CREATE INDEX IF NOT EXISTS idx_label_voids_label_id ON label_voids(label_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_label_voids_pending ON label_voids(label_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_label_events_label_id ON label_events(label_id, id);
+CREATE INDEX IF NOT EXISTS idx_label_events_to_status_created_at ON label_events(to_status, created_at);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
@@ -0,0 +1,251 @@
+// Package assets converts uploaded logos and marks to the 1 bit per pixel
+// bitmaps label printers print, and stores them by name and version for
+// templates to place with {{asset "name"}} or {{stored "name"}}.
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"regexp"
+
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/zpl"
+	"labelops-backend/models"
+)
+
+// MaxSize bounds the width and height of a converted asset in dots, a little
+// over 8 inches at 203 dpi
+const MaxSize = 1700
+
+// MaxUpload bounds the size of an uploaded file
+const MaxUpload = 2 << 20
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidName reports whether name can name an asset: lower case letters,
+// digits, '-' and '_', starting with a letter or digit
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Options control how an upload is converted
+type Options struct {
+	Width     int    // width in dots; zero keeps the image's own, the height follows the aspect ratio
+	Dither    string // models.AssetDither*; empty is Floyd-Steinberg
+	Threshold int    // grey level (1-255) below which a pixel is black; zero is 128
+}
+
+// ErrUnsupportedFormat is returned for uploads that are neither PNG nor SVG
+var ErrUnsupportedFormat = errors.New("assets: upload is not a PNG or SVG image")
+
+// Format returns "png" or "svg" for an upload, by its content
+func Format(data []byte) (string, error) {
+	if bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		return "png", nil
+	}
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if bytes.Contains(head, []byte("<svg")) {
+		return "svg", nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Convert decodes a PNG or SVG upload and dithers it to a bitmap. It returns
+// the bitmap and the upload's format.
+func Convert(data []byte, opts Options) (*layout.Bitmap, string, error) {
+	format, err := Format(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.Width < 0 || opts.Width > MaxSize {
+		return nil, "", fmt.Errorf("width must be between 1 and %d dots", MaxSize)
+	}
+	if opts.Threshold < 0 || opts.Threshold > 255 {
+		return nil, "", fmt.Errorf("threshold must be between 1 and 255")
+	}
+
+	var gray *image.Gray
+	switch format {
+	case "png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", err
+		}
+		gray = flatten(img)
+		if opts.Width > 0 && opts.Width != gray.Bounds().Dx() {
+			gray = resize(gray, opts.Width)
+		}
+	case "svg":
+		if gray, err = RasterizeSVG(data, opts.Width); err != nil {
+			return nil, "", err
+		}
+	}
+	if b := gray.Bounds(); b.Dx() < 1 || b.Dy() < 1 || b.Dx() > MaxSize || b.Dy() > MaxSize {
+		return nil, "", fmt.Errorf("image is %dx%d dots; assets must be between 1 and %d dots each way", b.Dx(), b.Dy(), MaxSize)
+	}
+
+	bitmap, err := Dither(gray, opts.Dither, opts.Threshold)
+	return bitmap, format, err
+}
+
+// flatten converts an image to grey, compositing transparent pixels onto white
+func flatten(img image.Image) *image.Gray {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Over)
+	gray := image.NewGray(rgba.Bounds())
+	draw.Draw(gray, gray.Bounds(), rgba, image.Point{}, draw.Src)
+	return gray
+}
+
+// resize scales a grey image to width, keeping its aspect ratio. Each
+// output pixel averages the source pixels it covers.
+func resize(src *image.Gray, width int) *image.Gray {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	height := (sh*width + sw/2) / sw
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			sum, n := 0, 0
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum += int(src.GrayAt(src.Bounds().Min.X+sx, src.Bounds().Min.Y+sy).Y)
+					n++
+				}
+			}
+			dst.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+	return dst
+}
+
+// bayer is the 4x4 ordered dither matrix
+var bayer = [4][4]int{{0, 8, 2, 10}, {12, 4, 14, 6}, {3, 11, 1, 9}, {15, 7, 13, 5}}
+
+// Dither reduces a grey image to black and white with the given method and threshold
+func Dither(gray *image.Gray, method string, threshold int) (*layout.Bitmap, error) {
+	if threshold == 0 {
+		threshold = 128
+	}
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	b := &layout.Bitmap{Width: w, Height: h}
+	b.Bits = make([]byte, b.Stride()*h)
+	at := func(x, y int) int {
+		return int(gray.GrayAt(gray.Bounds().Min.X+x, gray.Bounds().Min.Y+y).Y)
+	}
+	black := func(x, y int) {
+		b.Bits[y*b.Stride()+x/8] |= 0x80 >> uint(x%8)
+	}
+
+	switch method {
+	case models.AssetDitherNone:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if at(x, y) < threshold {
+					black(x, y)
+				}
+			}
+		}
+	case models.AssetDitherOrdered:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				// Spread the threshold by the matrix, one step in 16 grey levels apart
+				if at(x, y) < threshold+(bayer[y%4][x%4]*2-15)*8 {
+					black(x, y)
+				}
+			}
+		}
+	case models.AssetDitherFloydSteinberg, "":
+		// Errors for this row and the next, with a spare column each side
+		cur, next := make([]int, w+2), make([]int, w+2)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := at(x, y) + cur[x+1]/16
+				out := 255
+				if v < threshold {
+					black(x, y)
+					out = 0
+				}
+				e := v - out
+				cur[x+2] += e * 7
+				next[x] += e * 3
+				next[x+1] += e * 5
+				next[x+2] += e
+			}
+			cur, next = next, cur
+			for i := range next {
+				next[i] = 0
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown dither method %q", method)
+	}
+	return b, nil
+}
+
+// Bitmap returns an asset's stored bitmap
+func Bitmap(a models.LabelAsset) *layout.Bitmap {
+	return &layout.Bitmap{Width: a.Width, Height: a.Height, Bits: a.Bits}
+}
+
+// GraphicField returns the ^GFA command that prints an asset inline
+func GraphicField(a models.LabelAsset) string {
+	b := Bitmap(a)
+	return "^GFA," + zpl.EncodeGraphic(b.Bits, b.Stride())
+}
+
+// ObjectName is the name an asset version is stored under on the printer,
+// e.g. R:A1B2C3D4.GRF. Printers take eight characters, so the name is a
+// hash of the asset name and version; a new version never recalls an old one.
+func ObjectName(a models.LabelAsset) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s@%d", a.Name, a.Version)))
+	return fmt.Sprintf("R:%X.GRF", sum[:4])
+}
+
+// Download returns the ~DG command that stores an asset on the printer under ObjectName
+func Download(a models.LabelAsset) string {
+	b := Bitmap(a)
+	return zpl.EncodeDownload(ObjectName(a), b.Bits, b.Stride())
+}
+
+// Recall returns the ^XG command that prints an asset stored with Download
+func Recall(a models.LabelAsset) string {
+	return "^XG" + ObjectName(a) + ",1,1"
+}
+
+// PNG encodes an asset's bitmap as a black and white PNG, for previews
+func PNG(a models.LabelAsset) ([]byte, error) {
+	b := Bitmap(a)
+	img := image.NewGray(image.Rect(0, 0, b.Width, b.Height))
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			if !b.Black(x, y) {
+				img.SetGray(x, y, color.Gray{Y: 0xff})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,126 @@
+package assets_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"labelops-backend/internal/assets"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/zpl"
+	"labelops-backend/models"
+)
+
+func TestConvertSVG(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 50 25" width="100">
+	<style>.mark { fill: #000 }</style>
+	<rect x="0" y="0" width="20" height="20" class="mark"/>
+	<path d="M30 5 h15 v15 h-15 Z M34 9 h7 v7 h-7 Z" fill-rule="evenodd"/>
+	<line x1="0" y1="23" x2="50" y2="23" stroke="black" stroke-width="1"/>
+</svg>`
+	b, format, err := assets.Convert([]byte(svg), assets.Options{Dither: models.AssetDitherNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "svg" || b.Width != 100 || b.Height != 50 {
+		t.Fatalf("Convert = %s %dx%d, want svg 100x50", format, b.Width, b.Height)
+	}
+	for _, tt := range []struct {
+		x, y  int
+		black bool
+	}{
+		{20, 20, true},  // inside the rectangle
+		{45, 20, false}, // between the shapes
+		{62, 12, true},  // the square's frame
+		{75, 25, false}, // the square's hole
+		{50, 46, true},  // the stroked line
+		{50, 42, false},
+	} {
+		if got := b.Black(tt.x, tt.y); got != tt.black {
+			t.Errorf("dot (%d,%d) black = %v, want %v", tt.x, tt.y, got, tt.black)
+		}
+	}
+
+	if _, _, err := assets.Convert([]byte(`<svg viewBox="0 0 10 10"><text>SAIL</text></svg>`), assets.Options{}); err == nil {
+		t.Error("Convert accepted SVG text")
+	}
+}
+
+func TestConvertPNG(t *testing.T) {
+	// A mid grey square: thresholding turns it all one colour, dithering half black
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dither    string
+		threshold int
+		min, max  int // black dots
+	}{
+		{models.AssetDitherNone, 0, 0, 0},
+		{models.AssetDitherNone, 200, 32 * 32, 32 * 32},
+		{models.AssetDitherOrdered, 0, 512 - 64, 512 + 64},
+		{models.AssetDitherFloydSteinberg, 0, 512 - 64, 512 + 64},
+	}
+	for _, tt := range tests {
+		b, _, err := assets.Convert(buf.Bytes(), assets.Options{Width: 32, Dither: tt.dither, Threshold: tt.threshold})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Width != 32 || b.Height != 32 {
+			t.Fatalf("%s: %dx%d, want 32x32", tt.dither, b.Width, b.Height)
+		}
+		if n := black(b); n < tt.min || n > tt.max {
+			t.Errorf("%s at %d: %d black dots, want %d to %d", tt.dither, tt.threshold, n, tt.min, tt.max)
+		}
+	}
+}
+
+func TestDownloadRecall(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 12, 5))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for x := 0; x < 12; x++ {
+		img.SetGray(x, 2, color.Gray{})
+	}
+	b, err := assets.Dither(img, models.AssetDitherNone, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := models.LabelAsset{Name: "rule", Version: 2, Width: b.Width, Height: b.Height, Bits: b.Bits}
+
+	for _, label := range []string{
+		"^XA^PW40^LL20^FO10,10" + assets.GraphicField(a) + "^FS^XZ",
+		assets.Download(a) + "\n^XA^PW40^LL20^FO10,10" + assets.Recall(a) + "^FS^XZ",
+	} {
+		out, err := zpl.Render([]byte(label), zpl.Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", label, err)
+		}
+		for x := 0; x < 40; x++ {
+			want := x >= 10 && x < 22
+			if got := out.GrayAt(x, 12).Y == 0; got != want {
+				t.Errorf("%.20s...: dot (%d,12) black = %v, want %v", label, x, got, want)
+			}
+		}
+	}
+}
+
+func black(b *layout.Bitmap) int {
+	n := 0
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			if b.Black(x, y) {
+				n++
+			}
+		}
+	}
+	return n
+}
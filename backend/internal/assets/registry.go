@@ -0,0 +1,174 @@
+package assets
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/layout"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when an asset lookup has no match
+var ErrNotFound = errors.New("assets: not found")
+
+const assetColumns = `id, name, version, format, width, height, dither, threshold, bits, created_by, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAsset(row rowScanner) (models.LabelAsset, error) {
+	var a models.LabelAsset
+	var createdBy uuid.NullUUID
+	err := row.Scan(&a.ID, &a.Name, &a.Version, &a.Format, &a.Width, &a.Height, &a.Dither, &a.Threshold, &a.Bits,
+		&createdBy, &a.CreatedAt)
+	if createdBy.Valid {
+		a.CreatedBy = &createdBy.UUID
+	}
+	return a, err
+}
+
+func queryAssets(query string, args ...interface{}) ([]models.LabelAsset, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.LabelAsset{}
+	for rows.Next() {
+		a, err := scanAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// List returns the latest version of every asset, ordered by name
+func List() ([]models.LabelAsset, error) {
+	return queryAssets(`SELECT DISTINCT ON (name) ` + assetColumns + ` FROM label_assets ORDER BY name, version DESC`)
+}
+
+// Versions lists every version of an asset, newest first
+func Versions(name string) ([]models.LabelAsset, error) {
+	return queryAssets(`SELECT `+assetColumns+` FROM label_assets WHERE name = $1 ORDER BY version DESC`, name)
+}
+
+// Get fetches one version of an asset
+func Get(name string, version int) (models.LabelAsset, error) {
+	a, err := scanAsset(db.DB.QueryRow(`SELECT `+assetColumns+` FROM label_assets
+		WHERE name = $1 AND version = $2`, name, version))
+	if err == sql.ErrNoRows {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// Latest fetches the newest version of an asset
+func Latest(name string) (models.LabelAsset, error) {
+	a, err := scanAsset(db.DB.QueryRow(`SELECT `+assetColumns+` FROM label_assets
+		WHERE name = $1 ORDER BY version DESC LIMIT 1`, name))
+	if err == sql.ErrNoRows {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// Source returns the file an asset version was converted from
+func Source(name string, version int) ([]byte, error) {
+	var source []byte
+	err := db.DB.QueryRow(`SELECT source FROM label_assets WHERE name = $1 AND version = $2`, name, version).Scan(&source)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return source, err
+}
+
+// Create stores a converted upload as the next version of the named asset
+func Create(name, format string, source []byte, b *layout.Bitmap, opts Options, createdBy *uuid.UUID) (models.LabelAsset, error) {
+	if opts.Dither == "" {
+		opts.Dither = models.AssetDitherFloydSteinberg
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = 128
+	}
+	return scanAsset(db.DB.QueryRow(`
+		INSERT INTO label_assets (name, version, format, source, width, height, bits, dither, threshold, created_by)
+		SELECT $1, COALESCE((SELECT MAX(version) FROM label_assets WHERE name = $1), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9
+		RETURNING `+assetColumns,
+		name, format, source, b.Width, b.Height, b.Bits, opts.Dither, opts.Threshold, createdBy,
+	))
+}
+
+// Pinned returns a lookup that resolves asset names to the versions in pins,
+// the asset versions a template version was saved with
+func Pinned(pins map[string]int) func(name string) (models.LabelAsset, error) {
+	return func(name string) (models.LabelAsset, error) {
+		version, ok := pins[name]
+		if !ok {
+			return models.LabelAsset{}, fmt.Errorf("asset %q is not pinned by this template version; save it again to pin the latest version", name)
+		}
+		a, err := Get(name, version)
+		if err == ErrNotFound {
+			return a, fmt.Errorf("asset %q version %d not found", name, version)
+		}
+		return a, err
+	}
+}
+
+// LatestLookup resolves asset names to their newest version, for templates being edited
+func LatestLookup(name string) (models.LabelAsset, error) {
+	a, err := Latest(name)
+	if err == ErrNotFound {
+		return a, fmt.Errorf("asset %q not found", name)
+	}
+	return a, err
+}
+
+// Builtin resolves the names of the QCIN layout images to the assets
+// EnsureBuiltin adds, without a database, for linting templates offline
+func Builtin(name string) (models.LabelAsset, error) {
+	b, ok := layout.Images()[name]
+	if !ok {
+		return models.LabelAsset{}, fmt.Errorf("asset %q is not built in", name)
+	}
+	return models.LabelAsset{Name: name, Version: 1, Format: "png", Width: b.Width, Height: b.Height,
+		Dither: models.AssetDitherNone, Threshold: 128, Bits: b.Bits}, nil
+}
+
+// EnsureBuiltin adds the images of the QCIN layout as assets, so other
+// templates can place the same marks. Assets already stored under these
+// names are left alone.
+func EnsureBuiltin() error {
+	images := layout.Images()
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b := images[name]
+		source, err := PNG(models.LabelAsset{Width: b.Width, Height: b.Height, Bits: b.Bits})
+		if err != nil {
+			return err
+		}
+		_, err = db.DB.Exec(`
+			INSERT INTO label_assets (name, version, format, source, width, height, bits, dither, threshold)
+			SELECT $1, 1, 'png', $2, $3, $4, $5, $6, 128
+			WHERE NOT EXISTS (SELECT 1 FROM label_assets WHERE name = $1)
+			ON CONFLICT (name, version) DO NOTHING
+		`, name, source, b.Width, b.Height, b.Bits, models.AssetDitherNone)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
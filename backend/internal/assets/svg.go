@@ -0,0 +1,1097 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// designDPI converts physical SVG sizes (mm, in, pt) to dots, at the density
+// templates are designed for
+const designDPI = 203
+
+// supersample is the number of samples per dot each way when filling shapes
+const supersample = 4
+
+// RasterizeSVG renders an SVG image in grey at width dots, or at its own
+// size when width is zero (px are dots). It draws what logos are made of:
+// path, rect, circle, ellipse, line, polyline and polygon elements, filled
+// and stroked in solid colours, in groups with transforms and class styles.
+// Text, gradients, patterns, <use> and embedded images are reported as
+// errors; convert text to outlines before uploading.
+func RasterizeSVG(data []byte, width int) (*image.Gray, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+
+	var (
+		c       *canvas
+		classes = map[string]map[string]string{}
+		stack   []state
+		skip    int // depth inside elements that are not drawn, such as <defs>
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid SVG: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if skip > 0 {
+				skip++
+				if name == "style" {
+					// Class styles usually live in <defs>
+					if err := readStyle(dec, classes); err != nil {
+						return nil, err
+					}
+					skip--
+				}
+				continue
+			}
+			attrs := attributes(t, classes)
+
+			if c == nil {
+				if name != "svg" {
+					return nil, fmt.Errorf("invalid SVG: root element is <%s>", name)
+				}
+				root, err := newCanvas(attrs, width)
+				if err != nil {
+					return nil, err
+				}
+				c = root
+				stack = append(stack, defaultState(c.view).with(attrs))
+				continue
+			}
+
+			switch name {
+			case "style":
+				if err := readStyle(dec, classes); err != nil {
+					return nil, err
+				}
+				continue
+			case "defs", "title", "desc", "metadata", "clipPath", "mask", "symbol", "marker",
+				"linearGradient", "radialGradient", "pattern", "filter":
+				skip = 1
+				continue
+			case "text", "image", "use", "foreignObject":
+				return nil, fmt.Errorf("SVG <%s> elements are not supported; convert them to paths", name)
+			}
+
+			s := stack[len(stack)-1].with(attrs)
+			stack = append(stack, s)
+			if !s.visible {
+				continue
+			}
+			shape, err := shapeOf(name, attrs)
+			if err != nil {
+				return nil, err
+			}
+			if shape != nil {
+				if err := c.paint(shape, s); err != nil {
+					return nil, err
+				}
+			}
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if c == nil {
+		return nil, fmt.Errorf("invalid SVG: no <svg> element")
+	}
+	return c.image(), nil
+}
+
+// readStyle reads the text of a <style> element and records its class rules
+var classRule = regexp.MustCompile(`([^{}]+)\{([^}]*)\}`)
+
+func readStyle(dec *xml.Decoder, classes map[string]map[string]string) error {
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("invalid SVG: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			for _, m := range classRule.FindAllStringSubmatch(text.String(), -1) {
+				decls := declarations(m[2])
+				for _, sel := range strings.Split(m[1], ",") {
+					sel = strings.TrimSpace(sel)
+					if !strings.HasPrefix(sel, ".") {
+						continue
+					}
+					rules := classes[sel[1:]]
+					if rules == nil {
+						rules = map[string]string{}
+						classes[sel[1:]] = rules
+					}
+					for k, v := range decls {
+						rules[k] = v
+					}
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// declarations parses CSS declarations such as "fill:#000;stroke:none"
+func declarations(s string) map[string]string {
+	decls := map[string]string{}
+	for _, decl := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(decl, ":")
+		if ok {
+			decls[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return decls
+}
+
+// attributes collects an element's attributes, with its class rules and
+// style declarations taking precedence in that order
+func attributes(t xml.StartElement, classes map[string]map[string]string) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range t.Attr {
+		attrs[a.Name.Local] = a.Value
+	}
+	for _, class := range strings.Fields(attrs["class"]) {
+		for k, v := range classes[class] {
+			attrs[k] = v
+		}
+	}
+	for k, v := range declarations(attrs["style"]) {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// point is a position in dots or user units
+type point struct{ x, y float64 }
+
+// matrix is an affine transform [a b c d e f]: x' = ax + cy + e, y' = bx + dy + f
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// then returns the transform applying n, then m
+func (m matrix) then(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[2]*n[1], m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3], m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4], m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m matrix) apply(p point) point {
+	return point{m[0]*p.x + m[2]*p.y + m[4], m[1]*p.x + m[3]*p.y + m[5]}
+}
+
+// scale is the factor lengths are scaled by, for stroke widths
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+var transformFunc = regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
+
+// parseTransform parses a transform attribute
+func parseTransform(s string) (matrix, error) {
+	m := identity
+	for _, f := range transformFunc.FindAllStringSubmatch(s, -1) {
+		args, err := numbers(f[2])
+		if err != nil {
+			return m, err
+		}
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+		var n matrix
+		switch f[1] {
+		case "matrix":
+			if len(args) != 6 {
+				return m, fmt.Errorf("SVG transform matrix needs 6 numbers")
+			}
+			copy(n[:], args)
+		case "translate":
+			n = matrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			n = matrix{sx, 0, 0, arg(1, sx), 0, 0}
+		case "rotate":
+			a := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			n = matrix{1, 0, 0, 1, cx, cy}.
+				then(matrix{math.Cos(a), math.Sin(a), -math.Sin(a), math.Cos(a), 0, 0}).
+				then(matrix{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			n = matrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			n = matrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return m, fmt.Errorf("unknown SVG transform %q", f[1])
+		}
+		m = m.then(n)
+	}
+	return m, nil
+}
+
+// paint is a solid colour as a grey level from 0 (black) to 1 (white)
+type paint struct {
+	none bool
+	gray float64
+}
+
+var namedColors = map[string][3]float64{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "red": {255, 0, 0}, "green": {0, 128, 0},
+	"blue": {0, 0, 255}, "yellow": {255, 255, 0}, "gray": {128, 128, 128}, "grey": {128, 128, 128},
+	"silver": {192, 192, 192}, "navy": {0, 0, 128}, "maroon": {128, 0, 0}, "orange": {255, 165, 0},
+	"purple": {128, 0, 128}, "lime": {0, 255, 0}, "teal": {0, 128, 128}, "olive": {128, 128, 0},
+	"darkgray": {169, 169, 169}, "darkgrey": {169, 169, 169}, "lightgray": {211, 211, 211},
+	"lightgrey": {211, 211, 211},
+}
+
+// parsePaint parses a fill or stroke colour
+func parsePaint(s string) (paint, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	var rgb [3]float64
+	switch {
+	case s == "none" || s == "transparent":
+		return paint{none: true}, nil
+	case s == "currentcolor":
+		return paint{}, nil
+	case strings.HasPrefix(s, "url("):
+		return paint{}, fmt.Errorf("SVG gradient and pattern fills are not supported; use solid colours or upload a PNG")
+	case strings.HasPrefix(s, "#"):
+		hex := s[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) == 8 {
+			hex = hex[:6]
+		}
+		if len(hex) != 6 {
+			return paint{}, fmt.Errorf("invalid SVG colour %q", s)
+		}
+		for i := range rgb {
+			v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+			if err != nil {
+				return paint{}, fmt.Errorf("invalid SVG colour %q", s)
+			}
+			rgb[i] = float64(v)
+		}
+	case strings.HasPrefix(s, "rgb"):
+		open, end := strings.IndexByte(s, '('), strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			return paint{}, fmt.Errorf("invalid SVG colour %q", s)
+		}
+		parts := strings.Split(s[open+1:end], ",")
+		if len(parts) < 3 {
+			return paint{}, fmt.Errorf("invalid SVG colour %q", s)
+		}
+		for i := range rgb {
+			p := strings.TrimSpace(parts[i])
+			percent := strings.HasSuffix(p, "%")
+			v, err := strconv.ParseFloat(strings.TrimSuffix(p, "%"), 64)
+			if err != nil {
+				return paint{}, fmt.Errorf("invalid SVG colour %q", s)
+			}
+			if percent {
+				v *= 2.55
+			}
+			rgb[i] = v
+		}
+	default:
+		named, ok := namedColors[s]
+		if !ok {
+			return paint{}, fmt.Errorf("unknown SVG colour %q", s)
+		}
+		rgb = named
+	}
+	return paint{gray: (0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]) / 255}, nil
+}
+
+// state is the inherited drawing state of an element
+type state struct {
+	transform   matrix
+	fill        paint
+	stroke      paint
+	strokeWidth float64
+	fillOpacity float64
+	strokeAlpha float64
+	opacity     float64
+	evenOdd     bool
+	visible     bool
+	err         error
+}
+
+func defaultState(view matrix) state {
+	return state{transform: view, stroke: paint{none: true}, strokeWidth: 1,
+		fillOpacity: 1, strokeAlpha: 1, opacity: 1, visible: true}
+}
+
+// with returns the state of a child element with the given attributes
+func (s state) with(attrs map[string]string) state {
+	set := func(name string, f func(v string) error) {
+		if v, ok := attrs[name]; ok && s.err == nil && v != "inherit" {
+			s.err = f(v)
+		}
+	}
+	set("transform", func(v string) error {
+		m, err := parseTransform(v)
+		s.transform = s.transform.then(m)
+		return err
+	})
+	set("fill", func(v string) (err error) {
+		s.fill, err = parsePaint(v)
+		return err
+	})
+	set("stroke", func(v string) (err error) {
+		s.stroke, err = parsePaint(v)
+		return err
+	})
+	number := func(dst *float64) func(string) error {
+		return func(v string) error {
+			n, _ := trimUnits(v)
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return fmt.Errorf("invalid SVG number %q", v)
+			}
+			*dst = f
+			return nil
+		}
+	}
+	set("stroke-width", number(&s.strokeWidth))
+	set("fill-opacity", number(&s.fillOpacity))
+	set("stroke-opacity", number(&s.strokeAlpha))
+	// opacity is not inherited but compounds down the tree, which is the same here
+	var opacity float64 = 1
+	set("opacity", number(&opacity))
+	s.opacity *= opacity
+	set("fill-rule", func(v string) error {
+		s.evenOdd = v == "evenodd"
+		return nil
+	})
+	if attrs["display"] == "none" || attrs["visibility"] == "hidden" {
+		s.visible = false
+	}
+	return s
+}
+
+// shape is an element's outline as subpaths in user units. Open subpaths
+// are closed when filled and left open when stroked.
+type shape struct {
+	subpaths [][]point
+	closed   []bool
+}
+
+func (sh *shape) add(points []point, closed bool) {
+	if len(points) > 0 {
+		sh.subpaths = append(sh.subpaths, points)
+		sh.closed = append(sh.closed, closed)
+	}
+}
+
+// shapeOf returns the outline of a drawing element, or nil for elements
+// that only group others
+func shapeOf(name string, attrs map[string]string) (*shape, error) {
+	num := func(key string) (float64, error) {
+		v, ok := attrs[key]
+		if !ok || v == "" {
+			return 0, nil
+		}
+		n, _ := trimUnits(v)
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid SVG %s %q on <%s>", key, v, name)
+		}
+		return f, nil
+	}
+	nums := func(keys ...string) ([]float64, error) {
+		out := make([]float64, len(keys))
+		for i, k := range keys {
+			v, err := num(k)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+
+	sh := &shape{}
+	switch name {
+	case "path":
+		return parsePath(attrs["d"])
+	case "rect":
+		v, err := nums("x", "y", "width", "height", "rx", "ry")
+		if err != nil {
+			return nil, err
+		}
+		x, y, w, h, rx, ry := v[0], v[1], v[2], v[3], v[4], v[5]
+		if w <= 0 || h <= 0 {
+			return nil, nil
+		}
+		if rx == 0 {
+			rx = ry
+		}
+		if ry == 0 {
+			ry = rx
+		}
+		rx, ry = math.Min(rx, w/2), math.Min(ry, h/2)
+		if rx <= 0 {
+			sh.add([]point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}, true)
+			return sh, nil
+		}
+		var pts []point
+		corners := []struct{ cx, cy, start float64 }{
+			{x + w - rx, y + ry, -90}, {x + w - rx, y + h - ry, 0}, {x + rx, y + h - ry, 90}, {x + rx, y + ry, 180},
+		}
+		for _, c := range corners {
+			for i := 0; i <= 8; i++ {
+				a := (c.start + float64(i)*90/8) * math.Pi / 180
+				pts = append(pts, point{c.cx + rx*math.Cos(a), c.cy + ry*math.Sin(a)})
+			}
+		}
+		sh.add(pts, true)
+	case "circle", "ellipse":
+		v, err := nums("cx", "cy", "r", "rx", "ry")
+		if err != nil {
+			return nil, err
+		}
+		rx, ry := v[3], v[4]
+		if name == "circle" {
+			rx, ry = v[2], v[2]
+		}
+		if rx <= 0 || ry <= 0 {
+			return nil, nil
+		}
+		sh.add(ellipse(v[0], v[1], rx, ry), true)
+	case "line":
+		v, err := nums("x1", "y1", "x2", "y2")
+		if err != nil {
+			return nil, err
+		}
+		sh.add([]point{{v[0], v[1]}, {v[2], v[3]}}, false)
+	case "polyline", "polygon":
+		coords, err := numbers(attrs["points"])
+		if err != nil {
+			return nil, err
+		}
+		var pts []point
+		for i := 0; i+1 < len(coords); i += 2 {
+			pts = append(pts, point{coords[i], coords[i+1]})
+		}
+		sh.add(pts, name == "polygon")
+	default:
+		return nil, nil
+	}
+	return sh, nil
+}
+
+// ellipse approximates an ellipse with a polygon
+func ellipse(cx, cy, rx, ry float64) []point {
+	const n = 64
+	pts := make([]point, n)
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / n
+		pts[i] = point{cx + rx*math.Cos(a), cy + ry*math.Sin(a)}
+	}
+	return pts
+}
+
+var numberPattern = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// numbers parses a list of numbers separated by spaces or commas
+func numbers(s string) ([]float64, error) {
+	var out []float64
+	for _, m := range numberPattern.FindAllString(s, -1) {
+		f, err := strconv.ParseFloat(m, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SVG number %q", m)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// pathScanner reads the commands and numbers of path data
+type pathScanner struct {
+	s string
+	i int
+}
+
+func (p *pathScanner) skip() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n,", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// command returns the next command letter, if the next token is one
+func (p *pathScanner) command() (byte, bool) {
+	p.skip()
+	if p.i < len(p.s) && strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", p.s[p.i]) >= 0 {
+		p.i++
+		return p.s[p.i-1], true
+	}
+	return 0, false
+}
+
+// more reports whether a number follows
+func (p *pathScanner) more() bool {
+	p.skip()
+	return p.i < len(p.s) && strings.IndexByte("+-.0123456789", p.s[p.i]) >= 0
+}
+
+func (p *pathScanner) number() (float64, error) {
+	p.skip()
+	m := numberPattern.FindStringIndex(p.s[p.i:])
+	if m == nil || m[0] != 0 {
+		return 0, fmt.Errorf("invalid SVG path data at %q", p.s[p.i:])
+	}
+	f, err := strconv.ParseFloat(p.s[p.i:p.i+m[1]], 64)
+	p.i += m[1]
+	return f, err
+}
+
+// flag reads an arc flag, which may be written without a separator
+func (p *pathScanner) flag() (bool, error) {
+	p.skip()
+	if p.i < len(p.s) && (p.s[p.i] == '0' || p.s[p.i] == '1') {
+		p.i++
+		return p.s[p.i-1] == '1', nil
+	}
+	return false, fmt.Errorf("invalid SVG arc flag in path data")
+}
+
+func (p *pathScanner) numbers(n int) ([]float64, error) {
+	out := make([]float64, n)
+	for i := range out {
+		v, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// curveSteps is the number of line segments a Bézier curve is flattened to
+const curveSteps = 24
+
+// parsePath flattens SVG path data to subpaths
+func parsePath(d string) (*shape, error) {
+	sh := &shape{}
+	p := &pathScanner{s: d}
+	var (
+		cur, start, ctrl point
+		pts              []point
+		prev             byte
+	)
+	flush := func(closed bool) {
+		sh.add(pts, closed)
+		pts = nil
+	}
+	lineTo := func(q point) {
+		if len(pts) == 0 {
+			pts = append(pts, cur)
+		}
+		pts = append(pts, q)
+		cur = q
+	}
+	cubic := func(c1, c2, end point) {
+		p0 := cur
+		for i := 1; i <= curveSteps; i++ {
+			t := float64(i) / curveSteps
+			u := 1 - t
+			lineTo(point{
+				u*u*u*p0.x + 3*u*u*t*c1.x + 3*u*t*t*c2.x + t*t*t*end.x,
+				u*u*u*p0.y + 3*u*u*t*c1.y + 3*u*t*t*c2.y + t*t*t*end.y,
+			})
+		}
+	}
+	quad := func(c, end point) {
+		p0 := cur
+		for i := 1; i <= curveSteps; i++ {
+			t := float64(i) / curveSteps
+			u := 1 - t
+			lineTo(point{u*u*p0.x + 2*u*t*c.x + t*t*end.x, u*u*p0.y + 2*u*t*c.y + t*t*end.y})
+		}
+	}
+
+	cmd, ok := p.command()
+	if !ok {
+		p.skip()
+		if p.i < len(p.s) {
+			return nil, fmt.Errorf("SVG path data must start with a command")
+		}
+		return sh, nil
+	}
+	for {
+		rel := cmd >= 'a'
+		at := func(x, y float64) point {
+			if rel {
+				return point{cur.x + x, cur.y + y}
+			}
+			return point{x, y}
+		}
+		switch cmd | 0x20 {
+		case 'z':
+			if len(pts) > 0 {
+				flush(true)
+			}
+			cur = start
+		case 'm':
+			v, err := p.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			flush(false)
+			cur = at(v[0], v[1])
+			start = cur
+			// Further pairs are implicit line-tos
+			if rel {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+		case 'l':
+			v, err := p.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			lineTo(at(v[0], v[1]))
+		case 'h':
+			v, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			x := v
+			if rel {
+				x += cur.x
+			}
+			lineTo(point{x, cur.y})
+		case 'v':
+			v, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			y := v
+			if rel {
+				y += cur.y
+			}
+			lineTo(point{cur.x, y})
+		case 'c':
+			v, err := p.numbers(6)
+			if err != nil {
+				return nil, err
+			}
+			c1, c2, end := at(v[0], v[1]), at(v[2], v[3]), at(v[4], v[5])
+			cubic(c1, c2, end)
+			ctrl = c2
+		case 's':
+			v, err := p.numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			c1 := cur
+			if prev|0x20 == 'c' || prev|0x20 == 's' {
+				c1 = point{2*cur.x - ctrl.x, 2*cur.y - ctrl.y}
+			}
+			c2, end := at(v[0], v[1]), at(v[2], v[3])
+			cubic(c1, c2, end)
+			ctrl = c2
+		case 'q':
+			v, err := p.numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			c, end := at(v[0], v[1]), at(v[2], v[3])
+			quad(c, end)
+			ctrl = c
+		case 't':
+			v, err := p.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			c := cur
+			if prev|0x20 == 'q' || prev|0x20 == 't' {
+				c = point{2*cur.x - ctrl.x, 2*cur.y - ctrl.y}
+			}
+			quad(c, at(v[0], v[1]))
+			ctrl = c
+		case 'a':
+			v, err := p.numbers(3)
+			if err != nil {
+				return nil, err
+			}
+			large, err := p.flag()
+			if err != nil {
+				return nil, err
+			}
+			sweep, err := p.flag()
+			if err != nil {
+				return nil, err
+			}
+			end, err := p.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			for _, q := range arc(cur, v[0], v[1], v[2], large, sweep, at(end[0], end[1])) {
+				lineTo(q)
+			}
+		}
+		prev = cmd
+
+		if next, ok := p.command(); ok {
+			cmd = next
+			continue
+		}
+		if !p.more() {
+			p.skip()
+			if p.i < len(p.s) {
+				return nil, fmt.Errorf("invalid SVG path data at %q", p.s[p.i:])
+			}
+			break
+		}
+		if cmd|0x20 == 'z' {
+			return nil, fmt.Errorf("invalid SVG path data: numbers after Z")
+		}
+		// Repeated arguments repeat the command
+	}
+	flush(false)
+	return sh, nil
+}
+
+// arc flattens an elliptical arc from p to end, following the endpoint to
+// centre conversion in the SVG specification (appendix B.2.4)
+func arc(p point, rx, ry, rotation float64, large, sweep bool, end point) []point {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || p == end {
+		return []point{end}
+	}
+	phi := rotation * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (p.x-end.x)/2, (p.y-end.y)/2
+	x1, y1 := cos*dx+sin*dy, -sin*dx+cos*dy
+
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1, cy1 := coef*rx*y1/ry, -coef*ry*x1/rx
+	cx := cos*cx1 - sin*cy1 + (p.x+end.x)/2
+	cy := sin*cx1 + cos*cy1 + (p.y+end.y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	start := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 32)))
+	if n < 1 {
+		n = 1
+	}
+	pts := make([]point, 0, n)
+	for i := 1; i <= n; i++ {
+		t := start + delta*float64(i)/float64(n)
+		pts = append(pts, point{
+			cx + rx*math.Cos(t)*cos - ry*math.Sin(t)*sin,
+			cy + rx*math.Cos(t)*sin + ry*math.Sin(t)*cos,
+		})
+	}
+	pts[len(pts)-1] = end
+	return pts
+}
+
+// canvas is a grey image being drawn, 0 black to 1 white
+type canvas struct {
+	w, h int
+	pix  []float64
+	view matrix // user units of the root element to dots
+}
+
+// newCanvas sizes the canvas from the root element: its width and height,
+// scaled to width dots when width is set, and its viewBox
+func newCanvas(attrs map[string]string, width int) (*canvas, error) {
+	length := func(key string) (float64, error) {
+		v := attrs[key]
+		if v == "" {
+			return 0, nil
+		}
+		n, unit := trimUnits(v)
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid SVG %s %q", key, v)
+		}
+		switch unit {
+		case "mm":
+			f *= designDPI / 25.4
+		case "cm":
+			f *= designDPI / 2.54
+		case "in":
+			f *= designDPI
+		case "pt":
+			f *= designDPI / 72.0
+		case "%":
+			f = 0
+		}
+		return f, nil
+	}
+	w, err := length("width")
+	if err != nil {
+		return nil, err
+	}
+	h, err := length("height")
+	if err != nil {
+		return nil, err
+	}
+
+	var box []float64
+	if v, ok := attrs["viewBox"]; ok {
+		if box, err = numbers(v); err != nil || len(box) != 4 || box[2] <= 0 || box[3] <= 0 {
+			return nil, fmt.Errorf("invalid SVG viewBox %q", v)
+		}
+	}
+	switch {
+	case box == nil && (w <= 0 || h <= 0):
+		return nil, fmt.Errorf("SVG has no width and height or viewBox")
+	case box == nil:
+		box = []float64{0, 0, w, h}
+	case w <= 0 && h <= 0:
+		w, h = box[2], box[3]
+	case w <= 0:
+		w = h * box[2] / box[3]
+	case h <= 0:
+		h = w * box[3] / box[2]
+	}
+	if width > 0 {
+		h, w = h*float64(width)/w, float64(width)
+	}
+
+	c := &canvas{w: int(math.Round(w)), h: int(math.Round(h))}
+	if c.w < 1 || c.h < 1 || c.w > MaxSize || c.h > MaxSize {
+		return nil, fmt.Errorf("SVG is %dx%d dots; assets must be between 1 and %d dots each way", c.w, c.h, MaxSize)
+	}
+	c.pix = make([]float64, c.w*c.h)
+	for i := range c.pix {
+		c.pix[i] = 1
+	}
+	sx, sy := float64(c.w)/box[2], float64(c.h)/box[3]
+	c.view = matrix{sx, 0, 0, sy, -box[0] * sx, -box[1] * sy}
+	return c, nil
+}
+
+// paint fills and strokes a shape with the state's colours
+func (c *canvas) paint(sh *shape, s state) error {
+	if s.err != nil {
+		return s.err
+	}
+	if !s.fill.none {
+		var polys [][]point
+		for _, sub := range sh.subpaths {
+			polys = append(polys, transform(sub, s.transform))
+		}
+		c.fill(polys, s.evenOdd, s.fill.gray, s.fillOpacity*s.opacity)
+	}
+	if !s.stroke.none && s.strokeWidth > 0 {
+		half := s.strokeWidth * s.transform.scale() / 2
+		var polys [][]point
+		for i, sub := range sh.subpaths {
+			polys = append(polys, strokeOutline(transform(sub, s.transform), sh.closed[i], half)...)
+		}
+		c.fill(polys, false, s.stroke.gray, s.strokeAlpha*s.opacity)
+	}
+	return nil
+}
+
+func transform(pts []point, m matrix) []point {
+	out := make([]point, len(pts))
+	for i, p := range pts {
+		out[i] = m.apply(p)
+	}
+	return out
+}
+
+// strokeOutline covers a stroked polyline with a quadrilateral per segment
+// and a disc per vertex for round joins and caps. Every piece winds the same
+// way, so filling them with the non-zero rule draws their union.
+func strokeOutline(pts []point, closed bool, half float64) [][]point {
+	var polys [][]point
+	if closed && len(pts) > 1 {
+		pts = append(pts, pts[0])
+	}
+	for i := 0; i+1 < len(pts); i++ {
+		p, q := pts[i], pts[i+1]
+		dx, dy := q.x-p.x, q.y-p.y
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -dy/l*half, dx/l*half
+		polys = append(polys, positive([]point{{p.x + nx, p.y + ny}, {q.x + nx, q.y + ny}, {q.x - nx, q.y - ny}, {p.x - nx, p.y - ny}}))
+	}
+	for _, p := range pts {
+		polys = append(polys, positive(ellipse(p.x, p.y, half, half)))
+	}
+	return polys
+}
+
+// positive returns a polygon wound so its signed area is positive
+func positive(pts []point) []point {
+	area := 0.0
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		area += pts[i].x*pts[j].y - pts[j].x*pts[i].y
+	}
+	if area < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return pts
+}
+
+// edge is a polygon edge that is not horizontal, with y0 < y1
+type edge struct {
+	x0, y0, x1, y1 float64
+	dir            int
+}
+
+// fill paints polygons in dots with grey level gray and the given opacity.
+// Coverage is measured with supersample x supersample samples per dot.
+func (c *canvas) fill(polys [][]point, evenOdd bool, gray, alpha float64) {
+	var edges []edge
+	for _, poly := range polys {
+		for i := range poly {
+			p, q := poly[i], poly[(i+1)%len(poly)]
+			switch {
+			case p.y < q.y:
+				edges = append(edges, edge{p.x, p.y, q.x, q.y, 1})
+			case p.y > q.y:
+				edges = append(edges, edge{q.x, q.y, p.x, p.y, -1})
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	coverage := make([]int, c.w)
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var xs []crossing
+	for py := 0; py < c.h; py++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+		hit := false
+		for sy := 0; sy < supersample; sy++ {
+			y := float64(py) + (float64(sy)+0.5)/supersample
+			xs = xs[:0]
+			for _, e := range edges {
+				if e.y0 > y {
+					break
+				}
+				if y < e.y1 {
+					xs = append(xs, crossing{e.x0 + (y-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), e.dir})
+				}
+			}
+			if len(xs) == 0 {
+				continue
+			}
+			sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+			winding := 0
+			for i := 0; i+1 < len(xs); i++ {
+				if evenOdd {
+					winding ^= 1
+				} else {
+					winding += xs[i].dir
+				}
+				if winding == 0 {
+					continue
+				}
+				// Samples whose centres lie in [xs[i].x, xs[i+1].x)
+				first := int(math.Ceil(xs[i].x*supersample - 0.5))
+				last := int(math.Ceil(xs[i+1].x*supersample-0.5)) - 1
+				if first < 0 {
+					first = 0
+				}
+				if last >= c.w*supersample {
+					last = c.w*supersample - 1
+				}
+				for sx := first; sx <= last; sx++ {
+					coverage[sx/supersample]++
+					hit = true
+				}
+			}
+		}
+		if !hit {
+			continue
+		}
+		for px, n := range coverage {
+			if n == 0 {
+				continue
+			}
+			a := alpha * float64(n) / (supersample * supersample)
+			i := py*c.w + px
+			c.pix[i] = c.pix[i]*(1-a) + gray*a
+		}
+	}
+}
+
+// image returns the canvas as a grey image
+func (c *canvas) image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, c.w, c.h))
+	for i, v := range c.pix {
+		img.Pix[i] = color.Gray{Y: uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))}.Y
+	}
+	return img
+}
+
+// trimUnits strips a CSS length unit, returning the number and the unit
+func trimUnits(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	for _, unit := range []string{"px", "pt", "mm", "cm", "in", "%"} {
+		if strings.HasSuffix(s, unit) {
+			return strings.TrimSpace(strings.TrimSuffix(s, unit)), unit
+		}
+	}
+	return s, ""
+}
@@ -0,0 +1,209 @@
+// Package code128 encodes Code 128 bar codes, including GS1-128. Code sets
+// are chosen automatically: runs of digits use set C, lower case text set B
+// and control characters set A.
+package code128
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FNC1 stands for the function 1 character in the data passed to Encode. At
+// the start of a symbol it marks GS1-128; later it separates GS1 elements.
+const FNC1 = -1
+
+// ErrEmpty is returned when there is nothing to encode
+var ErrEmpty = errors.New("code128: no data")
+
+// patterns are the bar and space widths of each symbol value, in modules
+var patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// Symbol values with a special meaning
+const (
+	codeC  = 99
+	codeB  = 100
+	codeA  = 101
+	fnc1   = 102
+	startA = 103
+	startB = 104
+	startC = 105
+	stop   = 106
+)
+
+// Code is an encoded bar code
+type Code struct {
+	// Values are the symbol values from the start code to the check symbol
+	Values []int
+	// Modules are the bars (true) and spaces of the symbol, one module each
+	Modules []bool
+}
+
+// Text converts a string to Encode input
+func Text(s string) []int {
+	data := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		data[i] = int(s[i])
+	}
+	return data
+}
+
+// GS1 converts a GS1 element string, with GS separators, to Encode input
+// starting with FNC1
+func GS1(elementString string) []int {
+	data := []int{FNC1}
+	for i := 0; i < len(elementString); i++ {
+		if elementString[i] == 0x1d {
+			data = append(data, FNC1)
+			continue
+		}
+		data = append(data, int(elementString[i]))
+	}
+	return data
+}
+
+// Encode encodes data, where each element is a byte value from 0 to 127 or FNC1
+func Encode(data []int) (*Code, error) {
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+	for _, c := range data {
+		if c != FNC1 && (c < 0 || c > 127) {
+			return nil, fmt.Errorf("code128: cannot encode byte %d", c)
+		}
+	}
+
+	var values []int
+	set := 0
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c == FNC1 {
+			if set == 0 {
+				set = startSet(data[i+1:])
+				values = appendSwitch(values, 0, set)
+			}
+			values = append(values, fnc1)
+			i++
+			continue
+		}
+
+		// Set C pays off for 4 digits at the start or end and 6 in the middle
+		n := digitRun(data[i:])
+		useC := set == codeC && n >= 2 ||
+			set == 0 && (n >= 4 || n == 2 && i+n == len(data)) ||
+			set != 0 && (n >= 6 || n >= 4 && i+n == len(data))
+		if useC {
+			if set != codeC {
+				values = appendSwitch(values, set, codeC)
+				set = codeC
+			}
+			// An odd run leaves its last digit to set A or B
+			for ; n >= 2; n -= 2 {
+				values = append(values, (data[i]-'0')*10+data[i+1]-'0')
+				i += 2
+			}
+			continue
+		}
+
+		if want := setFor(data[i:], set); want != set {
+			values = appendSwitch(values, set, want)
+			set = want
+		}
+		if set == codeA && c < 32 {
+			values = append(values, c+64)
+		} else {
+			values = append(values, c-32)
+		}
+		i++
+	}
+
+	sum := values[0]
+	for i, v := range values[1:] {
+		sum += (i + 1) * v
+	}
+	values = append(values, sum%103)
+
+	code := &Code{Values: values}
+	for _, v := range append(values, stop) {
+		bar := true
+		for _, w := range patterns[v] {
+			for n := 0; n < int(w-'0'); n++ {
+				code.Modules = append(code.Modules, bar)
+			}
+			bar = !bar
+		}
+	}
+	return code, nil
+}
+
+// digitRun counts the digits at the start of data
+func digitRun(data []int) int {
+	n := 0
+	for n < len(data) && data[n] >= '0' && data[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// startSet picks the set a symbol starts in
+func startSet(data []int) int {
+	if n := digitRun(data); n >= 4 || n == 2 && n == len(data) {
+		return codeC
+	}
+	if len(data) == 0 {
+		return codeB
+	}
+	return setFor(data, 0)
+}
+
+// setFor picks set A or B for the next character, keeping the current set
+// when it can encode it
+func setFor(data []int, current int) int {
+	c := data[0]
+	switch {
+	case c < 32:
+		return codeA
+	case c >= 96:
+		return codeB
+	case current == codeA || current == codeB:
+		return current
+	}
+	// Look ahead for the first character only one of the sets can encode
+	for _, c := range data {
+		if c == FNC1 {
+			continue
+		}
+		if c < 32 {
+			return codeA
+		}
+		if c >= 96 {
+			return codeB
+		}
+	}
+	return codeB
+}
+
+// appendSwitch starts the symbol in a set, or switches to it mid-symbol
+func appendSwitch(values []int, from, to int) []int {
+	if from != 0 {
+		return append(values, to)
+	}
+	switch to {
+	case codeA:
+		return append(values, startA)
+	case codeC:
+		return append(values, startC)
+	}
+	return append(values, startB)
+}
@@ -0,0 +1,35 @@
+package code128_test
+
+import (
+	"reflect"
+	"testing"
+
+	"labelops-backend/internal/code128"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		data []int
+		want []int // symbol values from the start code to the check symbol
+	}{
+		{"text", code128.Text("PJJ123C"), []int{104, 48, 42, 42, 17, 18, 19, 35, 55}},
+		{"digits", code128.Text("123456"), []int{105, 12, 34, 56, 44}},
+		{"gs1", code128.GS1("0109506000134352"), []int{105, 102, 1, 9, 50, 60, 0, 13, 43, 52, 94}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := code128.Encode(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(code.Values, tt.want) {
+				t.Errorf("Values = %v, want %v", code.Values, tt.want)
+			}
+			// 11 modules per symbol, 13 for the stop pattern
+			if got, want := len(code.Modules), 11*len(tt.want)+13; got != want {
+				t.Errorf("%d modules, want %d", got, want)
+			}
+		})
+	}
+}
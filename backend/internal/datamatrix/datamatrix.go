@@ -0,0 +1,225 @@
+// Package datamatrix encodes Data Matrix ECC 200 symbols. Data is encoded in
+// ASCII mode, with digit pairs packed, into the smallest square symbol up to
+// 132x132 modules that holds it.
+package datamatrix
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FNC1 stands for the function 1 character in the data passed to Encode. As
+// the first character it marks GS1 DataMatrix.
+const FNC1 = -1
+
+// ErrTooLong is returned when the data does not fit in the largest symbol
+var ErrTooLong = errors.New("datamatrix: data too long")
+
+// symbolSize describes one square ECC 200 symbol
+type symbolSize struct {
+	size       int // modules per side, including finder patterns
+	regions    int // data regions per side
+	dataWords  int
+	errorWords int
+	blocks     int // interleaved Reed-Solomon blocks
+}
+
+var symbolSizes = []symbolSize{
+	{10, 1, 3, 5, 1}, {12, 1, 5, 7, 1}, {14, 1, 8, 10, 1}, {16, 1, 12, 12, 1},
+	{18, 1, 18, 14, 1}, {20, 1, 22, 18, 1}, {22, 1, 30, 20, 1}, {24, 1, 36, 24, 1},
+	{26, 1, 44, 28, 1}, {32, 2, 62, 36, 1}, {36, 2, 86, 42, 1}, {40, 2, 114, 48, 1},
+	{44, 2, 144, 56, 1}, {48, 2, 174, 68, 1}, {52, 2, 204, 84, 2}, {64, 4, 280, 112, 2},
+	{72, 4, 368, 144, 4}, {80, 4, 456, 192, 4}, {88, 4, 576, 224, 4}, {96, 4, 696, 272, 4},
+	{104, 4, 816, 336, 6}, {120, 6, 1050, 408, 6}, {132, 6, 1304, 496, 8},
+}
+
+// Code is an encoded symbol. Modules are indexed [y][x]; true is dark.
+type Code struct {
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// Text converts a string to Encode input
+func Text(s string) []int {
+	data := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		data[i] = int(s[i])
+	}
+	return data
+}
+
+// Encode encodes data, where each element is a byte value or FNC1
+func Encode(data []int) (*Code, error) {
+	words, err := encodeASCII(data)
+	if err != nil {
+		return nil, err
+	}
+	var sym symbolSize
+	for _, s := range symbolSizes {
+		if s.dataWords >= len(words) {
+			sym = s
+			break
+		}
+	}
+	if sym.size == 0 {
+		return nil, ErrTooLong
+	}
+
+	words = pad(words, sym.dataWords)
+	words = addErrorCorrection(words, sym)
+
+	regionSize := sym.size/sym.regions - 2
+	mapping := place(regionSize*sym.regions, regionSize*sym.regions, words)
+
+	code := &Code{Size: sym.size, modules: make([][]bool, sym.size)}
+	for y := range code.modules {
+		code.modules[y] = make([]bool, sym.size)
+	}
+	step := regionSize + 2
+	for y := 0; y < sym.size; y++ {
+		for x := 0; x < sym.size; x++ {
+			ry, rx := y%step, x%step
+			switch {
+			case rx == 0 || ry == step-1:
+				// Solid finder pattern on the left and bottom of each region
+				code.modules[y][x] = true
+			case ry == 0:
+				// Alternating clock track along the top
+				code.modules[y][x] = rx%2 == 0
+			case rx == step-1:
+				// and down the right
+				code.modules[y][x] = ry%2 == 1
+			default:
+				code.modules[y][x] = mapping[(y/step)*regionSize+ry-1][(x/step)*regionSize+rx-1]
+			}
+		}
+	}
+	return code, nil
+}
+
+// encodeASCII converts data to codewords in ASCII mode
+func encodeASCII(data []int) ([]byte, error) {
+	var words []byte
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == FNC1:
+			words = append(words, 232)
+		case c < 0 || c > 255:
+			return nil, fmt.Errorf("datamatrix: cannot encode byte %d", c)
+		case isDigit(c) && i+1 < len(data) && isDigit(data[i+1]):
+			words = append(words, byte(130+(c-'0')*10+data[i+1]-'0'))
+			i++
+		case c > 127:
+			// Upper shift
+			words = append(words, 235, byte(c-127))
+		default:
+			words = append(words, byte(c+1))
+		}
+	}
+	return words, nil
+}
+
+func isDigit(c int) bool {
+	return c >= '0' && c <= '9'
+}
+
+// pad fills unused data capacity: one 129, then pseudo-randomised pad values
+func pad(words []byte, capacity int) []byte {
+	if len(words) < capacity {
+		words = append(words, 129)
+	}
+	for len(words) < capacity {
+		pos := len(words) + 1
+		v := 129 + (149*pos)%253 + 1
+		if v > 254 {
+			v -= 254
+		}
+		words = append(words, byte(v))
+	}
+	return words
+}
+
+// addErrorCorrection appends the Reed-Solomon codewords. Larger symbols split
+// the data into interleaved blocks: block b holds every blocks-th codeword.
+func addErrorCorrection(data []byte, sym symbolSize) []byte {
+	eccPerBlock := sym.errorWords / sym.blocks
+	divisor := reedSolomonDivisor(eccPerBlock)
+	out := make([]byte, len(data)+sym.errorWords)
+	copy(out, data)
+	for b := 0; b < sym.blocks; b++ {
+		var block []byte
+		for i := b; i < len(data); i += sym.blocks {
+			block = append(block, data[i])
+		}
+		for i, e := range reedSolomonRemainder(block, divisor) {
+			out[len(data)+b+i*sym.blocks] = e
+		}
+	}
+	return out
+}
+
+// GF(256) arithmetic over the Data Matrix field polynomial x^8+x^5+x^3+x^2+1
+var gfExp, gfLog [256]int
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = i
+		x <<= 1
+		if x >= 256 {
+			x ^= 0x12d
+		}
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMultiply(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[a]+gfLog[b])%255]
+}
+
+// reedSolomonDivisor returns the generator polynomial (x-2^1)...(x-2^n),
+// highest power first without the leading 1
+func reedSolomonDivisor(degree int) []int {
+	poly := make([]int, degree)
+	poly[degree-1] = 1
+	for i := 1; i <= degree; i++ {
+		root := gfExp[i]
+		for j := 0; j < degree; j++ {
+			poly[j] = gfMultiply(poly[j], root)
+			if j+1 < degree {
+				poly[j] ^= poly[j+1]
+			}
+		}
+	}
+	return poly
+}
+
+func reedSolomonRemainder(data []byte, divisor []int) []byte {
+	rem := make([]int, len(divisor))
+	for _, d := range data {
+		factor := int(d) ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i, coef := range divisor {
+			rem[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	out := make([]byte, len(rem))
+	for i, r := range rem {
+		out[i] = byte(r)
+	}
+	return out
+}
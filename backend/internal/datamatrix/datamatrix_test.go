@@ -0,0 +1,34 @@
+package datamatrix
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The "123456" example from ISO/IEC 16022: three digit pair
+// codewords and five error correction codewords in a 10x10 symbol
+func TestEncodeExample(t *testing.T) {
+	words, err := encodeASCII(Text("123456"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := addErrorCorrection(words, symbolSizes[0])
+	want := []byte{142, 164, 186, 114, 25, 5, 88, 102}
+	if !bytes.Equal(got, want) {
+		t.Errorf("codewords = %v, want %v", got, want)
+	}
+
+	code, err := Encode(Text("123456"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Size != 10 {
+		t.Errorf("Size = %d, want 10", code.Size)
+	}
+	// Finder pattern: solid left column and bottom row
+	for i := 0; i < code.Size; i++ {
+		if !code.Dark(0, i) || !code.Dark(i, code.Size-1) {
+			t.Fatalf("finder pattern broken at %d", i)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package datamatrix
+
+// placer lays codeword bits out in the mapping matrix following the ECC 200
+// placement algorithm (ISO/IEC 16022 Annex F)
+type placer struct {
+	rows, cols int
+	words      []byte
+	bits       [][]bool
+	set        [][]bool
+}
+
+// place returns the mapping matrix, the symbol without its finder patterns
+func place(rows, cols int, words []byte) [][]bool {
+	p := &placer{rows: rows, cols: cols, words: words}
+	p.bits = make([][]bool, rows)
+	p.set = make([][]bool, rows)
+	for i := range p.bits {
+		p.bits[i] = make([]bool, cols)
+		p.set[i] = make([]bool, cols)
+	}
+
+	chr, row, col := 0, 4, 0
+	for {
+		// The four corner cases
+		if row == rows && col == 0 {
+			p.corner1(chr)
+			chr++
+		}
+		if row == rows-2 && col == 0 && cols%4 != 0 {
+			p.corner2(chr)
+			chr++
+		}
+		if row == rows-2 && col == 0 && cols%8 == 4 {
+			p.corner3(chr)
+			chr++
+		}
+		if row == rows+4 && col == 2 && cols%8 == 0 {
+			p.corner4(chr)
+			chr++
+		}
+		// Sweep up and to the right
+		for {
+			if row < rows && col >= 0 && !p.set[row][col] {
+				p.utah(row, col, chr)
+				chr++
+			}
+			row -= 2
+			col += 2
+			if row < 0 || col >= cols {
+				break
+			}
+		}
+		row++
+		col += 3
+		// then down and to the left
+		for {
+			if row >= 0 && col < cols && !p.set[row][col] {
+				p.utah(row, col, chr)
+				chr++
+			}
+			row += 2
+			col -= 2
+			if row >= rows || col < 0 {
+				break
+			}
+		}
+		row += 3
+		col++
+		if row >= rows && col >= cols {
+			break
+		}
+	}
+
+	// Some sizes leave the bottom right corner unfilled; it gets a fixed pattern
+	if !p.set[rows-1][cols-1] {
+		p.bits[rows-1][cols-1] = true
+		p.bits[rows-2][cols-2] = true
+	}
+	return p.bits
+}
+
+// module places bit (1 is the most significant) of codeword chr, wrapping
+// positions that fall outside the matrix
+func (p *placer) module(row, col, chr, bit int) {
+	if row < 0 {
+		row += p.rows
+		col += 4 - (p.rows+4)%8
+	}
+	if col < 0 {
+		col += p.cols
+		row += 4 - (p.cols+4)%8
+	}
+	p.set[row][col] = true
+	if chr < len(p.words) {
+		p.bits[row][col] = p.words[chr]&(1<<(8-bit)) != 0
+	}
+}
+
+// utah places the eight bits of a codeword in the standard L shape
+func (p *placer) utah(row, col, chr int) {
+	p.module(row-2, col-2, chr, 1)
+	p.module(row-2, col-1, chr, 2)
+	p.module(row-1, col-2, chr, 3)
+	p.module(row-1, col-1, chr, 4)
+	p.module(row-1, col, chr, 5)
+	p.module(row, col-2, chr, 6)
+	p.module(row, col-1, chr, 7)
+	p.module(row, col, chr, 8)
+}
+
+func (p *placer) corner1(chr int) {
+	p.module(p.rows-1, 0, chr, 1)
+	p.module(p.rows-1, 1, chr, 2)
+	p.module(p.rows-1, 2, chr, 3)
+	p.module(0, p.cols-2, chr, 4)
+	p.module(0, p.cols-1, chr, 5)
+	p.module(1, p.cols-1, chr, 6)
+	p.module(2, p.cols-1, chr, 7)
+	p.module(3, p.cols-1, chr, 8)
+}
+
+func (p *placer) corner2(chr int) {
+	p.module(p.rows-3, 0, chr, 1)
+	p.module(p.rows-2, 0, chr, 2)
+	p.module(p.rows-1, 0, chr, 3)
+	p.module(0, p.cols-4, chr, 4)
+	p.module(0, p.cols-3, chr, 5)
+	p.module(0, p.cols-2, chr, 6)
+	p.module(0, p.cols-1, chr, 7)
+	p.module(1, p.cols-1, chr, 8)
+}
+
+func (p *placer) corner3(chr int) {
+	p.module(p.rows-3, 0, chr, 1)
+	p.module(p.rows-2, 0, chr, 2)
+	p.module(p.rows-1, 0, chr, 3)
+	p.module(0, p.cols-2, chr, 4)
+	p.module(0, p.cols-1, chr, 5)
+	p.module(1, p.cols-1, chr, 6)
+	p.module(2, p.cols-1, chr, 7)
+	p.module(3, p.cols-1, chr, 8)
+}
+
+func (p *placer) corner4(chr int) {
+	p.module(p.rows-1, 0, chr, 1)
+	p.module(p.rows-1, p.cols-1, chr, 2)
+	p.module(0, p.cols-3, chr, 3)
+	p.module(0, p.cols-2, chr, 4)
+	p.module(0, p.cols-1, chr, 5)
+	p.module(1, p.cols-3, chr, 6)
+	p.module(1, p.cols-2, chr, 7)
+	p.module(1, p.cols-1, chr, 8)
+}
@@ -0,0 +1,183 @@
+// Package dbtest is a database/sql driver for tests of code that reaches
+// Postgres through db.DB. Each statement is answered by a handler registered
+// for a fragment of its text, so a test can model just the rows it needs.
+package dbtest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"labelops-backend/db"
+)
+
+// Result is what a handler answers a statement with: rows for a query, and
+// the number of rows affected for a statement run with Exec
+type Result struct {
+	Columns  []string
+	Rows     [][]driver.Value
+	Affected int64
+}
+
+// Handler answers one statement, given its arguments
+type Handler func(args []driver.Value) (Result, error)
+
+// Call is a statement the code under test ran
+type Call struct {
+	Query string
+	Args  []driver.Value
+}
+
+// DB answers statements with the handlers registered on it. Handlers run one
+// at a time, so they may share state without locking.
+type DB struct {
+	t        testing.TB
+	mu       sync.Mutex
+	handlers []route
+	calls    []Call
+}
+
+type route struct {
+	fragment string
+	handle   Handler
+}
+
+var drivers atomic.Int64
+
+// Open installs a DB as db.DB for the rest of the test
+func Open(t testing.TB) *DB {
+	t.Helper()
+	d := &DB{t: t}
+	name := fmt.Sprintf("dbtest-%d", drivers.Add(1))
+	sql.Register(name, d)
+	conn, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+	saved := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = saved
+		conn.Close()
+	})
+	return d
+}
+
+// On answers statements containing fragment with h. Whitespace is compared
+// loosely, and a later handler wins over an earlier one for the same statement.
+func (d *DB) On(fragment string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers = append(d.handlers, route{squash(fragment), h})
+}
+
+// Calls returns the statements run so far that contain fragment
+func (d *DB) Calls(fragment string) []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fragment = squash(fragment)
+	var calls []Call
+	for _, c := range d.calls {
+		if strings.Contains(c.Query, fragment) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Do runs f while no handler is running, so a test can read the state its
+// handlers share while the code under test is still busy
+func (d *DB) Do(f func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f()
+}
+
+// Row builds the Result of a query returning one row
+func Row(columns []string, values ...driver.Value) Result {
+	return Result{Columns: columns, Rows: [][]driver.Value{values}}
+}
+
+// Affected builds the Result of a statement changing n rows
+func Affected(n int64) Result {
+	return Result{Affected: n}
+}
+
+func (d *DB) run(query string, args []driver.Value) (Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	query = squash(query)
+	d.calls = append(d.calls, Call{Query: query, Args: args})
+	for i := len(d.handlers) - 1; i >= 0; i-- {
+		if strings.Contains(query, d.handlers[i].fragment) {
+			return d.handlers[i].handle(args)
+		}
+	}
+	d.t.Errorf("dbtest: no handler for %s", query)
+	return Result{}, fmt.Errorf("dbtest: no handler for statement")
+}
+
+// squash collapses runs of whitespace to single spaces
+func squash(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Open implements driver.Driver
+func (d *DB) Open(string) (driver.Conn, error) { return conn{d}, nil }
+
+type conn struct{ db *DB }
+
+func (c conn) Prepare(query string) (driver.Stmt, error) { return stmt{c.db, query}, nil }
+func (conn) Close() error                                { return nil }
+func (conn) Begin() (driver.Tx, error)                   { return tx{}, nil }
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type stmt struct {
+	db    *DB
+	query string
+}
+
+func (stmt) Close() error  { return nil }
+func (stmt) NumInput() int { return -1 }
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	res, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.Affected), nil
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	res, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: res.Columns, values: res.Rows}, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
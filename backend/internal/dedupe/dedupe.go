@@ -0,0 +1,156 @@
+// Package dedupe configures how the batch endpoint recognises a row it has
+// already stored, and compares such a row with the stored label so a resend
+// with corrected values is reported as a conflict rather than an identical
+// duplicate. Matching itself runs in the batch_label_process procedure.
+package dedupe
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// What a row is matched on
+const (
+	MatchLabelID     = "label_id"     // the row's ID
+	MatchComposite   = "composite"    // PQD, HEAT_NO and BUNDLE_NO together
+	MatchContentHash = "content_hash" // every stored field except the ID
+)
+
+// ValidMatch reports whether match is a known matching policy
+func ValidMatch(match string) bool {
+	return match == MatchLabelID || match == MatchComposite || match == MatchContentHash
+}
+
+// Policy decides which stored labels a row is a duplicate of: those matching
+// it on Match and created within Window, or at any time when Window is 0.
+// Label IDs are unique, so a row reusing a stored ID is always a duplicate of
+// that label whatever the policy, and a window only applies to the composite
+// and content_hash policies.
+type Policy struct {
+	Match  string
+	Window time.Duration
+}
+
+// Validate reports a policy that cannot be applied as configured
+func (p Policy) Validate() error {
+	if !ValidMatch(p.Match) {
+		return fmt.Errorf("policy %q must be label_id, composite or content_hash", p.Match)
+	}
+	if p.Match == MatchLabelID && p.Window > 0 {
+		return fmt.Errorf("a window needs the composite or content_hash policy; label IDs are unique, so a reused ID is a duplicate at any age")
+	}
+	return nil
+}
+
+// WindowString is the window as a duration, or empty for no window
+func (p Policy) WindowString() string {
+	if p.Window <= 0 {
+		return ""
+	}
+	return p.Window.String()
+}
+
+// ParseWindow reads a time window as a Go duration; empty or 0 means no window
+func ParseWindow(s string) (time.Duration, error) {
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a duration such as 24h", s)
+	}
+	return d, nil
+}
+
+// PolicyFromEnv reads DUPLICATE_POLICY (default label_id) and
+// DUPLICATE_WINDOW (default none), which label_id does not take
+func PolicyFromEnv() (Policy, error) {
+	p := Policy{Match: os.Getenv("DUPLICATE_POLICY")}
+	if p.Match == "" {
+		p.Match = MatchLabelID
+	}
+	if !ValidMatch(p.Match) {
+		return p, fmt.Errorf("DUPLICATE_POLICY %q must be label_id, composite or content_hash", p.Match)
+	}
+	window, err := ParseWindow(os.Getenv("DUPLICATE_WINDOW"))
+	if err != nil {
+		return p, fmt.Errorf("DUPLICATE_WINDOW %w", err)
+	}
+	p.Window = window
+	if err := p.Validate(); err != nil {
+		return p, fmt.Errorf("DUPLICATE_WINDOW: %w", err)
+	}
+	return p, nil
+}
+
+// FieldDiff is a field whose value differs between a row and the stored label it matched
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Stored   string `json:"stored"`
+	Received string `json:"received"`
+}
+
+// Duplicate is a row of a batch that matched a stored label. It is identical
+// when Diff is empty and a conflict otherwise.
+type Duplicate struct {
+	ID              string      `json:"id"`         // the row's ID
+	MatchedBy       string      `json:"matched_by"` // the policy that matched, or label_id for a reused ID
+	ExistingID      uuid.UUID   `json:"existing_id"`
+	ExistingLabelID string      `json:"existing_label_id"`
+	Diff            []FieldDiff `json:"diff,omitempty"`
+}
+
+// Compare lists the stored fields whose values differ between a row and a
+// stored label, in the row's field order. Values are compared as stored, so a
+// bundle number sent with leading zeros matches the stored number.
+func Compare(received models.LabelData, stored models.Label) []FieldDiff {
+	optional := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	bundleNo := func(s string) string {
+		if n, err := strconv.Atoi(s); err == nil {
+			return strconv.Itoa(n)
+		}
+		return s
+	}
+	pairs := []struct {
+		field            string
+		stored, received string
+	}{
+		{"ID", stored.LabelID, received.ID},
+		{"LOCATION", optional(stored.Location), optional(received.LOCATION)},
+		{"BUNDLE_NO", bundleNo(stored.BundleNo), bundleNo(received.BUNDLE_NO)},
+		{"BUNDLE_TYPE", stored.BundleType, received.BUNDLE_TYPE},
+		{"PQD", stored.PQD, received.PQD},
+		{"UNIT", stored.Unit, received.UNIT},
+		{"TIME", stored.Time, received.TIME},
+		{"LENGTH", strconv.Itoa(stored.Length), strconv.Itoa(received.LENGTH)},
+		{"HEAT_NO", stored.HeatNo, received.HEAT_NO},
+		{"PRODUCT_HEADING", stored.ProductHeading, received.PRODUCT_HEADING},
+		{"ISI_BOTTOM", stored.IsiBottom, received.ISI_BOTTOM},
+		{"ISI_TOP", stored.IsiTop, received.ISI_TOP},
+		{"CHARGE_DTM", stored.ChargeDtm, received.CHARGE_DTM},
+		{"MILL", stored.Mill, received.MILL},
+		{"GRADE", stored.Grade, received.GRADE},
+		{"URL_APIKEY", stored.UrlApikey, received.URL_APIKEY},
+		{"WEIGHT", optional(stored.Weight), optional(received.WEIGHT)},
+		{"SECTION", stored.Section, received.SECTION},
+		{"DATE", stored.Date, received.DATE},
+	}
+	var diff []FieldDiff
+	for _, p := range pairs {
+		if p.stored != p.received {
+			diff = append(diff, FieldDiff{Field: p.field, Stored: p.stored, Received: p.received})
+		}
+	}
+	return diff
+}
@@ -0,0 +1,94 @@
+package dedupe_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"labelops-backend/internal/dedupe"
+	"labelops-backend/models"
+)
+
+func TestCompare(t *testing.T) {
+	weight := "2.15"
+	stored := models.Label{
+		LabelID: "2025015212", BundleNo: "2025015212", BundleType: "DOUBLE", PQD: "100080004004005372", Unit: "SAIL-BSP",
+		Time: "13:55", Length: 12000, HeatNo: "C103247", ProductHeading: "ANGLE", IsiBottom: "CML 57534",
+		IsiTop: "IS 2062:2011", Mill: "MM", Grade: "IS 2062 E250BR", UrlApikey: "c1a05e9bcdae44b590944f014dc00320",
+		Weight: &weight, Section: "ANGLE 65*65*6", Date: "01-JUL-25",
+	}
+	received := models.LabelData{
+		ID: "2025015212", BUNDLE_NO: "2025015212", BUNDLE_TYPE: "DOUBLE", PQD: "100080004004005372", UNIT: "SAIL-BSP",
+		TIME: "13:55", LENGTH: 12000, HEAT_NO: "C103247", PRODUCT_HEADING: "ANGLE", ISI_BOTTOM: "CML 57534",
+		ISI_TOP: "IS 2062:2011", MILL: "MM", GRADE: "IS 2062 E250BR", URL_APIKEY: "c1a05e9bcdae44b590944f014dc00320",
+		WEIGHT: &weight, SECTION: "ANGLE 65*65*6", DATE: "01-JUL-25",
+	}
+
+	tests := []struct {
+		name   string
+		change func(d *models.LabelData)
+		want   []dedupe.FieldDiff
+	}{
+		{"identical", func(d *models.LabelData) {}, nil},
+		{"leading zeros", func(d *models.LabelData) { d.BUNDLE_NO = "02025015212" }, nil},
+		{"corrected weight", func(d *models.LabelData) { w := "2.18"; d.WEIGHT = &w },
+			[]dedupe.FieldDiff{{Field: "WEIGHT", Stored: "2.15", Received: "2.18"}}},
+		{"missing weight", func(d *models.LabelData) { d.WEIGHT = nil },
+			[]dedupe.FieldDiff{{Field: "WEIGHT", Stored: "2.15", Received: ""}}},
+		{"changed bundle type", func(d *models.LabelData) { d.BUNDLE_TYPE = "LONG" },
+			[]dedupe.FieldDiff{{Field: "BUNDLE_TYPE", Stored: "DOUBLE", Received: "LONG"}}},
+		{"new ID and length", func(d *models.LabelData) { d.ID = "2025015213"; d.LENGTH = 6000 },
+			[]dedupe.FieldDiff{
+				{Field: "ID", Stored: "2025015212", Received: "2025015213"},
+				{Field: "LENGTH", Stored: "12000", Received: "6000"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := received
+			tt.change(&d)
+			if got := dedupe.Compare(d, stored); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, true},
+		{"0", 0, true},
+		{"24h", 24 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"-1h", 0, false},
+		{"1 day", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := dedupe.ParseWindow(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseWindow(%q) = %v, %v; want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy dedupe.Policy
+		ok     bool
+	}{
+		{dedupe.Policy{Match: dedupe.MatchLabelID}, true},
+		{dedupe.Policy{Match: dedupe.MatchLabelID, Window: time.Hour}, false},
+		{dedupe.Policy{Match: dedupe.MatchComposite, Window: time.Hour}, true},
+		{dedupe.Policy{Match: dedupe.MatchContentHash, Window: time.Hour}, true},
+		{dedupe.Policy{Match: "heat_no"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v.Validate() = %v, want ok %v", tt.policy, err, tt.ok)
+		}
+	}
+}
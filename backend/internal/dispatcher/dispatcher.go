@@ -0,0 +1,549 @@
+package dispatcher
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/lifecycle"
+	"labelops-backend/internal/printer"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Print job statuses managed by the dispatcher (see retry.go for the retry statuses)
+const (
+	StatusPending   = "pending"
+	StatusQueued    = "queued"
+	StatusPrinting  = "printing"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled" // the label was voided before the job was sent
+)
+
+// Config controls the dispatcher worker pool
+type Config struct {
+	Workers      int           // maximum jobs claimed across all printers
+	PerPrinter   int           // maximum concurrent jobs per printer
+	PollInterval time.Duration // how often pending jobs are claimed
+	PrintTimeout time.Duration // upper bound for sending a single job
+	StaleAfter   time.Duration // claimed jobs untouched for this long are recovered on start
+	RetryBase    time.Duration // delay before the first automatic retry
+	RetryMax     time.Duration // upper bound for the retry delay
+	BatchSize    int           // maximum jobs sent to a printer in one batch
+}
+
+// ConfigFromEnv reads the dispatcher configuration from DISPATCHER_* environment variables
+func ConfigFromEnv() Config {
+	return Config{
+		Workers:      envInt("DISPATCHER_WORKERS", 8),
+		PerPrinter:   envInt("DISPATCHER_WORKERS_PER_PRINTER", 1),
+		PollInterval: envDuration("DISPATCHER_POLL_INTERVAL", time.Second),
+		PrintTimeout: envDuration("DISPATCHER_PRINT_TIMEOUT", 30*time.Second),
+		StaleAfter:   envDuration("DISPATCHER_STALE_AFTER", 5*time.Minute),
+		RetryBase:    envDuration("DISPATCHER_RETRY_BASE", 10*time.Second),
+		RetryMax:     envDuration("DISPATCHER_RETRY_MAX", 10*time.Minute),
+		BatchSize:    envInt("DISPATCHER_BATCH_SIZE", 20),
+	}
+}
+
+// job is a claimed print job
+type job struct {
+	ID         uuid.UUID
+	LabelID    uuid.UUID
+	PrinterID  *uuid.UUID
+	ZPLContent string
+	RetryCount int
+	MaxRetries int
+	Legacy     bool // ZPL rendered before jobs recorded their template version
+	Void       bool // a VOID label, which does not move its label through the lifecycle
+}
+
+// printerKey identifies a printer queue; jobs without a printer use the environment printer
+func (j job) printerKey() string {
+	if j.PrinterID == nil {
+		return ""
+	}
+	return j.PrinterID.String()
+}
+
+// printerQueue feeds claimed jobs to the workers of one printer
+type printerQueue struct {
+	jobs     chan job
+	inflight int // jobs claimed but not finished; guarded by Dispatcher.mu
+}
+
+// HealthChecker reports printers that are currently unable to print
+type HealthChecker interface {
+	Faulted() []uuid.UUID
+}
+
+// Dispatcher claims pending print jobs and sends them to their printers
+type Dispatcher struct {
+	cfg    Config
+	health HealthChecker
+	render labelrender.Renderer
+
+	mu       sync.Mutex
+	queues   map[string]*printerQueue
+	inflight int
+
+	stop     chan struct{}
+	stopping bool
+	loopDone chan struct{}
+	workers  sync.WaitGroup
+}
+
+// New creates a dispatcher; call Start to begin processing
+func New(cfg Config) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.PerPrinter <= 0 {
+		cfg.PerPrinter = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.PrintTimeout <= 0 {
+		cfg.PrintTimeout = 30 * time.Second
+	}
+	if cfg.RetryBase <= 0 {
+		cfg.RetryBase = 10 * time.Second
+	}
+	if cfg.RetryMax < cfg.RetryBase {
+		cfg.RetryMax = cfg.RetryBase
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1
+	}
+	return &Dispatcher{
+		cfg:      cfg,
+		render:   labelrender.Default,
+		queues:   map[string]*printerQueue{},
+		stop:     make(chan struct{}),
+		loopDone: make(chan struct{}),
+	}
+}
+
+// HoldFaultedPrinters makes the dispatcher leave jobs pending while their printer
+// reports an error, instead of spending retries on it. Call before Start.
+func (d *Dispatcher) HoldFaultedPrinters(h HealthChecker) {
+	d.health = h
+}
+
+// Start recovers stale jobs and launches the claim loop
+func (d *Dispatcher) Start() {
+	if err := d.recover(); err != nil {
+		log.Printf("dispatcher: failed to recover stale jobs: %v", err)
+	}
+	go d.loop()
+	log.Printf("🖨️  Print dispatcher started (%d workers, %d per printer)", d.cfg.Workers, d.cfg.PerPrinter)
+}
+
+// Shutdown stops claiming new jobs and waits for running jobs to finish.
+// Jobs that were claimed but not yet started are released back to pending.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if d.stopping {
+		d.mu.Unlock()
+		return nil
+	}
+	d.stopping = true
+	close(d.stop)
+	d.mu.Unlock()
+
+	<-d.loopDone
+
+	d.mu.Lock()
+	for _, q := range d.queues {
+		close(q.jobs)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("🖨️  Print dispatcher stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("dispatcher shutdown: %w", ctx.Err())
+	}
+}
+
+// loop claims pending jobs every poll interval until stopped
+func (d *Dispatcher) loop() {
+	defer close(d.loopDone)
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.claim(); err != nil {
+			log.Printf("dispatcher: claim failed: %v", err)
+		}
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recover makes jobs orphaned by a previous crash visible again.
+// Queued jobs never reached a printer and go back to pending; jobs caught mid-print
+// are failed rather than resent, since the printer may already have produced the label.
+func (d *Dispatcher) recover() error {
+	if d.cfg.StaleAfter <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-d.cfg.StaleAfter)
+
+	if _, err := db.DB.Exec(`
+		UPDATE print_jobs SET status = $1, updated_at = NOW()
+		WHERE status = $2 AND updated_at < $3
+	`, StatusPending, StatusQueued, cutoff); err != nil {
+		return err
+	}
+	rows, err := db.DB.Query(`
+		UPDATE print_jobs SET status = $1, error_message = $2, updated_at = NOW()
+		WHERE status = $3 AND updated_at < $4
+		RETURNING id, label_id, kind = 'void'
+	`, StatusFailed, "interrupted while printing", StatusPrinting, cutoff)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var failed []job
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.ID, &j.LabelID, &j.Void); err != nil {
+			return err
+		}
+		failed = append(failed, j)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, j := range failed {
+		labelFailed(j)
+	}
+	return nil
+}
+
+// claim moves pending jobs for healthy printers with spare capacity to queued and hands them to workers.
+// Jobs of voided labels are left alone; only their VOID labels still print.
+func (d *Dispatcher) claim() error {
+	d.mu.Lock()
+	free := d.cfg.Workers - d.inflight
+	busy := []string{}
+	for key, q := range d.queues {
+		if q.inflight >= d.cfg.PerPrinter {
+			busy = append(busy, key)
+		}
+	}
+	d.mu.Unlock()
+
+	if free <= 0 {
+		return nil
+	}
+	if d.health != nil {
+		for _, id := range d.health.Faulted() {
+			busy = append(busy, id.String())
+		}
+	}
+
+	rows, err := db.DB.Query(`
+		UPDATE print_jobs SET status = $1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM print_jobs
+			WHERE (status = $2 OR (status = $3 AND COALESCE(next_attempt_at, NOW()) <= NOW()))
+			  AND NOT (COALESCE(printer_id::text, '') = ANY($4))
+			  AND (kind = 'void' OR NOT EXISTS (
+			      SELECT 1 FROM labels l WHERE l.id = print_jobs.label_id AND l.status = 'voided'))
+			ORDER BY created_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, label_id, printer_id, zpl_content, retry_count, max_retries,
+		          template_id IS NULL AND language = 'zpl' AND kind = 'label', kind = 'void'
+	`, StatusQueued, StatusPending, StatusRetrying, pq.Array(busy), free)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var claimed []job
+	for rows.Next() {
+		var (
+			j         job
+			printerID uuid.NullUUID
+		)
+		if err := rows.Scan(&j.ID, &j.LabelID, &printerID, &j.ZPLContent, &j.RetryCount, &j.MaxRetries, &j.Legacy, &j.Void); err != nil {
+			return err
+		}
+		if printerID.Valid {
+			j.PrinterID = &printerID.UUID
+		}
+		claimed = append(claimed, j)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, j := range claimed {
+		d.enqueue(j)
+	}
+	return nil
+}
+
+// enqueue hands a claimed job to its printer queue, starting the printer's workers on first use
+func (d *Dispatcher) enqueue(j job) {
+	d.mu.Lock()
+	key := j.printerKey()
+	q, ok := d.queues[key]
+	if !ok {
+		q = &printerQueue{jobs: make(chan job, d.cfg.Workers)}
+		d.queues[key] = q
+		for i := 0; i < d.cfg.PerPrinter; i++ {
+			d.workers.Add(1)
+			go d.worker(q)
+		}
+	}
+	q.inflight++
+	d.inflight++
+	d.mu.Unlock()
+
+	q.jobs <- j
+}
+
+// worker prints jobs from a printer queue until the queue is closed.
+// Jobs already waiting in the queue are picked up together and sent as one batch.
+func (d *Dispatcher) worker(q *printerQueue) {
+	defer d.workers.Done()
+
+	for j := range q.jobs {
+		batch := []job{j}
+	drain:
+		for len(batch) < d.cfg.BatchSize {
+			select {
+			case next, ok := <-q.jobs:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		d.mu.Lock()
+		stopping := d.stopping
+		d.mu.Unlock()
+
+		if stopping {
+			for _, j := range batch {
+				d.release(j)
+			}
+		} else {
+			d.process(batch)
+		}
+
+		d.mu.Lock()
+		q.inflight -= len(batch)
+		d.inflight -= len(batch)
+		d.mu.Unlock()
+	}
+}
+
+// release returns a claimed job to pending so another dispatcher can pick it up
+func (d *Dispatcher) release(j job) {
+	if _, err := db.DB.Exec(`
+		UPDATE print_jobs SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3
+	`, StatusPending, j.ID, StatusQueued); err != nil {
+		log.Printf("dispatcher: failed to release job %s: %v", j.ID, err)
+	}
+}
+
+// process sends a batch of jobs for one printer and records each job's outcome on its own
+func (d *Dispatcher) process(batch []job) {
+	byID := make(map[string]job, len(batch))
+	printJobs := make([]printer.Job, 0, len(batch))
+	sending := batch[:0:0]
+	for i := range batch {
+		if !startPrinting(batch[i].ID) {
+			continue
+		}
+		if batch[i].Legacy {
+			d.rerender(&batch[i])
+		}
+		j := batch[i]
+		sending = append(sending, j)
+		byID[j.ID.String()] = j
+		printJobs = append(printJobs, printer.Job{ID: j.ID.String(), Data: []byte(j.ZPLContent)})
+	}
+	if len(sending) == 0 {
+		return
+	}
+	batch = sending
+
+	p, err := printerFor(batch[0].PrinterID)
+	if err != nil {
+		for _, j := range batch {
+			d.fail(j, printer.Result{JobID: j.ID.String(), Err: err})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PrintTimeout*time.Duration(len(batch)))
+	defer cancel()
+
+	printer.PrintBatch(ctx, p, printJobs, func(r printer.Result) {
+		j := byID[r.JobID]
+		if r.Success() {
+			complete(j, r)
+		} else {
+			d.fail(j, r)
+		}
+	})
+}
+
+// complete records a successfully printed job and moves its label on in the
+// same transaction, so a printed job never leaves its label behind
+func complete(j job, r printer.Result) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("dispatcher: failed updating job %s: %v", j.ID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE print_jobs
+		SET status = $1, error_message = NULL, bytes_sent = $2, duration_ms = $3,
+		    printed_at = NOW(), updated_at = NOW()
+		WHERE id = $4
+	`, StatusCompleted, r.BytesSent, r.Duration.Milliseconds(), j.ID)
+	if err == nil {
+		err = advanceLabel(tx, j, lifecycle.AfterPrint)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("dispatcher: failed updating job %s and its label %s: %v", j.ID, j.LabelID, err)
+	}
+}
+
+// labelFailed moves the label of a job that will not print again to failed,
+// unless another of its jobs is still waiting to print
+func labelFailed(j job) {
+	if j.Void {
+		return
+	}
+	var waiting bool
+	err := db.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM print_jobs WHERE label_id = $1 AND id <> $2 AND kind = 'label' AND status IN ($3, $4, $5, $6))
+	`, j.LabelID, j.ID, StatusPending, StatusQueued, StatusPrinting, StatusRetrying).Scan(&waiting)
+	if err != nil {
+		log.Printf("dispatcher: failed checking jobs of label %s: %v", j.LabelID, err)
+		return
+	}
+	if waiting {
+		return
+	}
+	tx, err := db.DB.Begin()
+	if err == nil {
+		defer tx.Rollback()
+		err = advanceLabel(tx, j, lifecycle.AfterFailure)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("dispatcher: failed updating status of label %s for job %s: %v", j.LabelID, j.ID, err)
+	}
+}
+
+// advanceLabel moves the label of a job through its lifecycle in tx once the
+// job has printed or failed. VOID labels leave their label as it is.
+func advanceLabel(tx *sql.Tx, j job, next func(from string) string) error {
+	if j.Void {
+		return nil
+	}
+	_, err := lifecycle.Advance(tx, j.LabelID, next, lifecycle.Event{PrintJobID: &j.ID})
+	return err
+}
+
+// printerFor builds the driver for a registered printer, or the environment printer when id is nil
+func printerFor(id *uuid.UUID) (printer.Printer, error) {
+	if id == nil {
+		return printer.NewFromEnv()
+	}
+	m, err := printer.Get(*id)
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsActive {
+		return nil, fmt.Errorf("printer %s is inactive", m.Name)
+	}
+	return printer.FromModel(m)
+}
+
+// startPrinting moves a claimed job to printing. It reports false when the job
+// is no longer queued, e.g. it was cancelled because its label was voided after
+// the job was claimed, and must not be sent.
+func startPrinting(id uuid.UUID) bool {
+	res, err := db.DB.Exec(`
+		UPDATE print_jobs
+		SET status = $1, error_message = NULL, updated_at = NOW()
+		WHERE id = $2 AND status = $3
+	`, StatusPrinting, id, StatusQueued)
+	if err != nil {
+		log.Printf("dispatcher: failed updating job %s: %v", id, err)
+		return false
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("dispatcher: failed updating job %s: %v", id, err)
+		return false
+	}
+	if n == 0 {
+		log.Printf("dispatcher: job %s is no longer queued, not sending it", id)
+	}
+	return n > 0
+}
+
+func envInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+		log.Printf("dispatcher: invalid %s=%q, using %d", key, v, def)
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		log.Printf("dispatcher: invalid %s=%q, using %s", key, v, def)
+	}
+	return def
+}
@@ -0,0 +1,460 @@
+package dispatcher
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"labelops-backend/internal/dbtest"
+	"labelops-backend/internal/printer"
+	"labelops-backend/internal/printer/fake"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// fakeJob is a print_jobs row of the fake database
+type fakeJob struct {
+	id, label   uuid.UUID
+	printer     *uuid.UUID
+	status      string
+	kind        string
+	retries     int
+	maxRetries  int
+	nextAttempt *time.Time
+	updatedAt   time.Time
+}
+
+// store models the print_jobs, labels and printers rows the dispatcher reads
+// and writes, answering its statements through dbtest
+type store struct {
+	db       *dbtest.DB
+	jobs     []*fakeJob
+	labels   map[uuid.UUID]string
+	printers map[uuid.UUID]string // printer address
+	events   []string             // label moves, as from>to
+	busy     [][]string           // printers each claim left out
+	sending  map[string]int       // jobs printing, by printer key
+	peak     map[string]int       // most jobs printing at once, by printer key
+}
+
+func newStore(t *testing.T) *store {
+	s := &store{db: dbtest.Open(t), labels: map[uuid.UUID]string{}, printers: map[uuid.UUID]string{},
+		sending: map[string]int{}, peak: map[string]int{}}
+	columns := func(names string) []string { return strings.Split(names, ",") }
+
+	s.db.On("FOR UPDATE SKIP LOCKED", func(args []driver.Value) (dbtest.Result, error) {
+		busy := parseArray(args[3].(string))
+		s.busy = append(s.busy, busy)
+		res := dbtest.Result{Columns: columns("id,label_id,printer_id,zpl_content,retry_count,max_retries,legacy,void")}
+		for _, j := range s.jobs {
+			if int64(len(res.Rows)) >= args[4].(int64) {
+				break
+			}
+			due := j.status == args[1] || (j.status == args[2] && (j.nextAttempt == nil || !j.nextAttempt.After(time.Now())))
+			if !due || contains(busy, key(j.printer)) || (j.kind == "label" && s.labels[j.label] == models.LabelStatusVoided) {
+				continue
+			}
+			j.status = args[0].(string)
+			var printerID driver.Value
+			if j.printer != nil {
+				printerID = j.printer.String()
+			}
+			res.Rows = append(res.Rows, []driver.Value{j.id.String(), j.label.String(), printerID,
+				"^XA^FD" + j.id.String() + "^FS^XZ", int64(j.retries), int64(j.maxRetries), false, j.kind == "void"})
+		}
+		return res, nil
+	})
+	// startPrinting
+	s.db.On("SET status = $1, error_message = NULL, updated_at = NOW() WHERE id = $2 AND status = $3",
+		func(args []driver.Value) (dbtest.Result, error) {
+			j := s.job(args[1])
+			if j.status != args[2] {
+				return dbtest.Affected(0), nil
+			}
+			j.status = args[0].(string)
+			k := key(j.printer)
+			s.sending[k]++
+			if s.sending[k] > s.peak[k] {
+				s.peak[k] = s.sending[k]
+			}
+			return dbtest.Affected(1), nil
+		})
+	// release
+	s.db.On("UPDATE print_jobs SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3",
+		func(args []driver.Value) (dbtest.Result, error) {
+			if j := s.job(args[1]); j.status == args[2] {
+				j.status = args[0].(string)
+			}
+			return dbtest.Affected(1), nil
+		})
+	// complete
+	s.db.On("printed_at = NOW()", func(args []driver.Value) (dbtest.Result, error) {
+		j := s.job(args[3])
+		j.status = args[0].(string)
+		s.sending[key(j.printer)]--
+		return dbtest.Affected(1), nil
+	})
+	// fail
+	s.db.On("RETURNING j.status", func(args []driver.Value) (dbtest.Result, error) {
+		j := s.job(args[6])
+		if j.status != args[8] {
+			return dbtest.Result{Columns: columns("status")}, nil
+		}
+		j.status = args[0].(string)
+		s.sending[key(j.printer)]--
+		j.nextAttempt = nil
+		if t, ok := args[3].(time.Time); ok {
+			j.nextAttempt = &t
+		}
+		if j.kind == "label" && s.labels[j.label] == models.LabelStatusVoided {
+			j.status, j.nextAttempt = args[7].(string), nil
+		}
+		j.retries += int(args[2].(int64))
+		return dbtest.Row(columns("status"), j.status), nil
+	})
+	// labelFailed
+	s.db.On("SELECT EXISTS (SELECT 1 FROM print_jobs", func(args []driver.Value) (dbtest.Result, error) {
+		waiting := false
+		for _, j := range s.jobs {
+			if j.label.String() == args[0] && j.id.String() != args[1] && j.kind == "label" &&
+				(j.status == args[2] || j.status == args[3] || j.status == args[4] || j.status == args[5]) {
+				waiting = true
+			}
+		}
+		return dbtest.Row(columns("exists"), waiting), nil
+	})
+	// recover
+	s.db.On("WHERE status = $2 AND updated_at < $3", func(args []driver.Value) (dbtest.Result, error) {
+		for _, j := range s.jobs {
+			if j.status == args[1] && j.updatedAt.Before(args[2].(time.Time)) {
+				j.status = args[0].(string)
+			}
+		}
+		return dbtest.Affected(1), nil
+	})
+	s.db.On("RETURNING id, label_id, kind = 'void'", func(args []driver.Value) (dbtest.Result, error) {
+		res := dbtest.Result{Columns: columns("id,label_id,void")}
+		for _, j := range s.jobs {
+			if j.status == args[2] && j.updatedAt.Before(args[3].(time.Time)) {
+				j.status = args[0].(string)
+				res.Rows = append(res.Rows, []driver.Value{j.id.String(), j.label.String(), j.kind == "void"})
+			}
+		}
+		return res, nil
+	})
+	// lifecycle.Advance
+	s.db.On("SELECT status FROM labels WHERE id = $1 FOR UPDATE", func(args []driver.Value) (dbtest.Result, error) {
+		return dbtest.Row(columns("status"), s.labels[uuid.MustParse(args[0].(string))]), nil
+	})
+	s.db.On("UPDATE labels SET status = $2", func(args []driver.Value) (dbtest.Result, error) {
+		id := uuid.MustParse(args[0].(string))
+		s.events = append(s.events, s.labels[id]+">"+args[1].(string))
+		s.labels[id] = args[1].(string)
+		return dbtest.Affected(1), nil
+	})
+	s.db.On("INSERT INTO label_events", func(args []driver.Value) (dbtest.Result, error) {
+		return dbtest.Row(columns("id,created_at"), int64(len(s.events)), time.Now()), nil
+	})
+	s.db.On("FROM printers WHERE id = $1", func(args []driver.Value) (dbtest.Result, error) {
+		id := uuid.MustParse(args[0].(string))
+		host, port, _ := net.SplitHostPort(s.printers[id])
+		now := time.Now()
+		return dbtest.Row(columns("id,name,driver,language,host,port,device_path,dpi,label_width,label_length,"+
+			"mill,location,is_default,is_active,media_profile_id,created_at,updated_at"),
+			id.String(), "printer-"+port, models.PrinterDriverTCP, models.PrinterLanguageZPL, host, port, nil,
+			int64(203), int64(812), int64(609), nil, nil, false, true, nil, now, now), nil
+	})
+	return s
+}
+
+// add stores a job for a label, creating the label with the status a label
+// with a waiting job has
+func (s *store) add(status string, printerID *uuid.UUID) *fakeJob {
+	j := &fakeJob{id: uuid.New(), label: uuid.New(), printer: printerID, status: status, kind: "label",
+		maxRetries: 3, updatedAt: time.Now()}
+	s.labels[j.label] = models.LabelStatusQueued
+	s.jobs = append(s.jobs, j)
+	return j
+}
+
+// printer registers a fake printer
+func (s *store) printer(t *testing.T) (*uuid.UUID, *fake.Server) {
+	t.Helper()
+	srv, err := fake.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	id := uuid.New()
+	s.printers[id] = srv.Addr()
+	return &id, srv
+}
+
+// job finds a job by the ID a statement was given
+func (s *store) job(id driver.Value) *fakeJob {
+	for _, j := range s.jobs {
+		if j.id.String() == id {
+			return j
+		}
+	}
+	panic("no job " + id.(string))
+}
+
+// status reads the statuses of a job and its label
+func (s *store) status(j *fakeJob) (job, label string) {
+	s.db.Do(func() { job, label = j.status, s.labels[j.label] })
+	return job, label
+}
+
+// parseArray reads the text form of a Postgres text array
+func parseArray(s string) []string {
+	s = strings.Trim(s, "{}")
+	if s == "" {
+		return nil
+	}
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		values = append(values, strings.Trim(v, `"`))
+	}
+	return values
+}
+
+func key(id *uuid.UUID) string {
+	return job{PrinterID: id}.printerKey()
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// startPrinter runs a fake printer and makes it the environment printer
+func startPrinter(t *testing.T) *fake.Server {
+	t.Helper()
+	srv, err := fake.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	host, port, _ := net.SplitHostPort(srv.Addr())
+	t.Setenv("PRINTER_HOST", host)
+	t.Setenv("PRINTER_PORT", port)
+	return srv
+}
+
+// start runs a dispatcher until the test ends
+func start(t *testing.T, cfg Config) *Dispatcher {
+	t.Helper()
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 10 * time.Millisecond
+	}
+	if cfg.PrintTimeout == 0 {
+		cfg.PrintTimeout = 2 * time.Second
+	}
+	d := New(cfg)
+	d.Start()
+	t.Cleanup(func() { d.Shutdown(context.Background()) })
+	return d
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// A job whose label is voided while it prints must not print again when it fails
+func TestVoidWhilePrintingThenFailure(t *testing.T) {
+	s := newStore(t)
+	srv := startPrinter(t)
+
+	printing := s.add(StatusPrinting, nil)
+	voidJob := &fakeJob{id: uuid.New(), label: printing.label, status: StatusPending, kind: "void", maxRetries: 3}
+	s.jobs = append(s.jobs, voidJob)
+	s.labels[printing.label] = models.LabelStatusVoided
+
+	d := New(Config{RetryBase: time.Millisecond})
+	d.fail(job{ID: printing.id, LabelID: printing.label, MaxRetries: 3}, printer.Result{Err: errors.New("connection reset")})
+	if job, _ := s.status(printing); job != StatusCancelled {
+		t.Fatalf("failed job of a voided label is %s, want %s", job, StatusCancelled)
+	}
+
+	start(t, Config{})
+	waitFor(t, "the VOID label to print", func() bool {
+		job, _ := s.status(voidJob)
+		return job == StatusCompleted && len(srv.Labels()) > 0
+	})
+	if job, label := s.status(printing); job != StatusCancelled || label != models.LabelStatusVoided {
+		t.Errorf("job %s, label %s; want the job cancelled and the label voided", job, label)
+	}
+	if labels := srv.Labels(); len(labels) != 1 || !strings.Contains(labels[0].ZPL, voidJob.id.String()) {
+		t.Errorf("printer received %d labels, want only the VOID label", len(labels))
+	}
+}
+
+// statuses reads the status of each job
+func (s *store) statuses(jobs ...*fakeJob) []string {
+	var statuses []string
+	s.db.Do(func() {
+		for _, j := range jobs {
+			statuses = append(statuses, j.status)
+		}
+	})
+	return statuses
+}
+
+func allAre(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s != status {
+			return false
+		}
+	}
+	return true
+}
+
+func TestClaimPrintsPendingJobs(t *testing.T) {
+	s := newStore(t)
+	srv := startPrinter(t)
+	jobs := []*fakeJob{s.add(StatusPending, nil), s.add(StatusPending, nil), s.add(StatusPending, nil)}
+	retrying := s.add(StatusRetrying, nil)
+	later := time.Now().Add(time.Hour)
+	retrying.nextAttempt = &later
+
+	start(t, Config{Workers: 2})
+	waitFor(t, "the pending jobs to print", func() bool {
+		return allAre(s.statuses(jobs...), StatusCompleted) && len(srv.Labels()) == len(jobs)
+	})
+
+	for i, l := range srv.Labels() {
+		if !strings.Contains(l.ZPL, jobs[i].id.String()) {
+			t.Errorf("label %d is not job %d", i+1, i+1)
+		}
+	}
+	for _, j := range jobs {
+		if _, label := s.status(j); label != models.LabelStatusPrinted {
+			t.Errorf("label of a printed job is %s, want %s", label, models.LabelStatusPrinted)
+		}
+	}
+	if job, _ := s.status(retrying); job != StatusRetrying {
+		t.Errorf("job retrying in an hour is %s, want it left %s", job, StatusRetrying)
+	}
+}
+
+func TestOneJobAtATimePerPrinter(t *testing.T) {
+	s := newStore(t)
+	var jobs []*fakeJob
+	printers := map[string]*fake.Server{}
+	for i := 0; i < 2; i++ {
+		id, srv := s.printer(t)
+		printers[id.String()] = srv
+		for n := 0; n < 3; n++ {
+			jobs = append(jobs, s.add(StatusPending, id))
+		}
+	}
+
+	start(t, Config{Workers: 6, PerPrinter: 1, BatchSize: 1})
+	waitFor(t, "every job to print", func() bool {
+		received := 0
+		for _, srv := range printers {
+			received += len(srv.Labels())
+		}
+		return allAre(s.statuses(jobs...), StatusCompleted) && received == len(jobs)
+	})
+
+	s.db.Do(func() {
+		for key, srv := range printers {
+			if s.peak[key] != 1 {
+				t.Errorf("printer %s printed %d jobs at once, want 1", key, s.peak[key])
+			}
+			if n := len(srv.Labels()); n != 3 {
+				t.Errorf("printer %s received %d labels, want 3", key, n)
+			}
+		}
+	})
+}
+
+// faulted is a HealthChecker reporting fixed printers
+type faulted []uuid.UUID
+
+func (f faulted) Faulted() []uuid.UUID { return f }
+
+func TestClaimSkipsBusyAndFaultedPrinters(t *testing.T) {
+	s := newStore(t)
+	healthyID, healthy := s.printer(t)
+	busyID, _ := s.printer(t)
+	faultedID, _ := s.printer(t)
+	printed := s.add(StatusPending, healthyID)
+	waiting := []*fakeJob{s.add(StatusPending, busyID), s.add(StatusPending, faultedID)}
+
+	d := New(Config{Workers: 4, PerPrinter: 1, PollInterval: 10 * time.Millisecond, PrintTimeout: 2 * time.Second})
+	d.HoldFaultedPrinters(faulted{*faultedID})
+	// The busy printer's only worker is taken by a job claimed earlier
+	d.queues[busyID.String()] = &printerQueue{jobs: make(chan job), inflight: 1}
+	d.Start()
+	t.Cleanup(func() { d.Shutdown(context.Background()) })
+
+	waitFor(t, "the healthy printer's job to print", func() bool {
+		return allAre(s.statuses(printed), StatusCompleted) && len(healthy.Labels()) == 1
+	})
+	time.Sleep(50 * time.Millisecond)
+	if statuses := s.statuses(waiting...); !allAre(statuses, StatusPending) {
+		t.Errorf("jobs of busy and faulted printers are %v, want them left pending", statuses)
+	}
+	s.db.Do(func() {
+		last := s.busy[len(s.busy)-1]
+		if !contains(last, busyID.String()) || !contains(last, faultedID.String()) {
+			t.Errorf("claim left out %v, want the busy and the faulted printer", last)
+		}
+	})
+}
+
+func TestRecoverStuckJobs(t *testing.T) {
+	s := newStore(t)
+	stale := time.Now().Add(-10 * time.Minute)
+
+	stuck := s.add(StatusPrinting, nil)
+	stuck.updatedAt = stale
+	// A label with another job still waiting is not failed with its stuck job
+	stuckWithRetry := s.add(StatusPrinting, nil)
+	stuckWithRetry.updatedAt = stale
+	s.jobs = append(s.jobs, &fakeJob{id: uuid.New(), label: stuckWithRetry.label, status: StatusPending, kind: "label"})
+	printing := s.add(StatusPrinting, nil)
+	queued := s.add(StatusQueued, nil)
+	queued.updatedAt = stale
+
+	d := New(Config{StaleAfter: 5 * time.Minute})
+	if err := d.recover(); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		job           *fakeJob
+		status, label string
+	}{
+		{"stuck printing", stuck, StatusFailed, models.LabelStatusFailed},
+		{"stuck printing with a waiting job", stuckWithRetry, StatusFailed, models.LabelStatusQueued},
+		{"printing", printing, StatusPrinting, models.LabelStatusQueued},
+		{"stuck queued", queued, StatusPending, models.LabelStatusQueued},
+	}
+	for _, tt := range tests {
+		if job, label := s.status(tt.job); job != tt.status || label != tt.label {
+			t.Errorf("%s: job %s, label %s; want %s, %s", tt.name, job, label, tt.status, tt.label)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package dispatcher
+
+import (
+	"database/sql"
+	"log"
+	"math/rand"
+	"time"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/printer"
+)
+
+// Statuses for jobs that failed to print
+const (
+	StatusRetrying = "retrying" // waiting for next_attempt_at before being claimed again
+	StatusDead     = "dead"     // max_retries exhausted; only an admin requeue revives it
+)
+
+// backoff returns the delay before retry number attempt (starting at 0).
+// The delay doubles with each attempt up to max, and is jittered to
+// between half and all of that value so failed jobs don't retry in lockstep.
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// fail records a failed attempt. The job is scheduled for another attempt with
+// backoff while retries remain, otherwise it moves to the dead status. A job
+// whose label was voided while it was printing is cancelled instead, and a job
+// that is no longer printing is left as it is.
+func (d *Dispatcher) fail(j job, r printer.Result) {
+	errorMessage := r.Err.Error()
+	status := StatusRetrying
+	increment := 1
+	var nextAttempt *time.Time
+	if j.RetryCount >= j.MaxRetries {
+		status = StatusDead
+		increment = 0
+	} else {
+		t := time.Now().Add(backoff(j.RetryCount, d.cfg.RetryBase, d.cfg.RetryMax))
+		nextAttempt = &t
+	}
+
+	err := db.DB.QueryRow(`
+		UPDATE print_jobs j
+		SET status = CASE WHEN j.kind = 'label' AND l.status = 'voided' THEN $8 ELSE $1 END,
+		    retry_count = j.retry_count + $3,
+		    error_message = $2, last_error = $2,
+		    next_attempt_at = CASE WHEN j.kind = 'label' AND l.status = 'voided' THEN NULL ELSE $4 END,
+		    bytes_sent = $5, duration_ms = $6, updated_at = NOW()
+		FROM labels l
+		WHERE j.id = $7 AND j.status = $9 AND l.id = j.label_id
+		RETURNING j.status
+	`, status, errorMessage, increment, nextAttempt, r.BytesSent, r.Duration.Milliseconds(), j.ID,
+		StatusCancelled, StatusPrinting).Scan(&status)
+	if err == sql.ErrNoRows {
+		log.Printf("dispatcher: job %s failed but is no longer printing, leaving it: %s", j.ID, errorMessage)
+		return
+	}
+	if err != nil {
+		log.Printf("dispatcher: failed updating job %s: %v", j.ID, err)
+		return
+	}
+
+	switch status {
+	case StatusCancelled:
+		log.Printf("dispatcher: job %s failed after its label was voided and is cancelled: %s", j.ID, errorMessage)
+	case StatusDead:
+		log.Printf("dispatcher: job %s is dead after %d retries: %s", j.ID, j.RetryCount, errorMessage)
+		labelFailed(j)
+	default:
+		log.Printf("dispatcher: job %s failed (retry %d of %d at %s): %s",
+			j.ID, j.RetryCount+1, j.MaxRetries, nextAttempt.Format(time.RFC3339), errorMessage)
+	}
+}
+
+// rerender replaces the ZPL of a job queued before print jobs recorded their
+// template version. Those jobs may hold output of the old generic layout, so
+// retries print the label the way every other path does. The stored ZPL is
+// kept when the label can no longer be rendered.
+func (d *Dispatcher) rerender(j *job) {
+	label, err := labelrender.Load(j.LabelID)
+	if err != nil {
+		log.Printf("dispatcher: cannot load label for legacy job %s, sending stored ZPL: %v", j.ID, err)
+		return
+	}
+	// Legacy jobs predate copies, reprints and media profiles, so they print a
+	// single label at the design size
+	rendered, err := d.render.Render(label, layout.Job{})
+	if err != nil {
+		log.Printf("dispatcher: cannot render legacy job %s, sending stored ZPL: %v", j.ID, err)
+		return
+	}
+
+	templateID, templateVersion := rendered.TemplateRef()
+	_, err = db.DB.Exec(`
+		UPDATE print_jobs
+		SET zpl_content = $1, template_id = $2, template_version = $3, zpl_hash = $4, qr_hash = $5,
+		    label_version = $6, qr_url_format = $8, qr_data_format = $9, qr_sign_alg = $10, updated_at = NOW()
+		WHERE id = $7
+	`, rendered.ZPL, templateID, templateVersion, rendered.Hash, rendered.QRHash, label.Version, j.ID,
+		rendered.QR.Format.URL, rendered.QR.Format.Data, rendered.QR.SignAlg)
+	if err != nil {
+		log.Printf("dispatcher: failed to store re-rendered ZPL for job %s: %v", j.ID, err)
+		return
+	}
+	j.ZPLContent = rendered.ZPL
+	j.Legacy = false
+}
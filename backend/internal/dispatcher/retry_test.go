@@ -0,0 +1,95 @@
+package dispatcher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"labelops-backend/internal/printer"
+	"labelops-backend/models"
+)
+
+func TestBackoff(t *testing.T) {
+	base, max := 10*time.Second, 10*time.Minute
+	tests := []struct {
+		attempt int
+		ceiling time.Duration // the delay before jitter
+	}{
+		{0, 10 * time.Second},
+		{1, 20 * time.Second},
+		{2, 40 * time.Second},
+		{5, 320 * time.Second},
+		{6, 10 * time.Minute}, // 640s is capped
+		{20, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := backoff(tt.attempt, base, max); d < tt.ceiling/2 || d > tt.ceiling {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.ceiling/2, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestFailRetriesUntilDead(t *testing.T) {
+	base := time.Minute
+	tests := []struct {
+		name        string
+		retries     int
+		waiting     bool // another job of the label is still waiting to print
+		status      string
+		retryCount  int
+		delay       time.Duration // ceiling of the delay before the next attempt
+		labelStatus string
+	}{
+		{"first failure", 0, false, StatusRetrying, 1, base, models.LabelStatusQueued},
+		{"third failure", 2, false, StatusRetrying, 3, 4 * base, models.LabelStatusQueued},
+		{"retries exhausted", 3, false, StatusDead, 3, 0, models.LabelStatusFailed},
+		{"retries exhausted with another job waiting", 3, true, StatusDead, 3, 0, models.LabelStatusQueued},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			failing := s.add(StatusPrinting, nil)
+			failing.retries = tt.retries
+			if tt.waiting {
+				s.add(StatusPending, nil).label = failing.label
+			}
+
+			d := New(Config{RetryBase: base, RetryMax: time.Hour})
+			failedAt := time.Now()
+			d.fail(job{ID: failing.id, LabelID: failing.label, RetryCount: tt.retries, MaxRetries: 3},
+				printer.Result{Err: errors.New("connection refused")})
+
+			if failing.status != tt.status || failing.retries != tt.retryCount {
+				t.Errorf("job %s after %d retries, want %s after %d", failing.status, failing.retries, tt.status, tt.retryCount)
+			}
+			switch {
+			case tt.delay == 0 && failing.nextAttempt != nil:
+				t.Errorf("dead job has a next attempt at %s", failing.nextAttempt)
+			case tt.delay > 0 && failing.nextAttempt == nil:
+				t.Error("retrying job has no next attempt")
+			case tt.delay > 0:
+				if wait := failing.nextAttempt.Sub(failedAt); wait < tt.delay/2 || wait > tt.delay+time.Second {
+					t.Errorf("next attempt in %s, want between %s and %s", wait, tt.delay/2, tt.delay)
+				}
+			}
+			if label := s.labels[failing.label]; label != tt.labelStatus {
+				t.Errorf("label %s, want %s", label, tt.labelStatus)
+			}
+		})
+	}
+}
+
+// A job recovered as failed while it was still printing is not failed again
+func TestFailLeavesJobNoLongerPrinting(t *testing.T) {
+	s := newStore(t)
+	recovered := s.add(StatusFailed, nil)
+
+	New(Config{}).fail(job{ID: recovered.id, LabelID: recovered.label, MaxRetries: 3},
+		printer.Result{Err: errors.New("write: broken pipe")})
+	if recovered.status != StatusFailed || recovered.retries != 0 || recovered.nextAttempt != nil {
+		t.Errorf("job %s after %d retries, want it left failed", recovered.status, recovered.retries)
+	}
+}
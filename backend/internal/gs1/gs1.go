@@ -0,0 +1,306 @@
+// Package gs1 builds GS1 element strings: application identifiers (AIs) with
+// their values, as carried by GS1-128 and GS1 DataMatrix symbols.
+package gs1
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GS is the group separator that ends a variable length value when another
+// element follows it. Symbols encode it as FNC1 (Code 128) or ASCII 29.
+const GS = '\x1d'
+
+// AI describes an application identifier
+type AI struct {
+	Code     string
+	Title    string
+	Numeric  bool
+	Length   int  // maximum value length; the exact length when Fixed
+	Fixed    bool // fixed length values need no separator
+	Check    bool // the last digit is a GS1 check digit
+	Date     bool // a YYMMDD date
+	Decimals int  // implied decimal places of a measure, from the last digit of the AI
+	Measure  bool
+}
+
+// ais are the application identifiers labels may carry
+var ais = map[string]AI{
+	"00":  {Code: "00", Title: "SSCC", Numeric: true, Length: 18, Fixed: true, Check: true},
+	"01":  {Code: "01", Title: "GTIN", Numeric: true, Length: 14, Fixed: true, Check: true},
+	"02":  {Code: "02", Title: "CONTENT", Numeric: true, Length: 14, Fixed: true, Check: true},
+	"10":  {Code: "10", Title: "BATCH/LOT", Length: 20},
+	"11":  {Code: "11", Title: "PROD DATE", Numeric: true, Length: 6, Fixed: true, Date: true},
+	"13":  {Code: "13", Title: "PACK DATE", Numeric: true, Length: 6, Fixed: true, Date: true},
+	"15":  {Code: "15", Title: "BEST BEFORE", Numeric: true, Length: 6, Fixed: true, Date: true},
+	"17":  {Code: "17", Title: "USE BY", Numeric: true, Length: 6, Fixed: true, Date: true},
+	"21":  {Code: "21", Title: "SERIAL", Length: 20},
+	"22":  {Code: "22", Title: "CPV", Length: 20},
+	"240": {Code: "240", Title: "ADDITIONAL ID", Length: 30},
+	"241": {Code: "241", Title: "CUST. PART No.", Length: 30},
+	"30":  {Code: "30", Title: "VAR. COUNT", Numeric: true, Length: 8},
+	"37":  {Code: "37", Title: "COUNT", Numeric: true, Length: 8},
+	"400": {Code: "400", Title: "ORDER NUMBER", Length: 30},
+	"90":  {Code: "90", Title: "INTERNAL", Length: 30},
+}
+
+func init() {
+	// Measures in kilograms and metres with 0 to 5 implied decimal places
+	for d := 0; d <= 5; d++ {
+		for _, m := range []struct{ prefix, title string }{
+			{"310", "NET WEIGHT (kg)"},
+			{"311", "LENGTH (m)"},
+			{"330", "GROSS WEIGHT (kg)"},
+		} {
+			code := m.prefix + strconv.Itoa(d)
+			ais[code] = AI{Code: code, Title: m.title, Numeric: true, Length: 6, Fixed: true, Measure: true, Decimals: d}
+		}
+	}
+	// Company internal information
+	for n := 91; n <= 99; n++ {
+		code := strconv.Itoa(n)
+		ais[code] = AI{Code: code, Title: "INTERNAL", Length: 90}
+	}
+}
+
+// Lookup returns the definition of an application identifier
+func Lookup(code string) (AI, bool) {
+	ai, ok := ais[code]
+	return ai, ok
+}
+
+// Codes lists the supported application identifiers in order
+func Codes() []string {
+	codes := make([]string, 0, len(ais))
+	for code := range ais {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Error reports a value that cannot be encoded under an AI
+type Error struct {
+	AI  string
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gs1 (%s): %s", e.AI, e.Msg)
+}
+
+// Element is one AI and its value
+type Element struct {
+	AI    string
+	Value string
+}
+
+// ElementString is a sequence of elements in the order they are encoded
+type ElementString []Element
+
+// dateLayouts are the label date formats accepted for date AIs
+var dateLayouts = []string{"060102", "2006-01-02", "02-01-2006", "02/01/2006", "02.01.2006", "02-Jan-06", "02-Jan-2006", "02 Jan 2006"}
+
+// Format converts a label value to the form an AI requires: dates become
+// YYMMDD, measures are scaled to their implied decimals and zero padded, and
+// a check digit is appended when the value is one digit short.
+func Format(code, value string) (string, error) {
+	ai, ok := Lookup(code)
+	if !ok {
+		return "", &Error{AI: code, Msg: "unsupported application identifier"}
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", &Error{AI: code, Msg: "value is empty"}
+	}
+
+	switch {
+	case ai.Date:
+		for _, layout := range dateLayouts {
+			// Month names are matched case-insensitively, so 01-JUL-25 parses
+			if t, err := time.Parse(layout, titleMonth(value)); err == nil {
+				value = t.Format("060102")
+				break
+			}
+		}
+	case ai.Measure:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			return "", &Error{AI: code, Msg: fmt.Sprintf("%q is not a measure", value)}
+		}
+		scaled := strconv.FormatFloat(f*pow10(ai.Decimals), 'f', 0, 64)
+		if len(scaled) > ai.Length {
+			return "", &Error{AI: code, Msg: fmt.Sprintf("%s does not fit in %d digits with %d decimals", value, ai.Length, ai.Decimals)}
+		}
+		value = strings.Repeat("0", ai.Length-len(scaled)) + scaled
+	case ai.Check && len(value) == ai.Length-1 && isDigits(value):
+		value += string(CheckDigit(value))
+	}
+	return value, Validate(code, value)
+}
+
+// Validate checks a formatted value against its AI
+func Validate(code, value string) error {
+	ai, ok := Lookup(code)
+	if !ok {
+		return &Error{AI: code, Msg: "unsupported application identifier"}
+	}
+	switch {
+	case value == "":
+		return &Error{AI: code, Msg: "value is empty"}
+	case ai.Fixed && len(value) != ai.Length:
+		return &Error{AI: code, Msg: fmt.Sprintf("%q must have exactly %d characters", value, ai.Length)}
+	case len(value) > ai.Length:
+		return &Error{AI: code, Msg: fmt.Sprintf("%q exceeds the maximum of %d characters", value, ai.Length)}
+	case ai.Numeric && !isDigits(value):
+		return &Error{AI: code, Msg: fmt.Sprintf("%q must be numeric", value)}
+	}
+	for i := 0; i < len(value); i++ {
+		if !isCSet82(value[i]) {
+			return &Error{AI: code, Msg: fmt.Sprintf("%q contains %q, which GS1 does not allow", value, value[i])}
+		}
+	}
+	if ai.Date && !validDate(value) {
+		return &Error{AI: code, Msg: fmt.Sprintf("%q is not a YYMMDD date", value)}
+	}
+	if ai.Check && value[len(value)-1] != CheckDigit(value[:len(value)-1]) {
+		return &Error{AI: code, Msg: fmt.Sprintf("%q has an invalid check digit", value)}
+	}
+	return nil
+}
+
+// CheckDigit computes the GS1 mod 10 check digit of a numeric string: digits
+// are weighted 3 and 1 alternately from the right
+func CheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// New validates a list of elements and returns them as an element string
+func New(elements ...Element) (ElementString, error) {
+	seen := map[string]bool{}
+	for _, e := range elements {
+		if err := Validate(e.AI, e.Value); err != nil {
+			return nil, err
+		}
+		if seen[e.AI] {
+			return nil, &Error{AI: e.AI, Msg: "appears more than once"}
+		}
+		seen[e.AI] = true
+	}
+	return ElementString(elements), nil
+}
+
+// HRI is the human readable interpretation, e.g. (10)C103247(21)2025015212.
+// It is also the data format of ZPL's ^BC UCC/EAN mode.
+func (es ElementString) HRI() string {
+	var b strings.Builder
+	for _, e := range es {
+		b.WriteString("(" + e.AI + ")" + e.Value)
+	}
+	return b.String()
+}
+
+// Data is the encoded element string without the leading FNC1: AIs and values
+// run together, with GS after each variable length value that is not last
+func (es ElementString) Data() string {
+	var b strings.Builder
+	for i, e := range es {
+		b.WriteString(e.AI + e.Value)
+		if ai, _ := Lookup(e.AI); !ai.Fixed && i < len(es)-1 {
+			b.WriteByte(GS)
+		}
+	}
+	return b.String()
+}
+
+// ParseHRI reads an element string written as HRI, such as the data of a
+// ^BC UCC/EAN mode field. Each AI must be in parentheses.
+func ParseHRI(s string) (ElementString, error) {
+	var elements []Element
+	for s != "" {
+		if s[0] != '(' {
+			return nil, fmt.Errorf("gs1: expected ( at %q", s)
+		}
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, fmt.Errorf("gs1: unterminated AI in %q", s)
+		}
+		code := s[1:end]
+		s = s[end+1:]
+		next := len(s)
+		// A value runs to the next parenthesised AI this package knows
+		for i := 0; i < len(s); i++ {
+			if s[i] != '(' {
+				continue
+			}
+			if j := strings.IndexByte(s[i:], ')'); j > 0 {
+				if _, ok := Lookup(s[i+1 : i+j]); ok {
+					next = i
+					break
+				}
+			}
+		}
+		elements = append(elements, Element{AI: code, Value: s[:next]})
+		s = s[next:]
+	}
+	return New(elements...)
+}
+
+// titleMonth upper-cases the first letter of a month name and lower-cases
+// the rest, the only form time.Parse accepts
+func titleMonth(s string) string {
+	b := []byte(strings.ToLower(s))
+	for i := 0; i < len(b); i++ {
+		if b[i] >= 'a' && b[i] <= 'z' && (i == 0 || b[i-1] < 'a' || b[i-1] > 'z') {
+			b[i] -= 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// validDate checks a YYMMDD date; a day of 00 stands for the end of the month
+func validDate(v string) bool {
+	day := v[4:]
+	if day == "00" {
+		day = "01"
+	}
+	_, err := time.Parse("060102", v[:4]+day)
+	return err == nil
+}
+
+func pow10(n int) float64 {
+	f := 1.0
+	for i := 0; i < n; i++ {
+		f *= 10
+	}
+	return f
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isCSet82 reports whether c is in GS1 AI encodable character set 82
+func isCSet82(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		return true
+	}
+	return strings.IndexByte(`!"%&'()*+,-./:;<=>?_`, c) >= 0
+}
@@ -0,0 +1,69 @@
+package gs1_test
+
+import (
+	"testing"
+
+	"labelops-backend/internal/gs1"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		ai, value string
+		want      string // empty when Format must fail
+	}{
+		{"01", "0950600013435", "09506000134352"},
+		{"01", "09506000134352", "09506000134352"},
+		{"01", "09506000134353", ""},
+		{"10", "C103247", "C103247"},
+		{"10", "C103 247", ""},
+		{"11", "01-JUL-25", "250701"},
+		{"11", "2025-07-01", "250701"},
+		{"11", "31-02-2025", ""},
+		{"3103", "2.150", "002150"},
+		{"3103", "1234.5", ""},
+		{"21", "2025015212", "2025015212"},
+		{"99", "", ""},
+	}
+	for _, tt := range tests {
+		got, err := gs1.Format(tt.ai, tt.value)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Format(%s, %q) = %q, want an error", tt.ai, tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Format(%s, %q) = %q, %v; want %q", tt.ai, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestElementString(t *testing.T) {
+	es, err := gs1.New(
+		gs1.Element{AI: "01", Value: "09506000134352"},
+		gs1.Element{AI: "10", Value: "C103247"},
+		gs1.Element{AI: "21", Value: "2025015212"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := es.HRI(), "(01)09506000134352(10)C103247(21)2025015212"; got != want {
+		t.Errorf("HRI = %q, want %q", got, want)
+	}
+	// Only the variable length batch needs a separator; the serial number is last
+	if got, want := es.Data(), "0109506000134352"+"10C103247\x1d"+"212025015212"; got != want {
+		t.Errorf("Data = %q, want %q", got, want)
+	}
+
+	parsed, err := gs1.ParseHRI(es.HRI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Data() != es.Data() {
+		t.Errorf("ParseHRI round trip = %q, want %q", parsed.Data(), es.Data())
+	}
+
+	if _, err := gs1.New(gs1.Element{AI: "10", Value: "A"}, gs1.Element{AI: "10", Value: "B"}); err == nil {
+		t.Error("New accepted a repeated AI")
+	}
+}
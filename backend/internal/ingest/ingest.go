@@ -0,0 +1,336 @@
+// Package ingest validates label rows sent to the batch endpoint before they
+// are stored. Rows are decoded field by field so one malformed value is
+// reported against its row instead of failing the whole batch, and the field
+// names older mill systems send are accepted as aliases.
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"labelops-backend/models"
+)
+
+// Validation modes of a batch
+const (
+	ModePartial = "partial" // store the valid rows and report the others
+	ModeStrict  = "strict"  // store nothing when any row is invalid
+)
+
+// ValidMode reports whether mode is a known validation mode
+func ValidMode(mode string) bool {
+	return mode == ModePartial || mode == ModeStrict
+}
+
+// Aliases maps field names some senders use to the canonical ones
+var Aliases = map[string]string{
+	"DATE1":       "DATE",
+	"LABEL_ID":    "ID",
+	"HEATNO":      "HEAT_NO",
+	"BUNDLENO":    "BUNDLE_NO",
+	"BUNDLE":      "BUNDLE_NO",
+	"CHARGEDTM":   "CHARGE_DTM",
+	"PRODUCT":     "PRODUCT_HEADING",
+	"APIKEY":      "URL_APIKEY",
+	"URL_API_KEY": "URL_APIKEY",
+}
+
+// Canonical returns the canonical name of a field name or alias, and whether
+// it is a known field
+func Canonical(name string) (string, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if canonical, ok := Aliases[name]; ok {
+		name = canonical
+	}
+	_, ok := fields[name]
+	return name, ok
+}
+
+// field describes a canonical field: whether it is required, the longest
+// value its column holds and how its value is checked
+type field struct {
+	required bool
+	max      int
+	check    func(string) error
+}
+
+var fields = map[string]field{
+	"ID":              {required: true, max: 255},
+	"BUNDLE_NO":       {required: true, max: 10, check: checkBundleNo},
+	"BUNDLE_TYPE":     {max: 50},
+	"PQD":             {required: true, max: 255},
+	"UNIT":            {required: true, max: 50},
+	"TIME":            {required: true, max: 10, check: checkTime},
+	"LENGTH":          {required: true, check: checkLength},
+	"HEAT_NO":         {required: true, max: 100, check: checkHeatNo},
+	"PRODUCT_HEADING": {required: true, max: 255},
+	"ISI_BOTTOM":      {required: true, max: 255},
+	"ISI_TOP":         {required: true, max: 255},
+	"CHARGE_DTM":      {max: 255},
+	"MILL":            {required: true, max: 50},
+	"GRADE":           {required: true, max: 100},
+	"URL_APIKEY":      {required: true, max: 255},
+	"WEIGHT":          {max: 50, check: checkWeight},
+	"SECTION":         {required: true, max: 255},
+	"DATE":            {required: true, max: 20, check: checkDate},
+	"LOCATION":        {max: 100},
+}
+
+// heatNoPattern is the format of heat numbers, e.g. C103247
+var heatNoPattern = regexp.MustCompile(`^[A-Z]{1,2}[0-9]{5,7}$`)
+
+// SetHeatNoPattern replaces the heat number format with a regular
+// expression; an empty one keeps the default
+func SetHeatNoPattern(expr string) error {
+	if expr == "" {
+		return nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	heatNoPattern = re
+	return nil
+}
+
+var timePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+var datePattern = regexp.MustCompile(`^[0-9]{2}-[A-Z]{3}-[0-9]{2}$`)
+
+func checkHeatNo(s string) error {
+	if !heatNoPattern.MatchString(s) {
+		return fmt.Errorf("%q does not match the heat number format %s", s, heatNoPattern)
+	}
+	return nil
+}
+
+func checkTime(s string) error {
+	if !timePattern.MatchString(s) {
+		return fmt.Errorf("%q is not a time as HH:MM", s)
+	}
+	return nil
+}
+
+func checkDate(s string) error {
+	if !datePattern.MatchString(s) {
+		return fmt.Errorf("%q is not a date as DD-MON-YY", s)
+	}
+	if _, err := time.Parse("02-Jan-06", s); err != nil {
+		return fmt.Errorf("%q is not a valid date", s)
+	}
+	return nil
+}
+
+// checkBundleNo requires the digits the batch procedure stores as an INTEGER
+func checkBundleNo(s string) error {
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || n < 0 || strings.HasPrefix(s, "+") {
+		return fmt.Errorf("%q is not a bundle number", s)
+	}
+	return nil
+}
+
+func checkLength(s string) error {
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("%q is not a whole, positive length in millimetres", s)
+	}
+	return nil
+}
+
+func checkWeight(s string) error {
+	w, err := strconv.ParseFloat(s, 64)
+	if err != nil || w <= 0 || strings.ContainsAny(s, "eExXnN") {
+		return fmt.Errorf("%q is not a positive weight in tonnes", s)
+	}
+	return nil
+}
+
+// FieldError is a problem with one field of a row; Field is empty for the row as a whole
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// RowError lists the problems with one row of a batch
+type RowError struct {
+	Row    int          `json:"row"`          // position in the batch, from 1
+	ID     string       `json:"id,omitempty"` // the row's ID, when it has a readable one
+	Errors []FieldError `json:"errors"`
+}
+
+// Result is a validated batch: the rows that passed, in order, and the
+// problems with the others. Warnings are about rows that passed, such as an
+// aliased or unknown field.
+type Result struct {
+	Labels   []models.LabelData
+	Rows     []int // position in the batch of each label, from 1
+	Errors   []RowError
+	Warnings []RowError
+}
+
+// Batch decodes and validates the rows of a batch
+func Batch(rows []json.RawMessage) Result {
+	res := Result{Labels: []models.LabelData{}, Rows: []int{}, Errors: []RowError{}, Warnings: []RowError{}}
+	for i, raw := range rows {
+		data, errs, warnings := Row(raw)
+		if len(errs) > 0 {
+			res.Errors = append(res.Errors, RowError{Row: i + 1, ID: data.ID, Errors: errs})
+			continue
+		}
+		if len(warnings) > 0 {
+			res.Warnings = append(res.Warnings, RowError{Row: i + 1, ID: data.ID, Errors: warnings})
+		}
+		res.Labels = append(res.Labels, data)
+		res.Rows = append(res.Rows, i+1)
+	}
+	return res
+}
+
+// Row decodes and validates one row. It returns the label data, which is
+// only complete when there are no errors, the errors and the warnings.
+func Row(raw json.RawMessage) (models.LabelData, []FieldError, []FieldError) {
+	var data models.LabelData
+	var errs, warnings []FieldError
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+		return data, []FieldError{{Message: "row is not a JSON object"}}, nil
+	}
+
+	// Collect the values by canonical name, in a stable order for messages.
+	// Null values are treated as missing.
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := map[string]string{}
+	from := map[string]string{}
+	unreadable := map[string]bool{}
+	for _, key := range keys {
+		name, ok := Canonical(key)
+		if !ok {
+			warnings = append(warnings, FieldError{Field: key, Message: "unknown field ignored"})
+			continue
+		}
+		if key != name {
+			warnings = append(warnings, FieldError{Field: key, Message: "read as " + name})
+		}
+		value, isNull, err := scalar(object[key])
+		if err != nil {
+			errs = append(errs, FieldError{Field: key, Message: err.Error()})
+			unreadable[name] = true
+			continue
+		}
+		if isNull {
+			continue
+		}
+		if name == "DATE" {
+			value = strings.ToUpper(value)
+		}
+		if prev, ok := from[name]; ok {
+			if values[name] != value {
+				errs = append(errs, FieldError{Field: name, Message: fmt.Sprintf("%s and %s give different values", prev, key)})
+			}
+			continue
+		}
+		from[name] = key
+		values[name] = value
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := fields[name]
+		value := values[name]
+		switch {
+		case unreadable[name]:
+			// Reported when it was read
+			continue
+		case value == "":
+			if f.required {
+				errs = append(errs, FieldError{Field: name, Message: "is required"})
+			}
+			continue
+		case !utf8.ValidString(value):
+			errs = append(errs, FieldError{Field: name, Message: "is not valid UTF-8"})
+			continue
+		case f.max > 0 && utf8.RuneCountInString(value) > f.max:
+			errs = append(errs, FieldError{Field: name, Message: fmt.Sprintf("is longer than %d characters", f.max)})
+			continue
+		}
+		if f.check != nil {
+			if err := f.check(value); err != nil {
+				errs = append(errs, FieldError{Field: name, Message: err.Error()})
+			}
+		}
+	}
+
+	optional := func(name string) *string {
+		if values[name] == "" {
+			return nil
+		}
+		v := values[name]
+		return &v
+	}
+	length, _ := strconv.Atoi(values["LENGTH"])
+	data = models.LabelData{
+		LOCATION:        optional("LOCATION"),
+		BUNDLE_NO:       values["BUNDLE_NO"],
+		BUNDLE_TYPE:     values["BUNDLE_TYPE"],
+		PQD:             values["PQD"],
+		UNIT:            values["UNIT"],
+		TIME:            values["TIME"],
+		LENGTH:          length,
+		HEAT_NO:         values["HEAT_NO"],
+		PRODUCT_HEADING: values["PRODUCT_HEADING"],
+		ISI_BOTTOM:      values["ISI_BOTTOM"],
+		ISI_TOP:         values["ISI_TOP"],
+		CHARGE_DTM:      values["CHARGE_DTM"],
+		MILL:            values["MILL"],
+		GRADE:           values["GRADE"],
+		URL_APIKEY:      values["URL_APIKEY"],
+		ID:              values["ID"],
+		WEIGHT:          optional("WEIGHT"),
+		SECTION:         values["SECTION"],
+		DATE:            values["DATE"],
+	}
+	return data, errs, warnings
+}
+
+// scalar reads a JSON string or number as trimmed text. Numbers keep their
+// literal form, so an ID sent as a number is stored as written.
+func scalar(raw json.RawMessage) (string, bool, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", true, nil
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", false, err
+		}
+		return strings.TrimSpace(s), false, nil
+	case '{', '[':
+		return "", false, fmt.Errorf("must be a string or number")
+	case 't', 'f':
+		return "", false, fmt.Errorf("must be a string or number, not %s", raw)
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", false, err
+	}
+	return n.String(), false, nil
+}
@@ -0,0 +1,96 @@
+package ingest_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"labelops-backend/internal/ingest"
+)
+
+const validRow = `{
+	"ID": "2025015212", "BUNDLE_NO": "2025015212", "BUNDLE_TYPE": "STD", "PQD": "100080004004005372",
+	"UNIT": "SAIL-BSP", "TIME": "13:55", "LENGTH": 12000, "HEAT_NO": "C103247", "PRODUCT_HEADING": "ANGLE",
+	"ISI_BOTTOM": "CML 57534", "ISI_TOP": "IS 2062:2011", "MILL": "MM", "GRADE": "IS 2062 E250BR",
+	"URL_APIKEY": "c1a05e9bcdae44b590944f014dc00320", "WEIGHT": null, "LOCATION": null,
+	"SECTION": "ANGLE 65*65*6", "DATE": "01-JUL-25"
+}`
+
+// row returns validRow with fields replaced, or removed when the value is nil
+func row(t *testing.T, changes map[string]interface{}) json.RawMessage {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(validRow), &m); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(m, k)
+		} else {
+			m[k] = v
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		errors  []string // fields with errors
+	}{
+		{"valid", nil, nil},
+		{"alias", map[string]interface{}{"DATE": nil, "DATE1": "01-jul-25"}, nil},
+		{"numbers as text", map[string]interface{}{"LENGTH": "12000", "WEIGHT": 2.15, "ID": 2025015212}, nil},
+		{"missing", map[string]interface{}{"HEAT_NO": nil, "GRADE": ""}, []string{"GRADE", "HEAT_NO"}},
+		{"heat number", map[string]interface{}{"HEAT_NO": "C-103247"}, []string{"HEAT_NO"}},
+		{"time", map[string]interface{}{"TIME": "25:10"}, []string{"TIME"}},
+		{"date", map[string]interface{}{"DATE": "31-FEB-25"}, []string{"DATE"}},
+		{"date format", map[string]interface{}{"DATE": "2025-07-01"}, []string{"DATE"}},
+		{"length", map[string]interface{}{"LENGTH": "12 m"}, []string{"LENGTH"}},
+		{"weight", map[string]interface{}{"WEIGHT": "heavy"}, []string{"WEIGHT"}},
+		{"bundle number", map[string]interface{}{"BUNDLE_NO": "B-12"}, []string{"BUNDLE_NO"}},
+		{"conflicting alias", map[string]interface{}{"DATE1": "02-JUL-25"}, []string{"DATE"}},
+		{"object value", map[string]interface{}{"MILL": map[string]string{"code": "MM"}}, []string{"MILL"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, errs, _ := ingest.Row(row(t, tt.changes))
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Field)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.errors) {
+				t.Fatalf("errors on %v, want %v: %+v", got, tt.errors, errs)
+			}
+			if len(errs) == 0 && data.DATE != "01-JUL-25" {
+				t.Errorf("DATE = %q, want 01-JUL-25", data.DATE)
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	rows := []json.RawMessage{
+		row(t, nil),
+		json.RawMessage(`"not an object"`),
+		row(t, map[string]interface{}{"TIME": "1:55"}),
+		row(t, map[string]interface{}{"DATE": nil, "DATE1": "01-JUL-25", "NOTE": "x"}),
+	}
+	res := ingest.Batch(rows)
+	if len(res.Labels) != 2 {
+		t.Errorf("%d labels accepted, want 2", len(res.Labels))
+	}
+	if len(res.Errors) != 2 || res.Errors[0].Row != 2 || res.Errors[1].Row != 3 || res.Errors[1].ID != "2025015212" {
+		t.Errorf("Errors = %+v, want rows 2 and 3", res.Errors)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Row != 4 || len(res.Warnings[0].Errors) != 2 {
+		t.Errorf("Warnings = %+v, want the alias and unknown field of row 4", res.Warnings)
+	}
+}
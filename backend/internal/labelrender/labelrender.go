@@ -0,0 +1,191 @@
+// Package labelrender is the single place labels are turned into printer
+// commands. The print endpoints and the dispatcher all render through a
+// Renderer, so a label prints the same way whichever path queued it.
+package labelrender
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/templates"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// Renderer turns a label into printer commands for a print job, reporting
+// the template version used
+type Renderer interface {
+	Render(label models.Label, job layout.Job) (templates.Rendered, error)
+}
+
+// Templates renders labels with the published template version selected for
+// them in the database
+type Templates struct{}
+
+// Render implements Renderer
+func (Templates) Render(label models.Label, job layout.Job) (templates.Rendered, error) {
+	return templates.GenerateZPL(label, job)
+}
+
+// Builtin renders every label with the QCIN layout template. It needs no
+// database, so the rendered output can be pinned by tests.
+type Builtin struct{}
+
+// Render implements Renderer
+func (Builtin) Render(label models.Label, job layout.Job) (templates.Rendered, error) {
+	return templates.Render(templates.BuiltinName, templates.Builtin(), label, templates.Options{Job: job})
+}
+
+// Layout renders labels through a printer language backend with the published
+// template version selected for them, like Templates. Stored templates are ZPL,
+// so a label whose template is not the QCIN layout fails with a
+// *templates.LanguageError instead of printing a different layout.
+type Layout struct {
+	Backend layout.Backend
+}
+
+// Render implements Renderer
+func (r Layout) Render(label models.Label, job layout.Job) (templates.Rendered, error) {
+	return templates.GenerateLayout(r.Backend, label, job)
+}
+
+// BuiltinLayout renders every label with the QCIN layout through a printer
+// language backend and the plant's QR formats. Like Builtin it needs no database.
+type BuiltinLayout struct {
+	Backend layout.Backend
+}
+
+// Render implements Renderer
+func (r BuiltinLayout) Render(label models.Label, job layout.Job) (templates.Rendered, error) {
+	return templates.RenderLayout(r.Backend, templates.BuiltinName, label, templates.Options{Job: job})
+}
+
+// Default is the renderer used by the print endpoints and the dispatcher
+var Default Renderer = Templates{}
+
+// Render renders a label with the Default renderer
+func Render(label models.Label, job layout.Job) (templates.Rendered, error) {
+	return Default.Render(label, job)
+}
+
+// ForLanguage returns the renderer for a printer's configured language.
+// ZPL printers use the Default renderer and other languages a Layout; both
+// select the stored template for the label.
+func ForLanguage(language string) (Renderer, error) {
+	if language == "" || language == models.PrinterLanguageZPL {
+		return Default, nil
+	}
+	backend, err := layout.For(language)
+	if err != nil {
+		return nil, err
+	}
+	return Layout{Backend: backend}, nil
+}
+
+// ToData maps a stored label back onto the fields the batch endpoint takes
+func ToData(label models.Label) models.LabelData {
+	return models.LabelData{
+		LOCATION:        label.Location,
+		BUNDLE_NO:       label.BundleNo,
+		BUNDLE_TYPE:     label.BundleType,
+		PQD:             label.PQD,
+		UNIT:            label.Unit,
+		TIME:            label.Time,
+		LENGTH:          label.Length,
+		HEAT_NO:         label.HeatNo,
+		PRODUCT_HEADING: label.ProductHeading,
+		ISI_BOTTOM:      label.IsiBottom,
+		ISI_TOP:         label.IsiTop,
+		CHARGE_DTM:      label.ChargeDtm,
+		MILL:            label.Mill,
+		GRADE:           label.Grade,
+		URL_APIKEY:      label.UrlApikey,
+		ID:              label.LabelID,
+		WEIGHT:          label.Weight,
+		SECTION:         label.Section,
+		DATE:            label.Date,
+	}
+}
+
+// FromData maps an ingested label onto models.Label using its DB UUID
+func FromData(data models.LabelData, userID uuid.UUID, id uuid.UUID) models.Label {
+	return models.Label{
+		ID:             id,
+		LabelID:        data.ID,
+		Location:       data.LOCATION,
+		BundleNo:       data.BUNDLE_NO,
+		BundleType:     data.BUNDLE_TYPE,
+		PQD:            data.PQD,
+		Unit:           data.UNIT,
+		Time:           data.TIME,
+		Length:         data.LENGTH,
+		HeatNo:         data.HEAT_NO,
+		ProductHeading: data.PRODUCT_HEADING,
+		IsiBottom:      data.ISI_BOTTOM,
+		IsiTop:         data.ISI_TOP,
+		ChargeDtm:      data.CHARGE_DTM,
+		Mill:           data.MILL,
+		Grade:          data.GRADE,
+		UrlApikey:      data.URL_APIKEY,
+		Weight:         data.WEIGHT,
+		Section:        data.SECTION,
+		Date:           data.DATE,
+		UserID:         userID,
+		Status:         models.LabelStatusReceived,
+		IsDuplicate:    false,
+		Version:        1,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
+
+// Load reads a label row by its UUID
+func Load(labelUUID uuid.UUID) (models.Label, error) {
+	var label models.Label
+	err := db.DB.QueryRow(
+		`SELECT id, label_id, location, bundle_no, bundle_type, pqd, unit, time, length,
+		 heat_no, product_heading, isi_bottom, isi_top, charge_dtm, mill, grade,
+		 url_apikey, weight, section, date, user_id, status,
+		 is_duplicate, version, created_at, updated_at
+		 FROM labels WHERE id = $1`,
+		labelUUID,
+	).Scan(
+		&label.ID, &label.LabelID, &label.Location, &label.BundleNo, &label.BundleType, &label.PQD,
+		&label.Unit, &label.Time, &label.Length, &label.HeatNo, &label.ProductHeading,
+		&label.IsiBottom, &label.IsiTop, &label.ChargeDtm, &label.Mill, &label.Grade,
+		&label.UrlApikey, &label.Weight, &label.Section, &label.Date,
+		&label.UserID, &label.Status, &label.IsDuplicate, &label.Version,
+		&label.CreatedAt, &label.UpdatedAt,
+	)
+	return label, err
+}
+
+// AtVersion returns a label as it was at an earlier version, from its
+// amendment history. Labels never amended have no history and are returned
+// as they are.
+func AtVersion(label models.Label, version int) (models.Label, error) {
+	if version == label.Version {
+		return label, nil
+	}
+	var data []byte
+	err := db.DB.QueryRow(`SELECT data FROM label_versions WHERE label_id = $1 AND version = $2`,
+		label.ID, version).Scan(&data)
+	if err == sql.ErrNoRows {
+		return label, nil
+	}
+	if err != nil {
+		return label, err
+	}
+	var d models.LabelData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return label, err
+	}
+	old := FromData(d, label.UserID, label.ID)
+	old.Status, old.IsDuplicate, old.Version = label.Status, label.IsDuplicate, version
+	old.CreatedAt, old.UpdatedAt = label.CreatedAt, label.UpdatedAt
+	return old, nil
+}
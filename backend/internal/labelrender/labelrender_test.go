@@ -0,0 +1,362 @@
+package labelrender_test
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"labelops-backend/internal/ingest"
+	"labelops-backend/internal/labelrender"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/qrsign"
+	"labelops-backend/internal/templates"
+	"labelops-backend/internal/zpl"
+	"labelops-backend/internal/zpl/lint"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// dummyLabels loads the sample batch shipped at the repository root
+func dummyLabels(t *testing.T) []models.LabelData {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("..", "..", "..", "dummy_data.json"))
+	if err != nil {
+		t.Fatalf("read dummy data: %v", err)
+	}
+	var batch models.LabelBatchRequest
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		t.Fatalf("decode dummy data: %v", err)
+	}
+	// Rows go through the same validation as the batch endpoint, which maps DATE1 to DATE
+	res := ingest.Batch(batch.Labels)
+	if len(res.Errors) > 0 {
+		t.Fatalf("dummy data fails validation: %+v", res.Errors)
+	}
+	if len(res.Labels) == 0 {
+		t.Fatal("dummy data has no labels")
+	}
+	return res.Labels
+}
+
+// checkGolden compares got with testdata/name, rewriting the file first when
+// the tests run with -update
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden (run go test -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s; run go test -update if the change is intended", golden)
+	}
+}
+
+func TestBuiltinGolden(t *testing.T) {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	for i, data := range dummyLabels(t) {
+		name := fmt.Sprintf("%02d-%s", i, data.ID)
+		t.Run(name, func(t *testing.T) {
+			// Fixed IDs keep the QCIN URL in the QR code stable
+			id := uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1))
+			label := labelrender.FromData(data, userID, id)
+
+			rendered, err := labelrender.Builtin{}.Render(label, layout.Job{})
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			checkGolden(t, name+".zpl", rendered.ZPL)
+
+			if _, err := zpl.Render([]byte(rendered.ZPL), zpl.Options{}); err != nil {
+				t.Errorf("rendered ZPL does not render: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuiltinDeterministic(t *testing.T) {
+	data := dummyLabels(t)[0]
+	label := labelrender.FromData(data, uuid.New(), uuid.New())
+
+	first, err := labelrender.Builtin{}.Render(label, layout.Job{})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	second, err := labelrender.Builtin{}.Render(label, layout.Job{})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if first.Hash != second.Hash {
+		t.Error("rendering the same label twice produced different ZPL")
+	}
+}
+
+func TestBuiltinEscapesFieldData(t *testing.T) {
+	data := dummyLabels(t)[0]
+	data.SECTION = "ANGLE 65×65×6"
+	data.HEAT_NO = "C10^XZ~JR"
+	label := labelrender.FromData(data, uuid.New(), uuid.New())
+
+	rendered, err := labelrender.Builtin{}.Render(label, layout.Job{})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, want := range []string{`^FDANGLE 65\C3\9765\C3\976^FS`, `^FDC10\5EXZ\7EJR^FS`} {
+		if !strings.Contains(rendered.ZPL, want) {
+			t.Errorf("rendered ZPL does not contain %s", want)
+		}
+	}
+
+	cmds, err := zpl.Parse([]byte(rendered.ZPL))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	formats, err := zpl.Formats(cmds)
+	if err != nil {
+		t.Fatalf("Formats: %v", err)
+	}
+	if len(formats) != 1 {
+		t.Errorf("field data split the label into %d formats", len(formats))
+	}
+	for _, cmd := range cmds {
+		if cmd.Name == "JR" {
+			t.Errorf("field data produced a %s command at line %d", cmd, cmd.Line)
+		}
+	}
+}
+
+func TestBuiltinRejectsInvalidFields(t *testing.T) {
+	tests := map[string]func(*models.LabelData){
+		"too long":     func(d *models.LabelData) { d.HEAT_NO = strings.Repeat("9", 21) },
+		"invalid utf8": func(d *models.LabelData) { d.GRADE = "IS 2062 \xff" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := dummyLabels(t)[0]
+			mutate(&data)
+
+			_, err := labelrender.Builtin{}.Render(labelrender.FromData(data, uuid.New(), uuid.New()), layout.Job{})
+			var fieldErr *templates.FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Render error = %v, want a *templates.FieldError", err)
+			}
+		})
+	}
+}
+
+func TestLayoutGolden(t *testing.T) {
+	data := dummyLabels(t)[0]
+	label := labelrender.FromData(data, uuid.New(), uuid.MustParse("00000000-0000-0000-0000-000000000001"))
+
+	for _, language := range []string{models.PrinterLanguageEPL2, models.PrinterLanguageTSPL} {
+		t.Run(language, func(t *testing.T) {
+			backend, err := layout.For(language)
+			if err != nil {
+				t.Fatalf("layout.For: %v", err)
+			}
+			rendered, err := labelrender.BuiltinLayout{Backend: backend}.Render(label, layout.Job{})
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if rendered.Language != language {
+				t.Errorf("Language = %q, want %q", rendered.Language, language)
+			}
+
+			checkGolden(t, "00-"+data.ID+"."+language, rendered.ZPL)
+		})
+	}
+}
+
+func TestLayoutEPL2RejectsNonASCII(t *testing.T) {
+	data := dummyLabels(t)[0]
+	data.SECTION = "ANGLE 65×65×6"
+
+	backend, err := layout.For(models.PrinterLanguageEPL2)
+	if err != nil {
+		t.Fatalf("layout.For: %v", err)
+	}
+	renderer := labelrender.BuiltinLayout{Backend: backend}
+	if _, err := renderer.Render(labelrender.FromData(data, uuid.New(), uuid.New()), layout.Job{}); err == nil {
+		t.Error("EPL2 rendered a section EPL2 cannot print")
+	}
+}
+
+// Layout jobs render with the options of their template version, as ZPL jobs do
+func TestLayoutTemplateOptions(t *testing.T) {
+	data := dummyLabels(t)[0]
+	label := labelrender.FromData(data, uuid.New(), uuid.New())
+	backend, err := layout.For(models.PrinterLanguageTSPL)
+	if err != nil {
+		t.Fatalf("layout.For: %v", err)
+	}
+
+	opts := templates.Options{QR: templates.QRFormat{URL: "https://example.test/{{.LabelID}}"}}
+	rendered, err := templates.RenderLayout(backend, templates.BuiltinName, label, opts)
+	if err != nil {
+		t.Fatalf("RenderLayout: %v", err)
+	}
+	if want := "https://example.test/" + data.ID; !strings.Contains(rendered.ZPL, want) {
+		t.Errorf("output does not contain the template's QR URL %s", want)
+	}
+
+	label.Location = nil
+	opts.GS1 = []models.GS1AI{{AI: "10", Field: "Location"}}
+	_, err = templates.RenderLayout(backend, templates.BuiltinName, label, opts)
+	var fieldErr *templates.FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Errorf("RenderLayout error = %v, want a *templates.FieldError for the empty GS1 field", err)
+	}
+}
+
+// A print job re-rendered with the QR settings recorded on it matches what was
+// printed after the plant's formats and signer change
+func TestRecordedQRSettings(t *testing.T) {
+	t.Cleanup(func() {
+		templates.SetSigner(nil)
+		templates.SetPlantQR(templates.QRFormat{})
+	})
+	label := labelrender.FromData(dummyLabels(t)[0], uuid.New(), uuid.New())
+	render := func(opts templates.Options) (templates.Rendered, error) {
+		return templates.Render(templates.BuiltinName, templates.Builtin(), label, opts)
+	}
+
+	templates.SetSigner(qrsign.NewHMAC([]byte("0123456789abcdef")))
+	printed, err := render(templates.Options{})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if printed.QR.SignAlg != qrsign.AlgorithmHMAC || printed.QR.Format.Data != templates.DefaultQRDataFormat {
+		t.Fatalf("recorded QR settings = %+v, want the default formats signed with %s", printed.QR, qrsign.AlgorithmHMAC)
+	}
+
+	if err := templates.SetPlantQR(templates.QRFormat{Data: "HEAT:{{.HeatNo}};"}); err != nil {
+		t.Fatalf("SetPlantQR: %v", err)
+	}
+	if current, err := render(templates.Options{}); err != nil || current.Hash == printed.Hash {
+		t.Fatalf("render with the changed plant formats = %v, want a different hash", err)
+	}
+	again, err := render(templates.Options{QRRecorded: &printed.QR})
+	if err != nil {
+		t.Fatalf("Render with recorded settings: %v", err)
+	}
+	if again.Hash != printed.Hash || again.QR != printed.QR {
+		t.Errorf("re-render with recorded settings differs from the printed label")
+	}
+
+	unsigned := printed.QR
+	unsigned.SignAlg = ""
+	r, err := render(templates.Options{QRRecorded: &unsigned})
+	if err != nil {
+		t.Fatalf("Render with recorded unsigned settings: %v", err)
+	}
+	if strings.Contains(r.ZPL, qrsign.Field) {
+		t.Error("job recorded as unsigned was re-rendered with a signature")
+	}
+
+	seed := make([]byte, 32)
+	signer, err := qrsign.NewEd25519(seed)
+	if err != nil {
+		t.Fatalf("NewEd25519: %v", err)
+	}
+	templates.SetSigner(signer)
+	if _, err := render(templates.Options{QRRecorded: &printed.QR}); !errors.Is(err, templates.ErrSignerChanged) {
+		t.Errorf("re-render after the algorithm changed = %v, want ErrSignerChanged", err)
+	}
+}
+
+func TestJobGolden(t *testing.T) {
+	data := dummyLabels(t)[0]
+	label := labelrender.FromData(data, uuid.New(), uuid.MustParse("00000000-0000-0000-0000-000000000001"))
+	job := layout.Job{Copies: 2, Reprint: 1, Serial: true}
+
+	renderers := map[string]labelrender.Renderer{models.PrinterLanguageZPL: labelrender.Builtin{}}
+	for _, language := range []string{models.PrinterLanguageEPL2, models.PrinterLanguageTSPL} {
+		backend, err := layout.For(language)
+		if err != nil {
+			t.Fatalf("layout.For: %v", err)
+		}
+		renderers[language] = labelrender.BuiltinLayout{Backend: backend}
+	}
+	for language, renderer := range renderers {
+		t.Run(language, func(t *testing.T) {
+			rendered, err := renderer.Render(label, job)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			checkGolden(t, "job-"+data.ID+"."+language, rendered.ZPL)
+
+			if language == models.PrinterLanguageZPL {
+				if issues := lint.Lint([]byte(rendered.ZPL), lint.Options{}); len(issues) > 0 {
+					t.Errorf("rendered ZPL has lint issues: %v", issues)
+				}
+			}
+		})
+	}
+}
+
+func TestMediaGolden(t *testing.T) {
+	data := dummyLabels(t)[0]
+	label := labelrender.FromData(data, uuid.New(), uuid.MustParse("00000000-0000-0000-0000-000000000001"))
+	darkness, speed := 20, 4
+	job := layout.Job{Media: &layout.Media{
+		WidthMM: 101.6, LengthMM: 76.2, DPI: 300,
+		Darkness: &darkness, Speed: &speed, TearOff: 10, Type: models.MediaTypeGap,
+	}}
+
+	renderers := map[string]labelrender.Renderer{models.PrinterLanguageZPL: labelrender.Builtin{}}
+	for _, language := range []string{models.PrinterLanguageEPL2, models.PrinterLanguageTSPL} {
+		backend, err := layout.For(language)
+		if err != nil {
+			t.Fatalf("layout.For: %v", err)
+		}
+		renderers[language] = labelrender.BuiltinLayout{Backend: backend}
+	}
+	for language, renderer := range renderers {
+		t.Run(language, func(t *testing.T) {
+			rendered, err := renderer.Render(label, job)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			checkGolden(t, "media300-"+data.ID+"."+language, rendered.ZPL)
+
+			if language == models.PrinterLanguageZPL {
+				img, err := zpl.Render([]byte(rendered.ZPL), zpl.Options{DPI: 300})
+				if err != nil {
+					t.Fatalf("rendered ZPL does not render: %v", err)
+				}
+				if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != 1200 || h != 900 {
+					t.Errorf("label is %dx%d dots, want 1200x900 for 4x3 inch stock at 300 dpi", w, h)
+				}
+			}
+		})
+	}
+}
+
+// Amendments rebuild a label's batch fields from the stored label, so the
+// mapping must round-trip
+func TestDataRoundTrip(t *testing.T) {
+	for _, data := range dummyLabels(t) {
+		label := labelrender.FromData(data, uuid.New(), uuid.New())
+		if got := labelrender.ToData(label); !reflect.DeepEqual(got, data) {
+			t.Errorf("ToData(FromData(%s)) = %+v, want %+v", data.ID, got, data)
+		}
+	}
+}
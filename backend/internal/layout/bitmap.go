@@ -0,0 +1,79 @@
+package layout
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Bitmap is a 1 bit per pixel image packed into whole bytes per row, most
+// significant bit first. A set bit is black.
+type Bitmap struct {
+	Width, Height int
+	Bits          []byte
+}
+
+// Stride is the number of bytes in each row
+func (b *Bitmap) Stride() int {
+	return (b.Width + 7) / 8
+}
+
+// Black reports whether the pixel at x, y is black
+func (b *Bitmap) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return false
+	}
+	return b.Bits[y*b.Stride()+x/8]&(0x80>>(x%8)) != 0
+}
+
+// DecodePNG reads a PNG as a bitmap; pixels darker than mid grey are black
+func DecodePNG(r io.Reader) (*Bitmap, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	b := &Bitmap{Width: bounds.Dx(), Height: bounds.Dy()}
+	b.Bits = make([]byte, b.Stride()*b.Height)
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			gray := color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray)
+			if gray.Y < 0x80 {
+				b.Bits[y*b.Stride()+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return b, nil
+}
+
+// Rects covers the black pixels with rectangles. Each row is split into runs
+// of black pixels, and a run repeated on the following rows extends the same
+// rectangle. Backends without a text-safe graphic format draw images this way.
+func (b *Bitmap) Rects() []image.Rectangle {
+	var rects []image.Rectangle
+	open := map[[2]int]int{} // run start and end to the rectangle it extends
+	for y := 0; y < b.Height; y++ {
+		next := map[[2]int]int{}
+		for x := 0; x < b.Width; {
+			if !b.Black(x, y) {
+				x++
+				continue
+			}
+			start := x
+			for x < b.Width && b.Black(x, y) {
+				x++
+			}
+			run := [2]int{start, x}
+			if i, ok := open[run]; ok {
+				rects[i].Max.Y = y + 1
+				next[run] = i
+				continue
+			}
+			next[run] = len(rects)
+			rects = append(rects, image.Rect(start, y, x, y+1))
+		}
+		open = next
+	}
+	return rects
+}
@@ -0,0 +1,179 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+
+	"labelops-backend/internal/zpl"
+	"labelops-backend/models"
+)
+
+// eplFonts are the heights in dots of EPL2 resident fonts 1 to 4 at 203 dpi;
+// they are scaled for other densities. Font 5 only has upper case letters
+// and is not used.
+var eplFonts = []int{12, 16, 20, 24}
+
+// EPL2 encodes layouts for Eltron and Honeywell printers that speak EPL2.
+// EPL2 has no scalable font, so text uses the resident font and multiplier
+// closest to the requested height. Images are drawn as bars, because GW
+// takes binary data and jobs are stored as text.
+type EPL2 struct{}
+
+// Language implements Backend
+func (EPL2) Language() string {
+	return models.PrinterLanguageEPL2
+}
+
+// Encode implements Backend. EPL2 counters take their start value from a
+// stored form, so a layout with a Serial is sent once per copy instead.
+func (e EPL2) Encode(l Layout) (string, error) {
+	var b strings.Builder
+	// The leading line feed ends any partial command left in the printer's buffer
+	b.WriteString("\n")
+	if l.Media != nil {
+		eplMedia(&b, *l.Media)
+	}
+	fmt.Fprintf(&b, "N\nq%d\n%s\n", l.Width, eplLength(l))
+	serial, numbered := l.serial()
+	if !numbered {
+		if err := e.encodeElements(&b, l, serial, 0); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "P%d\n", l.copies())
+		return b.String(), nil
+	}
+	for n := 0; n < l.copies(); n++ {
+		if n > 0 {
+			b.WriteString("N\n")
+		}
+		if err := e.encodeElements(&b, l, serial, n); err != nil {
+			return "", err
+		}
+		b.WriteString("P1\n")
+	}
+	return b.String(), nil
+}
+
+// encodeElements writes each element of l as an EPL2 command, with the
+// serial number of copy n
+func (EPL2) encodeElements(b *strings.Builder, l Layout, serial Serial, n int) error {
+	dpi := l.dpi()
+	for _, e := range l.Elements {
+		if _, ok := e.(Serial); ok {
+			e = serial.text(n)
+		}
+		switch e := e.(type) {
+		case Text:
+			value, err := eplQuote(e.Value)
+			if err != nil {
+				return err
+			}
+			font, mult := eplFont(zpl.ScaleDots(e.Height, dpi, 203))
+			height := zpl.ScaleDots(eplFonts[font-1]*mult, 203, dpi)
+			x, y := textOrigin(e, height*4/5)
+			fmt.Fprintf(b, "A%d,%d,%d,%d,%d,%d,N,%s\n", x, y, e.Rotation/90, font, mult, mult, value)
+		case Box:
+			fmt.Fprintf(b, "X%d,%d,%d,%d,%d\n", e.X, e.Y, e.Thickness, e.X+e.Width, e.Y+e.Height)
+		case Line:
+			w, h := e.Length, e.Thickness
+			if e.Vertical {
+				w, h = e.Thickness, e.Length
+			}
+			fmt.Fprintf(b, "LO%d,%d,%d,%d\n", e.X, e.Y, w, h)
+		case QR:
+			data, err := eplQuote(e.Data)
+			if err != nil {
+				return err
+			}
+			x, y, err := qrOrigin(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "b%d,%d,Q,m2,s%d,e%s,%s\n", x, y, e.Magnification, e.Level, data)
+		case Barcode:
+			if e.GS1 {
+				return fmt.Errorf("EPL2 printers cannot print GS1-128 bar codes")
+			}
+			data, err := eplQuote(e.Data)
+			if err != nil {
+				return err
+			}
+			readable := 'N'
+			if e.HumanReadable {
+				readable = 'B'
+			}
+			fmt.Fprintf(b, "B%d,%d,%d,1,%d,%d,%d,%c,%s\n", e.X, e.Y, e.Rotation/90, e.Module, e.Module, e.Height, readable, data)
+		case DataMatrix:
+			return fmt.Errorf("EPL2 printers cannot print Data Matrix symbols")
+		case Image:
+			for _, r := range e.Bitmap.Rects() {
+				fmt.Fprintf(b, "LO%d,%d,%d,%d\n", e.X+r.Min.X, e.Y+r.Min.Y, r.Dx(), r.Dy())
+			}
+		default:
+			return fmt.Errorf("unsupported layout element %T", e)
+		}
+	}
+	return nil
+}
+
+// eplMedia writes the EPL2 settings of the media: density (D, 0-15) and
+// speed (S). EPL2 has no tear-off or label top adjustment.
+func eplMedia(b *strings.Builder, m Media) {
+	if m.Darkness != nil {
+		fmt.Fprintf(b, "D%d\n", *m.Darkness/2)
+	}
+	if m.Speed != nil {
+		fmt.Fprintf(b, "S%d\n", *m.Speed)
+	}
+}
+
+// eplLength returns the Q command setting the label length and how the
+// printer finds the next label: by a 3 mm gap, a black mark or neither
+func eplLength(l Layout) string {
+	gap := zpl.ScaleDots(24, 203, l.dpi())
+	if l.Media != nil {
+		switch l.Media.Type {
+		case models.MediaTypeMark:
+			return fmt.Sprintf("Q%d,B%d", l.Length, gap)
+		case models.MediaTypeContinuous:
+			return fmt.Sprintf("Q%d,0", l.Length)
+		}
+	}
+	return fmt.Sprintf("Q%d,%d", l.Length, gap)
+}
+
+// eplFont picks the resident font and multiplier closest to a text height
+func eplFont(height int) (int, int) {
+	font, mult, best := 1, 1, -1
+	for i, h := range eplFonts {
+		for m := 1; m <= 9; m++ {
+			diff := h*m - height
+			if diff < 0 {
+				diff = -diff
+			}
+			if best < 0 || diff < best {
+				font, mult, best = i+1, m, diff
+			}
+		}
+	}
+	return font, mult
+}
+
+// eplQuote quotes a value for an EPL2 command. Only printable ASCII is
+// accepted, since code page characters would not survive storage as text.
+func eplQuote(s string) (string, error) {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e {
+			return "", fmt.Errorf("EPL2 printers cannot print %q: only ASCII text is supported", s)
+		}
+		if c == '"' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
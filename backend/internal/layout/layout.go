@@ -0,0 +1,221 @@
+// Package layout describes labels independently of the printer language.
+// A Layout is a list of positioned elements measured in printer dots; a
+// Backend encodes it as ZPL II, EPL2 or TSPL for the printer that prints it.
+package layout
+
+import (
+	"fmt"
+
+	"labelops-backend/internal/qrcode"
+	"labelops-backend/models"
+)
+
+// Layout is a label design. Coordinates are dots from the top left corner of
+// the label as it leaves the printer.
+type Layout struct {
+	Name     string
+	Width    int // dots
+	Length   int // dots
+	DPI      int
+	Copies   int    // labels printed; zero prints one
+	Media    *Media // printer setup sent with the label; nil leaves the printer's
+	Elements []Element
+}
+
+// copies returns the number of labels to print
+func (l Layout) copies() int {
+	if l.Copies < 1 {
+		return 1
+	}
+	return l.Copies
+}
+
+// dpi returns the layout's print density, 203 dpi when it is not set
+func (l Layout) dpi() int {
+	if l.DPI <= 0 {
+		return 203
+	}
+	return l.DPI
+}
+
+// serial returns the layout's Serial element, if it has one
+func (l Layout) serial() (Serial, bool) {
+	for _, e := range l.Elements {
+		if s, ok := e.(Serial); ok {
+			return s, true
+		}
+	}
+	return Serial{}, false
+}
+
+// MaxCopies bounds the labels one print job prints
+const MaxCopies = 99
+
+// Job is how a print job prints a label: how many copies, whether they are
+// numbered, whether the label is a reprint and the media it is printed on
+type Job struct {
+	Copies  int    // labels printed; zero prints one
+	Reprint int    // reprint number, printed as REPRINT n; zero for the first print
+	Serial  bool   // number the copies 1, 2, ... with a serial counter
+	Media   *Media // printer media; nil prints at the design density and size
+}
+
+// IsZero reports whether j prints a single unmarked label, whatever its media
+func (j Job) IsZero() bool {
+	return j.Copies <= 1 && j.Reprint == 0 && !j.Serial
+}
+
+// Element is something drawn on a label: a Text, Serial, Box, Line, QR, Barcode, DataMatrix or Image
+type Element interface {
+	element()
+}
+
+// Rotation turns an element clockwise
+type Rotation int
+
+// Rotations supported by every backend
+const (
+	Rotate0   Rotation = 0
+	Rotate90  Rotation = 90
+	Rotate180 Rotation = 180
+	Rotate270 Rotation = 270 // reads bottom to top
+)
+
+// Text is a line of text in the printer's scalable font. X, Y is the start of
+// the baseline, so text lines up whatever the ascent of a printer's font.
+type Text struct {
+	X, Y     int
+	Height   int // character height in dots
+	Width    int // character width in dots
+	Rotation Rotation
+	Value    string
+}
+
+// Serial is a number printed like Text that counts up from Start, by one on
+// each copy of the label. A layout has at most one.
+type Serial struct {
+	X, Y     int
+	Height   int
+	Width    int
+	Rotation Rotation
+	Start    int
+	Digits   int // zero padded width
+}
+
+// value returns the number printed on copy n, counting from zero
+func (s Serial) value(n int) string {
+	return fmt.Sprintf("%0*d", s.Digits, s.Start+n)
+}
+
+// text returns the Text printed on copy n
+func (s Serial) text(n int) Text {
+	return Text{X: s.X, Y: s.Y, Height: s.Height, Width: s.Width, Rotation: s.Rotation, Value: s.value(n)}
+}
+
+// Box is a rectangle outline; X, Y is its top left corner
+type Box struct {
+	X, Y          int
+	Width, Height int
+	Thickness     int
+}
+
+// Line is a solid horizontal or vertical line; X, Y is its top left corner
+type Line struct {
+	X, Y      int
+	Length    int
+	Thickness int
+	Vertical  bool
+}
+
+// QR is a QR Code symbol. X, Y is its bottom left corner, as with ^FT.
+type QR struct {
+	X, Y          int
+	Magnification int    // dots per module
+	Level         string // error correction: L, M, Q or H
+	Data          string
+}
+
+// Barcode is a Code 128 bar code; X, Y is its top left corner. With GS1 set it
+// is a GS1-128 symbol and Data is an element string written as HRI, e.g.
+// (10)C103247(21)2025015212.
+type Barcode struct {
+	X, Y          int
+	Height        int // bar height in dots
+	Module        int // narrow bar width in dots
+	Rotation      Rotation
+	Data          string
+	HumanReadable bool
+	GS1           bool
+}
+
+// DataMatrix is an ECC 200 Data Matrix symbol; X, Y is its top left corner.
+// With GS1 set Data is an element string written as HRI, as for Barcode.
+type DataMatrix struct {
+	X, Y   int
+	Module int // dots per module
+	Data   string
+	GS1    bool
+}
+
+// Image is a monochrome bitmap; X, Y is its top left corner
+type Image struct {
+	X, Y   int
+	Name   string
+	Bitmap *Bitmap
+}
+
+func (Text) element()       {}
+func (Serial) element()     {}
+func (Box) element()        {}
+func (Line) element()       {}
+func (QR) element()         {}
+func (Barcode) element()    {}
+func (DataMatrix) element() {}
+func (Image) element()      {}
+
+// Backend encodes layouts in one printer language
+type Backend interface {
+	Language() string
+	Encode(l Layout) (string, error)
+}
+
+// For returns the backend for a printer's configured language
+func For(language string) (Backend, error) {
+	switch language {
+	case models.PrinterLanguageZPL, "":
+		return ZPL{}, nil
+	case models.PrinterLanguageEPL2:
+		return EPL2{}, nil
+	case models.PrinterLanguageTSPL:
+		return TSPL{}, nil
+	}
+	return nil, fmt.Errorf("unsupported printer language %q", language)
+}
+
+// textOrigin returns the top left corner of text, before rotation, for
+// languages that position text by its top rather than its baseline
+func textOrigin(t Text, ascent int) (int, int) {
+	switch t.Rotation {
+	case Rotate90:
+		return t.X + ascent, t.Y
+	case Rotate180:
+		return t.X, t.Y + ascent
+	case Rotate270:
+		return t.X - ascent, t.Y
+	}
+	return t.X, t.Y - ascent
+}
+
+// qrOrigin returns the top left corner of a QR symbol, which depends on the
+// symbol size and so on its data
+func qrOrigin(q QR) (int, int, error) {
+	level, err := qrcode.ParseLevel(q.Level)
+	if err != nil {
+		return 0, 0, err
+	}
+	code, err := qrcode.Encode([]byte(q.Data), level)
+	if err != nil {
+		return 0, 0, err
+	}
+	return q.X, q.Y - code.Size*q.Magnification, nil
+}
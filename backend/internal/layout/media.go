@@ -0,0 +1,137 @@
+package layout
+
+import (
+	"math"
+
+	"labelops-backend/internal/zpl"
+	"labelops-backend/models"
+)
+
+// Media is the label stock and print settings of the printer a job prints on.
+// It is stored with the job as JSON so the job can be rendered again.
+type Media struct {
+	WidthMM  float64 `json:"width_mm"`
+	LengthMM float64 `json:"length_mm"`
+	DPI      int     `json:"dpi"`
+	Darkness *int    `json:"darkness,omitempty"` // 0-30
+	Speed    *int    `json:"speed,omitempty"`    // inches per second
+	TearOff  int     `json:"tear_off"`           // dots
+	LabelTop int     `json:"label_top"`          // dots
+	Type     string  `json:"media_type"`         // gap, mark or continuous
+}
+
+// MediaFromProfile returns the media of a media profile
+func MediaFromProfile(p models.MediaProfile) Media {
+	return Media{
+		WidthMM:  p.WidthMM,
+		LengthMM: p.LengthMM,
+		DPI:      p.DPI,
+		Darkness: p.Darkness,
+		Speed:    p.Speed,
+		TearOff:  p.TearOff,
+		LabelTop: p.LabelTop,
+		Type:     p.MediaType,
+	}
+}
+
+// dots converts millimetres to dots at the media's density
+func (m Media) dots(mm float64) int {
+	return int(math.Round(mm * float64(m.DPI) / 25.4))
+}
+
+// Width is the label width in dots
+func (m Media) Width() int {
+	return m.dots(m.WidthMM)
+}
+
+// Length is the label length in dots
+func (m Media) Length() int {
+	return m.dots(m.LengthMM)
+}
+
+// ZPL returns the media as the settings zpl.Adapt applies
+func (m Media) ZPL() zpl.Media {
+	tracking := map[string]byte{
+		models.MediaTypeGap:        'Y',
+		models.MediaTypeMark:       'M',
+		models.MediaTypeContinuous: 'N',
+	}
+	return zpl.Media{
+		DPI:      m.DPI,
+		Width:    m.Width(),
+		Length:   m.Length(),
+		Darkness: m.Darkness,
+		Speed:    m.Speed,
+		TearOff:  m.TearOff,
+		LabelTop: m.LabelTop,
+		Tracking: tracking[m.Type],
+	}
+}
+
+// ForMedia returns l scaled from its density to the media's, with the
+// media's label size. Positions, font sizes, bar code modules and images
+// are all scaled, so a layout designed at 203 dpi prints the same size at
+// 300 dpi.
+func ForMedia(l Layout, m Media) Layout {
+	from, to := l.dpi(), m.DPI
+	if to <= 0 {
+		to = from
+	}
+	m.DPI = to
+	scale := func(n int) int {
+		return zpl.ScaleDots(n, from, to)
+	}
+	atLeast := func(n, min int) int {
+		if n < min {
+			return min
+		}
+		return n
+	}
+
+	elements := make([]Element, len(l.Elements))
+	for i, e := range l.Elements {
+		switch e := e.(type) {
+		case Text:
+			e.X, e.Y, e.Height, e.Width = scale(e.X), scale(e.Y), atLeast(scale(e.Height), 1), atLeast(scale(e.Width), 1)
+			elements[i] = e
+		case Serial:
+			e.X, e.Y, e.Height, e.Width = scale(e.X), scale(e.Y), atLeast(scale(e.Height), 1), atLeast(scale(e.Width), 1)
+			elements[i] = e
+		case Box:
+			e.X, e.Y, e.Width, e.Height, e.Thickness = scale(e.X), scale(e.Y), scale(e.Width), scale(e.Height), atLeast(scale(e.Thickness), 1)
+			elements[i] = e
+		case Line:
+			e.X, e.Y, e.Length, e.Thickness = scale(e.X), scale(e.Y), scale(e.Length), atLeast(scale(e.Thickness), 1)
+			elements[i] = e
+		case QR:
+			e.X, e.Y, e.Magnification = scale(e.X), scale(e.Y), atLeast(scale(e.Magnification), 1)
+			elements[i] = e
+		case Barcode:
+			e.X, e.Y, e.Height, e.Module = scale(e.X), scale(e.Y), scale(e.Height), atLeast(scale(e.Module), 1)
+			elements[i] = e
+		case DataMatrix:
+			e.X, e.Y, e.Module = scale(e.X), scale(e.Y), atLeast(scale(e.Module), 1)
+			elements[i] = e
+		case Image:
+			e.X, e.Y = scale(e.X), scale(e.Y)
+			if e.Bitmap != nil {
+				bits, w, h := zpl.ScaleBitmap(e.Bitmap.Bits, e.Bitmap.Width, e.Bitmap.Height, from, to)
+				e.Bitmap = &Bitmap{Width: w, Height: h, Bits: bits}
+			}
+			elements[i] = e
+		default:
+			elements[i] = e
+		}
+	}
+
+	l.Elements = elements
+	l.Width, l.Length, l.DPI = scale(l.Width), scale(l.Length), to
+	if m.WidthMM > 0 {
+		l.Width = m.Width()
+	}
+	if m.LengthMM > 0 {
+		l.Length = m.Length()
+	}
+	l.Media = &m
+	return l
+}
@@ -0,0 +1,145 @@
+package layout
+
+import (
+	"bytes"
+	"embed"
+	"strconv"
+)
+
+// QCINName identifies the QCIN layout
+const QCINName = "qcin"
+
+//go:embed images/*.png
+var imageFiles embed.FS
+
+// Images used by the QCIN layout
+var (
+	isiMark          = mustImage("isi-mark")
+	sailLogo         = mustImage("sail-logo")
+	sailName         = mustImage("sail-name")
+	bhilaiSteelPlant = mustImage("bhilai-steel-plant")
+)
+
+// Images returns the images the QCIN layout prints, by name, so they can
+// be offered as assets for other templates
+func Images() map[string]*Bitmap {
+	return map[string]*Bitmap{
+		"isi-mark":           isiMark,
+		"sail-logo":          sailLogo,
+		"sail-name":          sailName,
+		"bhilai-steel-plant": bhilaiSteelPlant,
+	}
+}
+
+// mustImage loads an embedded PNG from the images directory
+func mustImage(name string) *Bitmap {
+	data, err := imageFiles.ReadFile("images/" + name + ".png")
+	if err != nil {
+		panic(err)
+	}
+	b, err := DecodePNG(bytes.NewReader(data))
+	if err != nil {
+		panic("layout: image " + name + ": " + err.Error())
+	}
+	return b
+}
+
+// QCINValues are the label values printed on the QCIN layout, already
+// formatted for display
+type QCINValues struct {
+	ProductHeading string
+	Mill           string
+	LabelID        string
+	Grade          string
+	Length         string
+	Date           string
+	Time           string
+	Section        string
+	HeatNo         string
+	IsiTop         string
+	IsiBottom      string
+	QRURL          string
+	QRData         string
+}
+
+// QCIN is the 4x3 inch QCIN bundle tag at 203 dpi. It is printed sideways:
+// every text element reads bottom to top.
+func QCIN(v QCINValues) Layout {
+	text := func(x, y, h, w int, value string) Text {
+		return Text{X: x, Y: y, Height: h, Width: w, Rotation: Rotate270, Value: value}
+	}
+	return Layout{
+		Name:   QCINName,
+		Width:  812,
+		Length: 609,
+		DPI:    203,
+		Elements: []Element{
+			Box{X: 161, Y: 16, Width: 65, Height: 556, Thickness: 3},
+			text(91, 504, 30, 30, "IN"),
+			text(130, 525, 30, 30, "INDIA"),
+			text(206, 343, 32, 32, v.ProductHeading),
+			text(53, 528, 30, 30, "MADE"),
+			Box{X: 16, Y: 410, Width: 130, Height: 160, Thickness: 3},
+			text(590, 218, 34, 33, v.Mill),
+			text(483, 570, 25, 25, "ID"),
+			text(516, 570, 31, 30, v.LabelID),
+			text(440, 570, 25, 25, v.Grade),
+			text(643, 182, 25, 25, v.Length),
+			text(643, 310, 25, 25, "LENGTH"),
+			text(718, 182, 25, 25, v.Time),
+			text(683, 182, 25, 25, v.Date),
+			text(718, 310, 25, 25, "TIME"),
+			text(683, 310, 25, 25, "DATE"),
+			text(365, 570, 25, 25, v.Section),
+			text(411, 570, 25, 25, "GRADE"),
+			text(339, 570, 25, 25, "SECTION"),
+			text(295, 569, 34, 33, v.HeatNo),
+			text(260, 343, 14, 15, v.IsiTop),
+			text(345, 340, 14, 15, v.IsiBottom),
+			text(262, 570, 34, 33, "HEAT NO."),
+			Line{X: 536, Y: 1, Length: 570, Thickness: 3, Vertical: true},
+			text(718, 199, 25, 25, ":"),
+			text(683, 199, 25, 25, ":"),
+			text(643, 199, 25, 25, ":"),
+			QR{X: 560, Y: 573, Magnification: 4, Level: "M", Data: "D" + v.QRData},
+			QR{X: 245, Y: 275, Magnification: 5, Level: "M", Data: v.QRURL},
+			Image{X: 266, Y: 261, Name: "isi-mark", Bitmap: isiMark},
+			Image{X: 18, Y: 300, Name: "sail-logo", Bitmap: sailLogo},
+			Image{X: 18, Y: 33, Name: "sail-name", Bitmap: sailName},
+			Image{X: 74, Y: 20, Name: "bhilai-steel-plant", Bitmap: bhilaiSteelPlant},
+		},
+	}
+}
+
+// QCINJob returns l set up to print a job: its copies, the reprint and
+// piece number marks, printed in the free space beside the label ID, and
+// scaled to the job's media
+func QCINJob(l Layout, j Job) Layout {
+	l.Copies = j.Copies
+	l.Elements = append(append([]Element(nil), l.Elements...), QCINMarks(j)...)
+	if j.Media != nil {
+		l = ForMedia(l, *j.Media)
+	}
+	return l
+}
+
+// QCINMarks are the elements that mark a job on the QCIN layout
+func QCINMarks(j Job) []Element {
+	var marks []Element
+	if j.Reprint > 0 {
+		marks = append(marks, Text{X: 516, Y: 425, Height: 31, Width: 30, Rotation: Rotate270, Value: "REPRINT " + strconv.Itoa(j.Reprint)})
+	}
+	if j.Serial {
+		copies := j.Copies
+		if copies < 1 {
+			copies = 1
+		}
+		digits := len(strconv.Itoa(copies))
+		marks = append(marks,
+			Text{X: 483, Y: 425, Height: 25, Width: 25, Rotation: Rotate270, Value: "PIECE"},
+			Serial{X: 483, Y: 350, Height: 25, Width: 25, Rotation: Rotate270, Start: 1, Digits: digits},
+			Text{X: 483, Y: 340 - 14*digits, Height: 25, Width: 25, Rotation: Rotate270, Value: "OF " + strconv.Itoa(copies)},
+		)
+	}
+	return marks
+}
@@ -0,0 +1,139 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+
+	"labelops-backend/models"
+)
+
+// TSPL encodes layouts for TSC printers. Text uses the scalable font "0",
+// sized in points, with the UTF-8 code page. Images are drawn as bars,
+// because BITMAP takes binary data and jobs are stored as text.
+type TSPL struct{}
+
+// Language implements Backend
+func (TSPL) Language() string {
+	return models.PrinterLanguageTSPL
+}
+
+// Encode implements Backend
+func (TSPL) Encode(l Layout) (string, error) {
+	var b strings.Builder
+	dpi := l.dpi()
+	mm := func(dots int) float64 {
+		return float64(dots) * 25.4 / float64(dpi)
+	}
+	points := func(dots int) int {
+		return (dots*72 + dpi/2) / dpi
+	}
+	fmt.Fprintf(&b, "SIZE %.1f mm,%.1f mm\r\n", mm(l.Width), mm(l.Length))
+	if l.Media == nil {
+		b.WriteString("GAP 3 mm,0 mm\r\n")
+	} else {
+		tsplMedia(&b, *l.Media, mm)
+	}
+	b.WriteString("DIRECTION 1\r\nREFERENCE 0,0\r\nCODEPAGE UTF-8\r\nCLS\r\n")
+	serial, numbered := l.serial()
+	if numbered {
+		// Counter @0 counts up on each label PRINT prints
+		fmt.Fprintf(&b, "SET COUNTER @0 1\r\n@0 = \"%s\"\r\n", serial.value(0))
+	}
+	for _, e := range l.Elements {
+		switch e := e.(type) {
+		case Serial:
+			x, y := textOrigin(Text{X: e.X, Y: e.Y, Rotation: e.Rotation}, e.Height*4/5)
+			fmt.Fprintf(&b, "TEXT %d,%d,\"0\",%d,%d,%d,@0\r\n", x, y, e.Rotation, points(e.Width), points(e.Height))
+		case Text:
+			value, err := tsplQuote(e.Value)
+			if err != nil {
+				return "", err
+			}
+			x, y := textOrigin(e, e.Height*4/5)
+			fmt.Fprintf(&b, "TEXT %d,%d,\"0\",%d,%d,%d,%s\r\n", x, y, e.Rotation, points(e.Width), points(e.Height), value)
+		case Box:
+			fmt.Fprintf(&b, "BOX %d,%d,%d,%d,%d\r\n", e.X, e.Y, e.X+e.Width, e.Y+e.Height, e.Thickness)
+		case Line:
+			w, h := e.Length, e.Thickness
+			if e.Vertical {
+				w, h = e.Thickness, e.Length
+			}
+			fmt.Fprintf(&b, "BAR %d,%d,%d,%d\r\n", e.X, e.Y, w, h)
+		case QR:
+			data, err := tsplQuote(e.Data)
+			if err != nil {
+				return "", err
+			}
+			x, y, err := qrOrigin(e)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&b, "QRCODE %d,%d,%s,%d,A,0,%s\r\n", x, y, e.Level, e.Magnification, data)
+		case Barcode:
+			if e.GS1 {
+				return "", fmt.Errorf("TSPL printers cannot print GS1-128 bar codes")
+			}
+			data, err := tsplQuote(e.Data)
+			if err != nil {
+				return "", err
+			}
+			readable := 0
+			if e.HumanReadable {
+				readable = 1
+			}
+			fmt.Fprintf(&b, "BARCODE %d,%d,\"128\",%d,%d,%d,%d,%d,%s\r\n", e.X, e.Y, e.Height, readable, e.Rotation, e.Module, e.Module, data)
+		case DataMatrix:
+			if e.GS1 {
+				return "", fmt.Errorf("TSPL printers cannot print GS1 Data Matrix symbols")
+			}
+			data, err := tsplQuote(e.Data)
+			if err != nil {
+				return "", err
+			}
+			// The symbol grows to fit its data; 144 modules bounds the largest square
+			fmt.Fprintf(&b, "DMATRIX %d,%d,%d,%d,x%d,%s\r\n", e.X, e.Y, 144*e.Module, 144*e.Module, e.Module, data)
+		case Image:
+			for _, r := range e.Bitmap.Rects() {
+				fmt.Fprintf(&b, "BAR %d,%d,%d,%d\r\n", e.X+r.Min.X, e.Y+r.Min.Y, r.Dx(), r.Dy())
+			}
+		default:
+			return "", fmt.Errorf("unsupported layout element %T", e)
+		}
+	}
+	if numbered {
+		fmt.Fprintf(&b, "PRINT %d,1\r\n", l.copies())
+	} else {
+		fmt.Fprintf(&b, "PRINT 1,%d\r\n", l.copies())
+	}
+	return b.String(), nil
+}
+
+// tsplMedia writes the TSPL settings of the media: how the printer finds
+// the next label, density (0-15), speed, tear-off offset and label top shift
+func tsplMedia(b *strings.Builder, m Media, mm func(int) float64) {
+	switch m.Type {
+	case models.MediaTypeMark:
+		b.WriteString("BLINE 3 mm,0 mm\r\n")
+	case models.MediaTypeContinuous:
+		b.WriteString("GAP 0 mm,0 mm\r\n")
+	default:
+		b.WriteString("GAP 3 mm,0 mm\r\n")
+	}
+	if m.Darkness != nil {
+		fmt.Fprintf(b, "DENSITY %d\r\n", *m.Darkness/2)
+	}
+	if m.Speed != nil {
+		fmt.Fprintf(b, "SPEED %d\r\n", *m.Speed)
+	}
+	fmt.Fprintf(b, "OFFSET %.1f mm\r\nSHIFT %d\r\n", mm(m.TearOff), m.LabelTop)
+}
+
+// tsplQuote quotes a value for a TSPL command; a double quote is written as \["]
+func tsplQuote(s string) (string, error) {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return "", fmt.Errorf("TSPL printers cannot print control characters in %q", s)
+		}
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\["]`) + `"`, nil
+}
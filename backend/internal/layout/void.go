@@ -0,0 +1,36 @@
+package layout
+
+// VoidName identifies the VOID layout
+const VoidName = "void"
+
+// VoidValues are the values printed on a VOID label
+type VoidValues struct {
+	LabelID string
+	HeatNo  string
+	Reason  string
+	Voided  string // when the label was voided, e.g. 17-OCT-26 13:55
+}
+
+// Void is the label printed for a voided bundle, to be stuck over its tag:
+// VOID in large type with the label it voids and why. It has the size and
+// orientation of the QCIN layout so it prints on the same stock.
+func Void(v VoidValues) Layout {
+	text := func(x, y, h, w int, value string) Text {
+		return Text{X: x, Y: y, Height: h, Width: w, Rotation: Rotate270, Value: value}
+	}
+	return Layout{
+		Name:   VoidName,
+		Width:  812,
+		Length: 609,
+		DPI:    203,
+		Elements: []Element{
+			Box{X: 16, Y: 16, Width: 780, Height: 577, Thickness: 8},
+			text(250, 545, 180, 120, "VOID"),
+			Line{X: 290, Y: 40, Length: 529, Thickness: 4, Vertical: true},
+			text(370, 570, 34, 28, "ID "+v.LabelID),
+			text(440, 570, 34, 28, "HEAT NO. "+v.HeatNo),
+			text(510, 570, 34, 28, v.Reason),
+			text(580, 570, 25, 22, "VOIDED "+v.Voided),
+		},
+	}
+}
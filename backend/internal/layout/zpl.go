@@ -0,0 +1,133 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+
+	"labelops-backend/internal/gs1"
+	"labelops-backend/internal/zpl"
+	"labelops-backend/models"
+)
+
+// ZPL encodes layouts as ZPL II for Zebra printers. Text is printed in
+// font 0 from UTF-8 (^CI28) and every value is escaped for ^FH\.
+type ZPL struct{}
+
+// Language implements Backend
+func (ZPL) Language() string {
+	return models.PrinterLanguageZPL
+}
+
+// Encode implements Backend. The media's darkness and tear-off are control
+// commands, sent ahead of the format; its speed, label top and tracking are
+// set at the start of the format.
+func (z ZPL) Encode(l Layout) (string, error) {
+	var b strings.Builder
+	control, format := "", ""
+	if l.Media != nil {
+		control, format = l.Media.ZPL().Commands()
+	}
+	fmt.Fprintf(&b, "%s^XA\n%s^MMT\n^PW%d\n^LL%d\n^LS0\n", control, format, l.Width, l.Length)
+	if err := z.encodeElements(&b, l.Elements); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&b, "^PQ%d,0,1,Y\n^XZ\n", l.copies())
+	return b.String(), nil
+}
+
+// EncodeElements encodes elements as ZPL fields, without a label format
+// around them, for adding to a format rendered from a template
+func (z ZPL) EncodeElements(elements []Element) (string, error) {
+	var b strings.Builder
+	if err := z.encodeElements(&b, elements); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// encodeElements writes each element as a ZPL field
+func (ZPL) encodeElements(b *strings.Builder, elements []Element) error {
+	for _, e := range elements {
+		switch e := e.(type) {
+		case Text:
+			value, err := zpl.EscapeField(e.Value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "^FT%d,%d^A0%c,%d,%d^FH\\^CI28^FD%s^FS^CI27\n", e.X, e.Y, zplRotation(e.Rotation), e.Height, e.Width, value)
+		case Serial:
+			// ^SN counts up on each copy ^PQ prints, keeping the leading zeros
+			fmt.Fprintf(b, "^FT%d,%d^A0%c,%d,%d^SN%s,1,Y^FS\n", e.X, e.Y, zplRotation(e.Rotation), e.Height, e.Width, e.value(0))
+		case Box:
+			fmt.Fprintf(b, "^FO%d,%d^GB%d,%d,%d^FS\n", e.X, e.Y, e.Width, e.Height, e.Thickness)
+		case Line:
+			// A box narrower than its border is drawn as a solid line
+			w, h := e.Length, 0
+			if e.Vertical {
+				w, h = 0, e.Length
+			}
+			fmt.Fprintf(b, "^FO%d,%d^GB%d,%d,%d^FS\n", e.X, e.Y, w, h, e.Thickness)
+		case QR:
+			data, err := zpl.EscapeField(e.Data)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "^FT%d,%d^BQN,2,%d\n^FH\\^FD%sA,%s^FS\n", e.X, e.Y, e.Magnification, e.Level, data)
+		case Barcode:
+			// UCC/EAN mode takes the HRI form; otherwise > starts an invocation code
+			mode, data := 'D', e.Data
+			if !e.GS1 {
+				mode, data = 'N', strings.ReplaceAll(e.Data, ">", "><")
+			}
+			data, err := zpl.EscapeField(data)
+			if err != nil {
+				return err
+			}
+			interpretation := 'N'
+			if e.HumanReadable {
+				interpretation = 'Y'
+			}
+			fmt.Fprintf(b, "^FO%d,%d^BY%d^BC%c,%d,%c,N,N,%c^FH\\^FD%s^FS\n",
+				e.X, e.Y, e.Module, zplRotation(e.Rotation), e.Height, interpretation, mode, data)
+		case DataMatrix:
+			data, err := zplDataMatrix(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "^FO%d,%d^BXN,%d,200,0,0,6,#^FH\\^FD%s^FS\n", e.X, e.Y, e.Module, data)
+		case Image:
+			fmt.Fprintf(b, "^FO%d,%d^GFA,%s^FS\n", e.X, e.Y, zpl.EncodeGraphic(e.Bitmap.Bits, e.Bitmap.Stride()))
+		default:
+			return fmt.Errorf("unsupported layout element %T", e)
+		}
+	}
+	return nil
+}
+
+// zplRotation maps a rotation onto a ZPL field orientation
+func zplRotation(r Rotation) byte {
+	switch r {
+	case Rotate90:
+		return 'R'
+	case Rotate180:
+		return 'I'
+	case Rotate270:
+		return 'B'
+	}
+	return 'N'
+}
+
+// zplDataMatrix escapes DataMatrix data for ^BX with the escape character #.
+// A GS1 element string starts with FNC1 (#1) and keeps its GS separators.
+func zplDataMatrix(e DataMatrix) (string, error) {
+	if !e.GS1 {
+		data := strings.ReplaceAll(e.Data, "#", "#d035")
+		return zpl.EscapeField(data)
+	}
+	es, err := gs1.ParseHRI(e.Data)
+	if err != nil {
+		return "", err
+	}
+	data, err := zpl.EscapeField(es.Data())
+	return "#1" + data, err
+}
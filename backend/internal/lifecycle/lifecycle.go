@@ -0,0 +1,128 @@
+// Package lifecycle is the state machine of a label: received when stored,
+// queued while a print job for it is waiting, then printed (or failed), and
+// reprinted by every later print; voided or dispatched end it. Every move is
+// recorded in label_events, which the stats are derived from. The database
+// enforces the same transitions: a trigger on labels.status and a CHECK
+// constraint on label_events.
+package lifecycle
+
+import (
+	"database/sql"
+	"fmt"
+
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// transitions lists the statuses a label may move to from each status. It
+// must match the label_events_transition_check constraint and the
+// label_status_transition trigger in db/schema.sql.
+var transitions = map[string][]string{
+	models.LabelStatusReceived:   {models.LabelStatusQueued, models.LabelStatusVoided},
+	models.LabelStatusQueued:     {models.LabelStatusPrinted, models.LabelStatusFailed, models.LabelStatusVoided},
+	models.LabelStatusFailed:     {models.LabelStatusQueued, models.LabelStatusPrinted, models.LabelStatusVoided},
+	models.LabelStatusPrinted:    {models.LabelStatusReprinted, models.LabelStatusDispatched, models.LabelStatusVoided},
+	models.LabelStatusReprinted:  {models.LabelStatusReprinted, models.LabelStatusDispatched, models.LabelStatusVoided},
+	models.LabelStatusDispatched: {},
+	models.LabelStatusVoided:     {},
+}
+
+// Valid reports whether status is a label status
+func Valid(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// Allowed reports whether a label may move from one status to another
+func Allowed(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned for a move the state machine does not allow
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("a %s label cannot be %s", e.From, e.To)
+}
+
+// To moves a label to status whatever its current status
+func To(status string) func(from string) string {
+	return func(string) string { return status }
+}
+
+// AfterQueue is the status of a label once a print job for it is queued. A
+// label that has printed keeps its status until the reprint completes.
+func AfterQueue(from string) string {
+	if from == models.LabelStatusReceived || from == models.LabelStatusFailed {
+		return models.LabelStatusQueued
+	}
+	return from
+}
+
+// AfterPrint is the status of a label once a print job for it completes
+func AfterPrint(from string) string {
+	switch from {
+	case models.LabelStatusQueued, models.LabelStatusFailed:
+		return models.LabelStatusPrinted
+	case models.LabelStatusPrinted, models.LabelStatusReprinted:
+		return models.LabelStatusReprinted
+	}
+	return from
+}
+
+// AfterFailure is the status of a label once its last waiting print job has
+// failed. A label that has printed keeps its status.
+func AfterFailure(from string) string {
+	if from == models.LabelStatusQueued {
+		return models.LabelStatusFailed
+	}
+	return from
+}
+
+// Event describes a move to record; the statuses are filled in by Advance
+type Event struct {
+	ActorID    *uuid.UUID // nil for the dispatcher
+	PrintJobID *uuid.UUID
+	Note       string
+}
+
+// Advance locks a label in tx and moves it to the status next returns for its
+// current status, recording the move in label_events. Nothing is recorded when
+// next leaves the status unchanged, unless the state machine allows the label
+// to stay (a further reprint). It returns a *TransitionError for a move the
+// state machine does not allow, and sql.ErrNoRows for an unknown label.
+func Advance(tx *sql.Tx, labelID uuid.UUID, next func(from string) string, e Event) (models.LabelEvent, error) {
+	var from string
+	if err := tx.QueryRow(`SELECT status FROM labels WHERE id = $1 FOR UPDATE`, labelID).Scan(&from); err != nil {
+		return models.LabelEvent{}, err
+	}
+	to := next(from)
+	ev := models.LabelEvent{LabelID: labelID, FromStatus: &from, ToStatus: to, ActorID: e.ActorID, PrintJobID: e.PrintJobID}
+	if to == from && !Allowed(from, to) {
+		return ev, nil
+	}
+	if !Allowed(from, to) {
+		return ev, &TransitionError{From: from, To: to}
+	}
+	if e.Note != "" {
+		ev.Note = &e.Note
+	}
+
+	if _, err := tx.Exec(`UPDATE labels SET status = $2, updated_at = NOW() WHERE id = $1`, labelID, to); err != nil {
+		return ev, err
+	}
+	err := tx.QueryRow(`
+		INSERT INTO label_events (label_id, from_status, to_status, actor_id, print_job_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, labelID, from, to, e.ActorID, e.PrintJobID, ev.Note).Scan(&ev.ID, &ev.CreatedAt)
+	return ev, err
+}
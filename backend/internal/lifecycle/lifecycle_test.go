@@ -0,0 +1,102 @@
+package lifecycle_test
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"labelops-backend/internal/lifecycle"
+	"labelops-backend/models"
+)
+
+var statuses = []string{
+	models.LabelStatusReceived, models.LabelStatusQueued, models.LabelStatusPrinted, models.LabelStatusFailed,
+	models.LabelStatusReprinted, models.LabelStatusVoided, models.LabelStatusDispatched,
+}
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		next func(string) string
+		want map[string]string // from status to status; others are unchanged
+	}{
+		{"queue", lifecycle.AfterQueue, map[string]string{
+			models.LabelStatusReceived: models.LabelStatusQueued,
+			models.LabelStatusFailed:   models.LabelStatusQueued,
+		}},
+		{"print", lifecycle.AfterPrint, map[string]string{
+			models.LabelStatusQueued:    models.LabelStatusPrinted,
+			models.LabelStatusFailed:    models.LabelStatusPrinted,
+			models.LabelStatusPrinted:   models.LabelStatusReprinted,
+			models.LabelStatusReprinted: models.LabelStatusReprinted,
+		}},
+		{"failure", lifecycle.AfterFailure, map[string]string{
+			models.LabelStatusQueued: models.LabelStatusFailed,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, from := range statuses {
+				want, ok := tt.want[from]
+				if !ok {
+					want = from
+				}
+				got := tt.next(from)
+				if got != want {
+					t.Errorf("%s: %s became %s, want %s", tt.name, from, got, want)
+				}
+				if got != from && !lifecycle.Allowed(from, got) {
+					t.Errorf("%s: %s to %s is not an allowed transition", tt.name, from, got)
+				}
+			}
+		})
+	}
+}
+
+func TestEndStates(t *testing.T) {
+	for _, from := range []string{models.LabelStatusVoided, models.LabelStatusDispatched} {
+		for _, to := range statuses {
+			if lifecycle.Allowed(from, to) {
+				t.Errorf("%s label may become %s", from, to)
+			}
+		}
+	}
+	if lifecycle.Valid("success") {
+		t.Error("success is a valid status")
+	}
+}
+
+// The label_events CHECK constraint and the labels trigger must allow exactly
+// the transitions Allowed does
+func TestSchemaTransitions(t *testing.T) {
+	schema, err := os.ReadFile("../../db/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ name, start, end string }{
+		{"label_events_transition_check", "CONSTRAINT label_events_transition_check", ");\n"},
+		{"label_status_transition", "FUNCTION label_status_transition()", "$$ LANGUAGE"},
+	} {
+		_, pairs, ok := strings.Cut(string(schema), tt.start)
+		if !ok {
+			t.Fatalf("%s not found in schema.sql", tt.name)
+		}
+		pairs, _, _ = strings.Cut(pairs, tt.end)
+
+		inSchema := map[[2]string]bool{}
+		for _, m := range regexp.MustCompile(`\('(\w+)', '(\w+)'\)`).FindAllStringSubmatch(pairs, -1) {
+			inSchema[[2]string{m[1], m[2]}] = true
+		}
+		for _, from := range statuses {
+			for _, to := range statuses {
+				if allowed := lifecycle.Allowed(from, to); allowed != inSchema[[2]string{from, to}] {
+					t.Errorf("%s to %s: allowed %v in Go, %v in %s", from, to, allowed, !allowed, tt.name)
+				}
+			}
+		}
+	}
+	if !regexp.MustCompile(`labels_status_check\s+CHECK \(status IN \('` + strings.Join(statuses, `', '`) + `'\)\)`).Match(schema) {
+		t.Error("labels_status_check does not list every status")
+	}
+}
@@ -0,0 +1,65 @@
+package printer
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// DevicePrinter prints by writing to a local device file (e.g. /dev/usb/lp0)
+type DevicePrinter struct {
+	Path         string
+	WriteTimeout time.Duration
+}
+
+// NewDevicePrinter creates a device printer with the default write timeout
+func NewDevicePrinter(path string) *DevicePrinter {
+	return &DevicePrinter{Path: path, WriteTimeout: DefaultWriteTimeout}
+}
+
+// Name returns the device path
+func (p *DevicePrinter) Name() string {
+	return p.Path
+}
+
+// Print writes data to the device file
+func (p *DevicePrinter) Print(ctx context.Context, data []byte) error {
+	if len(data) == 0 {
+		return ErrNoData
+	}
+
+	f, err := os.OpenFile(p.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return &PrintError{Printer: p.Name(), Op: OpConnect, Err: err}
+	}
+
+	// Device writes can block indefinitely when the printer is offline,
+	// so run the write in the background and give up after the timeout
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		done <- result{n, err}
+	}()
+
+	timer := time.NewTimer(orDefault(p.WriteTimeout, DefaultWriteTimeout))
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return &PrintError{Printer: p.Name(), Op: OpWrite, Written: r.n, Err: r.err}
+		}
+		return nil
+	case <-timer.C:
+		return &PrintError{Printer: p.Name(), Op: OpWrite, Err: os.ErrDeadlineExceeded}
+	case <-ctx.Done():
+		return &PrintError{Printer: p.Name(), Op: OpWrite, Err: ctx.Err()}
+	}
+}
@@ -0,0 +1,296 @@
+// Package fake provides an in-process Zebra printer that speaks raw ZPL over TCP.
+// It captures every ^XA...^XZ label it receives, answers ~HS host status queries
+// and can inject faults on demand so print flows can be tested without hardware.
+package fake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"labelops-backend/internal/printer"
+)
+
+// Label is one ^XA...^XZ format captured by the fake printer
+type Label struct {
+	Seq        int       `json:"seq"`
+	ZPL        string    `json:"zpl"`
+	Path       string    `json:"path,omitempty"` // file the label was saved to, when Dir is set
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Faults are the failures the fake printer injects into new connections
+type Faults struct {
+	Refuse    bool          `json:"refuse"`     // close the listener so connections are refused
+	Delay     time.Duration `json:"delay"`      // wait before reading each connection
+	DropAfter int           `json:"drop_after"` // close each connection after this many bytes (0 disables)
+	PaperOut  bool          `json:"paper_out"`  // report paper out in ~HS and discard labels
+}
+
+// Server is a fake ZPL printer listening on a TCP port
+type Server struct {
+	// Dir, when set, receives a label_NNNN.zpl file for every captured label
+	Dir string
+
+	mu       sync.Mutex
+	addr     string
+	listener net.Listener
+	faults   Faults
+	labels   []Label
+	seq      int
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// ErrClosed is returned when the server has been closed
+var ErrClosed = errors.New("fake printer: server closed")
+
+// Listen starts a fake printer on addr (for example ":9100" or "127.0.0.1:0")
+func Listen(addr string) (*Server, error) {
+	s := &Server{conns: make(map[net.Conn]struct{})}
+	if err := s.listen(addr); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// listen opens the listener and starts accepting connections; s.mu must not be held
+func (s *Server) listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = ln
+	s.addr = ln.Addr().String()
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.accept(ln)
+	return nil
+}
+
+// Addr returns the host:port the server listens on
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// Printer returns a TCPPrinter pointed at the server
+func (s *Server) Printer() *printer.TCPPrinter {
+	host, port, _ := net.SplitHostPort(s.Addr())
+	p, _ := strconv.Atoi(port)
+	return printer.NewTCPPrinter(host, p)
+}
+
+// Close stops the listener and drops all open connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+		s.listener = nil
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Faults returns the currently injected faults
+func (s *Server) Faults() Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.faults
+}
+
+// SetFaults replaces the injected faults. Turning Refuse on closes the listener so
+// the port actively refuses connections; turning it off listens on the same address again.
+func (s *Server) SetFaults(f Faults) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	wasRefusing := s.faults.Refuse
+	s.faults = f
+	addr := s.addr
+
+	if f.Refuse && !wasRefusing && s.listener != nil {
+		ln := s.listener
+		s.listener = nil
+		s.mu.Unlock()
+		return ln.Close()
+	}
+	s.mu.Unlock()
+
+	if !f.Refuse && wasRefusing {
+		return s.listen(addr)
+	}
+	return nil
+}
+
+// Labels returns a copy of every label captured so far
+func (s *Server) Labels() []Label {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Label(nil), s.labels...)
+}
+
+// Reset forgets captured labels and clears all faults
+func (s *Server) Reset() error {
+	s.mu.Lock()
+	s.labels = nil
+	s.seq = 0
+	s.mu.Unlock()
+	return s.SetFaults(Faults{})
+}
+
+// Status returns the host status the server reports to ~HS
+func (s *Server) Status() printer.HostStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return printer.HostStatus{
+		PaperOut:    s.faults.PaperOut,
+		LabelLength: 609,
+	}
+}
+
+func (s *Server) accept(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		// Like a real printer, handle one connection at a time so labels are
+		// captured in the order they were sent
+		s.serve(conn)
+	}
+}
+
+// serve reads a ZPL stream, answering ~HS and capturing labels until the client disconnects
+func (s *Server) serve(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	faults := s.Faults()
+	if faults.Delay > 0 {
+		time.Sleep(faults.Delay)
+	}
+
+	var (
+		buf   []byte
+		total int
+		chunk = make([]byte, 4096)
+	)
+	for {
+		n, err := conn.Read(chunk)
+		if faults.DropAfter > 0 && total+n >= faults.DropAfter {
+			// Simulate the printer going away part way through the stream
+			return
+		}
+		total += n
+		buf = append(buf, chunk[:n]...)
+
+		var queries int
+		buf, queries = extractStatusQueries(buf)
+		for i := 0; i < queries; i++ {
+			if _, werr := conn.Write(printer.FormatHostStatus(s.Status())); werr != nil {
+				return
+			}
+		}
+
+		var formats []string
+		buf, formats = extractLabels(buf)
+		for _, zpl := range formats {
+			s.capture(zpl)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// capture records a complete label, unless the printer is reporting paper out
+func (s *Server) capture(zpl string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.faults.PaperOut {
+		return
+	}
+
+	s.seq++
+	label := Label{Seq: s.seq, ZPL: zpl, ReceivedAt: time.Now()}
+	if s.Dir != "" {
+		path := filepath.Join(s.Dir, fmt.Sprintf("label_%04d.zpl", s.seq))
+		if err := os.WriteFile(path, []byte(zpl), 0644); err == nil {
+			label.Path = path
+		}
+	}
+	s.labels = append(s.labels, label)
+}
+
+// extractStatusQueries removes every ~HS command from buf and returns how many were found
+func extractStatusQueries(buf []byte) ([]byte, int) {
+	count := bytes.Count(buf, []byte("~HS"))
+	if count == 0 {
+		return buf, 0
+	}
+	return bytes.ReplaceAll(buf, []byte("~HS"), nil), count
+}
+
+// extractLabels removes every complete ^XA...^XZ format from buf and returns them.
+// Bytes outside a format are discarded; a trailing partial format is kept for the next read.
+func extractLabels(buf []byte) ([]byte, []string) {
+	var labels []string
+	for {
+		start := bytes.Index(buf, []byte("^XA"))
+		if start < 0 {
+			// Keep the last two bytes in case a command is split across reads
+			if len(buf) > 2 {
+				buf = buf[len(buf)-2:]
+			}
+			return buf, labels
+		}
+		end := bytes.Index(buf[start:], []byte("^XZ"))
+		if end < 0 {
+			return buf[start:], labels
+		}
+		end += start + len("^XZ")
+		labels = append(labels, string(buf[start:end]))
+		buf = buf[end:]
+	}
+}
@@ -0,0 +1,192 @@
+package fake_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"labelops-backend/internal/printer"
+	"labelops-backend/internal/printer/fake"
+)
+
+func startServer(t *testing.T) *fake.Server {
+	t.Helper()
+	srv, err := fake.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// waitForLabels waits for the server to finish reading closed connections
+func waitForLabels(t *testing.T, srv *fake.Server, n int) []fake.Label {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		labels := srv.Labels()
+		if len(labels) >= n || time.Now().After(deadline) {
+			return labels
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func label(n int) []byte {
+	return []byte(fmt.Sprintf("^XA^FO50,50^A0N,30,30^FDLABEL %d^FS^XZ", n))
+}
+
+func TestPrintBatchCapturesEveryLabel(t *testing.T) {
+	srv := startServer(t)
+	srv.Dir = t.TempDir()
+
+	jobs := []printer.Job{
+		{ID: "1", Data: label(1)},
+		{ID: "2", Data: append(label(2), label(3)...)},
+	}
+	results := printer.PrintBatch(context.Background(), srv.Printer(), jobs, nil)
+	for _, r := range results {
+		if !r.Success() {
+			t.Fatalf("job %s failed: %v", r.JobID, r.Err)
+		}
+	}
+
+	labels := waitForLabels(t, srv, 3)
+	if len(labels) != 3 {
+		t.Fatalf("got %d labels, want 3", len(labels))
+	}
+	for i, l := range labels {
+		want := string(label(i + 1))
+		if l.ZPL != want {
+			t.Errorf("label %d = %q, want %q", i+1, l.ZPL, want)
+		}
+		saved, err := os.ReadFile(l.Path)
+		if err != nil {
+			t.Fatalf("reading saved label: %v", err)
+		}
+		if string(saved) != want {
+			t.Errorf("saved label %d = %q, want %q", i+1, saved, want)
+		}
+	}
+}
+
+func TestQueryStatus(t *testing.T) {
+	srv := startServer(t)
+
+	status, err := srv.Printer().QueryStatus(context.Background())
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if !status.Ready() {
+		t.Fatalf("printer not ready: %v", status.Errors())
+	}
+
+	if err := srv.SetFaults(fake.Faults{PaperOut: true}); err != nil {
+		t.Fatal(err)
+	}
+	status, err = srv.Printer().QueryStatus(context.Background())
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if !status.PaperOut || status.Ready() {
+		t.Fatalf("status = %+v, want paper out", status)
+	}
+}
+
+func TestRefuseConnections(t *testing.T) {
+	srv := startServer(t)
+	p := srv.Printer()
+
+	if err := srv.SetFaults(fake.Faults{Refuse: true}); err != nil {
+		t.Fatal(err)
+	}
+	err := p.Print(context.Background(), label(1))
+	var printErr *printer.PrintError
+	if !errors.As(err, &printErr) || printErr.Op != printer.OpConnect {
+		t.Fatalf("Print error = %v, want connect failure", err)
+	}
+
+	if err := srv.SetFaults(fake.Faults{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Print(context.Background(), label(2)); err != nil {
+		t.Fatalf("Print after recovery: %v", err)
+	}
+	if labels := waitForLabels(t, srv, 1); len(labels) != 1 {
+		t.Fatalf("got %d labels, want 1", len(labels))
+	}
+}
+
+func TestDelayTimesOutStatusQuery(t *testing.T) {
+	srv := startServer(t)
+	if err := srv.SetFaults(fake.Faults{Delay: 500 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := srv.Printer()
+	p.ReadTimeout = 50 * time.Millisecond
+	if _, err := p.QueryStatus(context.Background()); !errors.Is(err, printer.ErrTimeout) {
+		t.Fatalf("QueryStatus error = %v, want timeout", err)
+	}
+}
+
+func TestDropMidStream(t *testing.T) {
+	srv := startServer(t)
+	data := label(1)
+	if err := srv.SetFaults(fake.Faults{DropAfter: len(data) / 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The client may or may not notice the drop, but the label must not be captured
+	srv.Printer().Print(context.Background(), data)
+	time.Sleep(100 * time.Millisecond)
+	if labels := srv.Labels(); len(labels) != 0 {
+		t.Fatalf("got %d labels from a dropped stream, want 0", len(labels))
+	}
+}
+
+func TestPaperOutDiscardsLabels(t *testing.T) {
+	srv := startServer(t)
+	if err := srv.SetFaults(fake.Faults{PaperOut: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	results := printer.PrintBatch(context.Background(), srv.Printer(), []printer.Job{{ID: "1", Data: label(1)}}, nil)
+	if !results[0].Success() {
+		t.Fatalf("Print: %v", results[0].Err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if labels := srv.Labels(); len(labels) != 0 {
+		t.Fatalf("got %d labels while out of paper, want 0", len(labels))
+	}
+}
+
+func TestBatchContinuesAfterFailure(t *testing.T) {
+	srv := startServer(t)
+	p := srv.Printer()
+
+	var seen []string
+	jobs := []printer.Job{{ID: "ok"}, {ID: "empty"}, {ID: "also-ok"}}
+	jobs[0].Data = label(1)
+	jobs[2].Data = label(2)
+
+	results := printer.PrintBatch(context.Background(), p, jobs, func(r printer.Result) {
+		seen = append(seen, r.JobID)
+	})
+	if strings.Join(seen, ",") != "ok,empty,also-ok" {
+		t.Fatalf("callbacks = %v", seen)
+	}
+	if !results[0].Success() || results[1].Success() || !results[2].Success() {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if !errors.Is(results[1].Err, printer.ErrNoData) {
+		t.Fatalf("empty job error = %v, want ErrNoData", results[1].Err)
+	}
+	if labels := waitForLabels(t, srv, 2); len(labels) != 2 {
+		t.Fatalf("got %d labels, want 2", len(labels))
+	}
+}
@@ -0,0 +1,69 @@
+package printer
+
+import (
+	"database/sql"
+	"errors"
+
+	"labelops-backend/db"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrMediaNotFound is returned when a media profile lookup has no match
+var ErrMediaNotFound = errors.New("printer: media profile not found")
+
+const mediaColumns = `id, name, width_mm, length_mm, dpi, darkness, speed, tear_off, label_top, media_type,
+	created_at, updated_at`
+
+func scanMedia(row rowScanner) (models.MediaProfile, error) {
+	var m models.MediaProfile
+	err := row.Scan(
+		&m.ID, &m.Name, &m.WidthMM, &m.LengthMM, &m.DPI, &m.Darkness, &m.Speed, &m.TearOff, &m.LabelTop, &m.MediaType,
+		&m.CreatedAt, &m.UpdatedAt,
+	)
+	return m, err
+}
+
+// ListMedia returns all media profiles ordered by name
+func ListMedia() ([]models.MediaProfile, error) {
+	rows, err := db.DB.Query(`SELECT ` + mediaColumns + ` FROM media_profiles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []models.MediaProfile{}
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, m)
+	}
+	return profiles, rows.Err()
+}
+
+// GetMedia fetches a media profile by ID
+func GetMedia(id uuid.UUID) (models.MediaProfile, error) {
+	m, err := scanMedia(db.DB.QueryRow(`SELECT `+mediaColumns+` FROM media_profiles WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return m, ErrMediaNotFound
+	}
+	return m, err
+}
+
+// MediaFor returns the media profile assigned to a printer, or nil when it has none
+func MediaFor(p models.Printer) (*models.MediaProfile, error) {
+	if p.MediaProfileID == nil {
+		return nil, nil
+	}
+	m, err := GetMedia(*p.MediaProfileID)
+	if err == ErrMediaNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
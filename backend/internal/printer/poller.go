@@ -0,0 +1,173 @@
+package printer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// PrinterStatus is the last known health of a registered printer
+type PrinterStatus struct {
+	PrinterID uuid.UUID   `json:"printer_id"`
+	Name      string      `json:"name"`
+	Online    bool        `json:"online"`
+	Ready     bool        `json:"ready"`
+	Errors    []string    `json:"errors"`
+	Host      *HostStatus `json:"host_status,omitempty"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// Poller periodically queries each registered printer with ~HS and caches the result
+type Poller struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.RWMutex
+	statuses map[uuid.UUID]PrinterStatus
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPoller creates a poller that checks every printer once per interval
+func NewPoller(interval time.Duration) *Poller {
+	return &Poller{
+		interval: interval,
+		timeout:  DefaultConnectTimeout + DefaultReadTimeout,
+		statuses: map[uuid.UUID]PrinterStatus{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start launches the polling loop
+func (p *Poller) Start() {
+	go p.loop()
+}
+
+// Stop ends the polling loop and waits for it to exit
+func (p *Poller) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+func (p *Poller) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.pollAll()
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollAll checks every active printer concurrently
+func (p *Poller) pollAll() {
+	printers, err := List()
+	if err != nil {
+		log.Printf("printer poller: failed to list printers: %v", err)
+		return
+	}
+
+	active := map[uuid.UUID]bool{}
+	var wg sync.WaitGroup
+	for _, m := range printers {
+		if !m.IsActive {
+			continue
+		}
+		active[m.ID] = true
+		wg.Add(1)
+		go func(m models.Printer) {
+			defer wg.Done()
+			p.Check(context.Background(), m)
+		}(m)
+	}
+	wg.Wait()
+
+	// Forget printers that were removed or deactivated
+	p.mu.Lock()
+	for id := range p.statuses {
+		if !active[id] {
+			delete(p.statuses, id)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// Check queries a printer immediately and caches the result.
+// Printers whose driver cannot report status, or that do not speak ZPL and
+// so do not answer ~HS, are assumed to be ready.
+func (p *Poller) Check(ctx context.Context, m models.Printer) PrinterStatus {
+	status := PrinterStatus{PrinterID: m.ID, Name: m.Name, Errors: []string{}, CheckedAt: time.Now()}
+
+	drv, err := FromModel(m)
+	if err != nil {
+		status.Errors = append(status.Errors, err.Error())
+	} else if querier, ok := drv.(StatusQuerier); ok && speaksZPL(m) {
+		ctx, cancel := context.WithTimeout(ctx, p.timeout)
+		host, err := querier.QueryStatus(ctx)
+		cancel()
+		if err != nil {
+			status.Errors = append(status.Errors, err.Error())
+		} else {
+			status.Online = true
+			status.Host = host
+			status.Errors = append(status.Errors, host.Errors()...)
+		}
+	} else {
+		status.Online = true
+	}
+	status.Ready = status.Online && len(status.Errors) == 0
+
+	p.mu.Lock()
+	prev, seen := p.statuses[m.ID]
+	p.statuses[m.ID] = status
+	p.mu.Unlock()
+
+	if seen && prev.Ready != status.Ready {
+		if status.Ready {
+			log.Printf("printer %s is ready again", m.Name)
+		} else {
+			log.Printf("printer %s reports errors: %v", m.Name, status.Errors)
+		}
+	}
+	return status
+}
+
+// speaksZPL reports whether a printer is configured for ZPL, the default
+func speaksZPL(m models.Printer) bool {
+	return m.Language == "" || m.Language == models.PrinterLanguageZPL
+}
+
+// Status returns the cached status of a printer
+func (p *Poller) Status(id uuid.UUID) (PrinterStatus, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s, ok := p.statuses[id]
+	return s, ok
+}
+
+// Faulted returns the IDs of printers whose last status check reported an error
+func (p *Poller) Faulted() []uuid.UUID {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var ids []uuid.UUID
+	for id, s := range p.statuses {
+		if !s.Ready {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -1,45 +1,247 @@
 package printer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strconv"
+	"time"
 )
 
-func PrintZPLFile(zplPath string) error {
-	absPath, err := filepath.Abs(zplPath)
+// DefaultPort is the raw JetDirect port exposed by Zebra network printers
+const DefaultPort = 9100
+
+// Default timeouts used when a printer does not configure its own
+const (
+	DefaultConnectTimeout = 5 * time.Second
+	DefaultWriteTimeout   = 10 * time.Second
+)
+
+// Printer sends raw printer language bytes (ZPL) to a physical device
+type Printer interface {
+	// Name identifies the printer in logs and error messages
+	Name() string
+	// Print streams data to the device and returns once it has been fully written
+	Print(ctx context.Context, data []byte) error
+}
+
+// Error operations reported by PrintError
+const (
+	OpConnect = "connect"
+	OpWrite   = "write"
+)
+
+var (
+	// ErrNoData is returned when there is nothing to send to the printer
+	ErrNoData = errors.New("printer: no data to print")
+	// ErrNotConfigured is returned when no printer has been configured
+	ErrNotConfigured = errors.New("printer: no printer configured")
+	// ErrTimeout matches any PrintError caused by a connect or write timeout
+	ErrTimeout = errors.New("printer: timeout")
+)
+
+// PrintError describes a failure talking to a printer
+type PrintError struct {
+	Printer string // printer name or address
+	Op      string // OpConnect or OpWrite
+	Written int    // bytes written before the failure
+	Err     error  // underlying network or I/O error
+}
+
+func (e *PrintError) Error() string {
+	if e.Op == OpWrite {
+		return fmt.Sprintf("printer %s: %s failed after %d bytes: %v", e.Printer, e.Op, e.Written, e.Err)
+	}
+	return fmt.Sprintf("printer %s: %s failed: %v", e.Printer, e.Op, e.Err)
+}
+
+func (e *PrintError) Unwrap() error { return e.Err }
+
+// Timeout reports whether the failure was caused by a deadline
+func (e *PrintError) Timeout() bool {
+	var netErr net.Error
+	if errors.As(e.Err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Is lets callers test for ErrTimeout with errors.Is
+func (e *PrintError) Is(target error) bool {
+	return target == ErrTimeout && e.Timeout()
+}
+
+// TCPPrinter prints over a raw socket (JetDirect / port 9100)
+type TCPPrinter struct {
+	Host           string
+	Port           int
+	ConnectTimeout time.Duration
+	WriteTimeout   time.Duration
+	ReadTimeout    time.Duration // used by status queries
+}
+
+// NewTCPPrinter creates a raw socket printer with default timeouts
+func NewTCPPrinter(host string, port int) *TCPPrinter {
+	if port == 0 {
+		port = DefaultPort
+	}
+	return &TCPPrinter{
+		Host:           host,
+		Port:           port,
+		ConnectTimeout: DefaultConnectTimeout,
+		WriteTimeout:   DefaultWriteTimeout,
+		ReadTimeout:    DefaultReadTimeout,
+	}
+}
+
+// Addr returns the host:port the printer listens on
+func (p *TCPPrinter) Addr() string {
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+}
+
+// Name returns the printer address
+func (p *TCPPrinter) Name() string {
+	return p.Addr()
+}
+
+// Print opens a connection, writes data and closes the connection
+func (p *TCPPrinter) Print(ctx context.Context, data []byte) error {
+	if len(data) == 0 {
+		return ErrNoData
+	}
+
+	conn, err := p.dial(ctx)
 	if err != nil {
 		return err
 	}
-	absPath = filepath.ToSlash(absPath)
+	defer conn.Close()
+
+	return p.write(ctx, conn, data)
+}
+
+// dial connects to the printer honoring both ctx and ConnectTimeout
+func (p *TCPPrinter) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: orDefault(p.ConnectTimeout, DefaultConnectTimeout)}
+	conn, err := dialer.DialContext(ctx, "tcp", p.Addr())
+	if err != nil {
+		return nil, &PrintError{Printer: p.Name(), Op: OpConnect, Err: err}
+	}
+	return conn, nil
+}
 
-	batPath := filepath.Join("printers", "bat", "print_label.bat")
-	if _, err := os.Stat(batPath); os.IsNotExist(err) {
-		content := `@echo off
-copy /B "%1" "\\%COMPUTERNAME%\\Your_Printer_Share_Name"
-echo Label sent to printer.
-pause`
-		os.WriteFile(batPath, []byte(content), 0755)
+// write streams data to conn, applying WriteTimeout to the whole transfer
+func (p *TCPPrinter) write(ctx context.Context, conn net.Conn, data []byte) error {
+	deadline := time.Now().Add(orDefault(p.WriteTimeout, DefaultWriteTimeout))
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return &PrintError{Printer: p.Name(), Op: OpWrite, Err: err}
 	}
 
-	cmd := exec.Command("cmd", "/C", batPath, absPath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Abort the write if the caller gives up
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetWriteDeadline(time.Now())
+	})
+	defer stop()
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to print label: %w", err)
+	n, err := conn.Write(data)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return &PrintError{Printer: p.Name(), Op: OpWrite, Written: n, Err: err}
 	}
 	return nil
 }
 
-// PrintZPLBatch handles printing multiple ZPL files.
-func PrintZPLBatch(zplPaths []string) error {
-	for _, path := range zplPaths {
-		fmt.Printf("Printing: %s\n", path)
-		if err := PrintZPLFile(path); err != nil {
-			return fmt.Errorf("failed printing %s: %w", path, err)
+// NewFromEnv builds the default printer from PRINTER_* environment variables
+func NewFromEnv() (Printer, error) {
+	host := os.Getenv("PRINTER_HOST")
+	if host == "" {
+		return nil, ErrNotConfigured
+	}
+
+	port := DefaultPort
+	if v := os.Getenv("PRINTER_PORT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRINTER_PORT %q: %w", v, err)
 		}
+		port = n
 	}
-	return nil
+
+	p := NewTCPPrinter(host, port)
+	if v := os.Getenv("PRINTER_CONNECT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRINTER_CONNECT_TIMEOUT %q: %w", v, err)
+		}
+		p.ConnectTimeout = d
+	}
+	if v := os.Getenv("PRINTER_WRITE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRINTER_WRITE_TIMEOUT %q: %w", v, err)
+		}
+		p.WriteTimeout = d
+	}
+	return p, nil
+}
+
+// Job is one document in a print batch
+type Job struct {
+	ID   string
+	Data []byte
+}
+
+// Result reports the outcome of printing one Job
+type Result struct {
+	JobID     string
+	Err       error
+	BytesSent int
+	Duration  time.Duration
+}
+
+// Success reports whether the job was fully sent to the printer
+func (r Result) Success() bool {
+	return r.Err == nil
+}
+
+// PrintBatch sends each job to the printer in order and returns one Result per job.
+// A failing job does not stop the batch; later jobs are still attempted.
+// onResult, when not nil, is called as soon as each job finishes.
+func PrintBatch(ctx context.Context, p Printer, jobs []Job, onResult func(Result)) []Result {
+	results := make([]Result, 0, len(jobs))
+	for _, job := range jobs {
+		start := time.Now()
+		err := ctx.Err()
+		if err == nil {
+			err = p.Print(ctx, job.Data)
+		}
+
+		r := Result{JobID: job.ID, Err: err, Duration: time.Since(start)}
+		var printErr *PrintError
+		switch {
+		case err == nil:
+			r.BytesSent = len(job.Data)
+		case errors.As(err, &printErr):
+			r.BytesSent = printErr.Written
+		}
+
+		results = append(results, r)
+		if onResult != nil {
+			onResult(r)
+		}
+	}
+	return results
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
 }
@@ -0,0 +1,89 @@
+package printer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"labelops-backend/internal/printer"
+)
+
+// stalledPrinter returns a printer whose listener accepts connections and never
+// reads from them, like a printer with a jammed buffer
+func stalledPrinter(t *testing.T) *printer.TCPPrinter {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	accepted := make(chan net.Conn, 16)
+	t.Cleanup(func() {
+		ln.Close()
+		for conn := range accepted {
+			conn.Close()
+		}
+	})
+	go func() {
+		defer close(accepted)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return &printer.TCPPrinter{Host: addr.IP.String(), Port: addr.Port}
+}
+
+func TestPrintWriteTimeout(t *testing.T) {
+	p := stalledPrinter(t)
+	p.WriteTimeout = 100 * time.Millisecond
+
+	// Far more than the socket buffers hold, so the write blocks
+	data := bytes.Repeat([]byte("^XA^FDSTALLED^FS^XZ"), 4<<20)
+	start := time.Now()
+	err := p.Print(context.Background(), data)
+	if !errors.Is(err, printer.ErrTimeout) {
+		t.Fatalf("Print error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Print returned after %s, want about the write timeout", elapsed)
+	}
+	var printErr *printer.PrintError
+	if !errors.As(err, &printErr) || printErr.Op != printer.OpWrite || printErr.Written >= len(data) {
+		t.Errorf("error = %#v, want a partial write", err)
+	}
+}
+
+func TestPrintConnectTimeout(t *testing.T) {
+	p := stalledPrinter(t)
+	p.ConnectTimeout = time.Nanosecond
+
+	err := p.Print(context.Background(), []byte("^XA^XZ"))
+	if !errors.Is(err, printer.ErrTimeout) {
+		t.Fatalf("Print error = %v, want ErrTimeout", err)
+	}
+	var printErr *printer.PrintError
+	if !errors.As(err, &printErr) || printErr.Op != printer.OpConnect {
+		t.Errorf("error = %#v, want a connect failure", err)
+	}
+}
+
+func TestPrintConnectionRefusedIsNotTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	p := &printer.TCPPrinter{Host: addr.IP.String(), Port: addr.Port, ConnectTimeout: time.Second}
+	if err := p.Print(context.Background(), []byte("^XA^XZ")); err == nil || errors.Is(err, printer.ErrTimeout) {
+		t.Errorf("Print error = %v, want a connect failure that is not a timeout", err)
+	}
+}
@@ -0,0 +1,101 @@
+package printer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"labelops-backend/db"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrPrinterNotFound is returned when a printer lookup has no match
+var ErrPrinterNotFound = errors.New("printer: not found")
+
+const printerColumns = `id, name, driver, language, host, port, device_path, dpi, label_width, label_length,
+	mill, location, is_default, is_active, media_profile_id, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPrinter(row rowScanner) (models.Printer, error) {
+	var p models.Printer
+	err := row.Scan(
+		&p.ID, &p.Name, &p.Driver, &p.Language, &p.Host, &p.Port, &p.DevicePath, &p.DPI, &p.LabelWidth, &p.LabelLength,
+		&p.Mill, &p.Location, &p.IsDefault, &p.IsActive, &p.MediaProfileID, &p.CreatedAt, &p.UpdatedAt,
+	)
+	return p, err
+}
+
+// List returns all registered printers ordered by name
+func List() ([]models.Printer, error) {
+	rows, err := db.DB.Query(`SELECT ` + printerColumns + ` FROM printers ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	printers := []models.Printer{}
+	for rows.Next() {
+		p, err := scanPrinter(rows)
+		if err != nil {
+			return nil, err
+		}
+		printers = append(printers, p)
+	}
+	return printers, rows.Err()
+}
+
+// Get fetches a registered printer by ID
+func Get(id uuid.UUID) (models.Printer, error) {
+	p, err := scanPrinter(db.DB.QueryRow(`SELECT `+printerColumns+` FROM printers WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return p, ErrPrinterNotFound
+	}
+	return p, err
+}
+
+// Resolve picks the active printer serving a mill/location.
+// A printer matching both mill and location wins over one matching only the mill,
+// which wins over one matching only the location; the default printer is the last resort.
+func Resolve(mill string, location *string) (models.Printer, error) {
+	loc := ""
+	if location != nil {
+		loc = *location
+	}
+
+	p, err := scanPrinter(db.DB.QueryRow(`
+		SELECT `+printerColumns+` FROM printers
+		WHERE is_active
+		  AND (mill IS NULL OR mill = $1)
+		  AND (location IS NULL OR location = $2)
+		  AND (mill IS NOT NULL OR location IS NOT NULL OR is_default)
+		ORDER BY (mill IS NOT NULL) DESC, (location IS NOT NULL) DESC, is_default DESC, name
+		LIMIT 1
+	`, mill, loc))
+	if err == sql.ErrNoRows {
+		return p, ErrPrinterNotFound
+	}
+	return p, err
+}
+
+// FromModel builds a Printer driver from a registry entry
+func FromModel(m models.Printer) (Printer, error) {
+	switch m.Driver {
+	case models.PrinterDriverTCP:
+		if m.Host == nil || *m.Host == "" {
+			return nil, fmt.Errorf("printer %s: tcp driver requires a host", m.Name)
+		}
+		return NewTCPPrinter(*m.Host, m.Port), nil
+	case models.PrinterDriverDevice:
+		if m.DevicePath == nil || *m.DevicePath == "" {
+			return nil, fmt.Errorf("printer %s: device driver requires a device path", m.Name)
+		}
+		return NewDevicePrinter(*m.DevicePath), nil
+	default:
+		return nil, fmt.Errorf("printer %s: unsupported driver %q", m.Name, m.Driver)
+	}
+}
@@ -0,0 +1,192 @@
+package printer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultReadTimeout bounds how long a printer may take to answer a status query
+const DefaultReadTimeout = 5 * time.Second
+
+// OpStatus is reported by PrintError when a host status query fails
+const OpStatus = "status"
+
+// ErrMalformedStatus is returned when a ~HS response cannot be parsed
+var ErrMalformedStatus = errors.New("printer: malformed host status response")
+
+// HostStatus is the parsed response to the ZPL ~HS (host status) command
+type HostStatus struct {
+	PaperOut        bool `json:"paper_out"`
+	Paused          bool `json:"paused"`
+	LabelLength     int  `json:"label_length"`
+	FormatsInBuffer int  `json:"formats_in_buffer"`
+	BufferFull      bool `json:"buffer_full"`
+	PartialFormat   bool `json:"partial_format"`
+	CorruptRAM      bool `json:"corrupt_ram"`
+	UnderTemp       bool `json:"under_temperature"`
+	OverTemp        bool `json:"over_temperature"`
+	HeadOpen        bool `json:"head_open"`
+	RibbonOut       bool `json:"ribbon_out"`
+	LabelWaiting    bool `json:"label_waiting"`
+	LabelsRemaining int  `json:"labels_remaining"`
+}
+
+// Errors lists the conditions that stop the printer from printing
+func (s HostStatus) Errors() []string {
+	var errs []string
+	if s.PaperOut {
+		errs = append(errs, "paper out")
+	}
+	if s.RibbonOut {
+		errs = append(errs, "ribbon out")
+	}
+	if s.HeadOpen {
+		errs = append(errs, "head open")
+	}
+	if s.Paused {
+		errs = append(errs, "paused")
+	}
+	if s.BufferFull {
+		errs = append(errs, "buffer full")
+	}
+	if s.CorruptRAM {
+		errs = append(errs, "corrupt RAM")
+	}
+	if s.UnderTemp {
+		errs = append(errs, "under temperature")
+	}
+	if s.OverTemp {
+		errs = append(errs, "over temperature")
+	}
+	return errs
+}
+
+// Ready reports whether the printer can accept jobs
+func (s HostStatus) Ready() bool {
+	return len(s.Errors()) == 0
+}
+
+// StatusQuerier is implemented by printers that can report their host status
+type StatusQuerier interface {
+	QueryStatus(ctx context.Context) (*HostStatus, error)
+}
+
+// ParseHostStatus parses the three STX/ETX framed strings a printer sends in reply to ~HS:
+//
+//	<STX>aaa,b,c,dddd,eee,f,g,h,iii,j,k,l<ETX><CR><LF>
+//	<STX>mmm,n,o,p,q,r,s,t,uuuuuuuu,v,www<ETX><CR><LF>
+//	<STX>xxxx,y<ETX><CR><LF>
+func ParseHostStatus(data []byte) (*HostStatus, error) {
+	lines := splitStatusFrames(data)
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("%w: expected 3 frames, got %d", ErrMalformedStatus, len(lines))
+	}
+
+	first := strings.Split(lines[0], ",")
+	second := strings.Split(lines[1], ",")
+	if len(first) < 12 || len(second) < 9 {
+		return nil, fmt.Errorf("%w: too few fields", ErrMalformedStatus)
+	}
+
+	s := &HostStatus{
+		PaperOut:      flag(first[1]),
+		Paused:        flag(first[2]),
+		BufferFull:    flag(first[5]),
+		PartialFormat: flag(first[7]),
+		CorruptRAM:    flag(first[9]),
+		UnderTemp:     flag(first[10]),
+		OverTemp:      flag(first[11]),
+		HeadOpen:      flag(second[2]),
+		RibbonOut:     flag(second[3]),
+		LabelWaiting:  flag(second[7]),
+	}
+
+	var err error
+	if s.LabelLength, err = strconv.Atoi(strings.TrimSpace(first[3])); err != nil {
+		return nil, fmt.Errorf("%w: label length %q", ErrMalformedStatus, first[3])
+	}
+	if s.FormatsInBuffer, err = strconv.Atoi(strings.TrimSpace(first[4])); err != nil {
+		return nil, fmt.Errorf("%w: formats in buffer %q", ErrMalformedStatus, first[4])
+	}
+	if s.LabelsRemaining, err = strconv.Atoi(strings.TrimSpace(second[8])); err != nil {
+		return nil, fmt.Errorf("%w: labels remaining %q", ErrMalformedStatus, second[8])
+	}
+	return s, nil
+}
+
+// FormatHostStatus renders s in the ~HS wire format; it is the inverse of ParseHostStatus
+func FormatHostStatus(s HostStatus) []byte {
+	b := func(v bool) int {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\x02030,%d,%d,%04d,%03d,%d,0,%d,000,%d,%d,%d\x03\r\n",
+		b(s.PaperOut), b(s.Paused), s.LabelLength, s.FormatsInBuffer, b(s.BufferFull),
+		b(s.PartialFormat), b(s.CorruptRAM), b(s.UnderTemp), b(s.OverTemp))
+	fmt.Fprintf(&buf, "\x02001,0,%d,%d,0,2,6,%d,%08d,1,000\x03\r\n",
+		b(s.HeadOpen), b(s.RibbonOut), b(s.LabelWaiting), s.LabelsRemaining)
+	buf.WriteString("\x021234,0\x03\r\n")
+	return buf.Bytes()
+}
+
+// splitStatusFrames returns the contents of each STX...ETX frame
+func splitStatusFrames(data []byte) []string {
+	var frames []string
+	for {
+		start := bytes.IndexByte(data, 0x02)
+		if start < 0 {
+			return frames
+		}
+		end := bytes.IndexByte(data[start:], 0x03)
+		if end < 0 {
+			return frames
+		}
+		frames = append(frames, string(data[start+1:start+end]))
+		data = data[start+end+1:]
+	}
+}
+
+func flag(v string) bool {
+	return strings.TrimSpace(v) == "1"
+}
+
+// QueryStatus sends ~HS and parses the printer's reply
+func (p *TCPPrinter) QueryStatus(ctx context.Context) (*HostStatus, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := p.write(ctx, conn, []byte("~HS")); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(orDefault(p.ReadTimeout, DefaultReadTimeout))
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, &PrintError{Printer: p.Name(), Op: OpStatus, Err: err}
+	}
+
+	// Read until all three frames have arrived
+	var buf []byte
+	chunk := make([]byte, 256)
+	for bytes.Count(buf, []byte{0x03}) < 3 {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil {
+			return nil, &PrintError{Printer: p.Name(), Op: OpStatus, Err: err}
+		}
+	}
+	return ParseHostStatus(buf)
+}
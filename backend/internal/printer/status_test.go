@@ -0,0 +1,67 @@
+package printer_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"labelops-backend/internal/printer"
+)
+
+// zt410Status is the ~HS reply of a ZT410 with the head open and 1245 dot labels
+const zt410Status = "\x02030,0,0,1245,000,0,0,0,000,0,0,0\x03\r\n" +
+	"\x02000,0,1,0,0,2,4,0,00000000,1,000\x03\r\n" +
+	"\x021234,0\x03\r\n"
+
+func TestParseHostStatus(t *testing.T) {
+	s, err := printer.ParseHostStatus([]byte(zt410Status))
+	if err != nil {
+		t.Fatalf("ParseHostStatus: %v", err)
+	}
+	want := printer.HostStatus{LabelLength: 1245, HeadOpen: true}
+	if *s != want {
+		t.Errorf("ParseHostStatus = %+v, want %+v", *s, want)
+	}
+	if s.Ready() || !reflect.DeepEqual(s.Errors(), []string{"head open"}) {
+		t.Errorf("Errors = %v, want [head open]", s.Errors())
+	}
+}
+
+func TestFormatHostStatusRoundTrip(t *testing.T) {
+	want := printer.HostStatus{
+		PaperOut: true, Paused: true, LabelLength: 812, FormatsInBuffer: 3, RibbonOut: true,
+		LabelWaiting: true, LabelsRemaining: 42,
+	}
+	got, err := printer.ParseHostStatus(printer.FormatHostStatus(want))
+	if err != nil {
+		t.Fatalf("ParseHostStatus: %v", err)
+	}
+	if *got != want {
+		t.Errorf("round trip = %+v, want %+v", *got, want)
+	}
+}
+
+func TestParseHostStatusMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"no frames", "030,0,0,1245,000,0,0,0,000,0,0,0\r\n"},
+		{"one frame", "\x02030,0,0,1245,000,0,0,0,000,0,0,0\x03\r\n"},
+		{"third frame missing", zt410Status[:len(zt410Status)-len("\x021234,0\x03\r\n")]},
+		{"third frame unterminated", zt410Status[:len(zt410Status)-len("\x03\r\n")]},
+		{"too few fields", "\x02030,0,0,1245\x03\r\n\x02000,0,1\x03\r\n\x021234,0\x03\r\n"},
+		{"label length not a number", "\x02030,0,0,12x5,000,0,0,0,000,0,0,0\x03\r\n" +
+			"\x02000,0,1,0,0,2,4,0,00000000,1,000\x03\r\n\x021234,0\x03\r\n"},
+		{"labels remaining not a number", "\x02030,0,0,1245,000,0,0,0,000,0,0,0\x03\r\n" +
+			"\x02000,0,1,0,0,2,4,0,-,1,000\x03\r\n\x021234,0\x03\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if s, err := printer.ParseHostStatus([]byte(tt.data)); !errors.Is(err, printer.ErrMalformedStatus) {
+				t.Errorf("ParseHostStatus = %+v, %v; want ErrMalformedStatus", s, err)
+			}
+		})
+	}
+}
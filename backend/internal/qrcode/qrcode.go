@@ -0,0 +1,576 @@
+// Package qrcode encodes text as a QR Code (model 2) symbol.
+// It supports numeric, alphanumeric and byte mode, all four error correction
+// levels and versions 1 through 40, and picks the smallest version that fits.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is a QR error correction level
+type Level int
+
+// Error correction levels, from lowest to highest redundancy
+const (
+	Low      Level = iota // L, ~7% recovery
+	Medium                // M, ~15% recovery
+	Quartile              // Q, ~25% recovery
+	High                  // H, ~30% recovery
+)
+
+// formatBits are the two bits identifying each level in the format information
+var formatBits = [4]int{1, 0, 3, 2}
+
+// ParseLevel converts the ZPL/ISO letter (L, M, Q or H) to a Level
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+	return 0, fmt.Errorf("qrcode: unknown error correction level %q", s)
+}
+
+// ErrTooLong is returned when the data does not fit in a version 40 symbol
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// Code is an encoded QR symbol. Modules are indexed [y][x]; true is dark.
+type Code struct {
+	Version int
+	Level   Level
+	Mask    int
+	Size    int
+
+	modules    [][]bool
+	isFunction functionMap // only set while encoding
+}
+
+// Dark reports whether the module at column x, row y is dark
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// Encode builds the smallest QR symbol holding data at the given level
+func Encode(data []byte, level Level) (*Code, error) {
+	seg := makeSegment(data)
+
+	version := 0
+	var bits bitBuffer
+	for v := 1; v <= 40; v++ {
+		capacity := dataCodewords(v, level) * 8
+		used := 4 + seg.mode.countBits(v) + len(seg.bits)
+		if seg.count < 1<<seg.mode.countBits(v) && used <= capacity {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	capacity := dataCodewords(version, level) * 8
+	bits.append(seg.mode.indicator, 4)
+	bits.append(seg.count, seg.mode.countBits(version))
+	bits = append(bits, seg.bits...)
+
+	// Terminator, byte alignment and alternating pad bytes
+	term := capacity - len(bits)
+	if term > 4 {
+		term = 4
+	}
+	bits.append(0, term)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, b := range bits {
+		if b {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	c := newCode(version, level)
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.addErrorCorrection(codewords))
+	c.chooseMask()
+	return c, nil
+}
+
+// mode is a QR data encoding mode
+type mode struct {
+	indicator int
+	count     [3]int // character count bits for versions 1-9, 10-26 and 27-40
+}
+
+var (
+	modeNumeric      = mode{0x1, [3]int{10, 12, 14}}
+	modeAlphanumeric = mode{0x2, [3]int{9, 11, 13}}
+	modeByte         = mode{0x4, [3]int{8, 16, 16}}
+)
+
+func (m mode) countBits(version int) int {
+	switch {
+	case version <= 9:
+		return m.count[0]
+	case version <= 26:
+		return m.count[1]
+	}
+	return m.count[2]
+}
+
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// segment is data encoded in a single mode
+type segment struct {
+	mode  mode
+	count int
+	bits  bitBuffer
+}
+
+// makeSegment encodes data in the most compact mode that covers all of it
+func makeSegment(data []byte) segment {
+	numeric, alnum := true, true
+	for _, b := range data {
+		if b < '0' || b > '9' {
+			numeric = false
+		}
+		if strings.IndexByte(alphanumericCharset, b) < 0 {
+			alnum = false
+		}
+	}
+
+	var bb bitBuffer
+	switch {
+	case numeric && len(data) > 0:
+		for i := 0; i < len(data); i += 3 {
+			n := len(data) - i
+			if n > 3 {
+				n = 3
+			}
+			v := 0
+			for _, d := range data[i : i+n] {
+				v = v*10 + int(d-'0')
+			}
+			bb.append(v, n*3+1)
+		}
+		return segment{modeNumeric, len(data), bb}
+	case alnum && len(data) > 0:
+		for i := 0; i+1 < len(data); i += 2 {
+			v := strings.IndexByte(alphanumericCharset, data[i])*45 + strings.IndexByte(alphanumericCharset, data[i+1])
+			bb.append(v, 11)
+		}
+		if len(data)%2 == 1 {
+			bb.append(strings.IndexByte(alphanumericCharset, data[len(data)-1]), 6)
+		}
+		return segment{modeAlphanumeric, len(data), bb}
+	}
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	return segment{modeByte, len(data), bb}
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, (v>>uint(i))&1 == 1)
+	}
+}
+
+func newCode(version int, level Level) *Code {
+	size := version*4 + 17
+	c := &Code{Version: version, Level: level, Size: size}
+	c.modules = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+	}
+	return c
+}
+
+// functionMap marks modules that belong to function patterns and must not be masked
+type functionMap [][]bool
+
+func (c *Code) functions() functionMap {
+	f := make(functionMap, c.Size)
+	for i := range f {
+		f[i] = make([]bool, c.Size)
+	}
+	return f
+}
+
+// drawFunctionPatterns draws finder, timing and alignment patterns and
+// reserves the format and version information areas
+func (c *Code) drawFunctionPatterns() {
+	c.isFunction = c.functions()
+
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	pos := alignmentPositions(c.Version)
+	last := len(pos) - 1
+	for i := range pos {
+		for j := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(pos[i], pos[j])
+		}
+	}
+
+	// Reserve the format areas now; the real bits are drawn once the mask is chosen
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+func (c *Code) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the level and mask format information
+func (c *Code) drawFormatBits(mask int) {
+	data := formatBits[c.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// drawVersion draws the two version information blocks used by versions 7 and up
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.Version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 == 1
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// addErrorCorrection splits data into blocks, appends Reed-Solomon codewords
+// to each and interleaves the result
+func (c *Code) addErrorCorrection(data []byte) []byte {
+	numBlocks := numErrorCorrectionBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	raw := rawDataModules(c.Version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	divisor := reedSolomonDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		dat := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := reedSolomonRemainder(dat, divisor)
+		if i < numShort {
+			dat = append(dat, 0) // placeholder so all blocks line up when interleaving
+		}
+		blocks[i] = append(dat, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawCodewords places data in the zig-zag order from the bottom right corner
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y][x] && i < len(data)*8 {
+					c.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask XORs the data modules with mask pattern m; applying it twice undoes it
+func (c *Code) applyMask(m int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch m {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.isFunction[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// chooseMask applies the mask pattern with the lowest penalty score
+func (c *Code) chooseMask() {
+	best, bestPenalty := 0, -1
+	for m := 0; m < 8; m++ {
+		c.applyMask(m)
+		c.drawFormatBits(m)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = m, p
+		}
+		c.applyMask(m)
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	c.isFunction = nil
+}
+
+// penalty scores the symbol using the four rules of ISO/IEC 18004 section 7.8.3
+func (c *Code) penalty() int {
+	result := 0
+	dark := 0
+
+	line := func(get func(i int) bool) {
+		run := 0
+		var prev bool
+		for i := 0; i < c.Size; i++ {
+			m := get(i)
+			if i > 0 && m == prev {
+				run++
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+			} else {
+				run = 1
+			}
+			prev = m
+		}
+
+		// Finder-like 1:1:3:1:1 patterns with four light modules on either side
+		for i := 0; i+7 <= c.Size; i++ {
+			if get(i) && !get(i+1) && get(i+2) && get(i+3) && get(i+4) && !get(i+5) && get(i+6) {
+				before, after := true, true
+				for j := 1; j <= 4; j++ {
+					if i-j >= 0 && get(i-j) {
+						before = false
+					}
+					if i+6+j < c.Size && get(i+6+j) {
+						after = false
+					}
+				}
+				if before || after {
+					result += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < c.Size; y++ {
+		line(func(x int) bool { return c.modules[y][x] })
+	}
+	for x := 0; x < c.Size; x++ {
+		line(func(y int) bool { return c.modules[y][x] })
+	}
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			m := c.modules[y][x]
+			if m {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size &&
+				m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+				result += 3
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+	return result
+}
+
+// alignmentPositions returns the row/column centres of the alignment patterns
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	num := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + num*2 + 1) / (num*2 - 2) * 2
+	}
+	pos := make([]int, num)
+	pos[0] = 6
+	for i, p := num-1, version*4+17-7; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// rawDataModules is the number of data and error correction bits a version holds
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		num := version/7 + 2
+		result -= (25*num-10)*num - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// dataCodewords is the number of data codewords a version holds at level
+func dataCodewords(version int, level Level) int {
+	return rawDataModules(version)/8 -
+		eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+// reedSolomonDivisor returns the generator polynomial of the given degree,
+// highest coefficient first with the leading 1 omitted
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
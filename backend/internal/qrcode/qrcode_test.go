@@ -0,0 +1,166 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"labelops-backend/internal/qrcode"
+)
+
+// masks are the eight data mask conditions of ISO/IEC 18004, by column x and row y
+var masks = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// readVersion1 reads a version 1 symbol back the way a scanner does: the
+// format information next to the top left finder, then the unmasked codewords
+// in zigzag order. It returns the level bits, the mask and the codewords.
+func readVersion1(t *testing.T, c *qrcode.Code) (int, int, []byte) {
+	t.Helper()
+	if c.Size != 21 {
+		t.Fatalf("Size = %d, want 21 for version 1", c.Size)
+	}
+	bit := func(x, y int) int {
+		if c.Dark(x, y) {
+			return 1
+		}
+		return 0
+	}
+
+	var format int
+	for i := 0; i <= 5; i++ {
+		format |= bit(8, i) << i
+	}
+	format |= bit(8, 7)<<6 | bit(8, 8)<<7 | bit(7, 8)<<8
+	for i := 9; i < 15; i++ {
+		format |= bit(14-i, 8) << i
+	}
+	format ^= 0x5412
+	data := format >> 10
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	if data<<10|rem&0x3FF != format {
+		t.Fatalf("format information %015b fails its BCH check", format)
+	}
+	level, mask := data>>3, data&7
+
+	function := func(x, y int) bool {
+		return x == 6 || y == 6 || (x < 9 && y < 9) || (x >= c.Size-8 && y < 9) || (x < 9 && y >= c.Size-8)
+	}
+	var codewords []byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if function(x, y) {
+					continue
+				}
+				if n%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+				if c.Dark(x, y) != masks[mask](x, y) {
+					codewords[n/8] |= 1 << (7 - n%8)
+				}
+				n++
+			}
+		}
+	}
+	return level, mask, codewords
+}
+
+// HELLO WORLD at level M, the worked example of thonky.com's QR Code tutorial:
+// an alphanumeric segment, padding and ten error correction codewords
+func TestEncodeHelloWorld(t *testing.T) {
+	code, err := qrcode.Encode([]byte("HELLO WORLD"), qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Version != 1 {
+		t.Fatalf("Version = %d, want 1", code.Version)
+	}
+
+	level, mask, got := readVersion1(t, code)
+	if level != 0 {
+		t.Errorf("format level bits = %02b, want 00 (M)", level)
+	}
+	if mask != code.Mask {
+		t.Errorf("format mask = %d, Code.Mask = %d", mask, code.Mask)
+	}
+	want := []byte{
+		32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17,
+		196, 35, 39, 119, 235, 215, 231, 226, 93, 23,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("codewords = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		level   qrcode.Level
+		version int
+	}{
+		{"numeric fits version 1 at H", strings.Repeat("1", 17), qrcode.High, 1},
+		{"numeric overflows version 1 at H", strings.Repeat("1", 18), qrcode.High, 2},
+		{"alphanumeric fits version 1 at L", strings.Repeat("A", 25), qrcode.Low, 1},
+		{"byte fits version 1 at L", strings.Repeat("a", 17), qrcode.Low, 1},
+		{"byte overflows version 1 at L", strings.Repeat("a", 18), qrcode.Low, 2},
+		{"URL", "https://qr.example.com/l/C103247?h=3f9a", qrcode.Quartile, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := qrcode.Encode([]byte(tt.data), tt.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code.Version != tt.version || code.Size != 17+4*tt.version {
+				t.Errorf("version %d size %d, want version %d size %d",
+					code.Version, code.Size, tt.version, 17+4*tt.version)
+			}
+			// Finder patterns: dark corners with a light ring inside
+			for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+				x, y := corner[0], corner[1]
+				if !code.Dark(x, y) || code.Dark(x+1, y+1) || !code.Dark(x+3, y+3) {
+					t.Errorf("finder pattern at %d,%d broken", x, y)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := qrcode.Encode(bytes.Repeat([]byte("a"), 3000), qrcode.Low); !errors.Is(err, qrcode.ErrTooLong) {
+		t.Errorf("Encode error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]qrcode.Level{"L": qrcode.Low, "m": qrcode.Medium, "Q": qrcode.Quartile, "H": qrcode.High} {
+		if got, err := qrcode.ParseLevel(s); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := qrcode.ParseLevel("X"); err == nil {
+		t.Error("ParseLevel(X) succeeded")
+	}
+}
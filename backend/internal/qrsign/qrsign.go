@@ -0,0 +1,132 @@
+// Package qrsign signs the traceability payload printed in a label's lower QR
+// code, so customers can check that a bundle label was issued by the plant.
+// The signature is appended to the payload as a final SIG:<base64url>; field.
+package qrsign
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Field starts the signature field appended to a signed payload
+const Field = "SIG:"
+
+// Supported signing algorithms
+const (
+	AlgorithmHMAC    = "hmac-sha256"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// ErrMalformed is returned for a signature field that cannot be decoded
+var ErrMalformed = errors.New("qrsign: malformed signature field")
+
+// Signer signs and verifies canonical payloads
+type Signer interface {
+	Algorithm() string
+	Sign(payload []byte) []byte
+	Verify(payload, sig []byte) bool
+	// PublicKey returns the key customers verify with, or nil for shared secrets
+	PublicKey() []byte
+}
+
+type hmacSigner struct {
+	key []byte
+}
+
+// NewHMAC returns a signer using HMAC-SHA256 with a shared secret
+func NewHMAC(key []byte) Signer {
+	return hmacSigner{key: key}
+}
+
+func (s hmacSigner) Algorithm() string { return AlgorithmHMAC }
+
+func (s hmacSigner) Sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (s hmacSigner) Verify(payload, sig []byte) bool {
+	return hmac.Equal(s.Sign(payload), sig)
+}
+
+func (s hmacSigner) PublicKey() []byte { return nil }
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519 returns a signer using the Ed25519 private key derived from a 32 byte seed
+func NewEd25519(seed []byte) (Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("qrsign: ed25519 seed must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return ed25519Signer{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+func (s ed25519Signer) Algorithm() string { return AlgorithmEd25519 }
+
+func (s ed25519Signer) Sign(payload []byte) []byte {
+	return ed25519.Sign(s.key, payload)
+}
+
+func (s ed25519Signer) Verify(payload, sig []byte) bool {
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), payload, sig)
+}
+
+func (s ed25519Signer) PublicKey() []byte {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// FromEnv reads QR_SIGNING_ALGORITHM (hmac-sha256 or ed25519) and
+// QR_SIGNING_KEY, the base64 HMAC secret or Ed25519 seed. It returns a nil
+// Signer when no algorithm is set, which leaves payloads unsigned.
+func FromEnv() (Signer, error) {
+	alg := strings.ToLower(strings.TrimSpace(os.Getenv("QR_SIGNING_ALGORITHM")))
+	if alg == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(os.Getenv("QR_SIGNING_KEY")))
+	if err != nil {
+		return nil, fmt.Errorf("qrsign: QR_SIGNING_KEY is not base64: %w", err)
+	}
+	switch alg {
+	case AlgorithmHMAC:
+		if len(key) < 16 {
+			return nil, fmt.Errorf("qrsign: QR_SIGNING_KEY must be at least 16 bytes for %s", alg)
+		}
+		return NewHMAC(key), nil
+	case AlgorithmEd25519:
+		return NewEd25519(key)
+	}
+	return nil, fmt.Errorf("qrsign: unknown QR_SIGNING_ALGORITHM %q", alg)
+}
+
+// Seal appends the signature of payload as its last field
+func Seal(s Signer, payload string) string {
+	return payload + Field + base64.RawURLEncoding.EncodeToString(s.Sign([]byte(payload))) + ";"
+}
+
+// Split separates a scanned payload into the canonical payload and its
+// signature. A payload without a signature field is returned with a nil signature.
+func Split(scanned string) (string, []byte, error) {
+	i := strings.LastIndex(scanned, Field)
+	if i < 0 {
+		return scanned, nil, nil
+	}
+	encoded := scanned[i+len(Field):]
+	if !strings.HasSuffix(encoded, ";") {
+		return "", nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(encoded, ";"))
+	if err != nil {
+		return "", nil, ErrMalformed
+	}
+	return scanned[:i], sig, nil
+}
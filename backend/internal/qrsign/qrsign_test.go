@@ -0,0 +1,36 @@
+package qrsign_test
+
+import (
+	"bytes"
+	"testing"
+
+	"labelops-backend/internal/qrsign"
+)
+
+func TestSealSplit(t *testing.T) {
+	ed, err := qrsign.NewEd25519(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := "UNIT:SAIL-BSP;MILL:MM;HEAT:C103247;ID:2025015212;"
+	for _, s := range []qrsign.Signer{qrsign.NewHMAC([]byte("0123456789abcdef")), ed} {
+		sealed := qrsign.Seal(s, payload)
+		got, sig, err := qrsign.Split(sealed)
+		if err != nil || got != payload {
+			t.Fatalf("%s: Split(%q) = %q, %v; want %q", s.Algorithm(), sealed, got, err, payload)
+		}
+		if !s.Verify([]byte(got), sig) {
+			t.Errorf("%s: signature of %q does not verify", s.Algorithm(), payload)
+		}
+		if s.Verify([]byte("UNIT:SAIL-BSP;MILL:MM;HEAT:C103248;ID:2025015212;"), sig) {
+			t.Errorf("%s: signature verifies a forged payload", s.Algorithm())
+		}
+	}
+
+	if got, sig, err := qrsign.Split(payload); err != nil || got != payload || sig != nil {
+		t.Errorf("Split(unsigned) = %q, %v, %v", got, sig, err)
+	}
+	if _, _, err := qrsign.Split(payload + "SIG:!!;"); err != qrsign.ErrMalformed {
+		t.Errorf("Split(bad signature) error = %v, want ErrMalformed", err)
+	}
+}
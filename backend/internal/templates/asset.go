@@ -0,0 +1,179 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"labelops-backend/internal/assets"
+	"labelops-backend/models"
+)
+
+// AssetLookup resolves an asset name used in a template body to the asset version to print
+type AssetLookup func(name string) (models.LabelAsset, error)
+
+// assetFuncs are the template helpers that place assets:
+//
+//	^FO50,50{{asset "sail-logo"}}^FS   prints the asset inline as ^GFA
+//	^FO50,50{{stored "sail-logo"}}^FS  recalls it with ^XG; the ~DG that stores
+//	                                    it on the printer is sent ahead of the label
+//
+// Stored assets are added to stored, by name, as they are placed.
+func assetFuncs(lookup AssetLookup, stored map[string]models.LabelAsset) template.FuncMap {
+	find := func(name string) (models.LabelAsset, error) {
+		if lookup == nil {
+			return models.LabelAsset{}, fmt.Errorf("asset %q: no assets are available here", name)
+		}
+		return lookup(name)
+	}
+	return template.FuncMap{
+		"asset": func(name string) (string, error) {
+			a, err := find(name)
+			if err != nil {
+				return "", err
+			}
+			return assets.GraphicField(a), nil
+		},
+		"stored": func(name string) (string, error) {
+			a, err := find(name)
+			if err != nil {
+				return "", err
+			}
+			stored[name] = a
+			return assets.Recall(a), nil
+		},
+	}
+}
+
+// execute runs a parsed template body with data, placing assets found with
+// lookup. The downloads of stored assets go ahead of the label formats.
+func execute(t *template.Template, data Data, lookup AssetLookup) (string, error) {
+	stored := map[string]models.LabelAsset{}
+	var b strings.Builder
+	if err := t.Funcs(assetFuncs(lookup, stored)).Execute(&b, data); err != nil {
+		return "", err
+	}
+	if len(stored) == 0 {
+		return b.String(), nil
+	}
+
+	names := make([]string, 0, len(stored))
+	for name := range stored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out strings.Builder
+	for _, name := range names {
+		out.WriteString(assets.Download(stored[name]))
+		out.WriteByte('\n')
+	}
+	out.WriteString(b.String())
+	return out.String(), nil
+}
+
+// AssetNames returns the names of the assets a template body places, sorted.
+// Names must be quoted strings so the versions can be pinned when it is saved.
+func AssetNames(body string) ([]string, error) {
+	t, err := Parse("assets", body)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var walk func(node parse.Node) error
+	walk = func(node parse.Node) error {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return nil
+			}
+			for _, child := range n.Nodes {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		case *parse.ActionNode:
+			return walk(n.Pipe)
+		case *parse.IfNode:
+			return walkBranch(walk, &n.BranchNode)
+		case *parse.RangeNode:
+			return walkBranch(walk, &n.BranchNode)
+		case *parse.WithNode:
+			return walkBranch(walk, &n.BranchNode)
+		case *parse.TemplateNode:
+			if n.Pipe != nil {
+				return walk(n.Pipe)
+			}
+		case *parse.PipeNode:
+			if n == nil {
+				return nil
+			}
+			for _, cmd := range n.Cmds {
+				if err := walk(cmd); err != nil {
+					return err
+				}
+			}
+		case *parse.CommandNode:
+			if id, ok := n.Args[0].(*parse.IdentifierNode); ok && (id.Ident == "asset" || id.Ident == "stored") {
+				var name *parse.StringNode
+				if len(n.Args) == 2 {
+					name, _ = n.Args[1].(*parse.StringNode)
+				}
+				if name == nil {
+					return fmt.Errorf("%s takes the asset name as a quoted string, e.g. {{%s \"sail-logo\"}}", id.Ident, id.Ident)
+				}
+				seen[name.Text] = true
+				return nil
+			}
+			for _, arg := range n.Args {
+				if err := walk(arg); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		if err := walk(tmpl.Tree.Root); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func walkBranch(walk func(parse.Node) error, n *parse.BranchNode) error {
+	for _, node := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := walk(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PinAssets returns the latest version of each asset a template body places,
+// to be saved with the template version
+func PinAssets(body string) (map[string]int, error) {
+	names, err := AssetNames(body)
+	if err != nil {
+		return nil, err
+	}
+	pins := map[string]int{}
+	for _, name := range names {
+		a, err := assets.LatestLookup(name)
+		if err != nil {
+			return nil, err
+		}
+		pins[name] = a.Version
+	}
+	return pins, nil
+}
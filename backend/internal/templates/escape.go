@@ -0,0 +1,50 @@
+package templates
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MaxFieldLength is the longest value, in characters, each label field may
+// have before the layout can no longer print it
+var MaxFieldLength = map[string]int{
+	"LabelID":        30,
+	"HeatNo":         20,
+	"Section":        40,
+	"Grade":          40,
+	"Mill":           10,
+	"Unit":           20,
+	"ProductHeading": 30,
+	"BundleNo":       30,
+	"PQD":            30,
+	"Date":           20,
+	"Time":           10,
+	"IsiTop":         30,
+	"IsiBottom":      30,
+	"ChargeDtm":      30,
+	"Weight":         20,
+	"Location":       50,
+}
+
+// FieldError reports label data that cannot be printed as given
+type FieldError struct {
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.Field, e.Msg)
+}
+
+// checkField validates the length and encoding of a label field
+func checkField(name, value string) error {
+	if limit, ok := MaxFieldLength[name]; ok {
+		if n := utf8.RuneCountInString(value); n > limit {
+			return &FieldError{Field: name, Msg: fmt.Sprintf("%d characters exceeds the maximum of %d", n, limit)}
+		}
+	}
+	if !utf8.ValidString(value) {
+		return &FieldError{Field: name, Msg: fmt.Sprintf("invalid UTF-8 in %q", value)}
+	}
+	return nil
+}
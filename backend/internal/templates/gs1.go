@@ -0,0 +1,78 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"labelops-backend/internal/gs1"
+	"labelops-backend/models"
+)
+
+// GS1Fields are the label fields a template may encode under a GS1 AI
+var GS1Fields = map[string]func(Data) string{
+	"LabelID":   func(d Data) string { return d.LabelID },
+	"HeatNo":    func(d Data) string { return d.HeatNo },
+	"BundleNo":  func(d Data) string { return d.BundleNo },
+	"Section":   func(d Data) string { return d.Section },
+	"Grade":     func(d Data) string { return d.Grade },
+	"Mill":      func(d Data) string { return d.Mill },
+	"PQD":       func(d Data) string { return d.PQD },
+	"Date":      func(d Data) string { return d.Date },
+	"ChargeDtm": func(d Data) string { return d.ChargeDtm },
+	"Weight":    func(d Data) string { return d.Weight },
+	"Location":  func(d Data) string { return d.Location },
+	"Length":    func(d Data) string { return strconv.Itoa(d.Length) },
+}
+
+// SampleGS1 is the mapping used to lint template bodies saved without one:
+// batch/lot, serial number, net weight in kg and production date
+var SampleGS1 = []models.GS1AI{
+	{AI: "10", Field: "HeatNo"},
+	{AI: "21", Field: "BundleNo"},
+	{AI: "3103", Field: "Weight"},
+	{AI: "11", Field: "Date"},
+}
+
+// ValidateGS1 checks that a mapping names known AIs and label fields, each AI once
+func ValidateGS1(ais []models.GS1AI) error {
+	seen := map[string]bool{}
+	for _, m := range ais {
+		if _, ok := gs1.Lookup(m.AI); !ok {
+			return fmt.Errorf("unknown GS1 application identifier %q", m.AI)
+		}
+		if _, ok := GS1Fields[m.Field]; !ok {
+			return fmt.Errorf("GS1 AI %s: label field %q cannot be encoded", m.AI, m.Field)
+		}
+		if seen[m.AI] {
+			return fmt.Errorf("GS1 AI %s is mapped more than once", m.AI)
+		}
+		seen[m.AI] = true
+	}
+	return nil
+}
+
+// gs1Elements builds the element string of a mapping from unescaped label
+// values. A value that cannot be encoded under its AI is a *FieldError.
+func gs1Elements(d Data, ais []models.GS1AI) (gs1.ElementString, error) {
+	if err := ValidateGS1(ais); err != nil {
+		return nil, err
+	}
+	elements := make([]gs1.Element, 0, len(ais))
+	for _, m := range ais {
+		value := GS1Fields[m.Field](d)
+		if value == "" {
+			return nil, &FieldError{Field: m.Field, Msg: fmt.Sprintf("is empty but encoded as GS1 AI %s", m.AI)}
+		}
+		formatted, err := gs1.Format(m.AI, value)
+		if err != nil {
+			var gs1Err *gs1.Error
+			if errors.As(err, &gs1Err) {
+				return nil, &FieldError{Field: m.Field, Msg: fmt.Sprintf("GS1 AI %s: %s", m.AI, gs1Err.Msg)}
+			}
+			return nil, err
+		}
+		elements = append(elements, gs1.Element{AI: m.AI, Value: formatted})
+	}
+	return gs1.New(elements...)
+}
@@ -0,0 +1,77 @@
+package templates
+
+import (
+	"regexp"
+	"strconv"
+
+	"labelops-backend/internal/zpl/lint"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// SampleLabel is representative label data used to render templates for validation
+func SampleLabel() models.Label {
+	weight := "2.150"
+	location := "BSP-YARD-4"
+	return models.Label{
+		ID:             uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		LabelID:        "2025015212",
+		Location:       &location,
+		BundleNo:       "2025015212",
+		BundleType:     "STD",
+		PQD:            "100080004004005372",
+		Unit:           "SAIL-BSP",
+		Time:           "13:55",
+		Length:         12000,
+		HeatNo:         "C103247",
+		ProductHeading: "ANGLE",
+		IsiBottom:      "CML 57534",
+		IsiTop:         "IS 2062:2011",
+		Mill:           "MM",
+		Grade:          "IS 2062 E250BR",
+		Weight:         &weight,
+		Section:        "ANGLE 65*65*6",
+		Date:           "01-JUL-25",
+	}
+}
+
+// templateErrorPos extracts the position from text/template errors such as
+// "template: qcin:12:5: executing ..." or "template: qcin:12: function ..."
+var templateErrorPos = regexp.MustCompile(`^template: [^:]*:(\d+)(?::(\d+))?: (.*)$`)
+
+// Lint compiles a template body, renders it with SampleLabel and opts (with
+// SampleGS1 when opts.GS1 is nil) and lints the resulting ZPL. Positions refer to lines of the body;
+// columns are those of the rendered output, which differ after a placeholder
+// on the same line.
+func Lint(name, body string, opts Options, lintOpts lint.Options) []lint.Issue {
+	t, err := Parse(name, body)
+	if err != nil {
+		return []lint.Issue{templateIssue(err)}
+	}
+	if opts.GS1 == nil {
+		opts.GS1 = SampleGS1
+	}
+	data, err := NewData(SampleLabel(), opts)
+	if err != nil {
+		return []lint.Issue{{Line: 1, Col: 1, Message: err.Error()}}
+	}
+	out, err := execute(t, data, opts.Assets)
+	if err != nil {
+		return []lint.Issue{templateIssue(err)}
+	}
+	return lint.Lint([]byte(out), lintOpts)
+}
+
+// templateIssue converts a text/template error into an issue
+func templateIssue(err error) lint.Issue {
+	issue := lint.Issue{Line: 1, Col: 1, Message: err.Error()}
+	if m := templateErrorPos.FindStringSubmatch(err.Error()); m != nil {
+		issue.Line, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			issue.Col, _ = strconv.Atoi(m[2])
+		}
+		issue.Message = m[3]
+	}
+	return issue
+}
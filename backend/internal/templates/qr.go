@@ -0,0 +1,162 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"text/template"
+
+	"labelops-backend/internal/qrsign"
+)
+
+// Default QR payload formats, executed with the unescaped label Data
+const (
+	DefaultQRURLFormat  = "https://madeinindia.qcin.org/product-details/{{.ID}}/{{.Mill}}_{{.HeatNo}}_{{.PQD}}"
+	DefaultQRDataFormat = "UNIT:SAIL-BSP;MILL:{{.Mill}};HEAT:{{.HeatNo}};SECTION:{{.Section}};GRADE:{{.Grade}};" +
+		"ID:{{.LabelID}};LENGTH:{{.Length}};WEIGHT:{{.Weight}};LOCATION:{{.Location}};PQD:{{.PQD}};DATE:{{.Date}};TIME:{{.Time}};"
+)
+
+// QRFormat holds the text/template formats of the two QR payloads. An empty
+// format falls back to the plant's, and then to the default.
+type QRFormat struct {
+	URL  string // upper QR code, the QCIN product details URL
+	Data string // lower QR code, the key/value traceability payload
+}
+
+// or fills the empty formats of f from g
+func (f QRFormat) or(g QRFormat) QRFormat {
+	if f.URL == "" {
+		f.URL = g.URL
+	}
+	if f.Data == "" {
+		f.Data = g.Data
+	}
+	return f
+}
+
+// QRSettings are the effective QR payload formats and signing algorithm a
+// label was rendered with, recorded on its print job so the job can be
+// verified after the plant's settings change
+type QRSettings struct {
+	Format  QRFormat
+	SignAlg string // empty when the lower payload is unsigned
+}
+
+// ErrSignerChanged is returned when recorded QR settings were signed with an
+// algorithm the plant no longer signs with
+var ErrSignerChanged = errors.New("QR payloads were signed with an algorithm that is no longer configured")
+
+var (
+	plantMu sync.RWMutex
+	plantQR = QRFormat{URL: DefaultQRURLFormat, Data: DefaultQRDataFormat}
+	signer  qrsign.Signer
+
+	formatMu    sync.Mutex
+	formatCache = map[string]*template.Template{}
+)
+
+// QRFormatFromEnv reads the plant's QR payload formats from QR_URL_FORMAT and QR_DATA_FORMAT
+func QRFormatFromEnv() QRFormat {
+	return QRFormat{URL: os.Getenv("QR_URL_FORMAT"), Data: os.Getenv("QR_DATA_FORMAT")}
+}
+
+// SetPlantQR sets the QR payload formats of templates that do not set their
+// own. Empty formats keep the defaults.
+func SetPlantQR(f QRFormat) error {
+	if err := ValidateQRFormat(f); err != nil {
+		return err
+	}
+	plantMu.Lock()
+	defer plantMu.Unlock()
+	plantQR = f.or(QRFormat{URL: DefaultQRURLFormat, Data: DefaultQRDataFormat})
+	return nil
+}
+
+// SetSigner signs every lower QR payload with s; nil leaves payloads unsigned
+func SetSigner(s qrsign.Signer) {
+	plantMu.Lock()
+	defer plantMu.Unlock()
+	signer = s
+}
+
+// Signer returns the signer set with SetSigner, or nil
+func Signer() qrsign.Signer {
+	plantMu.RLock()
+	defer plantMu.RUnlock()
+	return signer
+}
+
+// ValidateQRFormat checks that QR payload formats compile and only use Data fields
+func ValidateQRFormat(f QRFormat) error {
+	sample, err := Fields(SampleLabel(), Options{})
+	if err != nil {
+		return err
+	}
+	for _, format := range []struct{ name, text string }{{"qr_url_format", f.URL}, {"qr_data_format", f.Data}} {
+		if format.text == "" {
+			continue
+		}
+		if _, err := executeQRFormat(format.text, sample); err != nil {
+			return fmt.Errorf("%s: %v", format.name, err)
+		}
+	}
+	return nil
+}
+
+// qrPayloads builds the QR payloads of a label. The lower payload is sealed
+// with the signer when one is set; canonical is the payload before sealing.
+// Recorded settings, when given, are used in place of f, the plant's formats
+// and the signer's algorithm.
+func qrPayloads(d Data, f QRFormat, recorded *QRSettings) (url, data, canonical string, used QRSettings, err error) {
+	plantMu.RLock()
+	f = f.or(plantQR)
+	s := signer
+	plantMu.RUnlock()
+
+	if recorded != nil {
+		f = recorded.Format
+		switch {
+		case recorded.SignAlg == "":
+			s = nil
+		case s == nil || s.Algorithm() != recorded.SignAlg:
+			return "", "", "", QRSettings{}, ErrSignerChanged
+		}
+	}
+	used.Format = f
+	if url, err = executeQRFormat(f.URL, d); err != nil {
+		return "", "", "", QRSettings{}, fmt.Errorf("QR URL format: %w", err)
+	}
+	if canonical, err = executeQRFormat(f.Data, d); err != nil {
+		return "", "", "", QRSettings{}, fmt.Errorf("QR data format: %w", err)
+	}
+	data = canonical
+	if s != nil {
+		data = qrsign.Seal(s, canonical)
+		used.SignAlg = s.Algorithm()
+	}
+	return url, data, canonical, used, nil
+}
+
+// executeQRFormat runs a payload format, caching it compiled
+func executeQRFormat(format string, d Data) (string, error) {
+	formatMu.Lock()
+	t, ok := formatCache[format]
+	if !ok {
+		var err error
+		t, err = template.New("qr").Option("missingkey=error").Parse(format)
+		if err != nil {
+			formatMu.Unlock()
+			return "", err
+		}
+		formatCache[format] = t
+	}
+	formatMu.Unlock()
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
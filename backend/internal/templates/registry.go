@@ -0,0 +1,580 @@
+package templates
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"labelops-backend/db"
+	"labelops-backend/internal/assets"
+	"labelops-backend/internal/layout"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTemplateNotFound is returned when a template lookup has no match
+	ErrTemplateNotFound = errors.New("templates: not found")
+	// ErrVersionNotFound is returned when a template has no such version
+	ErrVersionNotFound = errors.New("templates: version not found")
+	// ErrVersionImmutable is returned when editing a version that is no longer a draft
+	ErrVersionImmutable = errors.New("templates: only draft versions can be edited")
+)
+
+// LanguageError is returned when a label's template is a stored ZPL body but
+// its printer speaks another language, which only the QCIN layout is encoded for
+type LanguageError struct {
+	Template string
+	Version  int
+	Language string
+}
+
+func (e *LanguageError) Error() string {
+	return fmt.Sprintf("templates: %s version %d is ZPL and cannot be printed in %s; only %s can",
+		e.Template, e.Version, e.Language, BuiltinName)
+}
+
+const templateColumns = `t.id, t.name, t.description, t.is_default, t.is_active, t.created_by,
+	(SELECT v.version FROM label_template_versions v WHERE v.template_id = t.id AND v.status = 'published'),
+	COALESCE((SELECT MAX(v.version) FROM label_template_versions v WHERE v.template_id = t.id), 0),
+	t.created_at, t.updated_at`
+
+const versionColumns = `id, template_id, version, body, gs1_ais, qr_url_format, qr_data_format, asset_versions,
+	status, created_by, created_at, published_at, retired_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func scanTemplate(row rowScanner) (models.LabelTemplate, error) {
+	var t models.LabelTemplate
+	var createdBy uuid.NullUUID
+	var published sql.NullInt64
+	err := row.Scan(
+		&t.ID, &t.Name, &t.Description, &t.IsDefault, &t.IsActive, &createdBy,
+		&published, &t.LatestVersion, &t.CreatedAt, &t.UpdatedAt,
+	)
+	if createdBy.Valid {
+		t.CreatedBy = &createdBy.UUID
+	}
+	if published.Valid {
+		v := int(published.Int64)
+		t.PublishedVersion = &v
+	}
+	t.Rules = []models.TemplateRule{}
+	return t, err
+}
+
+func scanVersion(row rowScanner) (models.LabelTemplateVersion, error) {
+	var v models.LabelTemplateVersion
+	var createdBy uuid.NullUUID
+	var publishedAt, retiredAt sql.NullTime
+	var ais, pins []byte
+	err := row.Scan(&v.ID, &v.TemplateID, &v.Version, &v.Body, &ais, &v.QRURLFormat, &v.QRDataFormat, &pins,
+		&v.Status, &createdBy, &v.CreatedAt, &publishedAt, &retiredAt)
+	if err != nil {
+		return v, err
+	}
+	if v.GS1AIs, err = decodeGS1(ais); err != nil {
+		return v, err
+	}
+	if v.AssetVersions, err = decodePins(pins); err != nil {
+		return v, err
+	}
+	if createdBy.Valid {
+		v.CreatedBy = &createdBy.UUID
+	}
+	if publishedAt.Valid {
+		v.PublishedAt = &publishedAt.Time
+	}
+	if retiredAt.Valid {
+		v.RetiredAt = &retiredAt.Time
+	}
+	return v, nil
+}
+
+// encodeGS1 stores an AI mapping as JSON; nil is stored as NULL so queries can
+// fall back to an existing mapping
+func encodeGS1(ais []models.GS1AI) (sql.NullString, error) {
+	if ais == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(ais)
+	return sql.NullString{String: string(data), Valid: true}, err
+}
+
+// decodeGS1 reads a gs1_ais column
+func decodeGS1(data []byte) ([]models.GS1AI, error) {
+	ais := []models.GS1AI{}
+	if len(data) == 0 {
+		return ais, nil
+	}
+	err := json.Unmarshal(data, &ais)
+	return ais, err
+}
+
+// encodePins stores the asset versions a body places as JSON
+func encodePins(body string) (string, error) {
+	pins, err := PinAssets(body)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(pins)
+	return string(data), err
+}
+
+// decodePins reads an asset_versions column
+func decodePins(data []byte) (map[string]int, error) {
+	pins := map[string]int{}
+	if len(data) == 0 {
+		return pins, nil
+	}
+	err := json.Unmarshal(data, &pins)
+	return pins, err
+}
+
+// VersionOptions returns the options a version saved from req renders with,
+// taking the settings req omits from base
+func VersionOptions(base models.LabelTemplateVersion, req models.LabelTemplateVersionRequest) Options {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	opts := Options{GS1: base.GS1AIs, QR: QRFormat{URL: deref(base.QRURLFormat), Data: deref(base.QRDataFormat)}}
+	if req.GS1AIs != nil {
+		opts.GS1 = req.GS1AIs
+	}
+	if opts.GS1 == nil {
+		opts.GS1 = []models.GS1AI{}
+	}
+	if req.QRURLFormat != nil {
+		opts.QR.URL = *req.QRURLFormat
+	}
+	if req.QRDataFormat != nil {
+		opts.QR.Data = *req.QRDataFormat
+	}
+	return opts
+}
+
+// List returns all label templates with their rules, ordered by name
+func List() ([]models.LabelTemplate, error) {
+	rows, err := db.DB.Query(`SELECT ` + templateColumns + ` FROM label_templates t ORDER BY t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.LabelTemplate{}
+	byID := map[uuid.UUID]int{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[t.ID] = len(list)
+		list = append(list, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rules, err := loadRules(`SELECT id, template_id, product_heading, mill, section, priority
+		FROM label_template_rules ORDER BY priority DESC, created_at`)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if i, ok := byID[r.TemplateID]; ok {
+			list[i].Rules = append(list[i].Rules, r)
+		}
+	}
+	return list, nil
+}
+
+// Get fetches a label template and its rules by ID
+func Get(id uuid.UUID) (models.LabelTemplate, error) {
+	t, err := scanTemplate(db.DB.QueryRow(`SELECT `+templateColumns+` FROM label_templates t WHERE t.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return t, ErrTemplateNotFound
+	}
+	if err != nil {
+		return t, err
+	}
+
+	rules, err := loadRules(`SELECT id, template_id, product_heading, mill, section, priority
+		FROM label_template_rules WHERE template_id = $1 ORDER BY priority DESC, created_at`, id)
+	if err != nil {
+		return t, err
+	}
+	t.Rules = append(t.Rules, rules...)
+	return t, nil
+}
+
+func loadRules(query string, args ...interface{}) ([]models.TemplateRule, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.TemplateRule
+	for rows.Next() {
+		var r models.TemplateRule
+		if err := rows.Scan(&r.ID, &r.TemplateID, &r.ProductHeading, &r.Mill, &r.Section, &r.Priority); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// Versions lists every version of a template, newest first
+func Versions(templateID uuid.UUID) ([]models.LabelTemplateVersion, error) {
+	rows, err := db.DB.Query(`SELECT `+versionColumns+` FROM label_template_versions
+		WHERE template_id = $1 ORDER BY version DESC`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.LabelTemplateVersion{}
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetVersion fetches one version of a template
+func GetVersion(templateID uuid.UUID, version int) (models.LabelTemplateVersion, error) {
+	v, err := scanVersion(db.DB.QueryRow(`SELECT `+versionColumns+` FROM label_template_versions
+		WHERE template_id = $1 AND version = $2`, templateID, version))
+	if err == sql.ErrNoRows {
+		return v, ErrVersionNotFound
+	}
+	return v, err
+}
+
+// CreateVersion adds the next version of a template as a draft, publishing it
+// straight away when publish is set. Settings the request omits are copied
+// from the latest version.
+func CreateVersion(q querier, templateID uuid.UUID, v models.LabelTemplateVersionRequest, createdBy *uuid.UUID, publish bool) (int, error) {
+	mapping, err := encodeGS1(v.GS1AIs)
+	if err != nil {
+		return 0, err
+	}
+	pins, err := encodePins(v.Body)
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	err = q.QueryRow(`
+		WITH latest AS (
+			SELECT gs1_ais, qr_url_format, qr_data_format FROM label_template_versions
+			WHERE template_id = $1 ORDER BY version DESC LIMIT 1
+		)
+		INSERT INTO label_template_versions
+			(template_id, version, body, gs1_ais, qr_url_format, qr_data_format, asset_versions, status, created_by)
+		SELECT $1, COALESCE((SELECT MAX(version) FROM label_template_versions WHERE template_id = $1), 0) + 1, $2,
+		       COALESCE($5::jsonb, (SELECT gs1_ais FROM latest), '[]'),
+		       CASE WHEN $6::text IS NULL THEN (SELECT qr_url_format FROM latest) ELSE NULLIF($6, '') END,
+		       CASE WHEN $7::text IS NULL THEN (SELECT qr_data_format FROM latest) ELSE NULLIF($7, '') END,
+		       $8, $3, $4
+		RETURNING version
+	`, templateID, v.Body, models.TemplateStatusDraft, createdBy, mapping, v.QRURLFormat, v.QRDataFormat, pins).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	if publish {
+		err = publishVersion(q, templateID, version)
+	}
+	return version, err
+}
+
+// UpdateDraft replaces the body of a draft version, and the settings the request
+// sets. The assets it places are pinned again at their latest versions.
+func UpdateDraft(templateID uuid.UUID, version int, v models.LabelTemplateVersionRequest) error {
+	mapping, err := encodeGS1(v.GS1AIs)
+	if err != nil {
+		return err
+	}
+	pins, err := encodePins(v.Body)
+	if err != nil {
+		return err
+	}
+	res, err := db.DB.Exec(`
+		UPDATE label_template_versions SET body = $1, gs1_ais = COALESCE($5::jsonb, gs1_ais),
+			qr_url_format = CASE WHEN $6::text IS NULL THEN qr_url_format ELSE NULLIF($6, '') END,
+			qr_data_format = CASE WHEN $7::text IS NULL THEN qr_data_format ELSE NULLIF($7, '') END,
+			asset_versions = $8
+		WHERE template_id = $2 AND version = $3 AND status = $4
+	`, v.Body, templateID, version, models.TemplateStatusDraft, mapping, v.QRURLFormat, v.QRDataFormat, pins)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := GetVersion(templateID, version); err != nil {
+			return err
+		}
+		return ErrVersionImmutable
+	}
+	return nil
+}
+
+// Publish makes a version the one used for printing, retiring the previously published version
+func Publish(templateID uuid.UUID, version int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := publishVersion(tx, templateID, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func publishVersion(q querier, templateID uuid.UUID, version int) error {
+	_, err := q.Exec(`
+		UPDATE label_template_versions SET status = $1, retired_at = NOW()
+		WHERE template_id = $2 AND status = $3 AND version <> $4
+	`, models.TemplateStatusRetired, templateID, models.TemplateStatusPublished, version)
+	if err != nil {
+		return err
+	}
+
+	res, err := q.Exec(`
+		UPDATE label_template_versions SET status = $1, published_at = NOW(), retired_at = NULL
+		WHERE template_id = $2 AND version = $3
+	`, models.TemplateStatusPublished, templateID, version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVersionNotFound
+	}
+	_, err = q.Exec(`UPDATE label_templates SET updated_at = NOW() WHERE id = $1`, templateID)
+	return err
+}
+
+// Retire takes a version out of use. It stays available for re-rendering past jobs.
+func Retire(templateID uuid.UUID, version int) error {
+	res, err := db.DB.Exec(`
+		UPDATE label_template_versions SET status = $1, retired_at = NOW()
+		WHERE template_id = $2 AND version = $3 AND status <> $1
+	`, models.TemplateStatusRetired, templateID, version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_, err := GetVersion(templateID, version)
+		return err
+	}
+	return nil
+}
+
+// selected is the published template version chosen for a label
+type selected struct {
+	id      uuid.UUID
+	name    string
+	version int
+	body    string
+	ais     []byte // gs1_ais JSON
+	qrURL   sql.NullString
+	qrData  sql.NullString
+	pins    []byte // asset_versions JSON
+
+	qrRecorded *QRSettings // QR settings of the print job being re-rendered, if any
+}
+
+// Select picks the published template version for a label. The rule matching the
+// most fields wins, then the higher priority; the default template comes next and
+// the built-in QCIN layout is the last resort.
+func Select(label models.Label) (selected, error) {
+	var s selected
+	err := db.DB.QueryRow(`
+		SELECT t.id, t.name, v.version, v.body, v.gs1_ais, v.qr_url_format, v.qr_data_format, v.asset_versions
+		FROM label_template_rules r
+		JOIN label_templates t ON t.id = r.template_id
+		JOIN label_template_versions v ON v.template_id = t.id AND v.status = 'published'
+		WHERE t.is_active
+		  AND (r.product_heading IS NULL OR UPPER(r.product_heading) = UPPER($1))
+		  AND (r.mill IS NULL OR UPPER(r.mill) = UPPER($2))
+		  AND (r.section IS NULL OR UPPER($3) LIKE UPPER(r.section) || '%')
+		ORDER BY (r.product_heading IS NOT NULL)::int + (r.mill IS NOT NULL)::int + (r.section IS NOT NULL)::int DESC,
+		         r.priority DESC, t.name
+		LIMIT 1
+	`, strings.TrimSpace(label.ProductHeading), strings.TrimSpace(label.Mill), strings.TrimSpace(label.Section),
+	).Scan(&s.id, &s.name, &s.version, &s.body, &s.ais, &s.qrURL, &s.qrData, &s.pins)
+	if err == sql.ErrNoRows {
+		err = db.DB.QueryRow(`
+			SELECT t.id, t.name, v.version, v.body, v.gs1_ais, v.qr_url_format, v.qr_data_format, v.asset_versions
+			FROM label_templates t
+			JOIN label_template_versions v ON v.template_id = t.id AND v.status = 'published'
+			WHERE t.is_active AND (t.is_default OR t.name = $1)
+			ORDER BY t.is_default DESC, t.updated_at DESC
+			LIMIT 1
+		`, BuiltinName).Scan(&s.id, &s.name, &s.version, &s.body, &s.ais, &s.qrURL, &s.qrData, &s.pins)
+	}
+	if err == sql.ErrNoRows {
+		return s, ErrTemplateNotFound
+	}
+	return s, err
+}
+
+// GenerateZPL renders the published template version selected for a label
+func GenerateZPL(label models.Label, job layout.Job) (Rendered, error) {
+	s, err := Select(label)
+	if err != nil {
+		return Rendered{}, err
+	}
+	return render(s, label, job)
+}
+
+// GenerateLayout renders the published template version selected for a label
+// through a printer language backend. A label whose template is not the QCIN
+// layout gets a *LanguageError rather than being printed with another layout.
+func GenerateLayout(b layout.Backend, label models.Label, job layout.Job) (Rendered, error) {
+	s, err := Select(label)
+	if err != nil {
+		return Rendered{}, err
+	}
+	return renderLayout(b, s, label, job)
+}
+
+// RenderVersion re-renders a label with a specific template version, whatever
+// its status. Recorded QR settings, when given, replace the version's and the plant's.
+func RenderVersion(templateID uuid.UUID, version int, label models.Label, job layout.Job, qr *QRSettings) (Rendered, error) {
+	s, err := loadVersion(templateID, version)
+	if err != nil {
+		return Rendered{}, err
+	}
+	s.qrRecorded = qr
+	return render(s, label, job)
+}
+
+// RenderVersionLayout re-renders a label with a specific template version
+// through a printer language backend, like GenerateLayout
+func RenderVersionLayout(b layout.Backend, templateID uuid.UUID, version int, label models.Label, job layout.Job, qr *QRSettings) (Rendered, error) {
+	s, err := loadVersion(templateID, version)
+	if err != nil {
+		return Rendered{}, err
+	}
+	s.qrRecorded = qr
+	return renderLayout(b, s, label, job)
+}
+
+func loadVersion(templateID uuid.UUID, version int) (selected, error) {
+	s := selected{id: templateID, version: version}
+	err := db.DB.QueryRow(`
+		SELECT t.name, v.body, v.gs1_ais, v.qr_url_format, v.qr_data_format, v.asset_versions
+		FROM label_template_versions v JOIN label_templates t ON t.id = v.template_id
+		WHERE v.template_id = $1 AND v.version = $2
+	`, templateID, version).Scan(&s.name, &s.body, &s.ais, &s.qrURL, &s.qrData, &s.pins)
+	if err == sql.ErrNoRows {
+		return s, ErrVersionNotFound
+	}
+	return s, err
+}
+
+// options returns the settings a template version renders a print job with
+func (s selected) options(job layout.Job) (Options, error) {
+	ais, err := decodeGS1(s.ais)
+	if err != nil {
+		return Options{}, err
+	}
+	pins, err := decodePins(s.pins)
+	if err != nil {
+		return Options{}, err
+	}
+	return Options{
+		GS1:        ais,
+		QR:         QRFormat{URL: s.qrURL.String, Data: s.qrData.String},
+		QRRecorded: s.qrRecorded,
+		Job:        job,
+		Assets:     assets.Pinned(pins),
+	}, nil
+}
+
+func render(s selected, label models.Label, job layout.Job) (Rendered, error) {
+	opts, err := s.options(job)
+	if err != nil {
+		return Rendered{}, err
+	}
+	r, err := Render(s.name, s.body, label, opts)
+	if err != nil {
+		return Rendered{}, err
+	}
+	r.TemplateID, r.Version = s.id, s.version
+	return r, nil
+}
+
+// renderLayout renders a template version through a printer language backend.
+// Only a version whose body is the QCIN layout can be.
+func renderLayout(b layout.Backend, s selected, label models.Label, job layout.Job) (Rendered, error) {
+	if s.body != builtinBody {
+		return Rendered{}, &LanguageError{Template: s.name, Version: s.version, Language: b.Language()}
+	}
+	opts, err := s.options(job)
+	if err != nil {
+		return Rendered{}, err
+	}
+	r, err := RenderLayout(b, s.name, label, opts)
+	if err != nil {
+		return Rendered{}, err
+	}
+	r.TemplateID, r.Version = s.id, s.version
+	return r, nil
+}
+
+// EnsureBuiltin registers the QCIN layout as a template and publishes
+// a new version of it whenever its ZPL encoding changes
+func EnsureBuiltin() error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO label_templates (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+	`, BuiltinName, "Built-in QCIN layout, used when no other template applies")
+	if err != nil {
+		return err
+	}
+
+	var id uuid.UUID
+	if err := tx.QueryRow(`SELECT id FROM label_templates WHERE name = $1 FOR UPDATE`, BuiltinName).Scan(&id); err != nil {
+		return err
+	}
+
+	var body string
+	err = tx.QueryRow(`
+		SELECT body FROM label_template_versions WHERE template_id = $1 AND status = 'published'
+	`, id).Scan(&body)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows || body != builtinBody {
+		if _, err := CreateVersion(tx, id, models.LabelTemplateVersionRequest{Body: builtinBody, GS1AIs: []models.GS1AI{}}, nil, true); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,405 @@
+// Package templates renders label ZPL from versioned text/template bodies
+// stored in the label_template_versions table. The QCIN layout, encoded as
+// ZPL, is registered as a template of its own and used when nothing else applies.
+package templates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"labelops-backend/internal/gs1"
+	"labelops-backend/internal/layout"
+	"labelops-backend/internal/zpl"
+	"labelops-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// BuiltinName identifies the QCIN layout template used when no stored template applies
+const BuiltinName = "builtin-qcin"
+
+// DesignDPI is the print density template bodies are written for. Jobs for
+// printers with a media profile of another density are scaled from it.
+const DesignDPI = 203
+
+// builtinBody is the QCIN layout encoded as ZPL with a placeholder for each label field
+var builtinBody = mustEncodeBuiltin()
+
+func mustEncodeBuiltin() string {
+	body, err := layout.ZPL{}.Encode(layout.QCIN(layout.QCINValues{
+		ProductHeading: "{{.ProductHeading}}",
+		Mill:           "{{.Mill}}",
+		LabelID:        "{{.LabelID}}",
+		Grade:          "{{.Grade}}",
+		Length:         "{{.Length}}",
+		Date:           "{{.Date}}",
+		Time:           "{{.Time}}",
+		Section:        "{{.Section}}",
+		HeatNo:         "{{.HeatNo}}",
+		IsiTop:         "{{.IsiTop}}",
+		IsiBottom:      "{{.IsiBottom}}",
+		QRURL:          "{{.QRURL}}",
+		QRData:         "{{.QRData}}",
+	}))
+	if err != nil {
+		panic("templates: encoding the QCIN layout: " + err.Error())
+	}
+	return body
+}
+
+// Data is the value templates are executed with. Every string field is
+// already escaped for an ^FH\ field, so templates can use {{.HeatNo}} directly.
+type Data struct {
+	ID             string
+	LabelID        string
+	HeatNo         string
+	Section        string
+	Grade          string
+	Mill           string
+	Unit           string
+	ProductHeading string
+	BundleNo       string
+	PQD            string
+	Date           string
+	Time           string
+	IsiTop         string
+	IsiBottom      string
+	ChargeDtm      string
+	Weight         string
+	Location       string
+	Length         int
+
+	// QRURL is the QCIN product details URL printed in the upper QR code
+	QRURL string
+	// QRData is the key/value payload printed in the lower QR code
+	QRData string
+
+	// GS1 is the element string of the template's AI mapping written as HRI,
+	// e.g. (10)C103247(21)2025015212, the data of a ^BC UCC/EAN mode (D) field
+	GS1 string
+	// GS1DataMatrix is the same element string for a ^BX field whose escape
+	// character is #: FNC1 as #1, with GS separators between variable length values
+	GS1DataMatrix string
+
+	// qrCanonical is the lower QR payload before it was signed
+	qrCanonical string
+	// qr holds the QR formats and signing algorithm the payloads were built with
+	qr QRSettings
+}
+
+// QRHash returns the hash recorded for the lower QR payload, see Rendered.QRHash
+func (d Data) QRHash() string {
+	return Hash(d.qrCanonical)
+}
+
+// Options are the settings a label is rendered with: those of the template
+// version, and the print job's copies, marks and media
+type Options struct {
+	GS1        []models.GS1AI // AIs encoded in the GS1 fields
+	QR         QRFormat       // QR payload formats; empty formats use the plant's
+	QRRecorded *QRSettings    // settings recorded on a re-rendered print job, used in place of QR, the plant's and the signer
+	Job        layout.Job
+	Assets     AssetLookup // versions of the assets placed with asset and stored; nil allows none
+}
+
+// Fields validates a label and returns its values unescaped, for printer
+// languages that quote text their own way. The first field that cannot be
+// printed is returned as a *FieldError. The QR payloads use opts.QR.
+func Fields(label models.Label, opts Options) (Data, error) {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	d := Data{
+		ID:             label.ID.String(),
+		LabelID:        label.LabelID,
+		HeatNo:         label.HeatNo,
+		Section:        label.Section,
+		Grade:          label.Grade,
+		Mill:           label.Mill,
+		Unit:           label.Unit,
+		ProductHeading: label.ProductHeading,
+		BundleNo:       label.BundleNo,
+		PQD:            label.PQD,
+		Date:           label.Date,
+		Time:           label.Time,
+		IsiTop:         label.IsiTop,
+		IsiBottom:      label.IsiBottom,
+		ChargeDtm:      label.ChargeDtm,
+		Weight:         deref(label.Weight),
+		Location:       deref(label.Location),
+		Length:         label.Length,
+	}
+	checks := []struct{ name, value string }{
+		{"LabelID", d.LabelID}, {"HeatNo", d.HeatNo}, {"Section", d.Section}, {"Grade", d.Grade},
+		{"Mill", d.Mill}, {"Unit", d.Unit}, {"ProductHeading", d.ProductHeading}, {"BundleNo", d.BundleNo},
+		{"PQD", d.PQD}, {"Date", d.Date}, {"Time", d.Time}, {"IsiTop", d.IsiTop}, {"IsiBottom", d.IsiBottom},
+		{"ChargeDtm", d.ChargeDtm}, {"Weight", d.Weight}, {"Location", d.Location},
+	}
+	for _, f := range checks {
+		if err := checkField(f.name, f.value); err != nil {
+			return Data{}, err
+		}
+	}
+	url, data, canonical, qr, err := qrPayloads(d, opts.QR, opts.QRRecorded)
+	if err != nil {
+		return Data{}, err
+	}
+	d.QRURL, d.QRData, d.qrCanonical, d.qr = url, data, canonical, qr
+	return d, nil
+}
+
+// NewData builds template data from a label. Every field is checked by
+// Fields and escaped with zpl.EscapeField; the GS1 fields encode opts.GS1.
+func NewData(label models.Label, opts Options) (Data, error) {
+	raw, err := Fields(label, opts)
+	if err != nil {
+		return Data{}, err
+	}
+	var elements gs1.ElementString
+	if len(opts.GS1) > 0 {
+		if elements, err = gs1Elements(raw, opts.GS1); err != nil {
+			return Data{}, err
+		}
+	}
+	// Fields has checked every value is valid UTF-8, so escaping cannot fail
+	esc := func(s string) string {
+		escaped, _ := zpl.EscapeField(s)
+		return escaped
+	}
+	dataMatrix := ""
+	if len(elements) > 0 {
+		dataMatrix = "#1" + esc(elements.Data())
+	}
+	return Data{
+		ID:             raw.ID,
+		LabelID:        esc(raw.LabelID),
+		HeatNo:         esc(raw.HeatNo),
+		Section:        esc(raw.Section),
+		Grade:          esc(raw.Grade),
+		Mill:           esc(raw.Mill),
+		Unit:           esc(raw.Unit),
+		ProductHeading: esc(raw.ProductHeading),
+		BundleNo:       esc(raw.BundleNo),
+		PQD:            esc(raw.PQD),
+		Date:           esc(raw.Date),
+		Time:           esc(raw.Time),
+		IsiTop:         esc(raw.IsiTop),
+		IsiBottom:      esc(raw.IsiBottom),
+		ChargeDtm:      esc(raw.ChargeDtm),
+		Weight:         esc(raw.Weight),
+		Location:       esc(raw.Location),
+		Length:         raw.Length,
+		// The QR payloads are built from the raw values and escaped as a whole
+		QRURL:  esc(raw.QRURL),
+		QRData: esc(raw.QRData),
+		// The element string is built from the raw values too
+		GS1:           esc(elements.HRI()),
+		GS1DataMatrix: dataMatrix,
+		qrCanonical:   raw.qrCanonical,
+		qr:            raw.qr,
+	}, nil
+}
+
+// Funcs are the helpers available to template bodies
+var Funcs = template.FuncMap{
+	// zpl escapes computed values the same way label fields are escaped
+	"zpl": zpl.EscapeField,
+	// hex encodes every byte as an ^FH escape using the given indicator, e.g. {{hex "_" .HeatNo}}
+	"hex": func(indicator, s string) string {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			fmt.Fprintf(&b, "%s%02X", indicator, s[i])
+		}
+		return b.String()
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	// trunc shortens an escaped field to at most n characters without splitting an escape
+	"trunc": func(n int, s string) (string, error) {
+		r := []rune(zpl.UnescapeField(s))
+		if len(r) <= n {
+			return s, nil
+		}
+		return zpl.EscapeField(string(r[:n]))
+	},
+	// asset and stored place a logo from the asset library, see assetFuncs.
+	// Render replaces these with ones that look the asset up.
+	"asset":  func(name string) (string, error) { return "", nil },
+	"stored": func(name string) (string, error) { return "", nil },
+	// default returns def when s is empty
+	"default": func(def, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+}
+
+// Parse compiles a template body, reporting syntax errors and unknown fields or functions
+func Parse(name, body string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	// Execute against empty data to catch references to fields Data does not have
+	if err := t.Execute(&bytes.Buffer{}, Data{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render executes a template body for a label. The result carries no
+// template ID or version; callers rendering a stored version fill them in.
+func Render(name, body string, label models.Label, opts Options) (Rendered, error) {
+	t, err := Parse(name, body)
+	if err != nil {
+		return Rendered{}, err
+	}
+	data, err := NewData(label, opts)
+	if err != nil {
+		return Rendered{}, err
+	}
+	out, err := execute(t, data, opts.Assets)
+	if err != nil {
+		return Rendered{}, err
+	}
+	if !opts.Job.IsZero() {
+		if out, err = applyJob(out, opts.Job); err != nil {
+			return Rendered{}, err
+		}
+	}
+	if opts.Job.Media != nil {
+		adapted, err := zpl.Adapt([]byte(out), DesignDPI, opts.Job.Media.ZPL())
+		if err != nil {
+			return Rendered{}, err
+		}
+		out = string(adapted)
+	}
+	return Rendered{
+		ZPL:          out,
+		Language:     models.PrinterLanguageZPL,
+		TemplateName: name,
+		Hash:         Hash(out),
+		QRHash:       data.QRHash(),
+		QR:           data.qr,
+	}, nil
+}
+
+// RenderLayout renders a label with the QCIN layout through a printer language
+// backend, for printers that do not speak ZPL. The layout has no GS1 fields or
+// assets, but opts.GS1 is checked as Render checks it. The result carries no
+// template ID or version.
+func RenderLayout(b layout.Backend, name string, label models.Label, opts Options) (Rendered, error) {
+	f, err := Fields(label, opts)
+	if err != nil {
+		return Rendered{}, err
+	}
+	if len(opts.GS1) > 0 {
+		if _, err := gs1Elements(f, opts.GS1); err != nil {
+			return Rendered{}, err
+		}
+	}
+	out, err := b.Encode(layout.QCINJob(layout.QCIN(layout.QCINValues{
+		ProductHeading: f.ProductHeading,
+		Mill:           f.Mill,
+		LabelID:        f.LabelID,
+		Grade:          f.Grade,
+		Length:         strconv.Itoa(f.Length),
+		Date:           f.Date,
+		Time:           f.Time,
+		Section:        f.Section,
+		HeatNo:         f.HeatNo,
+		IsiTop:         f.IsiTop,
+		IsiBottom:      f.IsiBottom,
+		QRURL:          f.QRURL,
+		QRData:         f.QRData,
+	}), opts.Job))
+	if err != nil {
+		return Rendered{}, err
+	}
+	return Rendered{
+		ZPL:          out,
+		Language:     b.Language(),
+		TemplateName: name,
+		Hash:         Hash(out),
+		QRHash:       f.QRHash(),
+		QR:           f.qr,
+	}, nil
+}
+
+// applyJob sets the quantity of the last label format in out to the job's
+// copies, replacing its ^PQ, and adds the QCIN job marks to it
+func applyJob(out string, j layout.Job) (string, error) {
+	end := strings.LastIndex(out, "^XZ")
+	if end < 0 {
+		return "", fmt.Errorf("template output has no ^XZ to end the label")
+	}
+	format := out[:end]
+	if i := strings.LastIndex(format, "^PQ"); i >= 0 {
+		// The command runs to the next one, taking its line break with it
+		rest := format[i+3:]
+		n := strings.IndexAny(rest, "^~")
+		if n < 0 {
+			n = len(rest)
+		}
+		format = format[:i] + rest[n:]
+	}
+	marks, err := layout.ZPL{}.EncodeElements(layout.QCINMarks(j))
+	if err != nil {
+		return "", err
+	}
+	copies := j.Copies
+	if copies < 1 {
+		copies = 1
+	}
+	return fmt.Sprintf("%s%s^PQ%d,0,1,Y\n%s", format, marks, copies, out[end:]), nil
+}
+
+// Builtin returns the QCIN template body
+func Builtin() string {
+	return builtinBody
+}
+
+// Rendered is a rendered label together with the template version that
+// produced it. ZPL holds the printer commands, which are in Language; labels
+// for EPL2 and TSPL printers are the QCIN layout, recorded against the version
+// of the built-in template they were rendered with.
+type Rendered struct {
+	ZPL          string
+	Language     string
+	TemplateID   uuid.UUID
+	TemplateName string
+	Version      int
+	Hash         string
+	// QRHash is the SHA-256 of the unsigned lower QR payload, which the
+	// public verify endpoint looks labels up by
+	QRHash string
+	// QR are the QR formats and signing algorithm the label was rendered
+	// with, recorded on the print job for verifying it later
+	QR QRSettings
+}
+
+// TemplateRef returns the template ID and version to record against a print
+// job, or nils when the label was not rendered from a stored template
+func (r Rendered) TemplateRef() (*uuid.UUID, *int) {
+	if r.TemplateID == uuid.Nil {
+		return nil, nil
+	}
+	return &r.TemplateID, &r.Version
+}
+
+// Hash returns the hex SHA-256 digest recorded against printed commands
+func Hash(zpl string) string {
+	sum := sha256.Sum256([]byte(zpl))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,313 @@
+package zpl
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"labelops-backend/internal/code128"
+	"labelops-backend/internal/datamatrix"
+	"labelops-backend/internal/gs1"
+)
+
+// barcodeDefaults are the ^BY module width, wide to narrow ratio and bar height
+type barcodeDefaults struct {
+	module int
+	ratio  float64
+	height int
+}
+
+// barcodeSpec holds the ^BC or ^BX parameters of a bar code field
+type barcodeSpec struct {
+	kind        string // "barcode" (Code 128) or "datamatrix"
+	orientation byte
+	height      int  // bar height, or the DataMatrix module size
+	interpret   bool // print the interpretation line
+	above       bool // interpretation line above the bars
+	mode        byte // ^BC mode: N, U, A or D (UCC/EAN)
+	escape      byte // ^BX escape sequence character
+}
+
+// setBarcodeDefaults handles ^BYw,r,h
+func (r *renderer) setBarcodeDefaults(cmd Command) error {
+	w, err := intArg(cmd, 0, r.by.module)
+	if err != nil {
+		return err
+	}
+	if w < 1 || w > 10 {
+		return errorAt(cmd, "module width %d out of range 1-10", w)
+	}
+	ratio := r.by.ratio
+	if args := cmd.Args(); len(args) > 1 && args[1] != "" {
+		ratio, err = strconv.ParseFloat(args[1], 64)
+		if err != nil || ratio < 2 || ratio > 3 {
+			return errorAt(cmd, "wide to narrow ratio %q out of range 2.0-3.0", args[1])
+		}
+	}
+	h, err := intArg(cmd, 2, r.by.height)
+	if err != nil {
+		return err
+	}
+	if h < 1 || h > 32000 {
+		return errorAt(cmd, "bar code height %d out of range", h)
+	}
+	r.by = barcodeDefaults{module: w, ratio: ratio, height: h}
+	return nil
+}
+
+// selectCode128 handles ^BCo,h,f,g,e,m
+func (r *renderer) selectCode128(cmd Command) error {
+	spec := &barcodeSpec{kind: "barcode", orientation: r.orientation, interpret: true, mode: 'N'}
+	if o := orientationArg(cmd, 0); o != 0 {
+		spec.orientation = o
+	}
+	h, err := intArg(cmd, 1, r.by.height)
+	if err != nil {
+		return err
+	}
+	if h < 1 || h > 32000 {
+		return errorAt(cmd, "bar code height %d out of range", h)
+	}
+	spec.height = h
+
+	args := cmd.Args()
+	flag := func(i int, def bool) bool {
+		if i >= len(args) || args[i] == "" {
+			return def
+		}
+		return strings.EqualFold(args[i], "Y")
+	}
+	spec.interpret = flag(2, true)
+	spec.above = flag(3, false)
+	if len(args) > 5 && args[5] != "" {
+		spec.mode = args[5][0] &^ 0x20
+	}
+	switch spec.mode {
+	case 'N', 'D':
+	default:
+		return errorAt(cmd, "Code 128 mode %q is not supported", args[5])
+	}
+	r.field.barcode = spec
+	return nil
+}
+
+// selectDataMatrix handles ^BXo,h,s,c,r,f,g. Only ECC 200 symbols are supported.
+func (r *renderer) selectDataMatrix(cmd Command) error {
+	spec := &barcodeSpec{kind: "datamatrix", orientation: r.orientation, escape: '~'}
+	if o := orientationArg(cmd, 0); o != 0 {
+		spec.orientation = o
+	}
+	h, err := intArg(cmd, 1, r.by.module)
+	if err != nil {
+		return err
+	}
+	if h < 1 || h > 1000 {
+		return errorAt(cmd, "module size %d out of range", h)
+	}
+	spec.height = h
+	quality, err := intArg(cmd, 2, 0)
+	if err != nil {
+		return err
+	}
+	if quality != 200 {
+		return errorAt(cmd, "DataMatrix quality %d is not supported; use 200", quality)
+	}
+	if args := cmd.Args(); len(args) > 6 && args[6] != "" {
+		spec.escape = args[6][0]
+	}
+	r.field.barcode = spec
+	return nil
+}
+
+// drawBarcode renders a ^BC or ^BX field
+func (r *renderer) drawBarcode(cmd Command, data []byte) error {
+	if r.field.barcode.kind == "datamatrix" {
+		return r.drawDataMatrix(cmd, data)
+	}
+	return r.drawCode128(cmd, data)
+}
+
+// drawCode128 renders a ^BC field. In mode D the data is a GS1 element string
+// written as HRI, e.g. (10)C103247(21)2025015212.
+func (r *renderer) drawCode128(cmd Command, data []byte) error {
+	spec := r.field.barcode
+
+	var (
+		input []int
+		text  string
+	)
+	if spec.mode == 'D' {
+		es, err := gs1.ParseHRI(string(data))
+		if err != nil {
+			return errorAt(cmd, "%v", err)
+		}
+		input, text = code128.GS1(es.Data()), es.HRI()
+	} else {
+		var err error
+		input, text, err = code128Invocations(data)
+		if err != nil {
+			return errorAt(cmd, "%v", err)
+		}
+	}
+	code, err := code128.Encode(input)
+	if err != nil {
+		return errorAt(cmd, "%v", err)
+	}
+
+	module := r.by.module
+	barWidth := len(code.Modules) * module
+	w, h := barWidth, spec.height
+	barTop := 0
+
+	var (
+		mask         *image.Alpha
+		textX, textY int
+	)
+	if spec.interpret && text != "" {
+		font := r.defaultFont
+		if r.field.font != nil {
+			font = *r.field.font
+		}
+		mask, _, err = textMask(text, font.height, font.width)
+		if err != nil {
+			return err
+		}
+		tw, th := mask.Bounds().Dx(), mask.Bounds().Dy()
+		if tw > w {
+			w = tw
+		}
+		textX = (w - tw) / 2
+		if spec.above {
+			barTop = th + module
+		} else {
+			textY = spec.height + module
+		}
+		h = spec.height + module + th
+	}
+	barLeft := (w - barWidth) / 2
+
+	r.drawBlock("barcode", cmd, spec.orientation, w, h, func(u, v int) bool {
+		if v >= barTop && v < barTop+spec.height && u >= barLeft && u < barLeft+barWidth {
+			return code.Modules[(u-barLeft)/module]
+		}
+		if mask != nil {
+			return mask.AlphaAt(u-textX, v-textY).A != 0
+		}
+		return false
+	})
+	return nil
+}
+
+// code128Invocations resolves the >x invocation codes of ^BC mode N data.
+// Start codes are ignored since the encoder picks code sets itself.
+func code128Invocations(data []byte) ([]int, string, error) {
+	var (
+		input []int
+		text  []byte
+	)
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c != '>' {
+			if c > 127 {
+				return nil, "", fmt.Errorf("character 0x%02x cannot be encoded in Code 128", c)
+			}
+			input = append(input, int(c))
+			text = append(text, c)
+			continue
+		}
+		if i+1 == len(data) {
+			return nil, "", fmt.Errorf("incomplete invocation code at end of data")
+		}
+		i++
+		switch data[i] {
+		case '<':
+			input = append(input, '>')
+			text = append(text, '>')
+		case '8':
+			input = append(input, code128.FNC1)
+		case '9', ':', ';':
+		default:
+			return nil, "", fmt.Errorf("unsupported invocation code >%c", data[i])
+		}
+	}
+	return input, string(text), nil
+}
+
+// drawDataMatrix renders a ^BX field, resolving escape sequences:
+// <esc>1 is FNC1 and <esc>dNNN a byte given in decimal
+func (r *renderer) drawDataMatrix(cmd Command, data []byte) error {
+	spec := r.field.barcode
+	var input []int
+	for i := 0; i < len(data); i++ {
+		if data[i] != spec.escape || i+1 == len(data) {
+			input = append(input, int(data[i]))
+			continue
+		}
+		switch data[i+1] {
+		case '1':
+			input = append(input, datamatrix.FNC1)
+			i++
+		case 'd', 'D':
+			if i+4 >= len(data) {
+				return errorAt(cmd, "incomplete escape sequence %q", data[i:])
+			}
+			n, err := strconv.Atoi(string(data[i+2 : i+5]))
+			if err != nil || n > 255 {
+				return errorAt(cmd, "invalid escape sequence %q", data[i:i+5])
+			}
+			input = append(input, n)
+			i += 4
+		default:
+			input = append(input, int(data[i]))
+		}
+	}
+
+	code, err := datamatrix.Encode(input)
+	if err != nil {
+		return errorAt(cmd, "%v", err)
+	}
+	mod := spec.height
+	size := code.Size * mod
+	r.drawBlock("datamatrix", cmd, spec.orientation, size, size, func(u, v int) bool {
+		return code.Dark(u/mod, v/mod)
+	})
+	return nil
+}
+
+// drawBlock paints a w by h block, rotated for the field orientation. dark
+// reports the modules of the unrotated block. ^FO places the top left corner
+// of the rotated block and ^FT its bottom left corner.
+func (r *renderer) drawBlock(kind string, cmd Command, orientation byte, w, h int, dark func(u, v int) bool) {
+	bw, bh := w, h
+	if orientation == 'R' || orientation == 'B' {
+		bw, bh = h, w
+	}
+	x, y := r.field.x, r.field.y
+	if r.field.typeset {
+		y -= bh
+	}
+
+	var place func(u, v int) (int, int)
+	switch orientation {
+	case 'R':
+		place = func(u, v int) (int, int) { return x + h - 1 - v, y + u }
+	case 'I':
+		place = func(u, v int) (int, int) { return x + w - 1 - u, y + h - 1 - v }
+	case 'B':
+		place = func(u, v int) (int, int) { return x + v, y + w - 1 - u }
+	default:
+		place = func(u, v int) (int, int) { return x + u, y + v }
+	}
+
+	r.drawn(kind, cmd, image.Rect(x, y, x+bw, y+bh))
+	for v := 0; v < h; v++ {
+		for u := 0; u < w; u++ {
+			if dark(u, v) {
+				px, py := place(u, v)
+				r.set(px, py, color.Gray{})
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package zpl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+)
+
+// WritePNG encodes a rendered label as a PNG
+func WritePNG(w io.Writer, img *image.Gray) error {
+	return png.Encode(w, img)
+}
+
+// WritePDF writes a single page PDF holding the rendered label at its physical
+// size for the given density
+func WritePDF(w io.Writer, img *image.Gray, dpi int) error {
+	if dpi <= 0 {
+		dpi = DefaultDPI
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// Pack the label into a 1 bit per pixel DeviceGray image (1 is white)
+	stride := (width + 7) / 8
+	packed := make([]byte, stride*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if img.GrayAt(img.Bounds().Min.X+x, img.Bounds().Min.Y+y).Y >= 0x80 {
+				packed[y*stride+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(packed); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	pageW := float64(width) * 72 / float64(dpi)
+	pageH := float64(height) * 72 / float64(dpi)
+	content := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Label Do Q\n", pageW, pageH)
+
+	var buf bytes.Buffer
+	offsets := make([]int, 0, 5)
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+		"/Resources << /XObject << /Label 4 0 R >> >> /Contents 5 0 R >>", pageW, pageH), nil)
+	object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray "+
+		"/BitsPerComponent 1 /Filter /FlateDecode /Length %d >>", width, height, compressed.Len()), compressed.Bytes())
+	object(fmt.Sprintf("<< /Length %d >>", len(content)), []byte(content))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
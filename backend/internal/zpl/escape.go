@@ -0,0 +1,47 @@
+package zpl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// HexIndicator is the ^FH escape character used for label data. Layouts
+// declare it with ^FH\ before every ^FD that holds a field.
+const HexIndicator = '\\'
+
+// EscapeField makes s safe inside an ^FH\ field. Every byte that is not
+// printable ASCII, and the reserved ^, ~ and \ characters, is written as a
+// \xx hex escape. Fields are printed with ^CI28, so s must be valid UTF-8.
+func EscapeField(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("invalid UTF-8 in %q", s)
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '^' || c == '~' || c == HexIndicator {
+			fmt.Fprintf(&b, "%c%02X", HexIndicator, c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// UnescapeField reverses EscapeField
+func UnescapeField(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == HexIndicator && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,221 @@
+package zpl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EncodeGraphic formats a 1 bit per pixel bitmap, perRow bytes to a row, as
+// the arguments of ^GFA after the compression type: zlib compressed Z64 data
+// with its CRC
+func EncodeGraphic(bits []byte, perRow int) string {
+	data, n := encodeZ64(bits)
+	return fmt.Sprintf("%d,%d,%d,%s", n, len(bits), perRow, data)
+}
+
+// EncodeDownload formats a ~DG command storing a bitmap on the printer as
+// object, e.g. R:LOGO.GRF, for ^XG to recall
+func EncodeDownload(object string, bits []byte, perRow int) string {
+	data, _ := encodeZ64(bits)
+	return fmt.Sprintf("~DG%s,%d,%d,%s", GraphicName(object), len(bits), perRow, data)
+}
+
+// encodeZ64 compresses bits as :Z64: data with its CRC, returning it with
+// the length of the compressed data
+func encodeZ64(bits []byte) (string, int) {
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	zw.Write(bits)
+	zw.Close()
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+	return fmt.Sprintf(":Z64:%s:%04X", encoded, crc16(encoded)), buf.Len()
+}
+
+// Graphic is a bitmap stored on the printer with ~DG
+type Graphic struct {
+	PerRow int // bytes per row
+	Bits   []byte
+}
+
+// GraphicName completes a stored graphic name with the default device R:
+// and extension .GRF, in upper case as the printer stores it
+func GraphicName(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.Contains(name, ":") {
+		name = "R:" + name
+	}
+	if !strings.Contains(name, ".") {
+		name += ".GRF"
+	}
+	return name
+}
+
+// Downloads decodes the graphics that ~DG commands store on the printer, by name
+func Downloads(cmds []Command) (map[string]Graphic, error) {
+	graphics := map[string]Graphic{}
+	for _, cmd := range cmds {
+		if cmd.Prefix != '~' || cmd.Name != "DG" {
+			continue
+		}
+		name, g, err := decodeDownload(cmd)
+		if err != nil {
+			return nil, err
+		}
+		graphics[name] = g
+	}
+	return graphics, nil
+}
+
+// decodeDownload decodes ~DGd:o.x,t,w,data
+func decodeDownload(cmd Command) (string, Graphic, error) {
+	parts := strings.SplitN(cmd.Params, ",", 4)
+	if len(parts) < 4 {
+		return "", Graphic{}, errorAt(cmd, "expected 4 parameters, got %d", len(parts))
+	}
+	total, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || total <= 0 {
+		return "", Graphic{}, errorAt(cmd, "invalid graphic byte count %q", parts[1])
+	}
+	perRow, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+	if err != nil || perRow <= 0 {
+		return "", Graphic{}, errorAt(cmd, "invalid bytes per row %q", parts[2])
+	}
+	bits, err := decodeGraphicASCII(parts[3], perRow, total)
+	if err != nil {
+		return "", Graphic{}, errorAt(cmd, "%v", err)
+	}
+	return GraphicName(parts[0]), Graphic{PerRow: perRow, Bits: bits}, nil
+}
+
+// decodeGraphicASCII decodes ^GF data in ASCII form: either :Z64: / :B64: base64
+// blocks or hexadecimal with the ZPL run-length compression scheme
+func decodeGraphicASCII(data string, perRow, total int) ([]byte, error) {
+	data = strings.TrimSpace(data)
+
+	switch {
+	case strings.HasPrefix(data, ":Z64:"), strings.HasPrefix(data, ":B64:"):
+		// :Z64:<base64>:<crc>, where the optional CRC is CRC-16/XMODEM of the base64 text
+		encoded := data[5:]
+		if i := strings.IndexByte(encoded, ':'); i >= 0 {
+			crc := strings.TrimSpace(encoded[i+1:])
+			encoded = encoded[:i]
+			if crc != "" {
+				want, err := strconv.ParseUint(crc, 16, 16)
+				if err != nil {
+					return nil, fmt.Errorf("invalid graphic data CRC %q", crc)
+				}
+				if got := crc16(encoded); got != uint16(want) {
+					return nil, fmt.Errorf("graphic data CRC is %04X but the data hashes to %04X", want, got)
+				}
+			}
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 graphic data: %w", err)
+		}
+		if strings.HasPrefix(data, ":B64:") {
+			return raw, nil
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Z64 graphic data: %w", err)
+		}
+		defer zr.Close()
+		out, err := io.ReadAll(io.LimitReader(zr, int64(total)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Z64 graphic data: %w", err)
+		}
+		return out, nil
+	}
+
+	rowLen := perRow * 2
+	var (
+		rows  []string
+		row   strings.Builder
+		count int
+	)
+	endRow := func(fill byte) {
+		for row.Len() < rowLen {
+			row.WriteByte(fill)
+		}
+		rows = append(rows, row.String())
+		row.Reset()
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c >= 'G' && c <= 'Y':
+			count += int(c-'G') + 1
+		case c >= 'g' && c <= 'z':
+			count += (int(c-'g') + 1) * 20
+		case c == ',':
+			endRow('0')
+		case c == '!':
+			endRow('F')
+		case c == ':':
+			if row.Len() > 0 {
+				endRow('0')
+			}
+			if len(rows) > 0 {
+				rows = append(rows, rows[len(rows)-1])
+			} else {
+				rows = append(rows, strings.Repeat("0", rowLen))
+			}
+		case isHex(c):
+			n := count
+			if n == 0 {
+				n = 1
+			}
+			count = 0
+			for ; n > 0; n-- {
+				row.WriteByte(c)
+				if row.Len() == rowLen {
+					rows = append(rows, row.String())
+					row.Reset()
+				}
+			}
+		case c == ' ' || c == '\t':
+		default:
+			return nil, fmt.Errorf("invalid graphic data character %q", c)
+		}
+	}
+	if row.Len() > 0 {
+		endRow('0')
+	}
+
+	out, err := hex.DecodeString(strings.Join(rows, ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex graphic data: %w", err)
+	}
+	if len(out) > total {
+		out = out[:total]
+	}
+	return out, nil
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'F') || (c >= 'a' && c <= 'f')
+}
+
+// crc16 computes CRC-16/XMODEM, the checksum Zebra appends to Z64 and B64 data
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for b := 0; b < 8; b++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
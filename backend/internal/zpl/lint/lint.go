@@ -0,0 +1,223 @@
+// Package lint checks ZPL label formats for mistakes that printers either
+// reject or print wrongly, reporting each with its line and column.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+
+	"labelops-backend/internal/zpl"
+)
+
+// Issue is one problem found in ZPL source
+type Issue struct {
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Command string `json:"command,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Command != "" {
+		return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Col, i.Command, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s", i.Line, i.Col, i.Message)
+}
+
+// Options control linting
+type Options struct {
+	// DPI sizes labels that do not set ^PW or ^LL, as when rendering
+	DPI int
+}
+
+// known lists the ZPL II commands, by prefix and name, that may appear in a label
+var known = map[string]bool{
+	// Format and field structure
+	"^XA": true, "^XZ": true, "^XF": true, "^XG": true, "^FO": true, "^FT": true, "^FD": true,
+	"^FS": true, "^FH": true, "^FV": true, "^FN": true, "^FB": true, "^FP": true, "^FR": true,
+	"^FW": true, "^FX": true, "^FC": true, "^FM": true, "^FL": true, "^SN": true, "^SF": true,
+	// Fonts and encoding
+	"^A": true, "^A@": true, "^CF": true, "^CI": true, "^CW": true, "^CC": true, "^CT": true,
+	"^CD": true, "~CC": true, "~CT": true, "~CD": true,
+	// Graphics
+	"^GB": true, "^GC": true, "^GD": true, "^GE": true, "^GF": true, "^GS": true, "^IM": true,
+	"^IL": true, "^IS": true, "^ID": true, "~DG": true, "~DY": true, "~DB": true, "~DU": true,
+	"^DF": true,
+	// Bar codes
+	"^BY": true, "^BC": true, "^BE": true, "^B8": true, "^B9": true, "^BU": true, "^B3": true,
+	"^BA": true, "^B2": true, "^BI": true, "^BQ": true, "^BX": true, "^B7": true, "^BO": true,
+	"^BD": true, "^BK": true, "^BR": true, "^BT": true, "^B0": true, "^B1": true, "^B4": true,
+	"^B5": true, "^BB": true, "^BF": true, "^BJ": true, "^BL": true, "^BM": true, "^BP": true,
+	"^BS": true, "^BZ": true,
+	// Label and media setup
+	"^PW": true, "^LL": true, "^LH": true, "^LS": true, "^LT": true, "^LR": true, "^PO": true,
+	"^PM": true, "^PR": true, "^PQ": true, "^PF": true, "^PP": true, "^MD": true, "^MM": true,
+	"^MN": true, "^MT": true, "^MF": true, "^MU": true, "^ML": true, "^JU": true, "^JZ": true,
+	"^JM": true, "^JS": true, "^JT": true, "^KD": true, "^KL": true, "^SZ": true, "^SC": true,
+	"~SD": true, "~TA": true, "~JS": true, "~JD": true, "~JE": true, "~PS": true, "~PH": true,
+	"~PP": true, "~JA": true, "~HS": true, "~HI": true, "~HM": true, "~JR": true, "~WC": true,
+	"^HH": true, "^HW": true, "^HG": true, "~HB": true, "^ID@": true, "^XB": true,
+}
+
+// Lint checks ZPL source. It never stops at the first problem unless the
+// source cannot be split into commands at all.
+func Lint(data []byte, opts Options) []Issue {
+	cmds, err := zpl.Parse(data)
+	if err != nil {
+		return []Issue{fromError(err)}
+	}
+
+	var issues []Issue
+	add := func(cmd zpl.Command, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			Line: cmd.Line, Col: cmd.Col, Command: string(cmd.Prefix) + cmd.Name,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	var (
+		formats [][]zpl.Command
+		current []zpl.Command
+		open    *zpl.Command
+	)
+	for i, cmd := range cmds {
+		name := string(cmd.Prefix) + cmd.Name
+		if !known[name] {
+			add(cmd, "unknown command")
+		}
+		switch name {
+		case "^XA":
+			if open != nil {
+				add(cmd, "^XA before the format at line %d col %d was closed with ^XZ", open.Line, open.Col)
+				formats = append(formats, current)
+			}
+			open = &cmds[i]
+			current = nil
+		case "^XZ":
+			if open == nil {
+				add(cmd, "^XZ without a matching ^XA")
+				continue
+			}
+			formats = append(formats, current)
+			open = nil
+		default:
+			if open != nil {
+				current = append(current, cmd)
+			} else if cmd.Prefix == '^' {
+				add(cmd, "command outside a ^XA...^XZ format")
+			}
+		}
+	}
+	if open != nil {
+		issues = append(issues, Issue{Line: open.Line, Col: open.Col, Command: "^XA", Message: "format is not terminated with ^XZ"})
+		formats = append(formats, current)
+	}
+	if len(formats) == 0 && len(issues) == 0 {
+		issues = append(issues, Issue{Line: 1, Col: 1, Message: "no ^XA...^XZ label format found"})
+	}
+
+	// Graphics downloaded with ~DG are drawn where the formats recall them
+	graphics, err := zpl.Downloads(cmds)
+	if err != nil {
+		issues = append(issues, fromError(err))
+	}
+	for _, format := range formats {
+		issues = append(issues, checkFields(format)...)
+		issues = append(issues, checkLayout(format, opts, graphics)...)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Col < issues[j].Col
+	})
+	return issues
+}
+
+// checkFields reports fields that are not closed with ^FS before the next
+// field starts or the format ends
+func checkFields(cmds []zpl.Command) []Issue {
+	var (
+		issues  []Issue
+		start   *zpl.Command // first command of the open field
+		content bool         // the open field has data or a graphic
+	)
+	unclosed := func() {
+		if start != nil && content {
+			issues = append(issues, Issue{
+				Line: start.Line, Col: start.Col, Command: string(start.Prefix) + start.Name,
+				Message: "field is not closed with ^FS",
+			})
+		}
+		start, content = nil, false
+	}
+
+	for i, cmd := range cmds {
+		if cmd.Prefix != '^' {
+			continue
+		}
+		switch cmd.Name {
+		case "FO", "FT":
+			unclosed()
+			start = &cmds[i]
+		case "FD", "FV", "SN", "GB", "GC", "GD", "GE", "GF", "XG", "IM":
+			if start == nil {
+				start = &cmds[i]
+			}
+			content = true
+		case "FS":
+			start, content = nil, false
+		}
+	}
+	unclosed()
+	return issues
+}
+
+// checkLayout renders the format and reports elements that fall outside the
+// label, along with anything the renderer rejects (bad parameters, corrupt
+// graphics, QR payloads that cannot be encoded)
+func checkLayout(cmds []zpl.Command, opts Options, graphics map[string]zpl.Graphic) []Issue {
+	width, length, err := zpl.Size(cmds, opts.DPI)
+	if err != nil {
+		return []Issue{fromError(err)}
+	}
+	label := image.Rect(0, 0, width, length)
+
+	var issues []Issue
+	_, err = zpl.RenderFormat(cmds, zpl.Options{
+		DPI:      opts.DPI,
+		Graphics: graphics,
+		OnDraw: func(e zpl.Element) {
+			if e.Bounds.In(label) {
+				return
+			}
+			at := e.Cmd
+			if e.Origin != nil {
+				at = *e.Origin
+			}
+			msg := fmt.Sprintf("%s at %d,%d-%d,%d extends outside the %dx%d dot label",
+				e.Kind, e.Bounds.Min.X, e.Bounds.Min.Y, e.Bounds.Max.X, e.Bounds.Max.Y, width, length)
+			if e.Kind == "qr" {
+				msg = fmt.Sprintf("QR code is %d dots square at this magnification and extends outside the %dx%d dot label; "+
+					"shorten the payload or lower the magnification", e.Bounds.Dx(), width, length)
+			}
+			issues = append(issues, Issue{Line: at.Line, Col: at.Col, Command: string(at.Prefix) + at.Name, Message: msg})
+		},
+	})
+	if err != nil {
+		issues = append(issues, fromError(err))
+	}
+	return issues
+}
+
+// fromError converts a parser or renderer error into an issue
+func fromError(err error) Issue {
+	var syntaxErr *zpl.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return Issue{Line: syntaxErr.Line, Col: syntaxErr.Col, Message: syntaxErr.Msg}
+	}
+	return Issue{Line: 1, Col: 1, Message: err.Error()}
+}
@@ -0,0 +1,90 @@
+package lint_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"labelops-backend/internal/zpl/lint"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		zpl  string
+		want []string // "line:col: substring" for each expected issue, in order
+	}{
+		{
+			name: "clean",
+			zpl:  "^XA\n^PW400^LL300\n^FO10,10^A0N,30,30^FDOK^FS\n^FO10,60^GB100,50,2^FS\n^XZ\n",
+		},
+		{
+			name: "missing XZ",
+			zpl:  "^XA\n^FO10,10^A0N,30,30^FDOK^FS\n",
+			want: []string{"1:1: not terminated with ^XZ"},
+		},
+		{
+			name: "XZ without XA",
+			zpl:  "^XA^FO10,10^FDA^FS^XZ\n^XZ\n",
+			want: []string{"2:1: without a matching ^XA"},
+		},
+		{
+			name: "unknown command",
+			zpl:  "^XA\n^FO10,10^FDA^FS\n^QQ1\n^XZ\n",
+			want: []string{"3:1: unknown command"},
+		},
+		{
+			name: "missing FS",
+			zpl:  "^XA\n^FO10,10^FDA\n^FO10,50^FDB^FS\n^XZ\n",
+			want: []string{"2:1: not closed with ^FS"},
+		},
+		{
+			name: "field outside label",
+			zpl:  "^XA^PW200^LL200\n^FO150,10^A0N,40,40^FDTOO WIDE^FS\n^XZ\n",
+			want: []string{"2:1: text at"},
+		},
+		{
+			name: "QR too large for magnification",
+			zpl:  "^XA^PW250^LL250\n^FO0,0^BQN,2,10^FDMA,https://example.com/products/0123456789^FS\n^XZ\n",
+			want: []string{"2:1: QR code is"},
+		},
+		{
+			name: "GS1-128 and DataMatrix",
+			zpl:  "^XA^PW600^LL400\n^FO10,10^BY2^BCN,80,Y,N,N,D^FD(10)C103247(21)2025015212^FS\n^FO10,150^BXN,5,200,0,0,6,#^FD#110C103247#d02921A1^FS\n^XZ\n",
+		},
+		{
+			name: "GS1 check digit",
+			zpl:  "^XA\n^FO10,10^BCN,50,Y,N,N,D^FD(01)09506000134353^FS\n^XZ\n",
+			want: []string{"2:45: invalid check digit"},
+		},
+		{
+			name: "bad Z64 CRC",
+			zpl:  "^XA\n^FO0,0^GFA,8,8,1,:Z64:eJxjYGBgAAAABAAB:FFFF^FS\n^XZ\n",
+			want: []string{"2:7: CRC"},
+		},
+		{
+			name: "syntax error",
+			zpl:  "^XA^FO10,10^FDA^FS^",
+			want: []string{"1:19: incomplete command"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := lint.Lint([]byte(tt.zpl), lint.Options{})
+			if len(issues) != len(tt.want) {
+				t.Fatalf("got %d issues %v, want %d", len(issues), issues, len(tt.want))
+			}
+			for i, want := range tt.want {
+				pos, msg, _ := strings.Cut(want, ": ")
+				got := issues[i]
+				if gotPos := fmt.Sprintf("%d:%d", got.Line, got.Col); gotPos != pos {
+					t.Errorf("issue %d at %s, want %s (%v)", i, gotPos, pos, got)
+				}
+				if !strings.Contains(got.Message, msg) {
+					t.Errorf("issue %d message %q does not contain %q", i, got.Message, msg)
+				}
+			}
+		})
+	}
+}
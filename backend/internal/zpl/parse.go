@@ -0,0 +1,139 @@
+// Package zpl parses Zebra Programming Language label formats and renders them
+// to bitmaps, PNG and PDF for previewing labels without a printer.
+package zpl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Command is one ZPL command with its raw parameter text
+type Command struct {
+	Prefix byte   // '^' for format commands, '~' for control commands
+	Name   string // upper-case command name without the prefix, e.g. "FO" or "A"
+	Params string // everything up to the next command prefix
+	Line   int    // 1-based position of the prefix in the source
+	Col    int
+}
+
+// String returns the command as it appears in ZPL
+func (c Command) String() string {
+	return string(c.Prefix) + c.Name + c.Params
+}
+
+// Args splits the parameters on commas, trimming surrounding whitespace
+func (c Command) Args() []string {
+	if c.Params == "" {
+		return nil
+	}
+	args := strings.Split(c.Params, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return args
+}
+
+// SyntaxError reports malformed ZPL with its source position
+type SyntaxError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("zpl: line %d col %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// errorAt builds a SyntaxError pointing at cmd
+func errorAt(cmd Command, format string, args ...interface{}) error {
+	return &SyntaxError{Line: cmd.Line, Col: cmd.Col, Msg: string(cmd.Prefix) + cmd.Name + ": " + fmt.Sprintf(format, args...)}
+}
+
+// Parse splits ZPL source into commands. Text outside commands (such as
+// newlines between them) is ignored; line breaks inside parameters are dropped.
+func Parse(data []byte) ([]Command, error) {
+	var cmds []Command
+	line, col := 1, 1
+
+	i := 0
+	advance := func() {
+		if data[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+		i++
+	}
+
+	for i < len(data) {
+		if data[i] != '^' && data[i] != '~' {
+			advance()
+			continue
+		}
+
+		cmd := Command{Prefix: data[i], Line: line, Col: col}
+		advance()
+		if i >= len(data) {
+			return nil, &SyntaxError{Line: cmd.Line, Col: cmd.Col, Msg: "incomplete command"}
+		}
+
+		// ^A selects a font with a one character name (^A0, ^AB...), except ^A@
+		name := strings.ToUpper(string(data[i]))
+		advance()
+		if name != "A" || (i < len(data) && data[i] == '@') {
+			if i >= len(data) {
+				return nil, &SyntaxError{Line: cmd.Line, Col: cmd.Col, Msg: "incomplete command"}
+			}
+			name += strings.ToUpper(string(data[i]))
+			advance()
+		}
+		cmd.Name = name
+
+		var params strings.Builder
+		for i < len(data) && data[i] != '^' && data[i] != '~' {
+			if data[i] != '\r' && data[i] != '\n' {
+				params.WriteByte(data[i])
+			}
+			advance()
+		}
+		cmd.Params = params.String()
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// Formats groups parsed commands into label formats, one per ^XA...^XZ pair.
+// Commands outside a format are dropped.
+func Formats(cmds []Command) ([][]Command, error) {
+	var (
+		formats [][]Command
+		current []Command
+		open    *Command
+	)
+	for i, cmd := range cmds {
+		switch {
+		case cmd.Prefix == '^' && cmd.Name == "XA":
+			if open != nil {
+				return nil, errorAt(cmd, "format started before the one at line %d col %d was closed", open.Line, open.Col)
+			}
+			open = &cmds[i]
+			current = nil
+		case cmd.Prefix == '^' && cmd.Name == "XZ":
+			if open == nil {
+				return nil, errorAt(cmd, "^XZ without ^XA")
+			}
+			formats = append(formats, current)
+			open = nil
+		case open != nil:
+			current = append(current, cmd)
+		}
+	}
+	if open != nil {
+		return nil, errorAt(*open, "format is not terminated with ^XZ")
+	}
+	if len(formats) == 0 {
+		return nil, &SyntaxError{Line: 1, Col: 1, Msg: "no ^XA...^XZ label format found"}
+	}
+	return formats, nil
+}
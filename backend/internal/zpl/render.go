@@ -0,0 +1,599 @@
+package zpl
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"labelops-backend/internal/qrcode"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// DefaultDPI is the print density of the Zebra printers on the shop floor
+const DefaultDPI = 203
+
+// Options control how a label is rendered
+type Options struct {
+	// DPI is the printer density; it sizes labels that do not set ^PW or ^LL
+	DPI int
+	// OnDraw, when set, is called for every element drawn
+	OnDraw func(Element)
+	// Graphics are the stored graphics ^XG recalls, by name such as R:LOGO.GRF.
+	// Render fills them from the ~DG commands in the data when nil.
+	Graphics map[string]Graphic
+}
+
+// Element describes something drawn on the label, for callers that inspect the layout
+type Element struct {
+	Kind   string          // "text", "qr", "barcode", "datamatrix", "box" or "graphic"
+	Cmd    Command         // the command that drew it: ^FS, ^GB, ^GF or ^XG
+	Origin *Command        // the ^FO or ^FT that positioned it, if any
+	Bounds image.Rectangle // area in dots before clipping to the label
+}
+
+// Render draws the first label format in data onto a white bitmap sized by ^PW and ^LL.
+// Commands the renderer does not implement are ignored.
+func Render(data []byte, opts Options) (*image.Gray, error) {
+	cmds, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	formats, err := Formats(cmds)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Graphics == nil {
+		if opts.Graphics, err = Downloads(cmds); err != nil {
+			return nil, err
+		}
+	}
+	return RenderFormat(formats[0], opts)
+}
+
+// RenderFormat draws the commands of a single ^XA...^XZ format
+func RenderFormat(cmds []Command, opts Options) (*image.Gray, error) {
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = DefaultDPI
+	}
+	width, length, err := Size(cmds, dpi)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{
+		img:         image.NewGray(image.Rect(0, 0, width, length)),
+		dpi:         dpi,
+		onDraw:      opts.OnDraw,
+		graphics:    opts.Graphics,
+		defaultFont: fontSpec{name: '0', orientation: 'N', height: 9, width: 5},
+		orientation: 'N',
+		by:          barcodeDefaults{module: 2, ratio: 3, height: 10},
+	}
+	draw.Draw(r.img, r.img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for _, cmd := range cmds {
+		if err := r.exec(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return r.img, nil
+}
+
+// Size returns the label width and length in dots set by ^PW and ^LL, which
+// may appear anywhere in the format. Unset sizes default to a 4x6 inch label.
+func Size(cmds []Command, dpi int) (int, int, error) {
+	if dpi <= 0 {
+		dpi = DefaultDPI
+	}
+	width, length := 4*dpi, 6*dpi
+	for _, cmd := range cmds {
+		if cmd.Prefix != '^' || (cmd.Name != "PW" && cmd.Name != "LL") {
+			continue
+		}
+		n, err := intArg(cmd, 0, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n <= 0 || n > 32000 {
+			return 0, 0, errorAt(cmd, "invalid size %d", n)
+		}
+		if cmd.Name == "PW" {
+			width = n
+		} else {
+			length = n
+		}
+	}
+	return width, length, nil
+}
+
+// fontSpec is a font selected with ^A or ^CF
+type fontSpec struct {
+	name        byte
+	orientation byte // N, R, I or B
+	height      int
+	width       int
+}
+
+// field collects the state of the field being built until ^FS
+type field struct {
+	origin       *Command
+	x, y         int
+	typeset      bool // origin set with ^FT (bottom left) instead of ^FO (top left)
+	font         *fontSpec
+	hexIndicator byte
+	qr           *qrSpec
+	barcode      *barcodeSpec
+	data         []byte
+	hasData      bool
+}
+
+// qrSpec holds the ^BQ parameters of a QR code field
+type qrSpec struct {
+	magnification int
+	level         string
+}
+
+type renderer struct {
+	img          *image.Gray
+	dpi          int
+	onDraw       func(Element)
+	graphics     map[string]Graphic
+	homeX, homeY int
+	shift        int
+	charset      int
+	defaultFont  fontSpec
+	orientation  byte // ^FW default field orientation
+	by           barcodeDefaults
+	field        field
+}
+
+func (r *renderer) exec(cmd Command) error {
+	if cmd.Prefix != '^' {
+		return nil
+	}
+
+	switch cmd.Name {
+	case "LH":
+		x, err := intArg(cmd, 0, 0)
+		if err != nil {
+			return err
+		}
+		y, err := intArg(cmd, 1, 0)
+		if err != nil {
+			return err
+		}
+		r.homeX, r.homeY = x, y
+
+	case "LS":
+		n, err := intArg(cmd, 0, 0)
+		if err != nil {
+			return err
+		}
+		r.shift = n
+
+	case "CI":
+		n, err := intArg(cmd, 0, 0)
+		if err != nil {
+			return err
+		}
+		r.charset = n
+
+	case "FW":
+		if o := orientationArg(cmd, 0); o != 0 {
+			r.orientation = o
+		}
+
+	case "CF":
+		args := cmd.Args()
+		if len(args) > 0 && args[0] != "" {
+			r.defaultFont.name = args[0][0]
+		}
+		h, err := intArg(cmd, 1, r.defaultFont.height)
+		if err != nil {
+			return err
+		}
+		w, err := intArg(cmd, 2, h)
+		if err != nil {
+			return err
+		}
+		r.defaultFont.height, r.defaultFont.width = h, w
+
+	case "FO", "FT":
+		x, err := intArg(cmd, 0, 0)
+		if err != nil {
+			return err
+		}
+		y, err := intArg(cmd, 1, 0)
+		if err != nil {
+			return err
+		}
+		origin := cmd
+		r.field.origin = &origin
+		r.field.x = x + r.homeX - r.shift
+		r.field.y = y + r.homeY
+		r.field.typeset = cmd.Name == "FT"
+
+	case "A":
+		return r.selectFont(cmd)
+
+	case "FH":
+		r.field.hexIndicator = '_'
+		if cmd.Params != "" {
+			r.field.hexIndicator = cmd.Params[0]
+		}
+
+	case "FD":
+		r.field.data = append(r.field.data, cmd.Params...)
+		r.field.hasData = true
+
+	case "SN":
+		// A serialized field shows its starting value, as on the first copy
+		if args := cmd.Args(); len(args) > 0 {
+			r.field.data = append(r.field.data, args[0]...)
+		}
+		r.field.hasData = true
+
+	case "BQ":
+		mag, err := intArg(cmd, 2, r.defaultQRMagnification())
+		if err != nil {
+			return err
+		}
+		if mag < 1 || mag > 10 {
+			return errorAt(cmd, "magnification %d out of range 1-10", mag)
+		}
+		level := "Q"
+		if args := cmd.Args(); len(args) > 3 && args[3] != "" {
+			level = args[3]
+		}
+		r.field.qr = &qrSpec{magnification: mag, level: level}
+
+	case "BY":
+		return r.setBarcodeDefaults(cmd)
+
+	case "BC":
+		return r.selectCode128(cmd)
+
+	case "BX":
+		return r.selectDataMatrix(cmd)
+
+	case "GB":
+		return r.drawBox(cmd)
+
+	case "GF":
+		return r.drawGraphic(cmd)
+
+	case "XG":
+		return r.recallGraphic(cmd)
+
+	case "FS":
+		err := r.drawField(cmd)
+		r.field = field{}
+		return err
+	}
+	return nil
+}
+
+// selectFont handles ^Afo,h,w where f is the font name and o the orientation
+func (r *renderer) selectFont(cmd Command) error {
+	if cmd.Params == "" {
+		return errorAt(cmd, "missing font name")
+	}
+	spec := r.defaultFont
+	spec.name = cmd.Params[0]
+	spec.orientation = r.orientation
+
+	rest := Command{Prefix: cmd.Prefix, Name: cmd.Name, Params: cmd.Params[1:], Line: cmd.Line, Col: cmd.Col}
+	if o := orientationArg(rest, 0); o != 0 {
+		spec.orientation = o
+	}
+	h, err := intArg(rest, 1, spec.height)
+	if err != nil {
+		return err
+	}
+	w, err := intArg(rest, 2, h)
+	if err != nil {
+		return err
+	}
+	if h < 1 || h > 32000 || w < 1 || w > 32000 {
+		return errorAt(cmd, "invalid font size %dx%d", h, w)
+	}
+	spec.height, spec.width = h, w
+	r.field.font = &spec
+	return nil
+}
+
+// defaultQRMagnification follows the printer defaults for each density
+func (r *renderer) defaultQRMagnification() int {
+	switch {
+	case r.dpi <= 150:
+		return 2
+	case r.dpi <= 200:
+		return 3
+	case r.dpi <= 300:
+		return 6
+	}
+	return 10
+}
+
+// fieldData returns the ^FD bytes with ^FH hexadecimal escapes resolved.
+// An indicator not followed by two hex digits is kept literally, as the printer does.
+func (r *renderer) fieldData() []byte {
+	data := r.field.data
+	if r.field.hexIndicator == 0 {
+		return data
+	}
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == r.field.hexIndicator && i+2 < len(data) {
+			if v, err := strconv.ParseUint(string(data[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, data[i])
+	}
+	return out
+}
+
+// decodeText converts field bytes to a string using the current ^CI character set
+func (r *renderer) decodeText(data []byte) string {
+	switch r.charset {
+	case 28:
+		if utf8.Valid(data) {
+			return string(data)
+		}
+		return strings.ToValidUTF8(string(data), "�")
+	default:
+		s, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return string(data)
+		}
+		return string(s)
+	}
+}
+
+// drawField renders the collected field when ^FS closes it
+func (r *renderer) drawField(cmd Command) error {
+	if !r.field.hasData {
+		return nil
+	}
+	data := r.fieldData()
+
+	if r.field.qr != nil {
+		return r.drawQR(cmd, data)
+	}
+	if r.field.barcode != nil {
+		return r.drawBarcode(cmd, data)
+	}
+
+	font := r.defaultFont
+	font.orientation = r.orientation
+	if r.field.font != nil {
+		font = *r.field.font
+	}
+	return r.drawText(cmd, r.decodeText(data), font)
+}
+
+// drawQR renders a ^BQ field. The data starts with the error correction level
+// and input mode, e.g. "MA,<data>".
+func (r *renderer) drawQR(cmd Command, data []byte) error {
+	level := r.field.qr.level
+	if len(data) >= 3 && data[2] == ',' {
+		level = string(data[0])
+		mode := data[1]
+		data = data[3:]
+		if mode == 'M' && len(data) > 0 {
+			switch data[0] {
+			case 'N', 'A':
+				data = data[1:]
+			case 'B':
+				if len(data) < 5 {
+					return errorAt(cmd, "byte mode needs a 4 digit length")
+				}
+				data = data[5:]
+			}
+		}
+	}
+
+	lvl, err := qrcode.ParseLevel(level)
+	if err != nil {
+		return errorAt(cmd, "%v", err)
+	}
+	code, err := qrcode.Encode(data, lvl)
+	if err != nil {
+		return errorAt(cmd, "%v", err)
+	}
+
+	mag := r.field.qr.magnification
+	size := code.Size * mag
+	x, y := r.field.x, r.field.y
+	if r.field.typeset {
+		y -= size
+	}
+	r.drawn("qr", cmd, image.Rect(x, y, x+size, y+size))
+	for my := 0; my < code.Size; my++ {
+		for mx := 0; mx < code.Size; mx++ {
+			if code.Dark(mx, my) {
+				r.fill(x+mx*mag, y+my*mag, mag, mag, color.Gray{})
+			}
+		}
+	}
+	return nil
+}
+
+// drawBox handles ^GBw,h,t,c,r
+func (r *renderer) drawBox(cmd Command) error {
+	t, err := intArg(cmd, 2, 1)
+	if err != nil {
+		return err
+	}
+	w, err := intArg(cmd, 0, t)
+	if err != nil {
+		return err
+	}
+	h, err := intArg(cmd, 1, t)
+	if err != nil {
+		return err
+	}
+	if t < 1 {
+		t = 1
+	}
+	if w < t {
+		w = t
+	}
+	if h < t {
+		h = t
+	}
+
+	c := color.Gray{}
+	if args := cmd.Args(); len(args) > 3 && strings.EqualFold(args[3], "W") {
+		c = color.Gray{Y: 0xff}
+	}
+
+	x, y := r.field.x, r.field.y
+	if r.field.typeset {
+		y -= h
+	}
+	r.drawn("box", cmd, image.Rect(x, y, x+w, y+h))
+	if 2*t >= w || 2*t >= h {
+		r.fill(x, y, w, h, c)
+		return nil
+	}
+	r.fill(x, y, w, t, c)
+	r.fill(x, y+h-t, w, t, c)
+	r.fill(x, y, t, h, c)
+	r.fill(x+w-t, y, t, h, c)
+	return nil
+}
+
+// drawGraphic handles ^GFa,b,c,d,data
+func (r *renderer) drawGraphic(cmd Command) error {
+	parts := strings.SplitN(cmd.Params, ",", 5)
+	if len(parts) < 5 {
+		return errorAt(cmd, "expected 5 parameters, got %d", len(parts))
+	}
+	format := strings.ToUpper(strings.TrimSpace(parts[0]))
+	total, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+	if err != nil || total <= 0 {
+		return errorAt(cmd, "invalid graphic field count %q", parts[2])
+	}
+	perRow, err := strconv.Atoi(strings.TrimSpace(parts[3]))
+	if err != nil || perRow <= 0 {
+		return errorAt(cmd, "invalid bytes per row %q", parts[3])
+	}
+
+	var bitmap []byte
+	switch format {
+	case "A", "":
+		bitmap, err = decodeGraphicASCII(parts[4], perRow, total)
+	case "B", "C":
+		bitmap = []byte(parts[4])
+	default:
+		return errorAt(cmd, "unknown compression type %q", format)
+	}
+	if err != nil {
+		return errorAt(cmd, "%v", err)
+	}
+
+	r.blit(cmd, bitmap, perRow, total/perRow, 1, 1)
+	return nil
+}
+
+// recallGraphic handles ^XGd:o.x,mx,my, drawing a graphic stored with ~DG.
+// Graphics the printer already holds are not known here and are skipped.
+func (r *renderer) recallGraphic(cmd Command) error {
+	args := cmd.Args()
+	if len(args) == 0 || args[0] == "" {
+		return errorAt(cmd, "missing graphic name")
+	}
+	mx, err := intArg(cmd, 1, 1)
+	if err != nil {
+		return err
+	}
+	my, err := intArg(cmd, 2, 1)
+	if err != nil {
+		return err
+	}
+	if mx < 1 || mx > 10 || my < 1 || my > 10 {
+		return errorAt(cmd, "magnification %dx%d out of range 1-10", mx, my)
+	}
+	g, ok := r.graphics[GraphicName(args[0])]
+	if !ok {
+		return nil
+	}
+	r.blit(cmd, g.Bits, g.PerRow, len(g.Bits)/g.PerRow, mx, my)
+	return nil
+}
+
+// blit draws a 1 bit per pixel bitmap at the field origin, each pixel mx by my dots
+func (r *renderer) blit(cmd Command, bitmap []byte, perRow, rows, mx, my int) {
+	x, y := r.field.x, r.field.y
+	if r.field.typeset {
+		y -= rows * my
+	}
+	r.drawn("graphic", cmd, image.Rect(x, y, x+perRow*8*mx, y+rows*my))
+	for row := 0; row < rows; row++ {
+		for col := 0; col < perRow*8; col++ {
+			i := row*perRow + col/8
+			if i < len(bitmap) && bitmap[i]&(0x80>>uint(col%8)) != 0 {
+				for dy := 0; dy < my; dy++ {
+					for dx := 0; dx < mx; dx++ {
+						r.set(x+col*mx+dx, y+row*my+dy, color.Gray{})
+					}
+				}
+			}
+		}
+	}
+}
+
+// drawn reports an element to the OnDraw callback
+func (r *renderer) drawn(kind string, cmd Command, bounds image.Rectangle) {
+	if r.onDraw != nil {
+		r.onDraw(Element{Kind: kind, Cmd: cmd, Origin: r.field.origin, Bounds: bounds})
+	}
+}
+
+// fill paints a rectangle, clipped to the label
+func (r *renderer) fill(x, y, w, h int, c color.Gray) {
+	rect := image.Rect(x, y, x+w, y+h).Intersect(r.img.Bounds())
+	draw.Draw(r.img, rect, &image.Uniform{C: c}, image.Point{}, draw.Src)
+}
+
+func (r *renderer) set(x, y int, c color.Gray) {
+	if image.Pt(x, y).In(r.img.Bounds()) {
+		r.img.SetGray(x, y, c)
+	}
+}
+
+// intArg parses argument i, returning def when it is missing or empty
+func intArg(cmd Command, i int, def int) (int, error) {
+	args := cmd.Args()
+	if i >= len(args) || args[i] == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[i])
+	if err != nil {
+		return 0, errorAt(cmd, "parameter %d: %q is not a number", i+1, args[i])
+	}
+	return n, nil
+}
+
+// orientationArg returns argument i as N, R, I or B, or 0 when it is not one of them
+func orientationArg(cmd Command, i int) byte {
+	args := cmd.Args()
+	if i >= len(args) || len(args[i]) != 1 {
+		return 0
+	}
+	switch o := args[i][0] &^ 0x20; o {
+	case 'N', 'R', 'I', 'B':
+		return o
+	}
+	return 0
+}
@@ -0,0 +1,216 @@
+package zpl
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Media is the printer setup a label format is adapted to by Adapt
+type Media struct {
+	DPI      int  // print density; formats are scaled to it from the density they were designed at
+	Width    int  // label width in dots, replacing ^PW; zero keeps the format's
+	Length   int  // label length in dots, replacing ^LL; zero keeps the format's
+	Darkness *int // ~SD, 0 to 30; nil keeps the printer's setting
+	Speed    *int // ^PR, inches per second; nil keeps the printer's setting
+	TearOff  int  // ~TA, dots the tear-off position is moved by
+	LabelTop int  // ^LT, dots the label is shifted down by
+	Tracking byte // ^MN: 'Y' gap, 'M' black mark or 'N' continuous; zero keeps the printer's setting
+}
+
+// Commands returns the control commands for the media, sent ahead of a label
+// format, and the format commands that go after its ^XA
+func (m Media) Commands() (control, format string) {
+	var c, f strings.Builder
+	if m.Darkness != nil {
+		fmt.Fprintf(&c, "~SD%02d\n", *m.Darkness)
+	}
+	fmt.Fprintf(&c, "~TA%03d\n", m.TearOff)
+	if m.Speed != nil {
+		fmt.Fprintf(&f, "^PR%d\n", *m.Speed)
+	}
+	fmt.Fprintf(&f, "^LT%d\n", m.LabelTop)
+	if m.Tracking != 0 {
+		fmt.Fprintf(&f, "^MN%c\n", m.Tracking)
+	}
+	return c.String(), f.String()
+}
+
+// mediaCommands are replaced by the ones Media.Commands returns
+var mediaCommands = map[string]bool{"~SD": true, "~TA": true, "^PR": true, "^LT": true, "^MN": true}
+
+// scaledArgs lists, for each command Adapt scales, the arguments that are
+// measured in dots
+var scaledArgs = map[string][]int{
+	"FO": {0, 1}, "FT": {0, 1}, "LH": {0, 1}, "LS": {0}, "PW": {0}, "LL": {0},
+	"A": {1, 2}, "A@": {1, 2}, "CF": {1, 2},
+	"FB": {0, 2}, "TB": {1, 2},
+	"GB": {0, 1, 2}, "GC": {0, 1}, "GD": {0, 1, 2}, "GE": {0, 1, 2},
+	"BY": {0, 2}, "BC": {1}, "BX": {1}, "BQ": {2},
+}
+
+// minArgs are the smallest values a scaled argument may take, such as one dot
+// for a line or bar width
+var minArgs = map[string]map[int]int{
+	"GB": {2: 1}, "GC": {1: 1}, "GD": {2: 1}, "GE": {2: 1},
+	"BY": {0: 1}, "BX": {1: 1}, "BQ": {2: 1}, "A": {1: 1, 2: 1}, "A@": {1: 1, 2: 1},
+}
+
+// maxArgs are the largest values a scaled argument may take
+var maxArgs = map[string]map[int]int{
+	"BY": {0: 10}, "BQ": {2: 10},
+}
+
+// Adapt adapts label formats designed for a printer of density from to the
+// media m: coordinates, font sizes, bar code modules and graphics (^GF and
+// ~DG) are scaled to m.DPI, ^PW and ^LL are set to the media size and the
+// media's darkness, speed, tear-off, label top and tracking replace any in
+// the formats. Commands keep their line breaks.
+func Adapt(data []byte, from int, m Media) ([]byte, error) {
+	cmds, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	to := m.DPI
+	if from <= 0 || to <= 0 {
+		from, to = 1, 1
+	}
+	control, format := m.Commands()
+
+	var b strings.Builder
+	newline := func() {
+		if b.Len() > 0 && b.String()[b.Len()-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+	first := true
+	for _, cmd := range cmds {
+		if mediaCommands[string(cmd.Prefix)+cmd.Name] {
+			continue
+		}
+		if cmd.Col == 1 {
+			newline()
+		}
+		if cmd.Prefix == '^' && cmd.Name == "XA" && first {
+			b.WriteString(control)
+			first = false
+		}
+
+		switch {
+		case cmd.Prefix == '^' && cmd.Name == "PW" && m.Width > 0:
+			cmd.Params = strconv.Itoa(m.Width)
+		case cmd.Prefix == '^' && cmd.Name == "LL" && m.Length > 0:
+			cmd.Params = strconv.Itoa(m.Length)
+		case cmd.Prefix == '^' && cmd.Name == "GF" && from != to:
+			if cmd, err = scaleGraphicCommand(cmd, from, to); err != nil {
+				return nil, err
+			}
+		case cmd.Prefix == '~' && cmd.Name == "DG" && from != to:
+			name, g, err := decodeDownload(cmd)
+			if err != nil {
+				return nil, err
+			}
+			bits, width, _ := ScaleBitmap(g.Bits, g.PerRow*8, len(g.Bits)/g.PerRow, from, to)
+			cmd.Params = strings.TrimPrefix(EncodeDownload(name, bits, (width+7)/8), "~DG")
+		case cmd.Prefix == '^' && from != to:
+			if cmd, err = scaleCommand(cmd, from, to); err != nil {
+				return nil, err
+			}
+		}
+		b.WriteString(cmd.String())
+
+		if cmd.Prefix == '^' && cmd.Name == "XA" {
+			newline()
+			b.WriteString(format)
+		}
+	}
+	newline()
+	return []byte(b.String()), nil
+}
+
+// scaleCommand scales the dot arguments of cmd from one density to another
+func scaleCommand(cmd Command, from, to int) (Command, error) {
+	indexes, ok := scaledArgs[cmd.Name]
+	if !ok {
+		return cmd, nil
+	}
+	args := cmd.Args()
+	for _, i := range indexes {
+		if i >= len(args) || args[i] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(args[i])
+		if err != nil {
+			return cmd, errorAt(cmd, "parameter %d: %q is not a number", i+1, args[i])
+		}
+		n = ScaleDots(n, from, to)
+		if min, ok := minArgs[cmd.Name][i]; ok && n < min {
+			n = min
+		}
+		if max, ok := maxArgs[cmd.Name][i]; ok && n > max {
+			n = max
+		}
+		args[i] = strconv.Itoa(n)
+	}
+	cmd.Params = strings.Join(args, ",")
+	return cmd, nil
+}
+
+// scaleGraphicCommand resamples the bitmap of a ^GFA command
+func scaleGraphicCommand(cmd Command, from, to int) (Command, error) {
+	parts := strings.SplitN(cmd.Params, ",", 5)
+	if len(parts) < 5 {
+		return cmd, errorAt(cmd, "expected 5 parameters, got %d", len(parts))
+	}
+	if format := strings.ToUpper(strings.TrimSpace(parts[0])); format != "A" && format != "" {
+		return cmd, errorAt(cmd, "only ASCII graphics can be scaled, not compression type %q", format)
+	}
+	total, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+	if err != nil || total <= 0 {
+		return cmd, errorAt(cmd, "invalid graphic field count %q", parts[2])
+	}
+	perRow, err := strconv.Atoi(strings.TrimSpace(parts[3]))
+	if err != nil || perRow <= 0 {
+		return cmd, errorAt(cmd, "invalid bytes per row %q", parts[3])
+	}
+	bits, err := decodeGraphicASCII(parts[4], perRow, total)
+	if err != nil {
+		return cmd, errorAt(cmd, "%v", err)
+	}
+	bits, width, _ := ScaleBitmap(bits, perRow*8, total/perRow, from, to)
+	cmd.Params = "A," + EncodeGraphic(bits, (width+7)/8)
+	return cmd, nil
+}
+
+// ScaleDots converts a length in dots from one print density to another
+func ScaleDots(n, from, to int) int {
+	if from == to || from <= 0 {
+		return n
+	}
+	return int(math.Round(float64(n) * float64(to) / float64(from)))
+}
+
+// ScaleBitmap resamples a 1 bit per pixel bitmap, packed into whole bytes
+// per row, from one print density to another by nearest neighbour. It returns
+// the new bitmap with its width and height.
+func ScaleBitmap(bits []byte, width, height, from, to int) ([]byte, int, int) {
+	if from == to || from <= 0 {
+		return bits, width, height
+	}
+	stride := (width + 7) / 8
+	w, h := ScaleDots(width, from, to), ScaleDots(height, from, to)
+	outStride := (w + 7) / 8
+	out := make([]byte, outStride*h)
+	for y := 0; y < h; y++ {
+		sy := y * from / to
+		for x := 0; x < w; x++ {
+			sx := x * from / to
+			i := sy*stride + sx/8
+			if i < len(bits) && bits[i]&(0x80>>uint(sx%8)) != 0 {
+				out[y*outStride+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+	return out, w, h
+}
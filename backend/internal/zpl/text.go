@@ -0,0 +1,146 @@
+package zpl
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// condensed narrows the Go Bold face towards the proportions of the printer's
+// scalable font 0 (CG Triumvirate Bold Condensed)
+const condensed = 0.8
+
+var (
+	fontOnce sync.Once
+	fontFace *opentype.Font
+	fontErr  error
+)
+
+// scalableFont returns the embedded face used for every ZPL font
+func scalableFont() (*opentype.Font, error) {
+	fontOnce.Do(func() {
+		fontFace, fontErr = opentype.Parse(gobold.TTF)
+	})
+	return fontFace, fontErr
+}
+
+// textMask renders s horizontally at the given ZPL character height and width.
+// It returns a 1-bit coverage mask and the baseline offset from the top of the mask.
+func textMask(s string, height, width int) (*image.Alpha, int, error) {
+	f, err := scalableFont()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Size the face so that ascent + descent spans the requested height
+	probe, err := opentype.NewFace(f, &opentype.FaceOptions{Size: 1000, DPI: 72})
+	if err != nil {
+		return nil, 0, err
+	}
+	m := probe.Metrics()
+	probe.Close()
+	size := float64(height) * 1000 / float64((m.Ascent + m.Descent).Ceil())
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer face.Close()
+
+	ascent := face.Metrics().Ascent.Ceil()
+	d := &font.Drawer{Src: image.Black, Face: face}
+	advance := d.MeasureString(s).Ceil()
+	if advance <= 0 {
+		return image.NewAlpha(image.Rect(0, 0, 0, height)), ascent, nil
+	}
+
+	src := image.NewAlpha(image.Rect(0, 0, advance, height))
+	d.Dst = src
+	d.Dot = fixed.P(0, ascent)
+	d.DrawString(s)
+
+	// Stretch horizontally for the requested character width
+	scale := float64(width) / float64(height) * condensed
+	w := int(float64(advance)*scale + 0.5)
+	if w < 1 {
+		w = 1
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, w, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < w; x++ {
+			sx := int(float64(x) / scale)
+			if sx < advance && src.AlphaAt(sx, y).A >= 0x80 {
+				mask.SetAlpha(x, y, color.Alpha{A: 0xff})
+			}
+		}
+	}
+	return mask, ascent, nil
+}
+
+// drawText renders a text field, rotating it for the font orientation.
+// For ^FT fields the origin is the start of the baseline; for ^FO it is the
+// top left corner of the rotated text block.
+func (r *renderer) drawText(cmd Command, s string, spec fontSpec) error {
+	if s == "" {
+		return nil
+	}
+	mask, ascent, err := textMask(s, spec.height, spec.width)
+	if err != nil {
+		return err
+	}
+
+	w, h := mask.Bounds().Dx(), mask.Bounds().Dy()
+	x, y := r.field.x, r.field.y
+	ft := r.field.typeset
+
+	// place maps a point (u along the text, v down from its top) onto the label
+	var place func(u, v int) (int, int)
+	switch spec.orientation {
+	case 'R': // rotated 90 degrees clockwise, reads top to bottom
+		if ft {
+			place = func(u, v int) (int, int) { return x + ascent - v, y + u }
+		} else {
+			place = func(u, v int) (int, int) { return x + h - 1 - v, y + u }
+		}
+	case 'I': // rotated 180 degrees
+		if ft {
+			place = func(u, v int) (int, int) { return x - u, y + ascent - v }
+		} else {
+			place = func(u, v int) (int, int) { return x + w - 1 - u, y + h - 1 - v }
+		}
+	case 'B': // rotated 270 degrees, reads bottom to top
+		if ft {
+			place = func(u, v int) (int, int) { return x - ascent + v, y - u }
+		} else {
+			place = func(u, v int) (int, int) { return x + v, y + w - 1 - u }
+		}
+	default:
+		if ft {
+			place = func(u, v int) (int, int) { return x + u, y - ascent + v }
+		} else {
+			place = func(u, v int) (int, int) { return x + u, y + v }
+		}
+	}
+
+	// Opposite corners of the text block give its extent whatever the rotation
+	if w > 0 {
+		x0, y0 := place(0, 0)
+		x1, y1 := place(w-1, h-1)
+		r.drawn("text", cmd, image.Rect(min(x0, x1), min(y0, y1), max(x0, x1)+1, max(y0, y1)+1))
+	}
+
+	for v := 0; v < h; v++ {
+		for u := 0; u < w; u++ {
+			if mask.AlphaAt(u, v).A != 0 {
+				px, py := place(u, v)
+				r.set(px, py, color.Gray{})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package zpl_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+
+	"labelops-backend/internal/qrcode"
+	"labelops-backend/internal/zpl"
+)
+
+func TestParse(t *testing.T) {
+	cmds, err := zpl.Parse([]byte("^XA\n^FO10,20^A0N,30,30^FDHEL\r\nLO^FS\n^A@N,20,20,E:ARIAL.TTF~JA^xz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		cmd       string
+		line, col int
+	}{
+		{"^XA", 1, 1},
+		{"^FO10,20", 2, 1},
+		{"^A0N,30,30", 2, 9},
+		{"^FDHELLO", 2, 19},
+		{"^FS", 3, 3},
+		{"^A@N,20,20,E:ARIAL.TTF", 4, 1},
+		{"~JA", 4, 23},
+		{"^XZ", 4, 26},
+	}
+	if len(cmds) != len(want) {
+		t.Fatalf("got %d commands %v, want %d", len(cmds), cmds, len(want))
+	}
+	for i, w := range want {
+		if got := cmds[i].String(); got != w.cmd || cmds[i].Line != w.line || cmds[i].Col != w.col {
+			t.Errorf("command %d = %s at %d:%d, want %s at %d:%d", i, got, cmds[i].Line, cmds[i].Col, w.cmd, w.line, w.col)
+		}
+	}
+	// ^A takes its one letter font name with the parameters
+	if args := cmds[2].Args(); strings.Join(args, "|") != "0N|30|30" || cmds[2].Name != "A" {
+		t.Errorf("^A0 parsed as name %q args %q", cmds[2].Name, args)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		zpl  string
+		want string
+	}{
+		{"dangling prefix", "^XA^FDA^FS^", "line 1 col 11: incomplete command"},
+		{"one letter command", "^XA^F", "line 1 col 4: incomplete command"},
+		{"unterminated format", "^XA^FDA^FS", "line 1 col 1: ^XA: format is not terminated"},
+		{"XZ without XA", "^XA^XZ\n^XZ", "line 2 col 1: ^XZ: ^XZ without ^XA"},
+		{"nested XA", "^XA\n^XA^XZ", "line 2 col 1: ^XA: format started before the one at line 1 col 1"},
+		{"no format", "~JA", "no ^XA...^XZ label format found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmds, err := zpl.Parse([]byte(tt.zpl))
+			if err == nil {
+				_, err = zpl.Formats(cmds)
+			}
+			var syntaxErr *zpl.SyntaxError
+			if !errors.As(err, &syntaxErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want a SyntaxError containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		zpl           string
+		dpi           int
+		width, length int
+		err           bool
+	}{
+		{"4x6 at 203 dpi by default", "^XA^XZ", 0, 812, 1218, false},
+		{"4x6 at 300 dpi", "^XA^XZ", 300, 1200, 1800, false},
+		{"set width and length", "^XA^PW400^LL300^XZ", 203, 400, 300, false},
+		{"set after fields", "^XA^FO0,0^FDA^FS^LL500^XZ", 203, 812, 500, false},
+		{"zero width", "^XA^PW0^XZ", 203, 0, 0, true},
+		{"too long", "^XA^LL32001^XZ", 203, 0, 0, true},
+		{"not a number", "^XA^PWwide^XZ", 203, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmds, err := zpl.Parse([]byte(tt.zpl))
+			if err != nil {
+				t.Fatal(err)
+			}
+			width, length, err := zpl.Size(cmds, tt.dpi)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if width != tt.width || length != tt.length {
+				t.Errorf("size = %dx%d, want %dx%d", width, length, tt.width, tt.length)
+			}
+		})
+	}
+}
+
+// render draws a label, collecting the elements drawn
+func render(t *testing.T, label string) (*image.Gray, []zpl.Element) {
+	t.Helper()
+	var elements []zpl.Element
+	img, err := zpl.Render([]byte(label), zpl.Options{OnDraw: func(e zpl.Element) { elements = append(elements, e) }})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	return img, elements
+}
+
+func TestFieldHex(t *testing.T) {
+	escaped, err := zpl.EscapeField("^A~B\\C é")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escaped != `\5EA\7EB\5CC \C3\A9` {
+		t.Errorf("EscapeField = %q", escaped)
+	}
+	if got := zpl.UnescapeField(escaped); got != "^A~B\\C é" {
+		t.Errorf("UnescapeField = %q", got)
+	}
+
+	// A field with ^FH prints its escapes decoded; an indicator not followed
+	// by two hex digits is printed as it is
+	tests := []struct{ hex, plain string }{
+		{`^FH\^FD\41\42C^FS`, `^FDABC^FS`},
+		{`^FH^FD_41_42C^FS`, `^FDABC^FS`},
+		{`^FH\^FDA\4^FS`, `^FDA\4^FS`},
+		{`^FD\41BC^FS`, `^FD\41BC^FS`},
+	}
+	for _, tt := range tests {
+		got, _ := render(t, "^XA^PW400^LL200^FO10,10^BCN,50,N"+tt.hex+"^XZ")
+		want, _ := render(t, "^XA^PW400^LL200^FO10,10^BCN,50,N"+tt.plain+"^XZ")
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%s does not print as %s", tt.hex, tt.plain)
+		}
+	}
+}
+
+func TestCode128Bounds(t *testing.T) {
+	// ABC is start B, three data symbols and the check symbol of 11 modules
+	// each, and the 13 module stop pattern: 68 modules
+	tests := []struct {
+		name string
+		zpl  string
+		want image.Rectangle
+	}{
+		{"normal", "^BY2^FO10,20^BCN,100,N^FDABC^FS", image.Rect(10, 20, 146, 120)},
+		{"wide modules", "^BY3^FO10,20^BCN,50,N^FDABC^FS", image.Rect(10, 20, 214, 70)},
+		{"rotated", "^BY2^FO10,20^BCR,100,N^FDABC^FS", image.Rect(10, 20, 110, 156)},
+		{"typeset from the bottom", "^BY2^FT10,220^BCN,100,N^FDABC^FS", image.Rect(10, 120, 146, 220)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, elements := render(t, "^XA^PW400^LL400"+tt.zpl+"^XZ")
+			if len(elements) != 1 || elements[0].Kind != "barcode" {
+				t.Fatalf("elements = %+v, want one barcode", elements)
+			}
+			if got := elements[0].Bounds; got != tt.want {
+				t.Errorf("bounds = %v, want %v", got, tt.want)
+			}
+			// The quiet zone is outside the bounds; the start bar is at the edge
+			b := tt.want
+			if img.GrayAt(b.Min.X-1, b.Max.Y-1).Y == 0 || img.GrayAt(b.Min.X, b.Max.Y-1).Y != 0 {
+				t.Errorf("bars do not start at the left edge of %v", b)
+			}
+		})
+	}
+
+	// The interpretation line widens and lengthens the block
+	_, elements := render(t, "^XA^PW400^LL400^BY2^FO10,20^BCN,100,Y^FDABC^FS^XZ")
+	if b := elements[0].Bounds; b.Dx() != 136 || b.Dy() <= 100 {
+		t.Errorf("bounds with interpretation line = %v", b)
+	}
+}
+
+func TestQRMatchesEncoder(t *testing.T) {
+	const data = "https://qr.example.com/l/C103247"
+	img, elements := render(t, "^XA^PW400^LL400^FO30,40^BQN,2,4^FDMA,"+data+"^FS^XZ")
+	code, err := qrcode.Encode([]byte(data), qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := code.Size * 4
+	if len(elements) != 1 || elements[0].Kind != "qr" || elements[0].Bounds != image.Rect(30, 40, 30+size, 40+size) {
+		t.Fatalf("elements = %+v, want one %d dot QR code at 30,40", elements, size)
+	}
+	// Every module is drawn four dots square where the encoder put it
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			for _, d := range [][2]int{{0, 0}, {3, 3}} {
+				dark := img.GrayAt(30+x*4+d[0], 40+y*4+d[1]).Y == 0
+				if dark != code.Dark(x, y) {
+					t.Fatalf("module %d,%d drawn dark=%v, encoded dark=%v", x, y, dark, code.Dark(x, y))
+				}
+			}
+		}
+	}
+}
+
+// checker is a 16x4 bitmap, 2 bytes to a row, of alternating 8 dot blocks
+var checker = []byte{0xFF, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0xFF}
+
+func TestGraphicFieldZ64(t *testing.T) {
+	args := zpl.EncodeGraphic(checker, 2)
+	if !strings.Contains(args, ",8,2,:Z64:") {
+		t.Fatalf("EncodeGraphic = %q, want 8 bytes of 2 to a row as Z64", args)
+	}
+	img, elements := render(t, "^XA^PW100^LL100^FO10,10^GFA,"+args+"^FS^XZ")
+	if len(elements) != 1 || elements[0].Bounds != image.Rect(10, 10, 26, 14) {
+		t.Fatalf("elements = %+v, want a 16x4 graphic at 10,10", elements)
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 16; x++ {
+			want := (x < 8) == (y%2 == 0)
+			if dark := img.GrayAt(10+x, 10+y).Y == 0; dark != want {
+				t.Fatalf("dot %d,%d dark=%v, want %v", x, y, dark, want)
+			}
+		}
+	}
+
+	// The same bitmap in hex with the ZPL run-length scheme: H is 2 repeats,
+	// a comma fills the rest of the row with zeros
+	hex, _ := render(t, "^XA^PW100^LL100^FO10,10^GFA,8,8,2,HF,00HF\nHF,00HF^FS^XZ")
+	if !bytes.Equal(hex.Pix, img.Pix) {
+		t.Error("compressed hex graphic differs from the Z64 one")
+	}
+}
+
+func TestDownloadGraphic(t *testing.T) {
+	download := zpl.EncodeDownload("logo", checker, 2)
+	cmds, err := zpl.Parse([]byte(download + "^XA^FO0,0^XGLOGO,2,1^FS^XZ"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphics, err := zpl.Downloads(cmds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, ok := graphics["R:LOGO.GRF"]
+	if !ok || g.PerRow != 2 || !bytes.Equal(g.Bits, checker) {
+		t.Fatalf("Downloads = %+v, want the checker as R:LOGO.GRF", graphics)
+	}
+
+	_, elements := render(t, download+"^XA^PW100^LL100^FO5,5^XGR:LOGO.GRF,2,3^FS^XZ")
+	if len(elements) != 1 || elements[0].Bounds != image.Rect(5, 5, 37, 17) {
+		t.Errorf("elements = %+v, want the graphic magnified 2x3 at 5,5", elements)
+	}
+}
+
+func TestDownloadGraphicErrors(t *testing.T) {
+	valid := zpl.EncodeDownload("LOGO", checker, 2)
+	badCRC := "0000"
+	if strings.HasSuffix(valid, badCRC) {
+		badCRC = "FFFF"
+	}
+	tests := []struct {
+		name string
+		zpl  string
+		want string
+	}{
+		{"bad CRC", valid[:len(valid)-4] + badCRC, "CRC"},
+		{"bad base64", "~DGR:LOGO.GRF,8,2,:Z64:!!!!", "invalid base64"},
+		{"not zlib", "~DGR:LOGO.GRF,8,2,:Z64:AAAA", "invalid Z64"},
+		{"missing data", "~DGR:LOGO.GRF,8,2", "expected 4 parameters"},
+		{"bad byte count", "~DGR:LOGO.GRF,none,2,FF", "invalid graphic byte count"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmds, err := zpl.Parse([]byte(tt.zpl))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = zpl.Downloads(cmds)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Downloads error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"labelops-backend/internal/assets"
+	"labelops-backend/internal/templates"
+	"labelops-backend/internal/zpl/lint"
+)
+
+// runLint implements the "lint" subcommand, which checks ZPL files or label
+// templates and prints one issue per line. It returns the process exit code.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	asTemplate := fs.Bool("template", false, "treat files as label templates and render them with sample data, a sample GS1 mapping and the built-in assets first")
+	dpi := fs.Int("dpi", 0, "printer density for labels that do not set ^PW/^LL (default 203)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: labelops-backend lint [-template] [-dpi n] file... (- reads stdin)")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	failed := false
+	for _, path := range fs.Args() {
+		var (
+			data []byte
+			err  error
+		)
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+
+		opts := lint.Options{DPI: *dpi}
+		var issues []lint.Issue
+		if *asTemplate {
+			name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			issues = templates.Lint(name, string(data), templates.Options{Assets: assets.Builtin}, opts)
+		} else {
+			issues = lint.Lint(data, opts)
+		}
+		for _, issue := range issues {
+			fmt.Printf("%s:%s\n", path, issue)
+		}
+		if len(issues) > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -1,11 +1,24 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"labelops-backend/controllers"
 	"labelops-backend/db"
+	"labelops-backend/internal/assets"
+	"labelops-backend/internal/dedupe"
+	"labelops-backend/internal/dispatcher"
+	"labelops-backend/internal/ingest"
+	"labelops-backend/internal/printer"
+	"labelops-backend/internal/qrsign"
+	"labelops-backend/internal/templates"
 	"labelops-backend/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -14,6 +27,11 @@ import (
 )
 
 func main() {
+	// Subcommands run without the database or HTTP server
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:]))
+	}
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
@@ -37,8 +55,9 @@ func main() {
 	// CORS middleware - More permissive for development
 	r.Use(cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Origin", "Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Origin", "Authorization", "Content-Type", middleware.IdempotencyHeader},
+		ExposedHeaders:   []string{"Content-Disposition", "X-ZPL-Source", middleware.ReplayedHeader},
 		AllowCredentials: true,
 		Debug:            true,
 	}))
@@ -57,6 +76,8 @@ func main() {
 		// Public routes
 		api.POST("/auth/login", controllers.Login)
 		api.POST("/auth/register", controllers.Register)
+		api.GET("/verify", controllers.VerifyQRPayload)
+		api.GET("/verify/public-key", controllers.GetQRPublicKey)
 
 		// Protected routes
 		protected := api.Group("/")
@@ -66,17 +87,34 @@ func main() {
 			protected.GET("/dashboard/stats", controllers.GetDashboardStats)
 
 			// Label routes
-			protected.POST("/labels/batch", controllers.BatchLabelProcess)
+			protected.POST("/labels/batch", middleware.Idempotency(), controllers.BatchLabelProcess)
 			protected.GET("/labels", controllers.GetLabels)
 			protected.GET("/labels/:id", controllers.GetLabelByID)
+			protected.PATCH("/labels/:id", controllers.AmendLabel)
+			protected.GET("/labels/:id/versions", controllers.GetLabelVersions)
+			protected.POST("/labels/:id/void", controllers.RequestLabelVoid)
+			protected.POST("/labels/:id/dispatch", controllers.DispatchLabel)
+			protected.GET("/labels/:id/events", controllers.GetLabelEvents)
+			protected.GET("/labels/:id/preview", controllers.GetLabelPreview)
 			protected.POST("/labels/print", controllers.PrintLabel)
 			protected.GET("/labels/export/csv", controllers.ExportLabelsCSV)
 
+			// Void requests (supervisor or admin)
+			protected.GET("/void-requests", middleware.SupervisorMiddleware(), controllers.GetVoidRequests)
+			protected.POST("/void-requests/:id/approve", middleware.SupervisorMiddleware(), controllers.ApproveVoidRequest)
+			protected.POST("/void-requests/:id/reject", middleware.SupervisorMiddleware(), controllers.RejectVoidRequest)
+
+			// Printer status
+			protected.GET("/printers/:id/status", controllers.GetPrinterStatus)
+
 			// Print job routes
 			protected.GET("/print-jobs", controllers.GetPrintJobs)
 			protected.GET("/print-jobs/:id", controllers.GetPrintJobByID)
+			protected.GET("/print-jobs/:id/verify", controllers.VerifyPrintJob)
 			protected.GET("/print-jobs/heatno/:heatno", controllers.GetPrintJobsByHeatNo)
 			protected.POST("/print-jobs/retry", controllers.RetryPrintJob)
+			protected.GET("/print-jobs/dead", middleware.AdminMiddleware(), controllers.GetDeadPrintJobs)
+			protected.POST("/print-jobs/dead/requeue", middleware.AdminMiddleware(), controllers.RequeueDeadPrintJobs)
 			protected.GET("/print-jobs/export/csv", controllers.ExportPrintJobsCSV)
 
 			// User routes
@@ -96,24 +134,144 @@ func main() {
 				admin.PUT("/users/:id", controllers.UpdateUser)
 				admin.DELETE("/users/:id", controllers.DeleteUser)
 				admin.GET("/stats", controllers.GetSystemStats)
+
+				// Printer registry
+				admin.GET("/printers", controllers.GetPrinters)
+				admin.POST("/printers", controllers.CreatePrinter)
+				admin.GET("/printers/:id", controllers.GetPrinterByID)
+				admin.PUT("/printers/:id", controllers.UpdatePrinter)
+				admin.DELETE("/printers/:id", controllers.DeletePrinter)
+
+				// Printer media profiles
+				admin.GET("/media-profiles", controllers.GetMediaProfiles)
+				admin.POST("/media-profiles", controllers.CreateMediaProfile)
+				admin.GET("/media-profiles/:id", controllers.GetMediaProfileByID)
+				admin.PUT("/media-profiles/:id", controllers.UpdateMediaProfile)
+				admin.DELETE("/media-profiles/:id", controllers.DeleteMediaProfile)
+
+				// Logo assets placed by templates
+				admin.GET("/assets", controllers.GetAssets)
+				admin.POST("/assets", controllers.CreateAsset)
+				admin.GET("/assets/:name", controllers.GetAssetVersions)
+				admin.GET("/assets/:name/versions/:version/preview", controllers.GetAssetPreview)
+				admin.GET("/assets/:name/versions/:version/zpl", controllers.GetAssetZPL)
+
+				// Label templates
+				admin.GET("/templates", controllers.GetTemplates)
+				admin.POST("/templates", controllers.CreateTemplate)
+				admin.POST("/templates/validate", controllers.ValidateTemplate)
+				admin.GET("/templates/:id", controllers.GetTemplateByID)
+				admin.PUT("/templates/:id", controllers.UpdateTemplate)
+				admin.DELETE("/templates/:id", controllers.DeleteTemplate)
+				admin.GET("/templates/:id/versions", controllers.GetTemplateVersions)
+				admin.POST("/templates/:id/versions", controllers.CreateTemplateVersion)
+				admin.GET("/templates/:id/versions/:version", controllers.GetTemplateVersion)
+				admin.PUT("/templates/:id/versions/:version", controllers.UpdateTemplateVersion)
+				admin.POST("/templates/:id/versions/:version/publish", controllers.PublishTemplateVersion)
+				admin.POST("/templates/:id/versions/:version/retire", controllers.RetireTemplateVersion)
 			}
 		}
+	}
 
-		// Get port from environment or use default
-		port := os.Getenv("PORT")
-		if port == "" {
-			port = "8080"
-		}
+	// Start the print queue dispatcher, holding jobs for printers that report errors
+	printDispatcher := dispatcher.New(dispatcher.ConfigFromEnv())
+
+	var statusPoller *printer.Poller
+	if interval := printerStatusInterval(); interval > 0 {
+		statusPoller = printer.NewPoller(interval)
+		statusPoller.Start()
+		controllers.SetPrinterPoller(statusPoller)
+		printDispatcher.HoldFaultedPrinters(statusPoller)
+	}
+	printDispatcher.Start()
+
+	// Get port from environment or use default
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
 
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+
+	go func() {
 		log.Printf("Starting LabelOps Backend on port %s", port)
-		if err := r.Run(":" + port); err != nil {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal("Failed to start server:", err)
 		}
+	}()
+
+	// Wait for an interrupt and shut down gracefully
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down LabelOps Backend...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+	if err := printDispatcher.Shutdown(ctx); err != nil {
+		log.Printf("Print dispatcher shutdown error: %v", err)
+	}
+	if statusPoller != nil {
+		statusPoller.Stop()
+	}
+	if err := db.CloseDB(); err != nil {
+		log.Printf("Database close error: %v", err)
 	}
 }
 
+// printerStatusInterval reads PRINTER_STATUS_INTERVAL; zero disables status polling
+func printerStatusInterval() time.Duration {
+	value := os.Getenv("PRINTER_STATUS_INTERVAL")
+	if value == "" {
+		return 30 * time.Second
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid PRINTER_STATUS_INTERVAL %q, status polling disabled", value)
+		return 0
+	}
+	return interval
+}
+
 func initialize() error {
+	// QR payload formats and signing are plant settings; invalid ones stop the server
+	if err := templates.SetPlantQR(templates.QRFormatFromEnv()); err != nil {
+		return fmt.Errorf("invalid QR payload format: %w", err)
+	}
+	signer, err := qrsign.FromEnv()
+	if err != nil {
+		return err
+	}
+	templates.SetSigner(signer)
+
+	// Batch validation settings
+	if err := ingest.SetHeatNoPattern(os.Getenv("HEAT_NO_PATTERN")); err != nil {
+		return fmt.Errorf("invalid HEAT_NO_PATTERN: %w", err)
+	}
+	if mode := os.Getenv("BATCH_MODE"); mode != "" && !ingest.ValidMode(mode) {
+		return fmt.Errorf("invalid BATCH_MODE %q: must be partial or strict", mode)
+	}
+	if _, err := dedupe.PolicyFromEnv(); err != nil {
+		return fmt.Errorf("invalid duplicate policy: %w", err)
+	}
+
 	// Initialize DB and run migrations/seeds
 	db.InitDB()
+
+	// Register the QCIN layout so print jobs can reference its version
+	if err := templates.EnsureBuiltin(); err != nil {
+		return fmt.Errorf("failed to register built-in template: %w", err)
+	}
+	// Offer the QCIN layout's images to other templates
+	if err := assets.EnsureBuiltin(); err != nil {
+		return fmt.Errorf("failed to register built-in assets: %w", err)
+	}
 	return nil
 }
@@ -101,3 +101,30 @@ func AdminMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SupervisorMiddleware ensures the user has the supervisor or admin role
+func SupervisorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			c.Abort()
+			return
+		}
+
+		userModel, ok := user.(models.User)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
+			c.Abort()
+			return
+		}
+
+		if !userModel.IsSupervisor() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Supervisor or admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
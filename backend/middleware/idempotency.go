@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"labelops-backend/db"
+	"labelops-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyHeader carries the client's key for a request it may retry
+const IdempotencyHeader = "Idempotency-Key"
+
+// ReplayedHeader is set on responses replayed for a retried key
+const ReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKey bounds the length of a key
+const maxIdempotencyKey = 255
+
+// IdempotencyWindow is how long a key's response is kept for replay, from
+// IDEMPOTENCY_TTL (default 24h)
+func IdempotencyWindow() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// IdempotencyClaimTimeout is how long a key stays claimed by a request that
+// has not finished, from IDEMPOTENCY_CLAIM_TIMEOUT (default 5m). A claim older
+// than that was left by a request that died, and the key can be used again.
+func IdempotencyClaimTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_CLAIM_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 5 * time.Minute
+}
+
+// recorder keeps a copy of the response body as it is written
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes a request safe to retry when the client sends an
+// Idempotency-Key header. The first request with a key runs and its response
+// is stored with a hash of the request; retries within the window get that
+// response again. A key reused with a different request, or while the first
+// request is still running, gets 409, unless the first request has held the
+// key past IdempotencyClaimTimeout without answering. Keys are scoped to the user, so this
+// must run after AuthMiddleware. Requests without the header are unaffected.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+		value, _ := c.Get("user")
+		user, ok := value.(models.User)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request", "details": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.RequestURI()+"\n"), body...))
+		hash := hex.EncodeToString(sum[:])
+
+		// Claim the key; expired keys are cleared first so they can be used again,
+		// and a claim abandoned without a response is taken over
+		if _, err := db.DB.Exec(`DELETE FROM idempotency_keys WHERE expires_at < NOW()`); err != nil {
+			log.Printf("Failed to clear expired idempotency keys: %v", err)
+		}
+		var claimedAt time.Time
+		err = db.DB.QueryRow(`
+			INSERT INTO idempotency_keys (user_id, key, request_hash, claimed_at, expires_at)
+			VALUES ($1, $2, $3, NOW(), NOW() + $4 * INTERVAL '1 second')
+			ON CONFLICT (user_id, key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, claimed_at = EXCLUDED.claimed_at, expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.status_code IS NULL
+			  AND idempotency_keys.claimed_at < NOW() - $5 * INTERVAL '1 second'
+			RETURNING claimed_at
+		`, user.ID, key, hash, int64(IdempotencyWindow()/time.Second),
+			int64(IdempotencyClaimTimeout()/time.Second)).Scan(&claimedAt)
+		if err == sql.ErrNoRows {
+			replay(c, user, key, hash)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record idempotency key", "details": err.Error()})
+			c.Abort()
+			return
+		}
+
+		// Server errors and panics leave nothing for a retry to replay; release the
+		// key, unless another request has taken over the claim in the meantime
+		stored := false
+		defer func() {
+			if stored {
+				return
+			}
+			if _, err := db.DB.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND claimed_at = $3`,
+				user.ID, key, claimedAt); err != nil {
+				log.Printf("Failed to release idempotency key %q: %v", key, err)
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		status := rec.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		_, err = db.DB.Exec(`
+			UPDATE idempotency_keys SET status_code = $4, content_type = $5, response = $6
+			WHERE user_id = $1 AND key = $2 AND claimed_at = $3
+		`, user.ID, key, claimedAt, status, rec.Header().Get("Content-Type"), rec.body.Bytes())
+		if err != nil {
+			log.Printf("Failed to store response for idempotency key %q: %v", key, err)
+			return
+		}
+		stored = true
+	}
+}
+
+// replay answers a request whose key is already claimed: with the stored
+// response when the request matches, otherwise with 409
+func replay(c *gin.Context, user models.User, key, hash string) {
+	var (
+		storedHash  string
+		status      sql.NullInt64
+		contentType sql.NullString
+		response    []byte
+	)
+	err := db.DB.QueryRow(`
+		SELECT request_hash, status_code, content_type, response FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`, user.ID, key).Scan(&storedHash, &status, &contentType, &response)
+	switch {
+	case err == sql.ErrNoRows:
+		// Released by a failed first request since the claim was attempted
+		c.JSON(http.StatusConflict, gin.H{"error": "The request with this Idempotency-Key failed; retry it"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up idempotency key", "details": err.Error()})
+	case storedHash != hash:
+		c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used with a different request"})
+	case !status.Valid:
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+	default:
+		c.Header(ReplayedHeader, "true")
+		c.Data(int(status.Int64), contentType.String, response)
+	}
+	c.Abort()
+}
@@ -0,0 +1,189 @@
+package middleware_test
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"labelops-backend/internal/dbtest"
+	"labelops-backend/middleware"
+	"labelops-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// storedKey is an idempotency_keys row of the fake database
+type storedKey struct {
+	hash      string
+	status    *int64
+	body      []byte
+	claimedAt time.Time
+}
+
+// keyStore answers the idempotency_keys statements of the middleware for one user
+type keyStore struct {
+	keys map[string]*storedKey
+}
+
+func newKeyStore(t *testing.T) *keyStore {
+	s := &keyStore{keys: map[string]*storedKey{}}
+	d := dbtest.Open(t)
+	d.On("DELETE FROM idempotency_keys WHERE expires_at < NOW()", func([]driver.Value) (dbtest.Result, error) {
+		return dbtest.Affected(0), nil
+	})
+	d.On("INSERT INTO idempotency_keys", func(args []driver.Value) (dbtest.Result, error) {
+		key, hash, timeout := args[1].(string), args[2].(string), time.Duration(args[4].(int64))*time.Second
+		k, ok := s.keys[key]
+		if ok && (k.status != nil || time.Since(k.claimedAt) <= timeout) {
+			return dbtest.Result{Columns: []string{"claimed_at"}}, nil
+		}
+		k = &storedKey{hash: hash, claimedAt: time.Now()}
+		s.keys[key] = k
+		return dbtest.Row([]string{"claimed_at"}, k.claimedAt), nil
+	})
+	d.On("SELECT request_hash, status_code, content_type, response FROM idempotency_keys", func(args []driver.Value) (dbtest.Result, error) {
+		res := dbtest.Result{Columns: []string{"request_hash", "status_code", "content_type", "response"}}
+		if k, ok := s.keys[args[1].(string)]; ok {
+			var status driver.Value
+			if k.status != nil {
+				status = *k.status
+			}
+			res.Rows = append(res.Rows, []driver.Value{k.hash, status, "application/json; charset=utf-8", k.body})
+		}
+		return res, nil
+	})
+	d.On("UPDATE idempotency_keys SET status_code", func(args []driver.Value) (dbtest.Result, error) {
+		if k, ok := s.keys[args[1].(string)]; ok && k.claimedAt.Equal(args[2].(time.Time)) {
+			status := args[3].(int64)
+			k.status, k.body = &status, args[5].([]byte)
+			return dbtest.Affected(1), nil
+		}
+		return dbtest.Affected(0), nil
+	})
+	d.On("DELETE FROM idempotency_keys WHERE user_id", func(args []driver.Value) (dbtest.Result, error) {
+		if k, ok := s.keys[args[1].(string)]; ok && k.claimedAt.Equal(args[2].(time.Time)) {
+			delete(s.keys, args[1].(string))
+		}
+		return dbtest.Affected(1), nil
+	})
+	return s
+}
+
+// batchServer runs Idempotency in front of a handler answering with status
+// and counting its calls
+func batchServer(status *int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	user := models.User{ID: uuid.New()}
+	r.POST("/labels/batch", func(c *gin.Context) { c.Set("user", user) }, middleware.Idempotency(), func(c *gin.Context) {
+		*calls++
+		c.JSON(*status, gin.H{"call": *calls})
+	})
+	return r
+}
+
+func post(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/labels/batch", strings.NewReader(body))
+	req.Header.Set(middleware.IdempotencyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	newKeyStore(t)
+	status, calls := http.StatusOK, 0
+	r := batchServer(&status, &calls)
+
+	first := post(r, "k1", `{"labels":[]}`)
+	again := post(r, "k1", `{"labels":[]}`)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if again.Code != first.Code || again.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", again.Code, again.Body, first.Code, first.Body)
+	}
+	if again.Header().Get(middleware.ReplayedHeader) != "true" || first.Header().Get(middleware.ReplayedHeader) != "" {
+		t.Errorf("%s header: first %q, replay %q", middleware.ReplayedHeader,
+			first.Header().Get(middleware.ReplayedHeader), again.Header().Get(middleware.ReplayedHeader))
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequest(t *testing.T) {
+	newKeyStore(t)
+	status, calls := http.StatusOK, 0
+	r := batchServer(&status, &calls)
+
+	post(r, "k1", `{"labels":[1]}`)
+	w := post(r, "k1", `{"labels":[2]}`)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "different request") {
+		t.Errorf("reused key = %d %s, want 409 for a different request", w.Code, w.Body)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		claimed time.Duration // how long ago the first request claimed the key
+		code    int
+		calls   int
+	}{
+		{"running", time.Second, http.StatusConflict, 0},
+		{"abandoned", 10 * time.Minute, http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IDEMPOTENCY_CLAIM_TIMEOUT", "5m")
+			s := newKeyStore(t)
+			status, calls := http.StatusOK, 0
+			r := batchServer(&status, &calls)
+
+			// The hash is of the request as sent; an abandoned claim is taken
+			// over whatever it was for
+			s.keys["k1"] = &storedKey{hash: "unknown", claimedAt: time.Now().Add(-tt.claimed)}
+			w := post(r, "k1", `{"labels":[]}`)
+			if w.Code != tt.code || calls != tt.calls {
+				t.Errorf("got %d after %d handler calls, want %d after %d", w.Code, calls, tt.code, tt.calls)
+			}
+		})
+	}
+
+	// A claim of the same request still running is reported as in progress
+	s := newKeyStore(t)
+	status, calls := http.StatusOK, 0
+	r := batchServer(&status, &calls)
+	post(r, "k1", `{"labels":[]}`)
+	s.keys["k1"].status = nil
+	w := post(r, "k1", `{"labels":[]}`)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "still being processed") {
+		t.Errorf("in progress key = %d %s, want 409 still being processed", w.Code, w.Body)
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterServerError(t *testing.T) {
+	s := newKeyStore(t)
+	status, calls := http.StatusInternalServerError, 0
+	r := batchServer(&status, &calls)
+
+	if w := post(r, "k1", `{"labels":[]}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first request = %d, want 500", w.Code)
+	}
+	if _, ok := s.keys["k1"]; ok {
+		t.Fatal("key still claimed after a server error")
+	}
+
+	status = http.StatusOK
+	if w := post(r, "k1", `{"labels":[]}`); w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry = %d after %d handler calls, want 200 after 2", w.Code, calls)
+	}
+	if w := post(r, "k1", `{"labels":[]}`); w.Header().Get(middleware.ReplayedHeader) != "true" || calls != 2 {
+		t.Errorf("second retry was not replayed")
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Asset dithering methods, applied when an upload is converted to 1 bit per pixel
+const (
+	AssetDitherFloydSteinberg = "floyd-steinberg" // error diffusion; best for photos and shading
+	AssetDitherOrdered        = "ordered"         // 4x4 Bayer pattern; regular texture
+	AssetDitherNone           = "none"            // plain threshold; best for line art
+)
+
+// LabelAsset is one version of a logo or mark that templates place by name.
+// Uploads are converted to a 1 bit per pixel bitmap when they are stored.
+type LabelAsset struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Version   int        `json:"version" db:"version"`
+	Format    string     `json:"format" db:"format"` // "png", "svg"
+	Width     int        `json:"width" db:"width"`   // dots
+	Height    int        `json:"height" db:"height"` // dots
+	Dither    string     `json:"dither" db:"dither"`
+	Threshold int        `json:"threshold" db:"threshold"`
+	Bits      []byte     `json:"-" db:"bits"` // packed rows, most significant bit first; 1 is black
+	CreatedBy *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
@@ -1,12 +1,14 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// LabelData represents the label data structure from the API (matches dummy_data.json exactly)
+// LabelData represents the label data structure from the API (matches dummy_data.json,
+// with aliases such as DATE1 mapped by internal/ingest)
 type LabelData struct {
 	LOCATION        *string `json:"LOCATION"`
 	BUNDLE_NO       string  `json:"BUNDLE_NO"`
@@ -19,6 +21,7 @@ type LabelData struct {
 	PRODUCT_HEADING string  `json:"PRODUCT_HEADING"`
 	ISI_BOTTOM      string  `json:"ISI_BOTTOM"`
 	ISI_TOP         string  `json:"ISI_TOP"`
+	CHARGE_DTM      string  `json:"CHARGE_DTM,omitempty"`
 	MILL            string  `json:"MILL"`
 	GRADE           string  `json:"GRADE"`
 	URL_APIKEY      string  `json:"URL_APIKEY"`
@@ -51,15 +54,105 @@ type Label struct {
 	Section        string    `json:"section" db:"section"`
 	Date           string    `json:"date" db:"date"`
 	UserID         uuid.UUID `json:"user_id" db:"user_id"`
-	Status         string    `json:"status" db:"status"` // "pending", "printed", "failed"
+	Status         string    `json:"status" db:"status"` // one of the LabelStatus constants
 	IsDuplicate    bool      `json:"is_duplicate" db:"is_duplicate"`
+	Version        int       `json:"version" db:"version"` // incremented by each amendment
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
 
-// LabelBatchRequest represents a batch of labels to be processed
+// LabelVersion is a label as it was at one version. Version 1 is the label as
+// first stored; later versions are amendments, with the reason given and the
+// values of the changed fields before and after.
+type LabelVersion struct {
+	ID        uuid.UUID     `json:"id" db:"id"`
+	LabelID   uuid.UUID     `json:"label_id" db:"label_id"`
+	Version   int           `json:"version" db:"version"`
+	Data      LabelData     `json:"data" db:"data"`
+	Reason    *string       `json:"reason" db:"reason"`
+	Changes   *LabelChanges `json:"changes" db:"changes"`
+	CreatedBy *uuid.UUID    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// LabelChanges holds the values of the fields an amendment changed, by field name
+type LabelChanges struct {
+	Before map[string]string `json:"before"`
+	After  map[string]string `json:"after"`
+}
+
+// Label statuses; internal/lifecycle holds the transitions between them
+const (
+	LabelStatusReceived   = "received"   // stored, never queued
+	LabelStatusQueued     = "queued"     // a print job is waiting
+	LabelStatusPrinted    = "printed"    // printed once
+	LabelStatusFailed     = "failed"     // its print jobs failed before it printed
+	LabelStatusReprinted  = "reprinted"  // printed again after the first time
+	LabelStatusVoided     = "voided"     // voided after approval; not printed again
+	LabelStatusDispatched = "dispatched" // left the plant with its bundle
+)
+
+// LabelEvent is a label moving from one status to another. The first event
+// of a label has no FromStatus. ActorID is nil for moves made by the dispatcher.
+type LabelEvent struct {
+	ID         int64      `json:"id" db:"id"`
+	LabelID    uuid.UUID  `json:"label_id" db:"label_id"`
+	FromStatus *string    `json:"from_status" db:"from_status"`
+	ToStatus   string     `json:"to_status" db:"to_status"`
+	ActorID    *uuid.UUID `json:"actor_id" db:"actor_id"`
+	PrintJobID *uuid.UUID `json:"print_job_id" db:"print_job_id"`
+	Note       *string    `json:"note" db:"note"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Void reason codes
+const (
+	VoidReasonMislabeled = "mislabeled"
+	VoidReasonDamaged    = "damaged"
+	VoidReasonDataError  = "data_error"
+	VoidReasonDuplicate  = "duplicate"
+	VoidReasonScrapped   = "scrapped"
+	VoidReasonOther      = "other" // requires a note
+)
+
+// ValidVoidReason reports whether code is a known void reason code
+func ValidVoidReason(code string) bool {
+	switch code {
+	case VoidReasonMislabeled, VoidReasonDamaged, VoidReasonDataError, VoidReasonDuplicate, VoidReasonScrapped, VoidReasonOther:
+		return true
+	}
+	return false
+}
+
+// Void request statuses
+const (
+	VoidStatusPending  = "pending"
+	VoidStatusApproved = "approved"
+	VoidStatusRejected = "rejected"
+)
+
+// LabelVoid is a request to void a label. Requests from supervisors and
+// admins are approved as they are made; others wait for one to decide.
+type LabelVoid struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	LabelID        uuid.UUID  `json:"label_id" db:"label_id"`
+	ReasonCode     string     `json:"reason_code" db:"reason_code"`
+	Note           *string    `json:"note" db:"note"`
+	PrintVoidLabel bool       `json:"print_void_label" db:"print_void_label"`
+	Status         string     `json:"status" db:"status"`
+	RequestedBy    uuid.UUID  `json:"requested_by" db:"requested_by"`
+	DecidedBy      *uuid.UUID `json:"decided_by" db:"decided_by"`
+	DecisionNote   *string    `json:"decision_note" db:"decision_note"`
+	VoidPrintJobID *uuid.UUID `json:"void_print_job_id" db:"void_print_job_id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	DecidedAt      *time.Time `json:"decided_at" db:"decided_at"`
+}
+
+// LabelBatchRequest represents a batch of labels to be processed. Rows are
+// decoded and validated one by one (internal/ingest) so a bad row does not
+// fail the others.
 type LabelBatchRequest struct {
-	Labels []LabelData `json:"labels" binding:"required"`
+	Labels []json.RawMessage `json:"labels" binding:"required"`
 }
 
 // LabelBatchResponse represents the response after processing a label batch
@@ -83,24 +176,35 @@ type LabelFilter struct {
 	Offset      int        `json:"offset"`
 }
 
-// LabelStats represents statistics about labels
+// LabelStats represents statistics about labels, derived from label_events.
+// ByStatus counts labels by their current status; PrintedLabels counts those
+// that have printed at least once, whatever happened to them since.
 type LabelStats struct {
-	TotalLabels     int            `json:"total_labels"`
-	PrintedLabels   int            `json:"printed_labels"`
-	PendingLabels   int            `json:"pending_labels"`
-	FailedLabels    int            `json:"failed_labels"`
-	DuplicateLabels int            `json:"duplicate_labels"`
-	ByGrade         map[string]int `json:"by_grade"`
-	BySection       map[string]int `json:"by_section"`
+	TotalLabels      int            `json:"total_labels"`
+	PrintedLabels    int            `json:"printed_labels"`
+	PendingLabels    int            `json:"pending_labels"` // received or queued
+	FailedLabels     int            `json:"failed_labels"`
+	ReprintedLabels  int            `json:"reprinted_labels"`
+	DispatchedLabels int            `json:"dispatched_labels"`
+	VoidedLabels     int            `json:"voided_labels"`
+	DuplicateLabels  int            `json:"duplicate_labels"`
+	ByStatus         map[string]int `json:"by_status"`
+	ByGrade          map[string]int `json:"by_grade"`
+	BySection        map[string]int `json:"by_section"`
 }
 
 type PrintJob struct {
-	ID            uuid.UUID `db:"id" json:"id"`
-	LabelID       uuid.UUID `db:"label_id" json:"label_id"`
-	HeatNo        string    `db:"heat_no" json:"heat_no"`
-	ActualLabelID uuid.UUID `db:"actual_label_id" json:"actual_label_id"`
-	Status        string    `db:"status" json:"status"`
-	RetryCount    int       `db:"retry_count" json:"retry_count"`
-	CreatedAt     time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+	ID            uuid.UUID  `db:"id" json:"id"`
+	LabelID       uuid.UUID  `db:"label_id" json:"label_id"`
+	HeatNo        string     `db:"heat_no" json:"heat_no"`
+	ActualLabelID uuid.UUID  `db:"actual_label_id" json:"actual_label_id"`
+	PrinterID     *uuid.UUID `db:"printer_id" json:"printer_id"`
+	Status        string     `db:"status" json:"status"`
+	RetryCount    int        `db:"retry_count" json:"retry_count"`
+	Copies        int        `db:"copies" json:"copies"`
+	Serialized    bool       `db:"serialized" json:"serialized"`
+	ReprintOf     *uuid.UUID `db:"reprint_of" json:"reprint_of"`
+	ReprintNo     int        `db:"reprint_no" json:"reprint_no"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
 }
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Printer drivers supported by the printer registry
+const (
+	PrinterDriverTCP    = "tcp"    // raw socket / JetDirect
+	PrinterDriverDevice = "device" // local device file such as /dev/usb/lp0
+)
+
+// Printer command languages labels can be rendered in
+const (
+	PrinterLanguageZPL  = "zpl"  // Zebra ZPL II
+	PrinterLanguageEPL2 = "epl2" // Eltron/Honeywell EPL2
+	PrinterLanguageTSPL = "tspl" // TSC TSPL/TSPL2
+)
+
+// Printer represents a registered label printer
+type Printer struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Name           string     `json:"name" db:"name"`
+	Driver         string     `json:"driver" db:"driver"`     // "tcp", "device"
+	Language       string     `json:"language" db:"language"` // "zpl", "epl2", "tspl"
+	Host           *string    `json:"host" db:"host"`
+	Port           int        `json:"port" db:"port"`
+	DevicePath     *string    `json:"device_path" db:"device_path"`
+	DPI            int        `json:"dpi" db:"dpi"`
+	LabelWidth     int        `json:"label_width" db:"label_width"`   // dots
+	LabelLength    int        `json:"label_length" db:"label_length"` // dots
+	Mill           *string    `json:"mill" db:"mill"`
+	Location       *string    `json:"location" db:"location"`
+	IsDefault      bool       `json:"is_default" db:"is_default"`
+	IsActive       bool       `json:"is_active" db:"is_active"`
+	MediaProfileID *uuid.UUID `json:"media_profile_id" db:"media_profile_id"` // stock loaded; labels are scaled to its density
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PrinterRequest represents a create/update printer request
+type PrinterRequest struct {
+	Name           string     `json:"name" binding:"required"`
+	Driver         string     `json:"driver" binding:"required,oneof=tcp device"`
+	Language       string     `json:"language" binding:"omitempty,oneof=zpl epl2 tspl"`
+	Host           *string    `json:"host"`
+	Port           int        `json:"port"`
+	DevicePath     *string    `json:"device_path"`
+	DPI            int        `json:"dpi"`
+	LabelWidth     int        `json:"label_width"`
+	LabelLength    int        `json:"label_length"`
+	Mill           *string    `json:"mill"`
+	Location       *string    `json:"location"`
+	IsDefault      bool       `json:"is_default"`
+	IsActive       *bool      `json:"is_active"`
+	MediaProfileID *uuid.UUID `json:"media_profile_id"` // nil prints labels at their design size
+}
+
+// Media types, i.e. how the printer finds the start of each label
+const (
+	MediaTypeGap        = "gap"        // die-cut labels separated by a gap
+	MediaTypeMark       = "mark"       // black mark on the back of the liner
+	MediaTypeContinuous = "continuous" // no separation; labels are cut to length
+)
+
+// MediaProfile describes label stock and how a printer prints on it
+type MediaProfile struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	WidthMM   float64   `json:"width_mm" db:"width_mm"`
+	LengthMM  float64   `json:"length_mm" db:"length_mm"`
+	DPI       int       `json:"dpi" db:"dpi"`
+	Darkness  *int      `json:"darkness" db:"darkness"`   // 0-30; nil keeps the printer's setting
+	Speed     *int      `json:"speed" db:"speed"`         // inches per second; nil keeps the printer's setting
+	TearOff   int       `json:"tear_off" db:"tear_off"`   // dots, -120 to 120
+	LabelTop  int       `json:"label_top" db:"label_top"` // dots, -120 to 120
+	MediaType string    `json:"media_type" db:"media_type"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MediaProfileRequest represents a create/update media profile request
+type MediaProfileRequest struct {
+	Name      string  `json:"name" binding:"required"`
+	WidthMM   float64 `json:"width_mm" binding:"required,gt=0,lte=300"`
+	LengthMM  float64 `json:"length_mm" binding:"required,gt=0,lte=1000"`
+	DPI       int     `json:"dpi" binding:"omitempty,oneof=152 203 300 600"`
+	Darkness  *int    `json:"darkness" binding:"omitempty,min=0,max=30"`
+	Speed     *int    `json:"speed" binding:"omitempty,min=1,max=14"`
+	TearOff   int     `json:"tear_off" binding:"min=-120,max=120"`
+	LabelTop  int     `json:"label_top" binding:"min=-120,max=120"`
+	MediaType string  `json:"media_type" binding:"omitempty,oneof=gap mark continuous"`
+}
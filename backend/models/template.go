@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Template version statuses. Only drafts may be edited; exactly one version
+// of a template is published and used for printing.
+const (
+	TemplateStatusDraft     = "draft"
+	TemplateStatusPublished = "published"
+	TemplateStatusRetired   = "retired"
+)
+
+// LabelTemplate is a ZPL layout rendered with text/template. Its body lives in
+// immutable LabelTemplateVersions.
+type LabelTemplate struct {
+	ID               uuid.UUID      `json:"id" db:"id"`
+	Name             string         `json:"name" db:"name"`
+	Description      *string        `json:"description" db:"description"`
+	IsDefault        bool           `json:"is_default" db:"is_default"`
+	IsActive         bool           `json:"is_active" db:"is_active"`
+	CreatedBy        *uuid.UUID     `json:"created_by" db:"created_by"`
+	PublishedVersion *int           `json:"published_version"`
+	LatestVersion    int            `json:"latest_version"`
+	Rules            []TemplateRule `json:"rules"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// LabelTemplateVersion is one revision of a template body. Null QR formats use the plant's.
+// AssetVersions pins the version of each asset the body places, taken when the version was saved.
+type LabelTemplateVersion struct {
+	ID            uuid.UUID      `json:"id" db:"id"`
+	TemplateID    uuid.UUID      `json:"template_id" db:"template_id"`
+	Version       int            `json:"version" db:"version"`
+	Body          string         `json:"body" db:"body"`
+	GS1AIs        []GS1AI        `json:"gs1_ais" db:"gs1_ais"`
+	AssetVersions map[string]int `json:"asset_versions" db:"asset_versions"`
+	QRURLFormat   *string        `json:"qr_url_format" db:"qr_url_format"`
+	QRDataFormat  *string        `json:"qr_data_format" db:"qr_data_format"`
+	Status        string         `json:"status" db:"status"` // "draft", "published", "retired"
+	CreatedBy     *uuid.UUID     `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	PublishedAt   *time.Time     `json:"published_at" db:"published_at"`
+	RetiredAt     *time.Time     `json:"retired_at" db:"retired_at"`
+}
+
+// GS1AI maps a label field onto a GS1 application identifier, e.g.
+// {"ai": "10", "field": "HeatNo"} encodes the heat number as the batch/lot
+type GS1AI struct {
+	AI    string `json:"ai"`
+	Field string `json:"field"`
+}
+
+// TemplateRule selects a template for labels matching every non-empty field.
+// Section matches by prefix, so "ANGLE" covers "ANGLE 65*65*6".
+type TemplateRule struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	TemplateID     uuid.UUID `json:"template_id" db:"template_id"`
+	ProductHeading *string   `json:"product_heading" db:"product_heading"`
+	Mill           *string   `json:"mill" db:"mill"`
+	Section        *string   `json:"section" db:"section"`
+	Priority       int       `json:"priority" db:"priority"`
+}
+
+// TemplateRuleRequest is one selection rule in a template request
+type TemplateRuleRequest struct {
+	ProductHeading *string `json:"product_heading"`
+	Mill           *string `json:"mill"`
+	Section        *string `json:"section"`
+	Priority       int     `json:"priority"`
+}
+
+// LabelTemplateRequest represents a create/update label template request.
+// Body, GS1AIs, the QR formats and Publish are only used on create, where they become version 1.
+type LabelTemplateRequest struct {
+	Name         string                `json:"name" binding:"required"`
+	Description  *string               `json:"description"`
+	Body         string                `json:"body"`
+	GS1AIs       []GS1AI               `json:"gs1_ais"`
+	QRURLFormat  *string               `json:"qr_url_format"`
+	QRDataFormat *string               `json:"qr_data_format"`
+	Publish      bool                  `json:"publish"`
+	IsDefault    bool                  `json:"is_default"`
+	IsActive     *bool                 `json:"is_active"`
+	Rules        []TemplateRuleRequest `json:"rules"`
+}
+
+// LabelTemplateVersionRequest creates or edits a draft template version.
+// Omitting GS1AIs or a QR format keeps the latest version's; an empty QR
+// format reverts to the plant's.
+type LabelTemplateVersionRequest struct {
+	Body         string  `json:"body" binding:"required"`
+	GS1AIs       []GS1AI `json:"gs1_ais"`
+	QRURLFormat  *string `json:"qr_url_format"`
+	QRDataFormat *string `json:"qr_data_format"`
+}
+
+// TemplateValidateRequest asks for a template body or raw ZPL to be linted
+type TemplateValidateRequest struct {
+	Name         string  `json:"name"`
+	Body         string  `json:"body"`
+	GS1AIs       []GS1AI `json:"gs1_ais"`
+	QRURLFormat  *string `json:"qr_url_format"`
+	QRDataFormat *string `json:"qr_data_format"`
+	ZPL          string  `json:"zpl"`
+	DPI          int     `json:"dpi"`
+}
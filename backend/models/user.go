@@ -12,13 +12,18 @@ type User struct {
 	PasswordHash string     `json:"-" db:"password_hash" binding:"required"`
 	FirstName    string     `json:"first_name" db:"first_name"`
 	LastName     string     `json:"last_name" db:"last_name"`
-	Role         string     `json:"role" db:"role"` // "admin", "user", "operator"
+	Role         string     `json:"role" db:"role"` // "admin", "supervisor", "user", "operator"
 	IsActive     bool       `json:"is_active" db:"is_active"`
 	LastLogin    *time.Time `json:"last_login" db:"last_login"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// IsSupervisor reports whether the user may approve label voids: supervisors and admins
+func (u User) IsSupervisor() bool {
+	return u.Role == "supervisor" || u.Role == "admin"
+}
+
 // LoginRequest represents a login request
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -44,7 +44,7 @@ export interface Label {
   section: string;
   date: string;
   user_id: string;
-  status: 'pending' | 'printed' | 'failed';
+  status: 'received' | 'queued' | 'printed' | 'failed' | 'reprinted' | 'voided' | 'dispatched';
   is_duplicate: boolean;
   created_at: string;
   updated_at: string;
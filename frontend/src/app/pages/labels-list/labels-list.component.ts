@@ -142,14 +142,17 @@ export class LabelsListComponent implements OnInit {
 
   getStatusClass(status: string): string {
     switch (status) {
-      case "success":
+      case "printed":
+      case "reprinted":
         return "bg-green-100 text-green-800";
       case "failed":
         return "bg-red-100 text-red-800";
-      case "pending":
+      case "received":
         return "bg-yellow-100 text-yellow-800";
-      case "processing":
+      case "queued":
         return "bg-blue-100 text-blue-800";
+      case "dispatched":
+        return "bg-purple-100 text-purple-800";
       default:
         return "bg-gray-100 text-gray-800";
     }